@@ -0,0 +1,592 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"httpdsl/core"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// message is a JSON-RPC 2.0 request, response, or notification
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *responseError   `json:"error,omitempty"`
+}
+
+// responseError is a JSON-RPC error object
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// position is a zero-based line/character location in a document. As LSP
+// requires, characters are UTF-16 code units, not bytes.
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// lspRange is a span between two positions
+type lspRange struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+
+// location points at a span inside a document
+type location struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+// diagnostic is a problem reported to the editor
+type diagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+// completionItem is a single completion proposal
+type completionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// textDocumentPositionParams is shared by hover, completion, and definition requests
+type textDocumentPositionParams struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position position `json:"position"`
+}
+
+const (
+	severityError      = 1
+	completionFunction = 3
+	completionVariable = 6
+	completionKeyword  = 14
+)
+
+// variableDefinition matches the statements that introduce a variable: set,
+// var and default, the for and foreach loops, and extract, load json|yaml and
+// dns lookup ... as $x
+var variableDefinition = regexp.MustCompile(`(?i)^\s*(?:(?:set|var|default)\s+\$([a-zA-Z_][a-zA-Z0-9_]*)|for(?:each)?\s+\$([a-zA-Z_][a-zA-Z0-9_]*)\s+in\b|(?:extract|load|dns)\s.*\bas\s+\$([a-zA-Z_][a-zA-Z0-9_]*))`)
+
+// templateDefinition matches the first line of a request template and
+// templateUse the invoke statements that send one
+var (
+	templateDefinition = regexp.MustCompile(`^\s*request\s+"([^"]*)"`)
+	templateUse        = regexp.MustCompile(`\binvoke\s+"([^"]*)"`)
+)
+
+// Server implements a Language Server Protocol server for .http DSL files
+type Server struct {
+	dsl       *core.HTTPDSLv3
+	documents map[string]string
+	reader    *bufio.Reader
+	writer    io.Writer
+	log       io.Writer // Where dropped notifications are reported
+	shutdown  bool
+}
+
+// NewServer creates a language server reading from r and writing to w
+func NewServer(r io.Reader, w io.Writer) *Server {
+	return &Server{
+		dsl:       core.NewHTTPDSLv3(),
+		documents: make(map[string]string),
+		reader:    bufio.NewReader(r),
+		writer:    w,
+		log:       os.Stderr,
+	}
+}
+
+// Run processes messages until the client sends exit or closes the stream
+func (s *Server) Run() error {
+	for {
+		msg, err := s.readMessage()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return fmt.Errorf("exit received before shutdown")
+			}
+			return nil
+		}
+
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// readMessage reads one Content-Length framed JSON-RPC message
+func (s *Server) readMessage() (*message, error) {
+	length := -1
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(strings.ToLower(line), "content-length:") {
+			length, err = strconv.Atoi(strings.TrimSpace(line[len("content-length:"):]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid Content-Length: %w", err)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.reader, body); err != nil {
+		return nil, err
+	}
+
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+	return &msg, nil
+}
+
+// writeMessage sends a framed JSON-RPC message to the client
+func (s *Server) writeMessage(msg *message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.writer, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+// reply answers a request; notifications (no id) are never answered
+func (s *Server) reply(req *message, result interface{}) error {
+	if req.ID == nil {
+		return nil
+	}
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+	return s.writeMessage(&message{ID: req.ID, Result: result})
+}
+
+// invalidParams answers a request whose params cannot be decoded with an
+// InvalidParams error; a notification cannot be answered, so it is reported
+// and dropped. Either way the server keeps serving.
+func (s *Server) invalidParams(msg *message, err error) error {
+	if msg.ID == nil {
+		fmt.Fprintf(s.log, "httpdsl-lsp: dropped %s: invalid params: %v\n", msg.Method, err)
+		return nil
+	}
+	return s.writeMessage(&message{
+		ID:    msg.ID,
+		Error: &responseError{Code: -32602, Message: "invalid params: " + err.Error()},
+	})
+}
+
+// handle dispatches a single message by method name
+func (s *Server) handle(msg *message) error {
+	switch msg.Method {
+	case "initialize":
+		return s.reply(msg, map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":   1, // Full document sync
+				"hoverProvider":      true,
+				"definitionProvider": true,
+				"completionProvider": map[string]interface{}{
+					"triggerCharacters": []string{"$"},
+				},
+			},
+			"serverInfo": map[string]string{"name": "httpdsl-lsp"},
+		})
+
+	case "shutdown":
+		s.shutdown = true
+		return s.reply(msg, nil)
+
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return s.invalidParams(msg, err)
+		}
+		s.documents[params.TextDocument.URI] = params.TextDocument.Text
+		return s.publishDiagnostics(params.TextDocument.URI)
+
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return s.invalidParams(msg, err)
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.documents[params.TextDocument.URI] = params.ContentChanges[n-1].Text
+		}
+		return s.publishDiagnostics(params.TextDocument.URI)
+
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return s.invalidParams(msg, err)
+		}
+		delete(s.documents, params.TextDocument.URI)
+		return s.writeMessage(&message{
+			Method: "textDocument/publishDiagnostics",
+			Params: mustMarshal(map[string]interface{}{
+				"uri":         params.TextDocument.URI,
+				"diagnostics": []diagnostic{},
+			}),
+		})
+
+	case "textDocument/completion":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return s.invalidParams(msg, err)
+		}
+		return s.reply(msg, s.completion(params))
+
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return s.invalidParams(msg, err)
+		}
+		return s.reply(msg, s.hover(params))
+
+	case "textDocument/definition":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return s.invalidParams(msg, err)
+		}
+		return s.reply(msg, s.definition(params))
+	}
+
+	// Unknown requests get a MethodNotFound error; unknown notifications are ignored
+	if msg.ID != nil {
+		return s.writeMessage(&message{
+			ID:    msg.ID,
+			Error: &responseError{Code: -32601, Message: "method not found: " + msg.Method},
+		})
+	}
+	return nil
+}
+
+// publishDiagnostics validates a document and sends the problems to the editor
+func (s *Server) publishDiagnostics(uri string) error {
+	text := s.documents[uri]
+	lines := strings.Split(text, "\n")
+
+	diagnostics := []diagnostic{}
+	for _, problem := range s.dsl.Validate(text) {
+		line := problem.Line - 1
+		start := problem.Column - 1
+		end := start + 1
+		if line >= 0 && line < len(lines) {
+			end = utf16Column(lines[line], tokenEnd(lines[line], start))
+			start = utf16Column(lines[line], start)
+		}
+		diagnostics = append(diagnostics, diagnostic{
+			Range: lspRange{
+				Start: position{Line: line, Character: start},
+				End:   position{Line: line, Character: end},
+			},
+			Severity: severityError,
+			Source:   "httpdsl",
+			Message:  problem.Message,
+		})
+	}
+
+	return s.writeMessage(&message{
+		Method: "textDocument/publishDiagnostics",
+		Params: mustMarshal(map[string]interface{}{
+			"uri":         uri,
+			"diagnostics": diagnostics,
+		}),
+	})
+}
+
+// completion proposes every keyword, the functions of expressions and the
+// variables defined in the document
+func (s *Server) completion(params textDocumentPositionParams) []completionItem {
+	functions := make(map[string]bool)
+	for _, name := range s.dsl.Functions() {
+		functions[name] = true
+	}
+
+	var items []completionItem
+	for _, kw := range s.dsl.Keywords() {
+		item := completionItem{Label: kw, Kind: completionKeyword}
+		if functions[kw] {
+			item.Kind = completionFunction
+			item.Detail = "function"
+		} else if doc, ok := s.dsl.DescribeCommand(kw); ok {
+			item.Detail = doc.Summary
+		}
+		items = append(items, item)
+	}
+
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(s.documents[params.TextDocument.URI], "\n") {
+		if name, _ := definedVariable(line); name != "" && !seen[name] {
+			seen[name] = true
+			items = append(items, completionItem{Label: "$" + name, Kind: completionVariable})
+		}
+	}
+	return items
+}
+
+// hover shows command documentation for keywords and the defining line for variables
+func (s *Server) hover(params textDocumentPositionParams) interface{} {
+	word := s.wordAt(params.TextDocument.URI, params.Position)
+	if word == "" {
+		return nil
+	}
+
+	var contents string
+	if name, ok := s.templateAt(params.TextDocument.URI, params.Position); ok {
+		loc := s.findTemplate(params.TextDocument.URI, name)
+		if loc == nil {
+			return nil
+		}
+		lines := strings.Split(s.documents[params.TextDocument.URI], "\n")
+		contents = fmt.Sprintf("```\n%s\n```\nRequest template defined on line %d", strings.TrimSpace(lines[loc.Range.Start.Line]), loc.Range.Start.Line+1)
+	} else if strings.HasPrefix(word, "$") {
+		loc := s.findDefinition(params.TextDocument.URI, strings.TrimPrefix(word, "$"))
+		if loc == nil {
+			return nil
+		}
+		lines := strings.Split(s.documents[params.TextDocument.URI], "\n")
+		contents = fmt.Sprintf("```\n%s\n```\nDefined on line %d", strings.TrimSpace(lines[loc.Range.Start.Line]), loc.Range.Start.Line+1)
+	} else {
+		doc, ok := s.dsl.DescribeCommand(word)
+		if !ok {
+			return nil
+		}
+		contents = fmt.Sprintf("**%s** — %s", doc.Keyword, doc.Summary)
+		if len(doc.Syntax) > 0 {
+			contents += "\n```\n" + strings.Join(doc.Syntax, "\n") + "\n```"
+		}
+	}
+
+	return map[string]interface{}{
+		"contents": map[string]string{"kind": "markdown", "value": contents},
+	}
+}
+
+// definition resolves a $variable to the statement that first assigns it,
+// and the name of an invoked request template to the template
+func (s *Server) definition(params textDocumentPositionParams) interface{} {
+	if name, ok := s.templateAt(params.TextDocument.URI, params.Position); ok {
+		if loc := s.findTemplate(params.TextDocument.URI, name); loc != nil {
+			return loc
+		}
+		return nil
+	}
+	word := s.wordAt(params.TextDocument.URI, params.Position)
+	if !strings.HasPrefix(word, "$") {
+		return nil
+	}
+	if loc := s.findDefinition(params.TextDocument.URI, strings.TrimPrefix(word, "$")); loc != nil {
+		return loc
+	}
+	return nil
+}
+
+// findDefinition returns the location of the first statement defining name
+func (s *Server) findDefinition(uri, name string) *location {
+	for i, line := range strings.Split(s.documents[uri], "\n") {
+		defined, col := definedVariable(line)
+		if defined != name {
+			continue
+		}
+		return &location{
+			URI: uri,
+			Range: lspRange{
+				Start: position{Line: i, Character: utf16Column(line, col)},
+				End:   position{Line: i, Character: utf16Column(line, col+len(name)+1)},
+			},
+		}
+	}
+	return nil
+}
+
+// findTemplate returns the location of the name of the request template
+// called name
+func (s *Server) findTemplate(uri, name string) *location {
+	for i, line := range strings.Split(s.documents[uri], "\n") {
+		match := templateDefinition.FindStringSubmatchIndex(line)
+		if match == nil || line[match[2]:match[3]] != name {
+			continue
+		}
+		return &location{
+			URI: uri,
+			Range: lspRange{
+				Start: position{Line: i, Character: utf16Column(line, match[2])},
+				End:   position{Line: i, Character: utf16Column(line, match[3])},
+			},
+		}
+	}
+	return nil
+}
+
+// templateAt returns the template name of the invoke statement under the
+// cursor, when it is on the keyword or the name
+func (s *Server) templateAt(uri string, pos position) (string, bool) {
+	lines := strings.Split(s.documents[uri], "\n")
+	if pos.Line < 0 || pos.Line >= len(lines) {
+		return "", false
+	}
+	line := lines[pos.Line]
+	offset := byteOffset(line, pos.Character)
+	for _, match := range templateUse.FindAllStringSubmatchIndex(line, -1) {
+		if offset >= match[0] && offset <= match[1] {
+			return line[match[2]:match[3]], true
+		}
+	}
+	return "", false
+}
+
+// wordAt returns the keyword or $variable under the cursor
+func (s *Server) wordAt(uri string, pos position) string {
+	lines := strings.Split(s.documents[uri], "\n")
+	if pos.Line < 0 || pos.Line >= len(lines) {
+		return ""
+	}
+	line := lines[pos.Line]
+	offset := byteOffset(line, pos.Character)
+	if offset < 0 {
+		return ""
+	}
+
+	isWordChar := func(c byte) bool {
+		return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+	}
+	start, end := offset, offset
+	for start > 0 && isWordChar(line[start-1]) {
+		start--
+	}
+	for end < len(line) && isWordChar(line[end]) {
+		end++
+	}
+	return line[start:end]
+}
+
+// definedVariable returns the variable name a line defines and the byte
+// offset of its $, or an empty string
+func definedVariable(line string) (string, int) {
+	match := variableDefinition.FindStringSubmatchIndex(line)
+	if match == nil {
+		return "", -1
+	}
+	for i := 2; i < len(match); i += 2 {
+		if match[i] >= 0 {
+			return line[match[i]:match[i+1]], match[i] - 1
+		}
+	}
+	return "", -1
+}
+
+// tokenEnd finds where the word starting at start ends, for diagnostic ranges
+func tokenEnd(line string, start int) int {
+	if start >= len(line) {
+		return start + 1
+	}
+	end := start
+	for end < len(line) && line[end] != ' ' && line[end] != '\t' {
+		end++
+	}
+	if end == start {
+		end++
+	}
+	return end
+}
+
+// utf16Column converts a byte offset in a line to the UTF-16 code units
+// before it, the unit of LSP positions
+func utf16Column(line string, offset int) int {
+	if offset > len(line) {
+		return len(utf16.Encode([]rune(line))) + offset - len(line)
+	}
+	return len(utf16.Encode([]rune(line[:offset])))
+}
+
+// byteOffset converts a UTF-16 character position of a line to a byte
+// offset, or -1 when it is past the end of the line
+func byteOffset(line string, character int) int {
+	units := 0
+	for offset, r := range line {
+		if units >= character {
+			return offset
+		}
+		units += utf16.RuneLen(r)
+	}
+	if units == character {
+		return len(line)
+	}
+	return -1
+}
+
+// mustMarshal encodes notification parameters that are known to be serializable
+func mustMarshal(v interface{}) json.RawMessage {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+func main() {
+	if len(os.Args) > 1 && (os.Args[1] == "-h" || os.Args[1] == "--help") {
+		fmt.Println("httpdsl-lsp - Language Server for HTTP DSL (.http) files")
+		fmt.Println()
+		fmt.Println("Usage: httpdsl-lsp")
+		fmt.Println()
+		fmt.Println("The server speaks the Language Server Protocol over stdin/stdout.")
+		fmt.Println("Configure your editor to start it for *.http files.")
+		fmt.Println()
+		fmt.Println("Features:")
+		fmt.Println("  ✅ Diagnostics (syntax and block structure, nothing is executed)")
+		fmt.Println("  ✅ Keyword, function and variable completion")
+		fmt.Println("  ✅ Hover documentation for commands")
+		fmt.Println("  ✅ Go to definition for variables and request templates")
+		return
+	}
+
+	server := NewServer(os.Stdin, os.Stdout)
+	if err := server.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "httpdsl-lsp: %v\n", err)
+		os.Exit(1)
+	}
+}
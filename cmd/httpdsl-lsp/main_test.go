@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// session runs the server over the given messages and returns what it sent
+func session(t *testing.T, messages ...map[string]interface{}) []map[string]interface{} {
+	t.Helper()
+	replies, _ := sessionWithLog(t, messages...)
+	return replies
+}
+
+// sessionWithLog is session that also returns what the server logged
+func sessionWithLog(t *testing.T, messages ...map[string]interface{}) ([]map[string]interface{}, string) {
+	t.Helper()
+	var input bytes.Buffer
+	for _, msg := range messages {
+		msg["jsonrpc"] = "2.0"
+		body, _ := json.Marshal(msg)
+		fmt.Fprintf(&input, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	var output, log bytes.Buffer
+	server := NewServer(&input, &output)
+	server.log = &log
+	if err := server.Run(); err != nil {
+		t.Fatalf("server failed: %v", err)
+	}
+
+	var replies []map[string]interface{}
+	reader := NewServer(&output, nil)
+	for {
+		msg, err := reader.readMessage()
+		if err != nil {
+			break
+		}
+		var reply map[string]interface{}
+		raw, _ := json.Marshal(msg)
+		json.Unmarshal(raw, &reply)
+		replies = append(replies, reply)
+	}
+	return replies, log.String()
+}
+
+func didOpen(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"method": "textDocument/didOpen",
+		"params": map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": "file:///test.http", "text": text},
+		},
+	}
+}
+
+func positionRequest(id int, method string, line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"id":     id,
+		"method": method,
+		"params": map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": "file:///test.http"},
+			"position":     map[string]interface{}{"line": line, "character": character},
+		},
+	}
+}
+
+// resultRange returns the start and end characters and the line of a
+// location result
+func resultRange(t *testing.T, reply map[string]interface{}) (int, int, int) {
+	t.Helper()
+	result, ok := reply["result"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected a location, got %v", reply)
+	}
+	span := result["range"].(map[string]interface{})
+	start := span["start"].(map[string]interface{})
+	end := span["end"].(map[string]interface{})
+	return int(start["line"].(float64)), int(start["character"].(float64)), int(end["character"].(float64))
+}
+
+func TestDiagnostics(t *testing.T) {
+	replies := session(t, didOpen("print \"héllo 🌍\"\nprint \"ünïcode\" nonsense"))
+	if len(replies) != 1 || replies[0]["method"] != "textDocument/publishDiagnostics" {
+		t.Fatalf("Expected one diagnostics notification, got %v", replies)
+	}
+	diagnostics := replies[0]["params"].(map[string]interface{})["diagnostics"].([]interface{})
+	if len(diagnostics) != 1 {
+		t.Fatalf("Expected one diagnostic, got %v", diagnostics)
+	}
+	span := diagnostics[0].(map[string]interface{})["range"].(map[string]interface{})
+	start := span["start"].(map[string]interface{})
+	end := span["end"].(map[string]interface{})
+	// "nonsense" starts after 16 UTF-16 units, though ü and ï take 2 bytes each
+	if start["line"] != 1.0 || start["character"] != 16.0 || end["character"] != 24.0 {
+		t.Errorf("Unexpected diagnostic range: %v", span)
+	}
+
+	replies = session(t, didOpen("GET \"https://example.com\"\nassert status 200"))
+	if diagnostics := replies[0]["params"].(map[string]interface{})["diagnostics"].([]interface{}); len(diagnostics) != 0 {
+		t.Errorf("Expected no diagnostics for a valid script, got %v", diagnostics)
+	}
+}
+
+func TestHover(t *testing.T) {
+	text := "set $name \"Zoë 🌍\" \nprint \"🌍 $name\""
+	replies := session(t,
+		didOpen(text),
+		positionRequest(1, "textDocument/hover", 0, 1),
+		// $name on line 1 starts after 10 UTF-16 units, as 🌍 takes 2 of them (and 4 bytes)
+		positionRequest(2, "textDocument/hover", 1, 11),
+	)
+	if len(replies) != 3 {
+		t.Fatalf("Expected diagnostics and two hovers, got %v", replies)
+	}
+	keyword := replies[1]["result"].(map[string]interface{})["contents"].(map[string]interface{})["value"].(string)
+	if !strings.HasPrefix(keyword, "**set**") {
+		t.Errorf("Expected the documentation of set, got %q", keyword)
+	}
+	variable := replies[2]["result"].(map[string]interface{})["contents"].(map[string]interface{})["value"].(string)
+	if !strings.Contains(variable, "Defined on line 1") {
+		t.Errorf("Expected where $name is defined, got %q", variable)
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	text := strings.Join([]string{
+		`print "🌍"`,
+		`request "crear café"`,
+		`    POST "$base/orders"`,
+		`end`,
+		`set $name "ñ"`,
+		`invoke "crear café" with $base "y"`,
+		`print "🌍 $name"`,
+	}, "\n")
+	replies := session(t,
+		didOpen(text),
+		positionRequest(1, "textDocument/definition", 5, 12),
+		positionRequest(2, "textDocument/definition", 6, 11),
+		positionRequest(3, "textDocument/definition", 0, 2),
+	)
+	if len(replies) != 4 {
+		t.Fatalf("Expected diagnostics and three definitions, got %v", replies)
+	}
+
+	// The template name runs from after `request "` to before the quote,
+	// 10 characters of which é is one UTF-16 unit and two bytes
+	if line, start, end := resultRange(t, replies[1]); line != 1 || start != 9 || end != 19 {
+		t.Errorf("Unexpected template definition: line %d, %d to %d", line, start, end)
+	}
+	if line, start, end := resultRange(t, replies[2]); line != 4 || start != 4 || end != 9 {
+		t.Errorf("Unexpected variable definition: line %d, %d to %d", line, start, end)
+	}
+	if replies[3]["result"] != nil {
+		t.Errorf("Expected no definition for a keyword, got %v", replies[3]["result"])
+	}
+}
+
+func TestByteOffset(t *testing.T) {
+	line := "a🌍é$x"
+	tests := []struct {
+		character, offset int
+	}{
+		{0, 0},
+		{1, 1},
+		{3, 5},
+		{4, 7},
+		{6, 9},
+		{7, -1},
+	}
+	for _, tt := range tests {
+		if got := byteOffset(line, tt.character); got != tt.offset {
+			t.Errorf("byteOffset(%d) = %d, want %d", tt.character, got, tt.offset)
+		}
+		if tt.offset >= 0 {
+			if got := utf16Column(line, tt.offset); got != tt.character {
+				t.Errorf("utf16Column(%d) = %d, want %d", tt.offset, got, tt.character)
+			}
+		}
+	}
+}
+
+func TestInvalidParams(t *testing.T) {
+	badHover := positionRequest(1, "textDocument/hover", 0, 0)
+	badHover["params"] = "not an object"
+	replies, log := sessionWithLog(t,
+		map[string]interface{}{"method": "textDocument/didOpen", "params": 42},
+		badHover,
+		didOpen(`set $name "x"`),
+		positionRequest(2, "textDocument/hover", 0, 1),
+	)
+	if len(replies) != 3 {
+		t.Fatalf("Expected an error, diagnostics and a hover, got %v", replies)
+	}
+	failure, _ := replies[0]["error"].(map[string]interface{})
+	if replies[0]["id"] != 1.0 || failure == nil || failure["code"] != -32602.0 {
+		t.Errorf("Expected InvalidParams for the bad request, got %v", replies[0])
+	}
+	if !strings.Contains(log, "dropped textDocument/didOpen") {
+		t.Errorf("Expected the bad notification to be logged, got %q", log)
+	}
+	if replies[2]["id"] != 2.0 || replies[2]["result"] == nil {
+		t.Errorf("Expected the valid request to be answered, got %v", replies[2])
+	}
+}
+
+func TestDefinedVariable(t *testing.T) {
+	tests := []struct {
+		line   string
+		name   string
+		offset int
+	}{
+		{`set $a 1`, "a", 4},
+		{`  var $b "x"`, "b", 6},
+		{`default $port 8080`, "port", 8},
+		{`for $i in 1 to 3 do`, "i", 4},
+		{`foreach $item in $items do`, "item", 8},
+		{`extract jsonpath "$.id" as $id`, "id", 27},
+		{`extract header "Link" all as $links`, "links", 29},
+		{`load json "fixtures/user.json" as $user`, "user", 34},
+		{`LOAD YAML "config.yaml" as $config`, "config", 27},
+		{`dns lookup "$host" as $host`, "host", 22},
+		{`dns lookup "example.com" AAAA as $v6`, "v6", 33},
+		{`print "$a"`, "", -1},
+		{`default header "Accept" "application/json"`, "", -1},
+		{`format $x`, "", -1},
+	}
+	for _, tt := range tests {
+		name, offset := definedVariable(tt.line)
+		if name != tt.name || offset != tt.offset {
+			t.Errorf("definedVariable(%q) = %q, %d, want %q, %d", tt.line, name, offset, tt.name, tt.offset)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	text := strings.Join([]string{
+		`load json "user.json" as $user`,
+		`dns lookup "api.example.com" as $ips`,
+		`default $retries 3`,
+		`for $i in 1 to 3 do`,
+		`endloop`,
+		`print "$`,
+	}, "\n")
+	replies := session(t, didOpen(text), positionRequest(1, "textDocument/completion", 5, 8))
+	if len(replies) != 2 {
+		t.Fatalf("Expected diagnostics and completions, got %v", replies)
+	}
+	kinds := make(map[string]float64)
+	for _, item := range replies[1]["result"].([]interface{}) {
+		item := item.(map[string]interface{})
+		kinds[item["label"].(string)] = item["kind"].(float64)
+	}
+	for _, variable := range []string{"$user", "$ips", "$retries", "$i"} {
+		if kinds[variable] != completionVariable {
+			t.Errorf("Expected %s to be completed as a variable, got kind %v", variable, kinds[variable])
+		}
+	}
+	if kinds["length"] != completionFunction || kinds["GET"] != completionKeyword {
+		t.Errorf("Expected length as a function and GET as a keyword, got %v and %v", kinds["length"], kinds["GET"])
+	}
+}
+
+func TestDefinitionForms(t *testing.T) {
+	text := strings.Join([]string{
+		`dns lookup "$host" as $host`,
+		`load yaml "config.yaml" as $config`,
+		`for $i in 1 to 2 do`,
+		`    print "$i $config $host"`,
+		`endloop`,
+	}, "\n")
+	replies := session(t,
+		didOpen(text),
+		positionRequest(1, "textDocument/definition", 3, 12),
+		positionRequest(2, "textDocument/definition", 3, 16),
+		positionRequest(3, "textDocument/definition", 3, 24),
+	)
+	if len(replies) != 4 {
+		t.Fatalf("Expected diagnostics and three definitions, got %v", replies)
+	}
+	expected := [][3]int{{2, 4, 6}, {1, 27, 34}, {0, 22, 27}}
+	for i, want := range expected {
+		if line, start, end := resultRange(t, replies[i+1]); line != want[0] || start != want[1] || end != want[2] {
+			t.Errorf("Definition %d: got line %d, %d to %d, want %v", i+1, line, start, end, want)
+		}
+	}
+}
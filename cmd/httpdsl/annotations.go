@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"httpdsl/core"
+	"strings"
+)
+
+// outputFormats are the accepted values of --output
+var outputFormats = map[string]bool{"text": true, "github": true}
+
+// githubEscaper escapes the message of a GitHub Actions workflow command
+var githubEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+
+// githubPropertyEscaper escapes a property value of a workflow command
+var githubPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+
+// githubAnnotation formats an ::error workflow command, which GitHub shows
+// inline on the line of the file in pull request views. Line and column are
+// left out when zero.
+func githubAnnotation(filename string, line, column int, title, message string) string {
+	properties := []string{"file=" + githubPropertyEscaper.Replace(filename)}
+	if line > 0 {
+		properties = append(properties, fmt.Sprintf("line=%d", line))
+	}
+	if column > 0 {
+		properties = append(properties, fmt.Sprintf("col=%d", column))
+	}
+	properties = append(properties, "title="+githubPropertyEscaper.Replace(title))
+	return fmt.Sprintf("::error %s::%s", strings.Join(properties, ","), githubEscaper.Replace(message))
+}
+
+// stepAnnotations returns the annotations of the failed steps of a run. When
+// no step failed, like when a statement did not parse, the error of the run
+// is reported on the file.
+func stepAnnotations(filename string, steps []core.StepResult, err error) []string {
+	var annotations []string
+	for _, step := range steps {
+		if step.Passed || step.Err == nil {
+			continue
+		}
+		title := "Step failed"
+		switch step.Kind {
+		case "assertion":
+			title = "Assertion failed"
+		case "request":
+			title = "Request failed"
+		}
+		message := fmt.Sprintf("%v\n%s", step.Err, step.Source)
+		annotations = append(annotations, githubAnnotation(filename, step.Line, 0, title, message))
+	}
+	if len(annotations) == 0 && err != nil {
+		annotations = append(annotations, githubAnnotation(filename, 0, 0, "Script failed", err.Error()))
+	}
+	return annotations
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"httpdsl/core"
+	"reflect"
+	"testing"
+)
+
+func TestGithubAnnotation(t *testing.T) {
+	tests := []struct {
+		name         string
+		filename     string
+		line, column int
+		title        string
+		message      string
+		want         string
+	}{
+		{
+			name:     "plain",
+			filename: "tests/login.http", line: 12, column: 3,
+			title: "Assertion failed", message: "expected 200",
+			want: "::error file=tests/login.http,line=12,col=3,title=Assertion failed::expected 200",
+		},
+		{
+			name:     "no position",
+			filename: "a.http",
+			title:    "Script failed", message: "boom",
+			want: "::error file=a.http,title=Script failed::boom",
+		},
+		{
+			// The message only escapes %, CR and LF: GitHub reads it up to the
+			// end of the line, so colons and commas are kept as they are
+			name:     "message",
+			filename: "a.http", line: 1,
+			title: "Request failed", message: "100% done\r\nGET http://x:8080/a, b",
+			want: "::error file=a.http,line=1,title=Request failed::100%25 done%0D%0AGET http://x:8080/a, b",
+		},
+		{
+			// Properties are separated by commas and end at ::, so the file
+			// and title escape colons and commas too
+			name:     "file and title",
+			filename: "C:\\tests\\a,b%\n.http", line: 2,
+			title: "Step: 1, 2\r",
+			want:  "::error file=C%3A\\tests\\a%2Cb%25%0A.http,line=2,title=Step%3A 1%2C 2%0D::",
+		},
+	}
+	for _, tt := range tests {
+		if got := githubAnnotation(tt.filename, tt.line, tt.column, tt.title, tt.message); got != tt.want {
+			t.Errorf("%s: githubAnnotation() =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStepAnnotations(t *testing.T) {
+	steps := []core.StepResult{
+		{Kind: "request", Line: 1, Passed: true},
+		{Kind: "assertion", Line: 2, Source: "assert status 200", Err: errors.New("status is 500")},
+		{Kind: "request", Line: 3, Source: `GET "http://x"`, Err: errors.New("refused")},
+		{Kind: "print", Line: 4, Source: `print $x`, Err: errors.New("no $x")},
+	}
+	want := []string{
+		"::error file=a.http,line=2,title=Assertion failed::status is 500%0Aassert status 200",
+		`::error file=a.http,line=3,title=Request failed::refused%0AGET "http://x"`,
+		"::error file=a.http,line=4,title=Step failed::no $x%0Aprint $x",
+	}
+	if got := stepAnnotations("a.http", steps, errors.New("run failed")); !reflect.DeepEqual(got, want) {
+		t.Errorf("stepAnnotations() =\n%v\nwant\n%v", got, want)
+	}
+
+	// Without failed steps, the error of the run is reported on the file
+	want = []string{"::error file=a.http,title=Script failed::parse error: line 1"}
+	if got := stepAnnotations("a.http", steps[:1], errors.New("parse error: line 1")); !reflect.DeepEqual(got, want) {
+		t.Errorf("stepAnnotations() = %v, want %v", got, want)
+	}
+	if got := stepAnnotations("a.http", steps[:1], nil); got != nil {
+		t.Errorf("Expected no annotations, got %v", got)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFiles are the names searched for in the current directory when no
+// --config flag is given
+var configFiles = []string{"httpdsl.yaml", "httpdsl.yml", ".httpdslrc"}
+
+// Config is the runner configuration file, defining named environments
+// selected with --env
+//
+// Example httpdsl.yaml:
+//
+//	default: dev
+//	environments:
+//	  dev:
+//	    base_url: http://localhost:8080
+//	  staging:
+//	    base_url: https://stg.example.com
+//	    headers:
+//	      X-Api-Key: abc123
+//	    variables:
+//	      user: qa-bot
+//	    tls:
+//	      ca_cert: certs/staging-ca.pem
+type Config struct {
+	Default      string                  `yaml:"default"`
+	Environments map[string]*Environment `yaml:"environments"`
+
+	dir string // Directory of the file, for relative certificate paths
+}
+
+// Environment holds the settings applied before a script runs against it
+type Environment struct {
+	BaseURL   string                 `yaml:"base_url"`
+	Headers   map[string]string      `yaml:"headers"`
+	Variables map[string]interface{} `yaml:"variables"`
+	TLS       *EnvironmentTLS        `yaml:"tls"`
+}
+
+// EnvironmentTLS holds the TLS options of an environment
+type EnvironmentTLS struct {
+	Insecure   bool   `yaml:"insecure"`
+	CACert     string `yaml:"ca_cert"`
+	ClientCert string `yaml:"client_cert"`
+	ClientKey  string `yaml:"client_key"`
+}
+
+// findConfig returns the first config file present in the current directory,
+// or "" when there is none
+func findConfig() string {
+	for _, name := range configFiles {
+		if info, err := os.Stat(name); err == nil && !info.IsDir() {
+			return name
+		}
+	}
+	return ""
+}
+
+// loadConfig reads a config file. .httpdslrc files use the same YAML format,
+// so JSON works too.
+func loadConfig(filename string) (*Config, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config file %s: %w", filename, err)
+	}
+
+	config := &Config{dir: filepath.Dir(filename)}
+	if err := yaml.Unmarshal(content, config); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", filename, err)
+	}
+	return config, nil
+}
+
+// environment returns the environment called name. It returns nil when name
+// is empty.
+func (c *Config) environment(name string) (*Environment, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	env, ok := c.Environments[name]
+	if !ok {
+		names := make([]string, 0, len(c.Environments))
+		for envName := range c.Environments {
+			names = append(names, envName)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown environment %q, available: %s", name, strings.Join(names, ", "))
+	}
+	if env == nil {
+		env = &Environment{}
+	}
+	return env, nil
+}
+
+// path resolves a path from the config file relative to its directory
+func (c *Config) path(name string) string {
+	if name == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(c.dir, name)
+}
+
+// UseEnvironment applies an environment from the config file: base URL,
+// default headers, TLS options and variables. The base URL is also available
+// to scripts as $base_url unless the environment defines that variable.
+func (hr *HTTPRunner) UseEnvironment(config *Config, name string) error {
+	if name == "" {
+		name = config.Default
+	}
+	env, err := config.environment(name)
+	if err != nil || env == nil {
+		return err
+	}
+
+	engine := hr.dsl.GetEngine()
+	if env.BaseURL != "" {
+		engine.SetBaseURL(env.BaseURL)
+		hr.dsl.SetVariable("base_url", strings.TrimSuffix(env.BaseURL, "/"))
+	}
+	for key, value := range env.Headers {
+		engine.SetHeader(key, value)
+	}
+
+	if tlsOptions := env.TLS; tlsOptions != nil {
+		if tlsOptions.Insecure {
+			engine.SetInsecureSkipVerify(true)
+		}
+		if tlsOptions.CACert != "" {
+			if err := engine.SetCustomCA(config.path(tlsOptions.CACert)); err != nil {
+				return fmt.Errorf("environment %s: %w", name, err)
+			}
+		}
+		if tlsOptions.ClientCert != "" || tlsOptions.ClientKey != "" {
+			if tlsOptions.ClientCert == "" || tlsOptions.ClientKey == "" {
+				return fmt.Errorf("environment %s: client_cert and client_key must be set together", name)
+			}
+			if err := engine.SetClientCertificate(config.path(tlsOptions.ClientCert), config.path(tlsOptions.ClientKey)); err != nil {
+				return fmt.Errorf("environment %s: %w", name, err)
+			}
+		}
+	}
+
+	for key, value := range env.Variables {
+		hr.dsl.SetVariable(key, value)
+	}
+	return nil
+}
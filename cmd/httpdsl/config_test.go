@@ -0,0 +1,226 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes a config file in a new directory, with the other files
+// it names, and returns its path. The working directory is moved elsewhere so
+// relative paths only resolve against the config file.
+func writeConfig(t *testing.T, content string, files ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range append([]string{"httpdsl.yaml"}, files...) {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(t.TempDir())
+	return filepath.Join(dir, "httpdsl.yaml")
+}
+
+const testConfig = `default: dev
+environments:
+  dev:
+    base_url: http://localhost:8080/
+    headers:
+      X-Env: dev
+  staging:
+    base_url: https://stg.example.com
+    variables:
+      user: qa-bot
+      base_url: https://override.example.com
+  empty:
+`
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantEnv []string
+		wantErr string
+	}{
+		{"yaml", testConfig, []string{"dev", "empty", "staging"}, ""},
+		{"json", `{"environments": {"ci": {"base_url": "http://ci"}}}`, []string{"ci"}, ""},
+		{"invalid", "environments: [", nil, "httpdsl.yaml"},
+	}
+	for _, tt := range tests {
+		filename := writeConfig(t, tt.content)
+		config, err := loadConfig(filename)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: loadConfig error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: loadConfig failed: %v", tt.name, err)
+			continue
+		}
+		if config.dir != filepath.Dir(filename) {
+			t.Errorf("%s: config dir = %q, want %q", tt.name, config.dir, filepath.Dir(filename))
+		}
+		for _, name := range tt.wantEnv {
+			if _, ok := config.Environments[name]; !ok {
+				t.Errorf("%s: environment %q missing", tt.name, name)
+			}
+		}
+	}
+
+	if _, err := loadConfig("missing.yaml"); err == nil || !strings.Contains(err.Error(), "cannot read config file missing.yaml") {
+		t.Errorf("Expected a missing file to fail, got %v", err)
+	}
+}
+
+func TestConfigEnvironment(t *testing.T) {
+	config, err := loadConfig(writeConfig(t, testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		wantURL string
+		wantNil bool
+		wantErr string
+	}{
+		{"dev", "http://localhost:8080/", false, ""},
+		{"empty", "", false, ""},
+		{"", "", true, ""},
+		{"prod", "", false, `unknown environment "prod", available: dev, empty, staging`},
+	}
+	for _, tt := range tests {
+		env, err := config.environment(tt.name)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("environment(%q) error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("environment(%q) failed: %v", tt.name, err)
+			continue
+		}
+		if (env == nil) != tt.wantNil {
+			t.Errorf("environment(%q) = %v, want nil %v", tt.name, env, tt.wantNil)
+			continue
+		}
+		if env != nil && env.BaseURL != tt.wantURL {
+			t.Errorf("environment(%q) base URL = %q, want %q", tt.name, env.BaseURL, tt.wantURL)
+		}
+	}
+}
+
+func TestConfigPath(t *testing.T) {
+	absolute, err := filepath.Abs("ca.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{dir: filepath.Join("configs", "staging")}
+	tests := []struct {
+		name, want string
+	}{
+		{"", ""},
+		{"ca.pem", filepath.Join("configs", "staging", "ca.pem")},
+		{"certs/ca.pem", filepath.Join("configs", "staging", "certs", "ca.pem")},
+		{"../ca.pem", filepath.Join("configs", "ca.pem")},
+		{absolute, absolute},
+	}
+	for _, tt := range tests {
+		if got := config.path(tt.name); got != tt.want {
+			t.Errorf("path(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUseEnvironment(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		files     []string
+		env       string
+		wantVars  map[string]string
+		wantHeads map[string]string
+		wantErr   string
+	}{
+		{
+			name:      "falls back to default",
+			content:   testConfig,
+			wantVars:  map[string]string{"base_url": "http://localhost:8080"},
+			wantHeads: map[string]string{"X-Env": "dev"},
+		},
+		{
+			name:     "variables win over base_url",
+			content:  testConfig,
+			env:      "staging",
+			wantVars: map[string]string{"base_url": "https://override.example.com", "user": "qa-bot"},
+		},
+		{
+			name:    "unknown environment",
+			content: testConfig,
+			env:     "prod",
+			wantErr: "available: dev, empty, staging",
+		},
+		{
+			name:    "no environment",
+			content: "environments:\n  dev:\n    base_url: http://dev\n",
+		},
+		{
+			name:    "ca_cert relative to the config file",
+			content: "default: ci\nenvironments:\n  ci:\n    tls:\n      ca_cert: certs/ca.pem\n",
+			files:   []string{"certs/ca.pem"},
+		},
+		{
+			name:    "missing ca_cert",
+			content: "default: ci\nenvironments:\n  ci:\n    tls:\n      ca_cert: certs/other.pem\n",
+			files:   []string{"certs/ca.pem"},
+			wantErr: filepath.Join("certs", "other.pem"),
+		},
+		{
+			name:    "client_cert without client_key",
+			content: "default: ci\nenvironments:\n  ci:\n    tls:\n      client_cert: client.pem\n",
+			files:   []string{"client.pem"},
+			wantErr: "environment ci: client_cert and client_key must be set together",
+		},
+		{
+			name:    "client_key without client_cert",
+			content: "default: ci\nenvironments:\n  ci:\n    tls:\n      client_key: client.key\n",
+			files:   []string{"client.key"},
+			wantErr: "environment ci: client_cert and client_key must be set together",
+		},
+	}
+	for _, tt := range tests {
+		config, err := loadConfig(writeConfig(t, tt.content, tt.files...))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		runner := NewHTTPRunner(false, false, false, false)
+		err = runner.UseEnvironment(config, tt.env)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: UseEnvironment error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: UseEnvironment failed: %v", tt.name, err)
+			continue
+		}
+		for name, want := range tt.wantVars {
+			if got, _ := runner.dsl.GetVariable(name); got != want {
+				t.Errorf("%s: $%s = %v, want %q", tt.name, name, got, want)
+			}
+		}
+		for key, want := range tt.wantHeads {
+			if got := runner.dsl.GetEngine().GetHeader(key); got != want {
+				t.Errorf("%s: header %s = %q, want %q", tt.name, key, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// LoadCookieJar loads cookies saved by an earlier run. A missing file is not
+// an error, so the first script of a flow can create it.
+func (hr *HTTPRunner) LoadCookieJar(filename string) error {
+	content, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read cookie jar %s: %w", filename, err)
+	}
+	if err := hr.dsl.GetEngine().ImportCookies(string(content)); err != nil {
+		return fmt.Errorf("cookie jar %s: %w", filename, err)
+	}
+	return nil
+}
+
+// SaveCookieJar writes the cookies held after the run, readable only by the
+// owner since they usually hold session credentials
+func (hr *HTTPRunner) SaveCookieJar(filename string) error {
+	data, err := hr.dsl.GetEngine().ExportCookies()
+	if err != nil {
+		return fmt.Errorf("cannot export cookies: %w", err)
+	}
+	if err := os.WriteFile(filename, []byte(data+"\n"), 0600); err != nil {
+		return fmt.Errorf("cannot write cookie jar %s: %w", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// daemonMetrics aggregates the runs of a scheduled script
+type daemonMetrics struct {
+	mu                  sync.Mutex
+	Script              string     `json:"script"`
+	Schedule            string     `json:"schedule"`
+	Runs                int        `json:"runs"`
+	Failures            int        `json:"failures"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastRun             *time.Time `json:"last_run,omitempty"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastDurationMs      int64      `json:"last_duration_ms"`
+	AvgDurationMs       int64      `json:"avg_duration_ms"`
+	MaxDurationMs       int64      `json:"max_duration_ms"`
+	totalDuration       time.Duration
+}
+
+// record adds the outcome of one run
+func (m *daemonMetrics) record(start time.Time, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Runs++
+	m.LastRun = &start
+	m.totalDuration += duration
+	m.LastDurationMs = duration.Milliseconds()
+	m.AvgDurationMs = (m.totalDuration / time.Duration(m.Runs)).Milliseconds()
+	if m.LastDurationMs > m.MaxDurationMs {
+		m.MaxDurationMs = m.LastDurationMs
+	}
+	if err != nil {
+		m.Failures++
+		m.ConsecutiveFailures++
+		m.LastError = err.Error()
+		return
+	}
+	m.ConsecutiveFailures = 0
+	m.LastSuccess = &start
+	m.LastError = ""
+}
+
+// ServeHTTP writes the metrics as JSON, for --metrics
+func (m *daemonMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	data, err := json.MarshalIndent(m, "", "  ")
+	m.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// summary is the one line report printed after every run
+func (m *daemonMetrics) summary() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return fmt.Sprintf("runs %d, failures %d, avg %dms, max %dms", m.Runs, m.Failures, m.AvgDurationMs, m.MaxDurationMs)
+}
+
+// daemonAlert is the JSON posted to the webhook when a run fails
+type daemonAlert struct {
+	Script              string    `json:"script"`
+	Time                time.Time `json:"time"`
+	Error               string    `json:"error"`
+	DurationMs          int64     `json:"duration_ms"`
+	Runs                int       `json:"runs"`
+	Failures            int       `json:"failures"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+}
+
+// sendAlert posts a failed run to the webhook
+func sendAlert(webhook string, alert daemonAlert) error {
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(webhook, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// runDaemon implements the daemon subcommand: it runs a script on a cron
+// schedule until interrupted, keeps aggregated metrics of the runs and posts
+// failed runs to a webhook. It returns the process exit code.
+func runDaemon(args []string) int {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	scheduleExpr := fs.String("schedule", "", `Cron schedule: minute hour day month weekday, e.g. "*/5 * * * *"`)
+	webhook := fs.String("webhook", "", "POST a JSON alert to this URL when a run fails")
+	metricsAddr := fs.String("metrics", "", "Serve the aggregated metrics as JSON on this address, e.g. :9090")
+	var options runnerOptions
+	options.register(fs)
+	fs.Usage = func() {
+		fmt.Println("Usage: http-runner daemon --schedule <cron> [options] <script.http>")
+		fmt.Println()
+		fmt.Println("Runs a script on a schedule, as a synthetic monitor. Every run starts")
+		fmt.Println("from a fresh engine. Stop it with Ctrl+C or SIGTERM.")
+		fmt.Println()
+		fmt.Println("Options:")
+		fmt.Println(`  --schedule <cron> Cron schedule, e.g. "*/5 * * * *" or @hourly`)
+		fmt.Println("  --webhook <url>   POST a JSON alert when a run fails")
+		fmt.Println("  --metrics <addr>  Serve the aggregated metrics as JSON, e.g. :9090")
+		options.usage()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Println("❌ Error: No script file specified")
+		fs.Usage()
+		return 1
+	}
+	if *scheduleExpr == "" {
+		fmt.Println("❌ Error: --schedule is required")
+		fs.Usage()
+		return 1
+	}
+	sched, err := parseSchedule(*scheduleExpr)
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		return 1
+	}
+
+	filename := fs.Arg(0)
+	if err := validateFile(filename); err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		return 1
+	}
+	if err := options.load(); err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		return 1
+	}
+
+	metrics := &daemonMetrics{Script: filename, Schedule: *scheduleExpr}
+	if *metricsAddr != "" {
+		server := &http.Server{Addr: *metricsAddr, Handler: metrics}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fmt.Printf("❌ Metrics server: %v\n", err)
+			}
+		}()
+		defer server.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("⏰ Running %s on schedule %q\n", filename, *scheduleExpr)
+	for {
+		next := sched.next(time.Now())
+		if next.IsZero() {
+			fmt.Printf("❌ Error: schedule %q never runs\n", *scheduleExpr)
+			return 1
+		}
+		fmt.Printf("   Next run at %s\n", next.Format(time.RFC3339))
+
+		select {
+		case <-ctx.Done():
+			fmt.Printf("\n🛑 Stopped after %s\n", metrics.summary())
+			return 0
+		case <-time.After(time.Until(next)):
+		}
+
+		start := time.Now()
+		err := options.runFile(filename, nil)
+		duration := time.Since(start)
+		metrics.record(start, duration, err)
+
+		if err == nil {
+			fmt.Printf("✅ %s passed in %v (%s)\n", start.Format(time.RFC3339), duration.Round(time.Millisecond), metrics.summary())
+			continue
+		}
+		fmt.Printf("❌ %s failed in %v: %v (%s)\n", start.Format(time.RFC3339), duration.Round(time.Millisecond), err, metrics.summary())
+
+		if *webhook != "" {
+			metrics.mu.Lock()
+			alert := daemonAlert{
+				Script:              filename,
+				Time:                start,
+				Error:               err.Error(),
+				DurationMs:          duration.Milliseconds(),
+				Runs:                metrics.Runs,
+				Failures:            metrics.Failures,
+				ConsecutiveFailures: metrics.ConsecutiveFailures,
+			}
+			metrics.mu.Unlock()
+			if err := sendAlert(*webhook, alert); err != nil {
+				fmt.Printf("⚠️  Alert not sent: %v\n", err)
+			}
+		}
+	}
+}
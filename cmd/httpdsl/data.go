@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"httpdsl/core"
+)
+
+// RunData runs a script once for every row of a CSV file, with the row in
+// $row so columns read as $row.email. Every run starts from the variables set
+// before the first one. Failing rows are reported and the other rows still
+// run, unless --stop is set.
+func (hr *HTTPRunner) RunData(filename, script, dataFile string) error {
+	rows, err := core.ReadCSVRows(dataFile)
+	if err != nil {
+		return fmt.Errorf("cannot read data file: %w", err)
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("data file %s has no rows", dataFile)
+	}
+
+	initial := hr.dsl.GetVariables()
+	failed := 0
+	for i, row := range rows {
+		hr.dsl.ClearVariables()
+		for name, value := range initial {
+			hr.dsl.SetVariable(name, value)
+		}
+		hr.dsl.SetVariable("row", row)
+
+		if !hr.quiet {
+			fmt.Printf("\n📄 Data row %d of %d: %v\n", i+1, len(rows), row)
+		}
+		if err := hr.Run(filename, script); err != nil {
+			failed++
+			fmt.Printf("❌ Row %d: %v\n", i+1, err)
+			if hr.stopOnFail {
+				break
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d data rows failed", failed, len(rows))
+	}
+	return nil
+}
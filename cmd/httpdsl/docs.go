@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"httpdsl/core"
+	"strings"
+)
+
+// runDocs implements the docs subcommand and returns the process exit code.
+// Syntax comes from the grammar rules, so it always matches the parser.
+func runDocs(args []string) int {
+	flags := flag.NewFlagSet("docs", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Println("Usage: http-runner docs [keyword]")
+		fmt.Println()
+		fmt.Println("Without a keyword, lists every keyword with a one line summary.")
+	}
+	flags.Parse(args)
+
+	dsl := core.NewHTTPDSLv3()
+	switch flags.NArg() {
+	case 0:
+		printKeywords(dsl)
+		return 0
+	case 1:
+		doc, ok := dsl.DescribeCommand(flags.Arg(0))
+		if !ok {
+			fmt.Printf("❌ Error: unknown keyword %s; http-runner docs lists them all\n", flags.Arg(0))
+			return 1
+		}
+		printCommandDoc(doc)
+		return 0
+	}
+	flags.Usage()
+	return 1
+}
+
+// printKeywords lists the documented keywords with their summaries
+func printKeywords(dsl *core.HTTPDSLv3) {
+	var docs []*core.CommandDoc
+	width := 0
+	for _, keyword := range dsl.Keywords() {
+		if doc, ok := dsl.DescribeCommand(keyword); ok {
+			docs = append(docs, doc)
+			width = max(width, len(doc.Keyword))
+		}
+	}
+	for _, doc := range docs {
+		fmt.Printf("  %-*s  %s\n", width, doc.Keyword, doc.Summary)
+	}
+	fmt.Println()
+	fmt.Println("Run http-runner docs <keyword> for its syntax and examples.")
+}
+
+// printCommandDoc prints the reference of one keyword
+func printCommandDoc(doc *core.CommandDoc) {
+	fmt.Printf("%s - %s\n", doc.Keyword, doc.Summary)
+	if len(doc.Syntax) > 0 {
+		fmt.Println()
+		fmt.Println("Syntax:")
+		printIndented(doc.Syntax)
+	}
+	for _, rule := range doc.Rules {
+		fmt.Println()
+		if rule.Element != "" {
+			fmt.Printf("<%s> is one or more <%s>, one of:\n", rule.Name, rule.Element)
+		} else {
+			fmt.Printf("<%s> is one of:\n", rule.Name)
+		}
+		printIndented(rule.Forms)
+	}
+	if len(doc.Examples) > 0 {
+		fmt.Println()
+		fmt.Println("Examples:")
+		printIndented(doc.Examples)
+	}
+}
+
+// printIndented prints lines indented under a heading
+func printIndented(lines []string) {
+	for _, line := range lines {
+		fmt.Println("  " + strings.TrimSpace(line))
+	}
+}
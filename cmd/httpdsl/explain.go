@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"httpdsl/core"
+	"os"
+	"strings"
+)
+
+// runExplain implements the explain subcommand and returns the process exit code
+func runExplain(args []string) int {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println("Usage: http-runner explain <script.http>")
+		fmt.Println()
+		fmt.Println("Prints the parse tree of every statement without executing the script:")
+		fmt.Println("the grammar rules matched, their actions, the tokens, and the places")
+		fmt.Println("where variables are expanded at run time.")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Println("❌ Error: No script file specified")
+		fs.Usage()
+		return 1
+	}
+
+	filename := fs.Arg(0)
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Printf("❌ Error: cannot read file %s: %v\n", filename, err)
+		return 1
+	}
+
+	dsl := core.NewHTTPDSLv3()
+	exitCode := 0
+
+	for _, stmt := range dsl.Explain(string(content)) {
+		fmt.Printf("line %d: %s\n", stmt.Line, stmt.Source)
+
+		if stmt.Kind != "statement" {
+			fmt.Printf("  [%s block]\n", stmt.Kind)
+		}
+		if stmt.Tree != nil {
+			for _, line := range strings.Split(strings.TrimSuffix(stmt.Tree.String(), "\n"), "\n") {
+				fmt.Println("  " + line)
+			}
+		}
+		if stmt.Err != nil {
+			exitCode = 1
+			if stmt.Rule == "" {
+				fmt.Printf("  ❌ %s\n", stmt.Err.Message)
+				fmt.Println()
+				continue
+			}
+			fmt.Printf("  ❌ no alternative of %s matched at column %d: %s\n", stmt.Rule, stmt.Err.Column, stmt.Err.Message)
+			if len(stmt.Expected) > 0 {
+				fmt.Printf("     expected one of: %s\n", strings.Join(stmt.Expected, ", "))
+			}
+		}
+		fmt.Println()
+	}
+
+	return exitCode
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// scriptExt is the extension of script files; arguments ending in it are
+// scripts to run, not arguments for the script
+const scriptExt = ".http"
+
+// splitScripts separates the scripts to run from the arguments passed to
+// them. The first argument is always a script; the ones after it are scripts
+// too while they end in .http or are glob patterns, which may use ** for any
+// number of directories, so tests/**/*.http works even where the shell does
+// not expand it. Every file is run once, in the order given. Stdin, -, can
+// only be read once, so it is the only script when given.
+func splitScripts(args []string) ([]string, []string, error) {
+	var scripts []string
+	seen := make(map[string]bool)
+	i := 0
+	for ; i < len(args); i++ {
+		arg := args[i]
+		isPattern := strings.ContainsAny(arg, "*?[")
+		if i > 0 && !isPattern && !strings.HasSuffix(arg, scriptExt) {
+			break
+		}
+
+		matches := []string{arg}
+		if isPattern {
+			var err error
+			if matches, err = globScripts(arg); err != nil {
+				return nil, nil, err
+			}
+			if len(matches) == 0 {
+				return nil, nil, fmt.Errorf("no scripts match %s", arg)
+			}
+		}
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				scripts = append(scripts, match)
+			}
+		}
+	}
+	if len(scripts) > 1 && seen["-"] {
+		return nil, nil, errors.New("cannot run stdin (-) together with other scripts")
+	}
+	return scripts, args[i:], nil
+}
+
+// globScripts returns the files matching a pattern in lexical order. Besides
+// the syntax of filepath.Match, a ** segment matches any number of
+// directories, none included.
+func globScripts(pattern string) ([]string, error) {
+	if !strings.Contains(pattern, "**") {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+		}
+		return matches, nil
+	}
+
+	// Walk from the directory before the first segment with a wildcard
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+	fixed := 0
+	for fixed < len(segments)-1 && !strings.ContainsAny(segments[fixed], "*?[") {
+		fixed++
+	}
+	root := strings.Join(segments[:fixed], "/")
+	if root == "" && fixed > 0 {
+		root = "/"
+	} else if root == "" {
+		root = "."
+	}
+
+	if _, err := os.Stat(filepath.FromSlash(root)); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	var matches []string
+	err := filepath.WalkDir(filepath.FromSlash(root), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(filepath.FromSlash(root), path)
+		if err != nil {
+			return err
+		}
+		ok, err := matchSegments(segments[fixed:], strings.Split(filepath.ToSlash(rel), "/"))
+		if ok {
+			matches = append(matches, path)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot expand %s: %w", pattern, err)
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// matchSegments reports whether the segments of a path match those of a
+// pattern, where ** stands for any number of segments
+func matchSegments(pattern, path []string) (bool, error) {
+	if len(pattern) == 0 {
+		return len(path) == 0, nil
+	}
+	if pattern[0] == "**" {
+		for skip := 0; skip <= len(path); skip++ {
+			if ok, err := matchSegments(pattern[1:], path[skip:]); ok || err != nil {
+				return ok, err
+			}
+		}
+		return false, nil
+	}
+	if len(path) == 0 {
+		return false, nil
+	}
+	ok, err := filepath.Match(pattern[0], path[0])
+	if !ok || err != nil {
+		return false, err
+	}
+	return matchSegments(pattern[1:], path[1:])
+}
+
+// scriptResult is how one script of a multi-file run ended
+type scriptResult struct {
+	filename string
+	err      error
+	duration time.Duration
+}
+
+// RunFiles runs several scripts in order and prints a combined summary. Each
+// script starts from the variables and cookies there were before the first
+// one, unless shareSession is set, when it sees those the previous scripts
+// left, as in one session. Failing scripts are reported and the others still
+// run, unless --stop is set.
+func (hr *HTTPRunner) RunFiles(filenames []string, dataFile string, shareSession bool) error {
+	engine := hr.dsl.GetEngine()
+	variables := hr.dsl.GetVariables()
+	cookies, err := engine.ExportCookies()
+	if err != nil {
+		return fmt.Errorf("cannot export cookies: %w", err)
+	}
+
+	var results []scriptResult
+	for i, filename := range filenames {
+		if i > 0 && !shareSession {
+			hr.dsl.ClearVariables()
+			for name, value := range variables {
+				hr.dsl.SetVariable(name, value)
+			}
+			engine.ClearCookies()
+			if err := engine.ImportCookies(cookies); err != nil {
+				return fmt.Errorf("cannot restore cookies: %w", err)
+			}
+		}
+
+		start := time.Now()
+		err := hr.runScriptFile(filename, dataFile)
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n", filename, err)
+		}
+		results = append(results, scriptResult{filename: filename, err: err, duration: time.Since(start)})
+		if err != nil && hr.stopOnFail {
+			break
+		}
+	}
+
+	failed := 0
+	fmt.Printf("\n📋 Scripts run: %d of %d\n", len(results), len(filenames))
+	for _, result := range results {
+		status := "✅"
+		if result.err != nil {
+			status = "❌"
+			failed++
+		}
+		fmt.Printf("   %s %s (%v)\n", status, result.filename, result.duration.Round(time.Millisecond))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d scripts failed", failed, len(filenames))
+	}
+	return nil
+}
+
+// runScriptFile reads a script and runs it once, or once per row of the data
+// file, with its snapshots next to it
+func (hr *HTTPRunner) runScriptFile(filename, dataFile string) error {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("cannot read file %s: %w", filename, err)
+	}
+	hr.useSnapshotDir(filename)
+	if dataFile != "" {
+		return hr.RunData(filename, string(content), dataFile)
+	}
+	return hr.Run(filename, string(content))
+}
+
+// useSnapshotDir keeps snapshots next to the script, so they can be committed
+// with it; those of inline and stdin scripts go in the current directory
+func (hr *HTTPRunner) useSnapshotDir(filename string) {
+	dir := "__snapshots__"
+	if filename != inlineName && filename != stdinName {
+		dir = filepath.Join(filepath.Dir(filename), dir)
+	}
+	hr.dsl.SetSnapshotDir(dir)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// scriptTree creates a tree of scripts in a temporary directory and makes it
+// the working directory
+func scriptTree(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{
+		"a.http",
+		"tests/b.http",
+		"tests/users/c.http",
+		"tests/users/admin/d.http",
+		"tests/users/notes.txt",
+		"other/e.http",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("print \"ok\""), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+}
+
+// slashed converts paths to forward slashes, so expectations hold on Windows
+func slashed(paths []string) []string {
+	converted := make([]string, len(paths))
+	for i, path := range paths {
+		converted[i] = filepath.ToSlash(path)
+	}
+	return converted
+}
+
+func TestMatchSegments(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		// ** at the start
+		{"**/*.http", "a.http", true},
+		{"**/*.http", "x/y/a.http", true},
+		{"**/*.http", "x/a.txt", false},
+		// ** in the middle
+		{"tests/**/*.http", "tests/a.http", true},
+		{"tests/**/*.http", "tests/x/y/a.http", true},
+		{"tests/**/*.http", "other/a.http", false},
+		{"tests/**/users/*.http", "tests/v1/users/a.http", true},
+		{"tests/**/users/*.http", "tests/users/a.http", true},
+		{"tests/**/users/*.http", "tests/users/v1/a.http", false},
+		// ** at the end
+		{"tests/**", "tests/a.txt", true},
+		{"tests/**", "tests/x/y/a.http", true},
+		{"tests/**", "other/a.http", false},
+		// Several of them
+		{"**/users/**/*.http", "v1/users/admin/a.http", true},
+		{"**/users/**/*.http", "users/a.http", true},
+		{"**/users/**/*.http", "v1/groups/a.http", false},
+		// Without **, segments match one to one
+		{"tests/*.http", "tests/x/a.http", false},
+	}
+	for _, tt := range tests {
+		got, err := matchSegments(strings.Split(tt.pattern, "/"), strings.Split(tt.path, "/"))
+		if err != nil || got != tt.want {
+			t.Errorf("matchSegments(%q, %q) = %v, %v, want %v", tt.pattern, tt.path, got, err, tt.want)
+		}
+	}
+
+	if _, err := matchSegments([]string{"**", "[a"}, []string{"a"}); err == nil {
+		t.Error("Expected an invalid pattern to fail")
+	}
+}
+
+func TestGlobScripts(t *testing.T) {
+	scriptTree(t)
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"**/*.http", []string{"a.http", "other/e.http", "tests/b.http", "tests/users/admin/d.http", "tests/users/c.http"}},
+		{"tests/**/*.http", []string{"tests/b.http", "tests/users/admin/d.http", "tests/users/c.http"}},
+		{"tests/**/admin/*.http", []string{"tests/users/admin/d.http"}},
+		{"tests/users/**", []string{"tests/users/admin/d.http", "tests/users/c.http", "tests/users/notes.txt"}},
+		{"tests/*.http", []string{"tests/b.http"}},
+		{"missing/**/*.http", nil},
+	}
+	for _, tt := range tests {
+		got, err := globScripts(tt.pattern)
+		if err != nil {
+			t.Errorf("globScripts(%q) failed: %v", tt.pattern, err)
+			continue
+		}
+		if got := slashed(got); len(got)+len(tt.want) > 0 && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("globScripts(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestSplitScripts(t *testing.T) {
+	scriptTree(t)
+	tests := []struct {
+		args        []string
+		wantScripts []string
+		wantArgs    []string
+		wantErr     string
+	}{
+		// The first argument not ending in .http starts the script arguments
+		{[]string{"a.http", "token", "b.http"}, []string{"a.http"}, []string{"token", "b.http"}, ""},
+		{[]string{"a.http", "tests/b.http", "--verbose"}, []string{"a.http", "tests/b.http"}, []string{"--verbose"}, ""},
+		{[]string{"a.http"}, []string{"a.http"}, []string{}, ""},
+		// The first argument is a script whatever its name
+		{[]string{"script.txt", "a.http", "x"}, []string{"script.txt", "a.http"}, []string{"x"}, ""},
+		// Patterns are expanded, and files given twice run once
+		{[]string{"a.http", "tests/**/*.http", "tests/b.http", "1"}, []string{"a.http", "tests/b.http", "tests/users/admin/d.http", "tests/users/c.http"}, []string{"1"}, ""},
+		{[]string{"**/*.json"}, nil, nil, "no scripts match"},
+		// Stdin is read once, alone
+		{[]string{"-", "x"}, []string{"-"}, []string{"x"}, ""},
+		{[]string{"-", "a.http"}, nil, nil, "stdin"},
+		{[]string{"-", "tests/*.http"}, nil, nil, "stdin"},
+	}
+	for _, tt := range tests {
+		scripts, args, err := splitScripts(tt.args)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("splitScripts(%q) error = %v, want %q", tt.args, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("splitScripts(%q) failed: %v", tt.args, err)
+			continue
+		}
+		if got := slashed(scripts); !reflect.DeepEqual(got, tt.wantScripts) {
+			t.Errorf("splitScripts(%q) scripts = %v, want %v", tt.args, got, tt.wantScripts)
+		}
+		if !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("splitScripts(%q) arguments = %q, want %q", tt.args, args, tt.wantArgs)
+		}
+	}
+}
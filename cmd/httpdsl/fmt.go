@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"httpdsl/core"
+	"os"
+)
+
+// runFmt implements the fmt subcommand and returns the process exit code
+func runFmt(args []string) int {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	check := fs.Bool("check", false, "Exit with status 1 if any file is not formatted (for CI)")
+	write := fs.Bool("w", false, "Write the formatted result back to the file")
+	fs.Usage = func() {
+		fmt.Println("Usage: http-runner fmt [--check] [-w] <script.http>...")
+		fmt.Println()
+		fmt.Println("Options:")
+		fmt.Println("  --check           Report files that are not formatted and exit 1")
+		fmt.Println("  -w                Rewrite files in place")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fmt.Println("❌ Error: No script file specified")
+		fs.Usage()
+		return 1
+	}
+
+	dsl := core.NewHTTPDSLv3()
+	exitCode := 0
+
+	for _, filename := range fs.Args() {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Printf("❌ Error: cannot read file %s: %v\n", filename, err)
+			exitCode = 1
+			continue
+		}
+
+		original := string(content)
+		formatted := dsl.Format(original)
+
+		switch {
+		case *check:
+			if formatted != original {
+				fmt.Println(filename)
+				exitCode = 1
+			}
+		case *write:
+			if formatted == original {
+				continue
+			}
+			if err := os.WriteFile(filename, []byte(formatted), 0644); err != nil {
+				fmt.Printf("❌ Error: cannot write file %s: %v\n", filename, err)
+				exitCode = 1
+			}
+		default:
+			fmt.Print(formatted)
+		}
+	}
+
+	return exitCode
+}
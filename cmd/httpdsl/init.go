@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// starterFile is a file created by init, with its path in the project
+type starterFile struct {
+	path    string
+	content string
+}
+
+// starterFiles make up the layout init creates: environments, a smoke test
+// that passes against the dev environment, a fixture it sends and the
+// variables it reads from --var-file
+var starterFiles = []starterFile{
+	{"httpdsl.yaml", `# Environments of http-runner: dev is used unless --env names another
+default: dev
+environments:
+  dev:
+    base_url: https://httpbin.org
+  staging:
+    base_url: https://staging.example.com
+    variables:
+      user: qa-bot
+`},
+	{"tests/smoke.http", `# Smoke test: http-runner tests/smoke.http
+# Against staging, with secrets from .env:
+#   http-runner --env staging --var-file .env tests/smoke.http
+default $api_token "anonymous"
+
+GET "$base_url/get"
+    header "Accept" "application/json"
+    header "Authorization" "Bearer $api_token"
+assert status 200
+assert time less 2000 ms
+
+# Send a fixture as the body of a request
+load json "fixtures/user.json" as $user
+POST "$base_url/post" json {"user": $user}
+assert status 200
+assert jsonpath "$.json.user.name" equals "Ada Lovelace"
+`},
+	{"fixtures/user.json", `{
+  "name": "Ada Lovelace",
+  "email": "ada@example.com",
+  "roles": ["admin"]
+}
+`},
+	{".env.example", `# Variables for --var-file, one key=value per line. Copy this file to .env,
+# fill it in and keep .env out of version control:
+#   http-runner --var-file .env tests/smoke.http
+api_token=change-me
+`},
+}
+
+// runInit implements the init subcommand and returns the process exit code
+func runInit(args []string) int {
+	flags := flag.NewFlagSet("init", flag.ExitOnError)
+	force := flags.Bool("force", false, "Overwrite files that already exist")
+	flags.Usage = func() {
+		fmt.Println("Usage: http-runner init [--force] [directory]")
+		fmt.Println()
+		fmt.Println("Creates httpdsl.yaml, tests/smoke.http, fixtures/ and .env.example in the")
+		fmt.Println("directory, the current one by default. Existing files are kept.")
+		fmt.Println()
+		fmt.Println("Options:")
+		fmt.Println("  --force           Overwrite files that already exist")
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 1 {
+		fmt.Println("❌ Error: init takes a single directory")
+		flags.Usage()
+		return 1
+	}
+	dir := "."
+	if flags.NArg() == 1 {
+		dir = flags.Arg(0)
+	}
+
+	fmt.Printf("🌱 Creating an http-runner project in %s\n", dir)
+	exitCode := 0
+	for _, file := range starterFiles {
+		created, err := writeStarterFile(dir, file, *force)
+		switch {
+		case err != nil:
+			fmt.Printf("❌ Error: %v\n", err)
+			exitCode = 1
+		case created:
+			fmt.Printf("   created %s\n", file.path)
+		default:
+			fmt.Printf("   kept    %s (already exists)\n", file.path)
+		}
+	}
+	if exitCode != 0 {
+		return exitCode
+	}
+
+	fmt.Println()
+	fmt.Println("Next steps:")
+	if dir != "." {
+		fmt.Printf("  cd %s\n", dir)
+	}
+	fmt.Println("  http-runner tests/smoke.http")
+	fmt.Println("  cp .env.example .env   # then http-runner --var-file .env tests/smoke.http")
+	return 0
+}
+
+// writeStarterFile writes a file of the layout under dir, creating its
+// directories. Existing files are kept unless force is set; it reports
+// whether the file was written.
+func writeStarterFile(dir string, file starterFile, force bool) (bool, error) {
+	path := filepath.Join(dir, filepath.FromSlash(file.path))
+	if !force {
+		if _, err := os.Stat(path); err == nil {
+			return false, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return false, fmt.Errorf("cannot check %s: %w", path, err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, fmt.Errorf("cannot create directory %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(file.content), 0644); err != nil {
+		return false, fmt.Errorf("cannot write file %s: %w", path, err)
+	}
+	return true, nil
+}
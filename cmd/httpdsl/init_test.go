@@ -0,0 +1,92 @@
+package main
+
+import (
+	"httpdsl/core"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readProjectFile reads a file of the layout init created under dir
+func readProjectFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatalf("Expected %s to be created: %v", name, err)
+	}
+	return string(content)
+}
+
+func TestRunInit(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "project")
+	if code := runInit([]string{dir}); code != 0 {
+		t.Fatalf("init exited with %d", code)
+	}
+	for _, name := range []string{"httpdsl.yaml", "tests/smoke.http", "fixtures/user.json", ".env.example"} {
+		readProjectFile(t, dir, name)
+	}
+
+	// The files work together: the config has the dev environment the smoke
+	// test runs against, the test parses and .env.example is a variable file
+	config, err := loadConfig(filepath.Join(dir, "httpdsl.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env, err := config.environment(config.Default); err != nil || env.BaseURL == "" {
+		t.Errorf("Expected a default environment with a base URL, got %+v, %v", env, err)
+	}
+	if problems := core.NewHTTPDSLv3().Validate(readProjectFile(t, dir, "tests/smoke.http")); len(problems) > 0 {
+		t.Errorf("Expected the smoke test to be valid, got %v", problems)
+	}
+	if vars, err := loadVarFile(filepath.Join(dir, ".env.example")); err != nil || vars["api_token"] == nil {
+		t.Errorf("Expected .env.example to set $api_token, got %v, %v", vars, err)
+	}
+
+	// Existing files are kept, unless --force is given
+	smoke := filepath.Join(dir, "tests", "smoke.http")
+	if err := os.WriteFile(smoke, []byte("print \"mine\""), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, ".env.example")); err != nil {
+		t.Fatal(err)
+	}
+	if code := runInit([]string{dir}); code != 0 {
+		t.Fatalf("init exited with %d", code)
+	}
+	if got := readProjectFile(t, dir, "tests/smoke.http"); got != "print \"mine\"" {
+		t.Errorf("Expected the existing smoke test to be kept, got %q", got)
+	}
+	readProjectFile(t, dir, ".env.example")
+
+	if code := runInit([]string{"--force", dir}); code != 0 {
+		t.Fatalf("init --force exited with %d", code)
+	}
+	if got := readProjectFile(t, dir, "tests/smoke.http"); got != starterFiles[1].content {
+		t.Errorf("Expected --force to overwrite the smoke test, got %q", got)
+	}
+}
+
+func TestRunInitCurrentDirectory(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if code := runInit(nil); code != 0 {
+		t.Fatalf("init exited with %d", code)
+	}
+	readProjectFile(t, dir, "httpdsl.yaml")
+
+	if code := runInit([]string{"a", "b"}); code != 1 {
+		t.Errorf("Expected two directories to fail, got %d", code)
+	}
+}
+
+func TestWriteStarterFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	// A file where a directory is needed
+	if err := os.WriteFile(filepath.Join(dir, "tests"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	created, err := writeStarterFile(dir, starterFile{"tests/smoke.http", "print 1"}, false)
+	if created || err == nil {
+		t.Errorf("Expected writing under a file to fail, got %v, %v", created, err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Names the runner reports for scripts that do not come from a file
+const (
+	stdinName  = "<stdin>"
+	inlineName = "<inline>"
+)
+
+// readScript reads a script file, or stdin when filename is -
+func readScript(filename string) (string, error) {
+	if filename == "-" {
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("cannot read script from stdin: %w", err)
+		}
+		return string(content), nil
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("cannot read file %s: %w", filename, err)
+	}
+	return string(content), nil
+}
+
+// inlineScript turns the text of -e into a script. A \n outside quotes
+// separates statements, so a one-liner can hold several; inside quotes it is
+// left for the string to unescape.
+func inlineScript(text string) string {
+	var b strings.Builder
+	inString := false
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text):
+			if !inString && text[i+1] == 'n' {
+				b.WriteByte('\n')
+			} else {
+				b.WriteByte(c)
+				b.WriteByte(text[i+1])
+			}
+			i++
+			continue
+		case c == '"':
+			inString = !inString
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
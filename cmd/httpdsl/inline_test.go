@@ -0,0 +1,70 @@
+package main
+
+import (
+	"httpdsl/core"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInlineScript(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{`print "hi"`, `print "hi"`},
+		// \n outside quotes separates statements
+		{`set $a 1\nprint $a`, "set $a 1\nprint $a"},
+		{`GET "http://x"\nassert status 200\n`, "GET \"http://x\"\nassert status 200\n"},
+		// Inside quotes it is left for the string
+		{`print "a\nb"`, `print "a\nb"`},
+		{`print "a\nb"\nprint "c"`, "print \"a\\nb\"\nprint \"c\""},
+		// An escaped quote does not end the string
+		{`print "say \"hi\n\""\nprint 1`, "print \"say \\\"hi\\n\\\"\"\nprint 1"},
+		// Other escapes and a trailing backslash are kept
+		{`print "tab\t"\t`, `print "tab\t"\t`},
+		{`print 1\`, `print 1\`},
+	}
+	for _, tt := range tests {
+		if got := inlineScript(tt.text); got != tt.want {
+			t.Errorf("inlineScript(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+
+	// The statements parse as separate lines
+	script := inlineScript(`set $a 1\nset $b "x\ny"\nprint $a`)
+	if problems := core.NewHTTPDSLv3().Validate(script); len(problems) > 0 {
+		t.Errorf("Expected %q to be valid, got %v", script, problems)
+	}
+}
+
+func TestReadScript(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.http")
+	if err := os.WriteFile(path, []byte(`print "file"`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if script, err := readScript(path); err != nil || script != `print "file"` {
+		t.Errorf("readScript(%q) = %q, %v", path, script, err)
+	}
+	if _, err := readScript(filepath.Join(dir, "missing.http")); err == nil || !strings.Contains(err.Error(), "cannot read file") {
+		t.Errorf("Expected a missing file to fail, got %v", err)
+	}
+
+	// - reads stdin
+	stdin := filepath.Join(dir, "stdin")
+	if err := os.WriteFile(stdin, []byte(`print "stdin"`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(stdin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	saved := os.Stdin
+	os.Stdin = f
+	defer func() { os.Stdin = saved }()
+	if script, err := readScript("-"); err != nil || script != `print "stdin"` {
+		t.Errorf("readScript(\"-\") = %q, %v", script, err)
+	}
+}
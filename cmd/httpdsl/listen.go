@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxTriggerBody is the largest webhook payload accepted
+const maxTriggerBody = 1 << 20
+
+// triggerServer runs the script named in the path of a webhook request
+type triggerServer struct {
+	dir     string         // Directory holding the scripts
+	token   string         // Shared secret the request must send, "" for none
+	options *runnerOptions // Prepares the runner of every run
+}
+
+// triggerResult is the JSON answer to a webhook
+type triggerResult struct {
+	Script     string `json:"script"`
+	Passed     bool   `json:"passed"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ServeHTTP handles POST /run/<name>: it runs <name>.http with the payload in
+// $TRIGGER_BODY, and in $TRIGGER too when it is JSON. It answers 200 when the
+// script passes and 422 when it fails.
+func (ts *triggerServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutPrefix(r.URL.Path, "/run/")
+	if !ok || name == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if !ts.authorized(r) {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	filename, err := ts.script(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxTriggerBody))
+	if err != nil {
+		http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	variables := map[string]interface{}{"TRIGGER_BODY": string(body)}
+	var payload interface{}
+	if json.Unmarshal(body, &payload) == nil {
+		variables["TRIGGER"] = payload
+	}
+
+	start := time.Now()
+	err = ts.options.runFile(filename, variables)
+	result := triggerResult{Script: name, Passed: err == nil, DurationMs: time.Since(start).Milliseconds()}
+	status := http.StatusOK
+	if err != nil {
+		result.Error = err.Error()
+		status = http.StatusUnprocessableEntity
+		fmt.Printf("❌ %s %s failed in %dms: %v\n", start.Format(time.RFC3339), name, result.DurationMs, err)
+	} else {
+		fmt.Printf("✅ %s %s passed in %dms\n", start.Format(time.RFC3339), name, result.DurationMs)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
+
+// authorized checks the token of a request, sent as a bearer token or in an
+// X-Webhook-Token header
+func (ts *triggerServer) authorized(r *http.Request) bool {
+	if ts.token == "" {
+		return true
+	}
+	token := r.Header.Get("X-Webhook-Token")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token = bearer
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(ts.token)) == 1
+}
+
+// script returns the file of a script name. Names are plain file names in the
+// scripts directory, with or without the .http extension.
+func (ts *triggerServer) script(name string) (string, error) {
+	if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid script name %q", name)
+	}
+	if filepath.Ext(name) != ".http" {
+		name += ".http"
+	}
+	filename := filepath.Join(ts.dir, name)
+	if info, err := os.Stat(filename); err != nil || info.IsDir() {
+		return "", fmt.Errorf("unknown script %q", strings.TrimSuffix(name, ".http"))
+	}
+	return filename, nil
+}
+
+// runListen implements the listen subcommand: it serves webhooks that run the
+// scripts of a directory, until interrupted. It returns the process exit code.
+func runListen(args []string) int {
+	fs := flag.NewFlagSet("listen", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "Address to listen on")
+	token := fs.String("token", os.Getenv("HTTPDSL_WEBHOOK_TOKEN"), "Require this token as a bearer token or X-Webhook-Token header (default $HTTPDSL_WEBHOOK_TOKEN)")
+	var options runnerOptions
+	options.register(fs)
+	fs.Usage = func() {
+		fmt.Println("Usage: http-runner listen [options] <scripts directory>")
+		fmt.Println()
+		fmt.Println("Runs a script of the directory for every POST /run/<name>, with the")
+		fmt.Println("request body in $TRIGGER_BODY (and parsed in $TRIGGER when it is JSON).")
+		fmt.Println("Answers 200 when the script passes and 422 when it fails.")
+		fmt.Println()
+		fmt.Println("Options:")
+		fmt.Println("  --addr <addr>     Address to listen on (default :8080)")
+		fmt.Println("  --token <secret>  Require a bearer token or X-Webhook-Token header")
+		options.usage()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Println("❌ Error: No scripts directory specified")
+		fs.Usage()
+		return 1
+	}
+	dir := fs.Arg(0)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		fmt.Printf("❌ Error: %s is not a directory\n", dir)
+		return 1
+	}
+	if err := options.load(); err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		return 1
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           &triggerServer{dir: dir, token: *token, options: &options},
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	fmt.Printf("👂 Listening on %s for POST /run/<name> (scripts in %s)\n", *addr, dir)
+	if *token == "" {
+		fmt.Println("⚠️  No --token set: anyone who can reach the port can run the scripts")
+	}
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("❌ Error: %v\n", err)
+		return 1
+	}
+	fmt.Println("\n🛑 Stopped")
+	return 0
+}
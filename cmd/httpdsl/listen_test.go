@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// triggerFixture starts a server recording the bodies posted to it, and
+// returns a trigger server for scripts that post their trigger variables
+// there
+func triggerFixture(t *testing.T) (*triggerServer, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var received []string
+	echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, string(body))
+		mu.Unlock()
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(echo.Close)
+
+	dir := t.TempDir()
+	scripts := map[string]string{
+		"raw.http":    `POST "$echo/raw" body "$TRIGGER_BODY"`,
+		"json.http":   "POST \"$echo/json\" body \"$TRIGGER_BODY\"\nPOST \"$echo/json\" body \"${TRIGGER.user}\"",
+		"broken.http": "POST \"$echo/fail\" body \"$TRIGGER_BODY\"\nassert status 200",
+	}
+	for name, script := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub.http"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	options := &runnerOptions{vars: varFlags{"echo=" + echo.URL}}
+	ts := &triggerServer{dir: dir, token: "s3cret", options: options}
+	return ts, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		bodies := received
+		received = nil
+		return bodies
+	}
+}
+
+func TestTriggerServer(t *testing.T) {
+	ts, received := triggerFixture(t)
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		header     string // Header sending the token, "" for none
+		token      string
+		body       string
+		wantStatus int
+		wantPassed bool
+		wantBodies []string
+	}{
+		{"raw body", "POST", "/run/raw", "Authorization", "Bearer s3cret", "hello", 200, true, []string{"hello"}},
+		{"with extension", "POST", "/run/raw.http", "X-Webhook-Token", "s3cret", "x", 200, true, []string{"x"}},
+		{"json body", "POST", "/run/json", "Authorization", "Bearer s3cret", `{"user":"ana"}`, 200, true, []string{`{"user":"ana"}`, "ana"}},
+		{"failing script", "POST", "/run/broken", "X-Webhook-Token", "s3cret", "boom", 422, false, []string{"boom"}},
+		{"wrong token", "POST", "/run/raw", "Authorization", "Bearer nope", "", 401, false, nil},
+		{"missing token", "POST", "/run/raw", "", "", "", 401, false, nil},
+		{"token without bearer", "POST", "/run/raw", "Authorization", "s3cret", "", 401, false, nil},
+		{"not POST", "GET", "/run/raw", "Authorization", "Bearer s3cret", "", 405, false, nil},
+		{"unknown script", "POST", "/run/missing", "Authorization", "Bearer s3cret", "", 404, false, nil},
+		{"directory", "POST", "/run/sub", "Authorization", "Bearer s3cret", "", 404, false, nil},
+		{"path in name", "POST", "/run/..%2Fraw", "Authorization", "Bearer s3cret", "", 404, false, nil},
+		{"hidden name", "POST", "/run/.raw", "Authorization", "Bearer s3cret", "", 404, false, nil},
+		{"other path", "POST", "/raw", "Authorization", "Bearer s3cret", "", 404, false, nil},
+		{"too large", "POST", "/run/raw", "Authorization", "Bearer s3cret", strings.Repeat("x", maxTriggerBody+1), 413, false, nil},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.token)
+		}
+		rec := httptest.NewRecorder()
+		ts.ServeHTTP(rec, req)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+		}
+		if bodies := received(); !reflect.DeepEqual(bodies, tt.wantBodies) {
+			t.Errorf("%s: the script posted %q, want %q", tt.name, bodies, tt.wantBodies)
+		}
+		if rec.Code != http.StatusOK && rec.Code != http.StatusUnprocessableEntity {
+			continue
+		}
+		var result triggerResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Errorf("%s: invalid answer %q: %v", tt.name, rec.Body.String(), err)
+			continue
+		}
+		if result.Passed != tt.wantPassed || (result.Error == "") != tt.wantPassed {
+			t.Errorf("%s: unexpected result %+v", tt.name, result)
+		}
+	}
+}
+
+func TestTriggerServerWithoutToken(t *testing.T) {
+	ts, received := triggerFixture(t)
+	ts.token = ""
+
+	rec := httptest.NewRecorder()
+	ts.ServeHTTP(rec, httptest.NewRequest("POST", "/run/raw", strings.NewReader("open")))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected the script to run without a token, got %d", rec.Code)
+	}
+	if bodies := received(); !reflect.DeepEqual(bodies, []string{"open"}) {
+		t.Errorf("Unexpected bodies %q", bodies)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"httpdsl/core"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -16,6 +17,9 @@ type HTTPRunner struct {
 	stopOnFail bool
 	dryRun     bool
 	validate   bool
+	quiet      bool
+	progress   bool
+	output     string // Output format: text, or github for workflow annotations
 	scriptArgs []string
 }
 
@@ -42,52 +46,59 @@ func (hr *HTTPRunner) SetScriptArguments(args []string) {
 	hr.dsl.SetVariable("ARGC", len(args))
 }
 
-// RunFile executes an HTTP DSL script file
-func (hr *HTTPRunner) RunFile(filename string) error {
-	content, err := os.ReadFile(filename)
-	if err != nil {
-		return fmt.Errorf("cannot read file %s: %w", filename, err)
-	}
-
-	script := string(content)
-
+// Run executes a script; filename is where it came from, for messages
+func (hr *HTTPRunner) Run(filename, script string) error {
 	if hr.validate {
 		fmt.Printf("🔍 Validating script: %s\n", filename)
-		return hr.validateScript(script)
+		return hr.validateScript(filename, script)
 	}
 
-	fmt.Printf("\n🚀 Executing HTTP Script: %s\n", filename)
-	fmt.Println(strings.Repeat("═", 60))
+	if !hr.quiet {
+		fmt.Printf("\n🚀 Executing HTTP Script: %s\n", filename)
+		fmt.Println(strings.Repeat("═", 60))
+	}
 
 	start := time.Now()
 
 	if hr.dryRun {
 		fmt.Println("🔍 DRY RUN - Script would execute:")
 		fmt.Println(hr.formatScript(script))
-		return nil
+		return hr.validateScript(filename, script)
+	}
+
+	var live *progress
+	if hr.progress {
+		live = newProgress(hr.dsl.StatementLines(script))
+		hr.dsl.OnEvent(live.handle)
 	}
 
-	// Use ParseWithBlockSupport for full block support
-	result, err := hr.dsl.ParseWithBlockSupport(script)
+	// Run the script with full block support, collecting typed step results
+	steps, err := hr.dsl.RunScript(script)
+	if live != nil {
+		live.stop()
+	}
 	if err != nil {
+		if hr.output == "github" {
+			for _, annotation := range stepAnnotations(filename, steps, err) {
+				fmt.Println(annotation)
+			}
+		}
 		return fmt.Errorf("execution failed: %w", err)
 	}
 
-	// Show any output from the execution (like print statements)
-	if results, ok := result.([]interface{}); ok {
-		for _, res := range results {
-			if res != nil {
-				// Check if it's a print output (string)
-				if str, ok := res.(string); ok {
-					// Print outputs from the DSL (like print statements)
-					// Filter out internal status messages
-					if !strings.HasPrefix(str, "HTTP ") &&
-						!strings.HasPrefix(str, "Variable set:") &&
-						!strings.HasPrefix(str, "Condition evaluated") {
-						fmt.Println(str)
-					}
-				}
+	// Show the output of statements meant for the reader; requests and
+	// variable changes are summarized in verbose mode only
+	for _, step := range steps {
+		if hr.quiet || step.Kind == "request" || step.Kind == "variable" {
+			continue
+		}
+		switch output := step.Output.(type) {
+		case string:
+			if output != "" {
+				fmt.Println(output)
 			}
+		case fmt.Stringer:
+			fmt.Println(output)
 		}
 	}
 
@@ -97,8 +108,12 @@ func (hr *HTTPRunner) RunFile(filename string) error {
 		fmt.Printf("\n📊 Execution Summary:\n")
 		fmt.Printf("   Duration: %v\n", duration)
 		fmt.Printf("   Variables: %v\n", hr.dsl.GetVariables())
-		if results, ok := result.([]interface{}); ok {
-			fmt.Printf("   Steps executed: %d\n", len(results))
+		fmt.Printf("   Steps executed: %d\n", len(steps))
+		for _, step := range steps {
+			if step.Request != nil {
+				fmt.Printf("   line %d: %s %s -> %v (%v)\n", step.Line, step.Request.Method, step.Request.URL,
+					step.Response["status"], step.Duration.Round(time.Millisecond))
+			}
 		}
 	}
 
@@ -107,14 +122,19 @@ func (hr *HTTPRunner) RunFile(filename string) error {
 }
 
 // validateScript validates the script syntax without execution
-func (hr *HTTPRunner) validateScript(script string) error {
+func (hr *HTTPRunner) validateScript(filename, script string) error {
 	fmt.Println("Validating syntax...")
 
-	// Try parsing without execution
-	_, err := hr.dsl.ParseWithBlockSupport(script)
-	if err != nil {
-		fmt.Printf("❌ Validation failed: %v\n", err)
-		return err
+	// Statements are only parsed, never executed
+	problems := hr.dsl.Validate(script)
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Printf("❌ %s\n", problem.Error())
+			if hr.output == "github" {
+				fmt.Println(githubAnnotation(filename, problem.Line, problem.Column, "Syntax error", problem.Message))
+			}
+		}
+		return fmt.Errorf("validation failed with %d error(s)", len(problems))
 	}
 
 	fmt.Println("✅ Script is valid")
@@ -136,15 +156,57 @@ func (hr *HTTPRunner) formatScript(script string) string {
 }
 
 func main() {
+	// Subcommands are dispatched before the runner flags are parsed
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "init":
+			os.Exit(runInit(os.Args[2:]))
+		case "fmt":
+			os.Exit(runFmt(os.Args[2:]))
+		case "explain":
+			os.Exit(runExplain(os.Args[2:]))
+		case "docs":
+			os.Exit(runDocs(os.Args[2:]))
+		case "daemon":
+			os.Exit(runDaemon(os.Args[2:]))
+		case "listen":
+			os.Exit(runListen(os.Args[2:]))
+		}
+	}
+
 	var (
 		verbose    = flag.Bool("v", false, "Verbose output with execution details")
 		verbose2   = flag.Bool("verbose", false, "Verbose output with execution details")
+		debugMode  = flag.Bool("vv", false, "Verbose output plus full request and response dumps")
+		wireTrace  = flag.Bool("trace", false, "Print requests and responses as sent and received, like curl -v")
+		quiet      = flag.Bool("q", false, "Only show failures and the summary")
+		quiet2     = flag.Bool("quiet", false, "Only show failures and the summary")
 		stopOnFail = flag.Bool("stop", false, "Stop execution on first failure")
 		dryRun     = flag.Bool("dry-run", false, "Show what would be executed without running")
 		validate   = flag.Bool("validate", false, "Validate script syntax only")
+		pluginPath = flag.String("plugin", "", "Load custom commands from a Go plugin (.so)")
+		noProgress = flag.Bool("no-progress", false, "Do not report progress while the script runs")
+		varFile    = flag.String("var-file", "", "Set script variables from a key=value or JSON file")
+		envName    = flag.String("env", "", "Use a named environment from the config file")
+		configPath = flag.String("config", "", "Config file with environments (default: httpdsl.yaml, httpdsl.yml or .httpdslrc)")
+		cookieJar  = flag.String("cookie-jar", "", "Load cookies from this file before the run and save them after")
+		updateSnap = flag.Bool("update-snapshots", false, "Overwrite stored snapshots with the current responses")
+		dataFile   = flag.String("data", "", "Run the script once per row of a CSV file, with the row in $row")
+		denyPriv   = flag.Bool("deny-private", false, "Refuse requests to loopback, private and link-local addresses")
+		noEnvProxy = flag.Bool("no-env-proxy", false, "Ignore HTTP_PROXY, HTTPS_PROXY and NO_PROXY and connect directly")
+		seed       = flag.String("seed", "", "Seed the random functions, to replay a run that used random data")
+		output     = flag.String("output", "text", "Output format: text, or github for GitHub Actions annotations of failures")
+		inline     = flag.String("e", "", "Run this script instead of a file; \\n separates statements")
+		shareSess  = flag.Bool("share-session", false, "Let each of several scripts see the variables and cookies the previous left")
 		help       = flag.Bool("h", false, "Show help")
 		help2      = flag.Bool("help", false, "Show help")
+		vars       varFlags
+		allowHosts listFlags
+		denyHosts  listFlags
 	)
+	flag.Var(&vars, "var", "Set a script variable as key=value (repeatable)")
+	flag.Var(&allowHosts, "allow-host", "Only allow requests to hosts matching this pattern (repeatable)")
+	flag.Var(&denyHosts, "deny-host", "Refuse requests to hosts matching this pattern (repeatable)")
 
 	flag.Parse()
 
@@ -153,25 +215,151 @@ func main() {
 		return
 	}
 
-	if flag.NArg() == 0 {
+	if flag.NArg() == 0 && *inline == "" {
 		fmt.Println("❌ Error: No script file specified")
 		showUsage()
 		os.Exit(1)
 	}
 
-	verboseMode := *verbose || *verbose2
+	verboseMode := *verbose || *verbose2 || *debugMode
+	quietMode := *quiet || *quiet2
+	if quietMode && verboseMode {
+		fmt.Println("❌ Error: --quiet cannot be combined with -v or -vv")
+		os.Exit(1)
+	}
+
+	if !outputFormats[*output] {
+		fmt.Printf("❌ Error: unknown output format %q, expected text or github\n", *output)
+		os.Exit(1)
+	}
+
 	runner := NewHTTPRunner(verboseMode, *stopOnFail, *dryRun, *validate)
+	runner.output = *output
+	if *seed != "" {
+		n, err := strconv.ParseInt(*seed, 10, 64)
+		if err != nil {
+			fmt.Printf("❌ Error: invalid --seed %q, expected an integer\n", *seed)
+			os.Exit(1)
+		}
+		runner.dsl.SetRandomSeed(n)
+	}
+	runner.quiet = quietMode
+	runner.progress = !*noProgress && !quietMode
+
+	engine := runner.dsl.GetEngine()
+	switch {
+	case quietMode:
+		engine.SetLogLevel(core.LogError)
+	case *debugMode:
+		engine.SetLogLevel(core.LogDebug)
+		engine.SetDebug(true)
+	}
+	engine.SetWireTrace(*wireTrace)
+
+	if *pluginPath != "" {
+		if err := runner.dsl.LoadPlugin(*pluginPath); err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
-	filename := flag.Arg(0)
+	// Environment settings come first so variables from flags override them
+	if *configPath == "" {
+		*configPath = findConfig()
+	}
+	if *configPath != "" {
+		config, err := loadConfig(*configPath)
+		if err == nil {
+			err = runner.UseEnvironment(config, *envName)
+		}
+		if err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+	} else if *envName != "" {
+		fmt.Printf("❌ Error: --env %s needs a config file (%s)\n", *envName, strings.Join(configFiles, ", "))
+		os.Exit(1)
+	}
+
+	// The script comes from -e, from stdin with -, or from files; a single
+	// script is read once, as stdin cannot be read again for every row of
+	// --data
+	scripts, scriptArgs := []string{inlineName}, flag.Args()
+	var script string
+	if *inline != "" {
+		script = inlineScript(*inline)
+	} else {
+		var err error
+		if scripts, scriptArgs, err = splitScripts(scriptArgs); err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(scripts) == 1 {
+			if script, err = readScript(scripts[0]); err != nil {
+				fmt.Printf("❌ Error: %v\n", err)
+				os.Exit(1)
+			}
+			if scripts[0] == "-" {
+				scripts[0] = stdinName
+			}
+		}
+	}
 
 	// Pass command-line arguments to the DSL engine
-	scriptArgs := flag.Args()[1:] // Get all args after the script filename
 	runner.SetScriptArguments(scriptArgs)
 
-	if err := runner.RunFile(filename); err != nil {
+	if err := runner.SetVariables(*varFile, vars); err != nil {
 		fmt.Printf("❌ Error: %v\n", err)
 		os.Exit(1)
 	}
+
+	runner.SetHostPolicy(allowHosts, denyHosts, *denyPriv)
+	if *noEnvProxy {
+		runner.dsl.GetEngine().ClearProxy()
+	}
+
+	if *cookieJar != "" {
+		if err := runner.LoadCookieJar(*cookieJar); err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	runner.dsl.SetUpdateSnapshots(*updateSnap)
+
+	data := *dataFile
+	if *dryRun || *validate {
+		data = ""
+	}
+	var runErr error
+	switch {
+	case len(scripts) > 1:
+		runErr = runner.RunFiles(scripts, data, *shareSess)
+	case data != "":
+		runner.useSnapshotDir(scripts[0])
+		runErr = runner.RunData(scripts[0], script, data)
+	default:
+		runner.useSnapshotDir(scripts[0])
+		runErr = runner.Run(scripts[0], script)
+	}
+
+	// Cookies are saved even when the script fails, so a login that
+	// succeeded before the failure is kept
+	if *cookieJar != "" && !*dryRun && !*validate {
+		if err := runner.SaveCookieJar(*cookieJar); err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if runErr != nil {
+		fmt.Printf("❌ Error: %v\n", runErr)
+		if runner.dsl.UsedRandom() {
+			seed := runner.dsl.RandomSeed()
+			fmt.Printf("🎲 The script used random data; replay it with --seed %d\n", seed)
+		}
+		os.Exit(1)
+	}
 }
 
 func showHelp() {
@@ -182,11 +370,39 @@ func showHelp() {
 	fmt.Println()
 	fmt.Println("Options:")
 	fmt.Println("  -v, --verbose     Show detailed execution information")
+	fmt.Println("  -vv               Also dump every request and response")
+	fmt.Println("  --trace           Print requests and responses with > and < like curl -v")
+	fmt.Println("  -q, --quiet       Only show failures and the summary")
 	fmt.Println("  --stop            Stop execution on first failure")
 	fmt.Println("  --dry-run         Show what would be executed without running")
 	fmt.Println("  --validate        Validate script syntax only")
+	fmt.Println("  --plugin <file>   Load custom commands from a Go plugin (.so)")
+	fmt.Println("  --no-progress     Do not report progress while the script runs")
+	fmt.Println("  --var key=value   Set a script variable (repeatable)")
+	fmt.Println("  --var-file <file> Set script variables from a key=value or JSON file")
+	fmt.Println("  --env <name>      Use a named environment from the config file")
+	fmt.Println("  --config <file>   Config file (default: httpdsl.yaml, httpdsl.yml or .httpdslrc)")
+	fmt.Println("  --cookie-jar <file> Load cookies before the run and save them after")
+	fmt.Println("  --update-snapshots Overwrite stored snapshots with the current responses")
+	fmt.Println("  --data <file>     Run the script once per CSV row, with the row in $row")
+	fmt.Println("  --allow-host <pattern> Only allow hosts like *.staging.example.com (repeatable)")
+	fmt.Println("  --deny-host <pattern>  Refuse hosts matching the pattern (repeatable)")
+	fmt.Println("  --deny-private    Refuse loopback, private and link-local addresses")
+	fmt.Println("  --no-env-proxy    Ignore HTTP_PROXY, HTTPS_PROXY and NO_PROXY")
+	fmt.Println("  --seed <n>        Seed random(), random_string(), random_email() and uuid()")
+	fmt.Println("  --output github   Also print failures as GitHub Actions annotations")
+	fmt.Println("  -e <script>       Run a script given on the command line; \\n separates statements")
+	fmt.Println("  --share-session   Keep variables and cookies from one script to the next")
 	fmt.Println("  -h, --help        Show this help message")
 	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  init [--force] [dir]         Create a starter project: config, smoke test, fixtures")
+	fmt.Println("  fmt [--check] [-w] <files>   Format scripts in canonical layout")
+	fmt.Println("  explain <file>               Print the parse tree of each statement")
+	fmt.Println("  docs [keyword]               Print the syntax, options and examples of a keyword")
+	fmt.Println("  daemon --schedule <cron> <file> Run a script on a schedule as a monitor")
+	fmt.Println("  listen [--addr :8080] <dir>  Run a script of dir for every POST /run/<name>")
+	fmt.Println()
 	fmt.Println("Features supported:")
 	fmt.Println("  ✅ All HTTP methods (GET, POST, PUT, DELETE, etc.)")
 	fmt.Println("  ✅ Multiple headers per request")
@@ -205,8 +421,16 @@ func showHelp() {
 	fmt.Println("  http-runner --validate script.http      # Validate syntax only")
 	fmt.Println("  http-runner --dry-run script.http       # Show execution plan")
 	fmt.Println("  http-runner script.http url token       # Pass arguments to script")
+	fmt.Println("  http-runner --var base_url=http://localhost:8080 script.http")
+	fmt.Println("  http-runner --env staging script.http   # Use the staging environment")
+	fmt.Println("  http-runner --data users.csv login.http # Run once per user")
+	fmt.Println("  http-runner fmt --check *.http          # Check formatting in CI")
+	fmt.Println(`  http-runner -e 'GET "https://example.com" \n assert status 200'`)
+	fmt.Println("  generate-script | http-runner -          # Read the script from stdin")
+	fmt.Println("  http-runner 'tests/**/*.http'           # Run every script under tests")
 }
 
 func showUsage() {
-	fmt.Println("Usage: http-runner [options] <script.http> [script arguments...]")
+	fmt.Println("Usage: http-runner [options] <script.http | -> [more.http | 'pattern'...] [script arguments...]")
+	fmt.Println("       http-runner [options] -e '<script>' [script arguments...]")
 }
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"httpdsl/core"
+	"os"
+	"strings"
+)
+
+// runnerOptions are the flags of the subcommands that run scripts many times,
+// daemon and listen, and prepare a fresh runner for every run
+type runnerOptions struct {
+	varFile    string
+	envName    string
+	configPath string
+	vars       varFlags
+	config     *Config
+}
+
+// register adds the flags to a subcommand's flag set
+func (o *runnerOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.varFile, "var-file", "", "Set script variables from a key=value or JSON file")
+	fs.StringVar(&o.envName, "env", "", "Use a named environment from the config file")
+	fs.StringVar(&o.configPath, "config", "", "Config file with environments (default: httpdsl.yaml, httpdsl.yml or .httpdslrc)")
+	fs.Var(&o.vars, "var", "Set a script variable as key=value (repeatable)")
+}
+
+// usage prints the help lines of the flags
+func (o *runnerOptions) usage() {
+	fmt.Println("  --var key=value   Set a script variable (repeatable)")
+	fmt.Println("  --var-file <file> Set script variables from a key=value or JSON file")
+	fmt.Println("  --env <name>      Use a named environment from the config file")
+	fmt.Println("  --config <file>   Config file (default: httpdsl.yaml, httpdsl.yml or .httpdslrc)")
+}
+
+// load reads the config file once the flags are parsed, and checks that a
+// runner can be prepared so mistakes show at start rather than on every run
+func (o *runnerOptions) load() error {
+	if o.configPath == "" {
+		o.configPath = findConfig()
+	}
+	if o.configPath != "" {
+		config, err := loadConfig(o.configPath)
+		if err != nil {
+			return err
+		}
+		o.config = config
+	} else if o.envName != "" {
+		return fmt.Errorf("--env %s needs a config file (%s)", o.envName, strings.Join(configFiles, ", "))
+	}
+	_, err := o.newRunner()
+	return err
+}
+
+// newRunner returns a runner with the environment and variables applied.
+// Every run gets a new one, so no variables or cookies leak between runs.
+func (o *runnerOptions) newRunner() (*HTTPRunner, error) {
+	runner := NewHTTPRunner(false, false, false, false)
+	runner.dsl.GetEngine().SetLogLevel(core.LogError)
+	if o.config != nil {
+		if err := runner.UseEnvironment(o.config, o.envName); err != nil {
+			return nil, err
+		}
+	}
+	return runner, runner.SetVariables(o.varFile, o.vars)
+}
+
+// validateFile checks that a script file can be read and parses, since a
+// script that cannot parse would fail on every run
+func validateFile(filename string) error {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("cannot read file %s: %w", filename, err)
+	}
+	if problems := core.NewHTTPDSLv3().Validate(string(content)); len(problems) > 0 {
+		messages := make([]string, len(problems))
+		for i, problem := range problems {
+			messages[i] = problem.Error()
+		}
+		return fmt.Errorf("%s is not valid:\n  %s", filename, strings.Join(messages, "\n  "))
+	}
+	return nil
+}
+
+// runFile runs a script once with a new runner and extra variables. The file
+// is read on every run, so edits apply without restarting.
+func (o *runnerOptions) runFile(filename string, variables map[string]interface{}) error {
+	runner, err := o.newRunner()
+	if err != nil {
+		return err
+	}
+	for name, value := range variables {
+		runner.dsl.SetVariable(name, value)
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("cannot read file %s: %w", filename, err)
+	}
+	if _, err := runner.dsl.RunScript(string(content)); err != nil {
+		return fmt.Errorf("execution failed: %w", err)
+	}
+	return nil
+}
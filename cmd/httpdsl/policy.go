@@ -0,0 +1,34 @@
+package main
+
+import "strings"
+
+// listFlags collects a repeatable flag, splitting comma-separated values
+type listFlags []string
+
+func (l *listFlags) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlags) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// SetHostPolicy limits the hosts the script may reach before it runs, so a
+// CI job cannot hit production or metadata services whatever the script says
+func (hr *HTTPRunner) SetHostPolicy(allow, deny []string, denyPrivate bool) {
+	engine := hr.dsl.GetEngine()
+	if len(allow) > 0 {
+		engine.AllowHosts(allow...)
+	}
+	if len(deny) > 0 {
+		engine.DenyHosts(deny...)
+	}
+	if denyPrivate {
+		engine.SetDenyPrivateNetworks(true)
+	}
+}
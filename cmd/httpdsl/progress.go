@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"httpdsl/core"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// spinnerFrames are drawn in turn while a script runs on a terminal
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// progress reports script execution as it happens. On a terminal it keeps a
+// single status line with a spinner; otherwise it prints one plain line per
+// finished request so CI logs show where a long script is.
+type progress struct {
+	mu      sync.Mutex
+	out     io.Writer
+	tty     bool
+	steps   []int     // Lines of the top-level statements, each one step
+	start   time.Time // When the script started
+	line    int       // Line being executed
+	current string    // Request or statement being executed
+	frame   int
+	done    chan struct{}
+	stopped sync.WaitGroup
+}
+
+// newProgress starts reporting to stderr the progress of a script whose steps
+// start at the given lines, in order
+func newProgress(steps []int) *progress {
+	p := &progress{
+		out:   os.Stderr,
+		tty:   isTerminal(os.Stderr),
+		steps: steps,
+		start: time.Now(),
+		done:  make(chan struct{}),
+	}
+
+	if p.tty {
+		// Keep the spinner and elapsed time moving during slow requests
+		p.stopped.Add(1)
+		go func() {
+			defer p.stopped.Done()
+			ticker := time.NewTicker(100 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					p.mu.Lock()
+					p.draw()
+					p.mu.Unlock()
+				case <-p.done:
+					return
+				}
+			}
+		}()
+	}
+	return p
+}
+
+// handle updates the progress from an execution event
+func (p *progress) handle(e core.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.line = e.Line
+	switch e.Type {
+	case core.EventStatementStart:
+		p.current = e.Source
+	case core.EventRequestSent:
+		p.current = e.Method + " " + e.URL
+	case core.EventResponseReceived:
+		if !p.tty {
+			status := fmt.Sprint(e.Status)
+			if e.Err != nil {
+				status = "failed"
+			}
+			fmt.Fprintf(p.out, "[step %d/%d, line %d] %s %s -> %s (%v, %v elapsed)\n", p.step(), len(p.steps),
+				p.line, e.Method, e.URL, status, e.Duration.Round(time.Millisecond), p.elapsed())
+		}
+	}
+
+	if p.tty {
+		p.draw()
+	}
+}
+
+// draw rewrites the status line; the caller holds the lock
+func (p *progress) draw() {
+	p.frame = (p.frame + 1) % len(spinnerFrames)
+	current := p.current
+	if runes := []rune(current); len(runes) > 60 {
+		current = string(runes[:57]) + "..."
+	}
+	fmt.Fprintf(p.out, "\r\033[K%s step %d/%d · line %d · %v · %s", spinnerFrames[p.frame],
+		p.step(), len(p.steps), p.line, p.elapsed(), current)
+}
+
+// step is the number of the step being executed: the steps starting at or
+// before its line, since a nested statement reports the line it is on
+func (p *progress) step() int {
+	return sort.SearchInts(p.steps, p.line+1)
+}
+
+// elapsed is the time since the script started, rounded for display
+func (p *progress) elapsed() time.Duration {
+	return time.Since(p.start).Round(100 * time.Millisecond)
+}
+
+// stop ends the progress display and clears the status line
+func (p *progress) stop() {
+	close(p.done)
+	p.stopped.Wait()
+	if p.tty {
+		p.mu.Lock()
+		fmt.Fprint(p.out, "\r\033[K")
+		p.mu.Unlock()
+	}
+}
+
+// isTerminal reports whether f is a character device such as a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
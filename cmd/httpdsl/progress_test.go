@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"httpdsl/core"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressStep(t *testing.T) {
+	p := &progress{steps: []int{2, 3, 6, 11}}
+	tests := []struct {
+		line, want int
+	}{
+		{0, 0},  // Before the first statement
+		{2, 1},  // On a step
+		{3, 2},  // On the next one
+		{7, 3},  // Inside the block that starts at line 6
+		{11, 4}, // The last step
+		{40, 4}, // Past the end
+	}
+	for _, tt := range tests {
+		p.line = tt.line
+		if got := p.step(); got != tt.want {
+			t.Errorf("step() at line %d = %d, want %d", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestProgressPlainLines(t *testing.T) {
+	var out bytes.Buffer
+	p := &progress{out: &out, steps: []int{1, 4}, start: time.Now()}
+
+	p.handle(core.Event{Type: core.EventStatementStart, Line: 4, Source: `GET "http://x/a"`})
+	p.handle(core.Event{Type: core.EventResponseReceived, Line: 4, Method: "GET", URL: "http://x/a", Status: 200})
+	p.handle(core.Event{Type: core.EventResponseReceived, Line: 4, Method: "GET", URL: "http://x/b", Err: errors.New("refused")})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected a line per response, got %q", out.String())
+	}
+	if !strings.HasPrefix(lines[0], "[step 2/2, line 4] GET http://x/a -> 200 (") {
+		t.Errorf("Unexpected line %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "[step 2/2, line 4] GET http://x/b -> failed (") {
+		t.Errorf("Unexpected line %q", lines[1])
+	}
+}
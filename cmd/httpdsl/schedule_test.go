@@ -0,0 +1,112 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// bits returns the set holding values
+func bits(values ...int) uint64 {
+	var set uint64
+	for _, v := range values {
+		set |= 1 << v
+	}
+	return set
+}
+
+// span returns the set holding lo to hi, every step
+func span(lo, hi, step int) uint64 {
+	var set uint64
+	for v := lo; v <= hi; v += step {
+		set |= 1 << v
+	}
+	return set
+}
+
+func TestParseCronField(t *testing.T) {
+	tests := []struct {
+		field    string
+		min, max int
+		want     uint64
+		wantErr  bool
+	}{
+		{"*", 0, 59, span(0, 59, 1), false},
+		{"5", 0, 59, bits(5), false},
+		{"1-5", 0, 59, span(1, 5, 1), false},
+		{"*/15", 0, 59, bits(0, 15, 30, 45), false},
+		{"0-30/10", 0, 59, bits(0, 10, 20, 30), false},
+		{"10/20", 0, 59, bits(10, 30, 50), false},
+		{"*/2", 1, 31, span(1, 31, 2), false},
+		{"1,3,5-7", 0, 7, bits(1, 3, 5, 6, 7), false},
+		{"8-18/4,22", 0, 23, bits(8, 12, 16, 22), false},
+		{"60", 0, 59, 0, true},
+		{"0", 1, 31, 0, true},
+		{"5-1", 0, 59, 0, true},
+		{"1-60", 0, 59, 0, true},
+		{"*/0", 0, 59, 0, true},
+		{"*/x", 0, 59, 0, true},
+		{"a", 0, 59, 0, true},
+		{"1-b", 0, 59, 0, true},
+		{"", 0, 59, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseCronField(tt.field, tt.min, tt.max)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCronField(%q) error = %v, wantErr %v", tt.field, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseCronField(%q) = %b, want %b", tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	at := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	// January 1st 2026 is a Thursday
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"step minutes", "*/15 * * * *", at(2026, 1, 1, 10, 7), at(2026, 1, 1, 10, 15)},
+		{"strictly after", "0 * * * *", at(2026, 1, 1, 10, 0), at(2026, 1, 1, 11, 0)},
+		{"weekdays skip the weekend", "0 9 * * 1-5", at(2026, 1, 2, 10, 0), at(2026, 1, 5, 9, 0)},
+		{"sunday as 7", "0 0 * * 7", at(2026, 1, 1, 0, 0), at(2026, 1, 4, 0, 0)},
+		{"day of month only", "0 0 13 * *", at(2026, 1, 1, 0, 0), at(2026, 1, 13, 0, 0)},
+		{"day of week only", "0 0 * * 5", at(2026, 1, 1, 0, 0), at(2026, 1, 2, 0, 0)},
+		{"both days, weekday first", "0 0 13 * 5", at(2026, 1, 1, 0, 0), at(2026, 1, 2, 0, 0)},
+		{"both days, day of month first", "0 0 13 * 5", at(2026, 1, 9, 12, 0), at(2026, 1, 13, 0, 0)},
+		{"stepped day of month counts as *", "0 0 */10 * 1", at(2026, 1, 21, 1, 0), at(2026, 5, 11, 0, 0)},
+		{"hour range with step", "0 8-18/4 * * *", at(2026, 1, 1, 12, 30), at(2026, 1, 1, 16, 0)},
+		{"hour range rolls to the next day", "0 8-18/4 * * *", at(2026, 1, 1, 18, 0), at(2026, 1, 2, 8, 0)},
+		{"month without the day", "30 23 31 * *", at(2026, 1, 31, 23, 45), at(2026, 3, 31, 23, 30)},
+		{"month range with step", "0 0 1 */3 *", at(2026, 2, 15, 0, 0), at(2026, 4, 1, 0, 0)},
+		{"year rollover", "0 0 1 1 *", at(2026, 6, 1, 0, 0), at(2027, 1, 1, 0, 0)},
+		{"leap day", "0 0 29 2 *", at(2026, 3, 1, 0, 0), at(2028, 2, 29, 0, 0)},
+		{"never", "0 0 30 2 *", at(2026, 1, 1, 0, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := parseSchedule(tt.expr)
+		if err != nil {
+			t.Fatalf("%s: parseSchedule(%q) failed: %v", tt.name, tt.expr, err)
+		}
+		if got := s.next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%s: next(%s) of %q = %s, want %s", tt.name, tt.from, tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, expr := range []string{"* * * *", "* * * * * *", "61 * * * *", "* 24 * * *", "* * * 13 *", "* * * * 8", "@never"} {
+		if _, err := parseSchedule(expr); err == nil {
+			t.Errorf("Expected %q to be rejected", expr)
+		}
+	}
+	if _, err := parseSchedule("@daily"); err != nil {
+		t.Errorf("Expected @daily to be accepted, got %v", err)
+	}
+}
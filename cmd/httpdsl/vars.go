@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// varFlags collects repeated --var key=value flags in the order given
+type varFlags []string
+
+func (v *varFlags) String() string {
+	return strings.Join(*v, ",")
+}
+
+func (v *varFlags) Set(value string) error {
+	if _, _, err := splitVar(value); err != nil {
+		return err
+	}
+	*v = append(*v, value)
+	return nil
+}
+
+// splitVar splits a key=value pair, dropping a leading $ from the key
+func splitVar(pair string) (string, string, error) {
+	key, value, ok := strings.Cut(pair, "=")
+	key = strings.TrimPrefix(strings.TrimSpace(key), "$")
+	if !ok || key == "" || strings.ContainsAny(key, " \t") {
+		return "", "", fmt.Errorf("invalid variable %q, expected key=value", pair)
+	}
+	return key, value, nil
+}
+
+// loadVarFile reads variables from a file. A file holding a JSON object sets
+// one variable per field, keeping numbers, booleans and nested values.
+// Otherwise each line is a key=value pair; blank lines and lines starting
+// with # are skipped, and values may be wrapped in matching quotes.
+func loadVarFile(filename string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read variable file %s: %w", filename, err)
+	}
+
+	if trimmed := strings.TrimSpace(string(content)); strings.HasPrefix(trimmed, "{") {
+		var vars map[string]interface{}
+		if err := json.Unmarshal([]byte(trimmed), &vars); err != nil {
+			return nil, fmt.Errorf("variable file %s: %w", filename, err)
+		}
+		return vars, nil
+	}
+
+	vars := make(map[string]interface{})
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, err := splitVar(line)
+		if err != nil {
+			return nil, fmt.Errorf("variable file %s line %d: %w", filename, lineNum, err)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
+
+// SetVariables sets named DSL variables before the script runs. Variables
+// from the file are set first, so --var flags override them.
+func (hr *HTTPRunner) SetVariables(varFile string, vars []string) error {
+	if varFile != "" {
+		fileVars, err := loadVarFile(varFile)
+		if err != nil {
+			return err
+		}
+		for key, value := range fileVars {
+			hr.dsl.SetVariable(key, value)
+		}
+	}
+
+	for _, pair := range vars {
+		key, value, err := splitVar(pair)
+		if err != nil {
+			return err
+		}
+		hr.dsl.SetVariable(key, value)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitVar(t *testing.T) {
+	tests := []struct {
+		pair, key, value string
+		wantErr          bool
+	}{
+		{"user=admin", "user", "admin", false},
+		{"$user=admin", "user", "admin", false},
+		{" user =admin", "user", "admin", false},
+		{"query=a=b", "query", "a=b", false},
+		{"empty=", "empty", "", false},
+		{"user", "", "", true},
+		{"=admin", "", "", true},
+		{"$=admin", "", "", true},
+		{"two words=x", "", "", true},
+	}
+	for _, tt := range tests {
+		key, value, err := splitVar(tt.pair)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("splitVar(%q) error = %v, wantErr %v", tt.pair, err, tt.wantErr)
+			continue
+		}
+		if key != tt.key || value != tt.value {
+			t.Errorf("splitVar(%q) = %q, %q, want %q, %q", tt.pair, key, value, tt.key, tt.value)
+		}
+	}
+}
+
+// writeVarFile writes a variable file in a temporary directory and returns
+// its path
+func writeVarFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "vars")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadVarFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:    "json object",
+			content: ` {"user": "admin", "retries": 3, "debug": true, "tags": ["a"]}`,
+			want:    map[string]interface{}{"user": "admin", "retries": 3.0, "debug": true, "tags": []interface{}{"a"}},
+		},
+		{
+			name:    "invalid json",
+			content: `{"user": }`,
+			wantErr: "variable file",
+		},
+		{
+			name: "key=value lines",
+			content: `# Credentials
+user=admin
+
+$password = "s3cr=t"
+  token='abc'
+quote="unclosed
+mixed="x'
+empty=
+`,
+			want: map[string]interface{}{
+				"user": "admin", "password": "s3cr=t", "token": "abc",
+				"quote": `"unclosed`, "mixed": `"x'`, "empty": "",
+			},
+		},
+		{
+			name:    "invalid line",
+			content: "user=admin\n# comment\nno value here\n",
+			wantErr: "line 3: invalid variable",
+		},
+	}
+	for _, tt := range tests {
+		vars, err := loadVarFile(writeVarFile(t, tt.content))
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: loadVarFile error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: loadVarFile failed: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(vars, tt.want) {
+			t.Errorf("%s: loadVarFile = %v, want %v", tt.name, vars, tt.want)
+		}
+	}
+
+	if _, err := loadVarFile(filepath.Join(t.TempDir(), "missing")); err == nil || !strings.Contains(err.Error(), "cannot read variable file") {
+		t.Errorf("Expected a missing file to fail, got %v", err)
+	}
+}
+
+func TestSetVariables(t *testing.T) {
+	runner := NewHTTPRunner(false, false, false, false)
+	file := writeVarFile(t, "user=admin\nrole=viewer\n")
+	if err := runner.SetVariables(file, []string{"$role=editor", "extra=1"}); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"user": "admin", "role": "editor", "extra": "1"} {
+		if got, _ := runner.dsl.GetVariable(name); got != want {
+			t.Errorf("$%s = %v, want %q", name, got, want)
+		}
+	}
+
+	if err := runner.SetVariables("", []string{"broken"}); err == nil {
+		t.Error("Expected an invalid --var to fail")
+	}
+}
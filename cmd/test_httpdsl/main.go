@@ -15,7 +15,8 @@ func main() {
 	time.Sleep(100 * time.Millisecond) // Give server time to start
 
 	fmt.Println("HTTP DSL Examples")
-	fmt.Println("=================\n")
+	fmt.Println("=================")
+	fmt.Println()
 
 	// Create DSL instance
 	dsl := core.NewHTTPDSL()
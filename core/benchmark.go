@@ -0,0 +1,144 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// benchmarkStats summarizes the latencies of a benchmark run, in milliseconds
+type benchmarkStats struct {
+	requests    int
+	concurrency int
+	errors      int
+	latencies   map[string]float64 // min, avg, max, p50, p90, p95 and p99
+	duration    time.Duration
+}
+
+// benchmarkLatencies lists the latency statistics in the order they are shown
+var benchmarkLatencies = []string{"min", "avg", "max", "p50", "p90", "p95", "p99"}
+
+// errorRate is the percentage of requests that failed
+func (s *benchmarkStats) errorRate() float64 {
+	if s.requests == 0 {
+		return 0
+	}
+	return float64(s.errors) * 100 / float64(s.requests)
+}
+
+// stat returns a latency statistic or the error rate by name
+func (s *benchmarkStats) stat(name string) (float64, bool) {
+	if name == "error_rate" {
+		return s.errorRate(), true
+	}
+	value, ok := s.latencies[name]
+	return value, ok
+}
+
+// variable returns the statistics as the object stored in $benchmark
+func (s *benchmarkStats) variable() map[string]interface{} {
+	result := map[string]interface{}{
+		"requests":    s.requests,
+		"concurrency": s.concurrency,
+		"errors":      s.errors,
+		"error_rate":  s.errorRate(),
+		"rps":         float64(s.requests) / s.duration.Seconds(),
+	}
+	for _, name := range benchmarkLatencies {
+		result[name] = s.latencies[name]
+	}
+	return result
+}
+
+func (s *benchmarkStats) String() string {
+	text := fmt.Sprintf("Benchmark: %d requests, concurrency %d, %.1f req/s\n",
+		s.requests, s.concurrency, float64(s.requests)/s.duration.Seconds())
+	for _, name := range benchmarkLatencies {
+		text += fmt.Sprintf("  %-4s %.2fms\n", name, s.latencies[name])
+	}
+	return text + fmt.Sprintf("  errors %d (%.1f%%)", s.errors, s.errorRate())
+}
+
+// benchmark sends a request n times from concurrency workers at once and
+// summarizes the latencies. The request is built once, so its variables are
+// expanded a single time, and request hooks do not run for it. A request
+// fails when it gets no response or a status of 400 or more.
+func (hd *HTTPDSLv3) benchmark(request interface{}, n, concurrency int) (*benchmarkStats, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("benchmark needs at least one request")
+	}
+	if concurrency < 1 {
+		return nil, fmt.Errorf("benchmark concurrency must be at least 1")
+	}
+	if concurrency > n {
+		concurrency = n
+	}
+
+	taken, err := hd.takeRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	latencies := make([]float64, n)
+	failed := make([]bool, n)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				sent := time.Now()
+				result, err := hd.engine.RequestContext(hd.ctx, taken.method, taken.url, taken.options)
+				latencies[i] = float64(time.Since(sent).Microseconds()) / 1000
+				status := 0
+				if response, ok := result.(map[string]interface{}); ok {
+					status, _ = response["status"].(int)
+				}
+				failed[i] = err != nil || status >= 400
+			}
+		}()
+	}
+	for i := 0; i < n && hd.ctx.Err() == nil; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	if err := hd.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	stats := &benchmarkStats{
+		requests:    n,
+		concurrency: concurrency,
+		latencies:   make(map[string]float64, len(benchmarkLatencies)),
+		duration:    time.Since(start),
+	}
+	total := 0.0
+	for i, latency := range latencies {
+		total += latency
+		if failed[i] {
+			stats.errors++
+		}
+	}
+	sort.Float64s(latencies)
+	stats.latencies["min"] = latencies[0]
+	stats.latencies["avg"] = total / float64(n)
+	stats.latencies["max"] = latencies[n-1]
+	for _, p := range []int{50, 90, 95, 99} {
+		stats.latencies[fmt.Sprintf("p%d", p)] = percentile(latencies, p)
+	}
+	return stats, nil
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []float64, p int) float64 {
+	rank := int(math.Ceil(float64(p) / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHTTPDSLv3Benchmark(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1)%10 == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	script := `benchmark 20 times GET "$base/users" concurrency 4
+assert benchmark p95 less 5000 ms
+assert benchmark error_rate less 20
+set $p95 $benchmark.p95`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("benchmark failed: %v", err)
+	}
+	if atomic.LoadInt32(&calls) != 20 {
+		t.Errorf("Expected 20 requests, got %d", calls)
+	}
+	stats, _ := dsl.GetVariable("benchmark")
+	if s, ok := stats.(map[string]interface{}); !ok || s["errors"] != 2 || s["concurrency"] != 4 {
+		t.Errorf("Unexpected benchmark statistics: %v", stats)
+	}
+	if p95, _ := dsl.GetVariable("p95"); p95 == nil {
+		t.Error("Expected $benchmark.p95 to be set")
+	}
+
+	if _, err := dsl.Parse(`assert benchmark error_rate less 5`); err == nil {
+		t.Error("Expected the error rate assertion to fail")
+	}
+}
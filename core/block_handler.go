@@ -7,7 +7,7 @@ import (
 
 // Helper function to check if a line starts with an HTTP method
 func isHTTPMethod(line string) bool {
-	methods := []string{"GET ", "POST ", "PUT ", "DELETE ", "PATCH ", "HEAD ", "OPTIONS ", "CONNECT ", "TRACE "}
+	methods := []string{"GET ", "POST ", "PUT ", "DELETE ", "PATCH ", "HEAD ", "OPTIONS ", "CONNECT ", "TRACE ", "METHOD ", "graphql "}
 	for _, method := range methods {
 		if strings.HasPrefix(line, method) {
 			return true
@@ -18,10 +18,20 @@ func isHTTPMethod(line string) bool {
 
 // ParseWithBlockSupport handles multiline blocks properly
 func (hd *HTTPDSLv3) ParseWithBlockSupport(code string) (interface{}, error) {
-	lines := strings.Split(code, "\n")
+	lines, heredocErr := joinHeredocs(strings.Split(code, "\n"))
+	if heredocErr != nil {
+		return nil, fmt.Errorf("error at line %d: %w", heredocErr.Line+1, heredocErr)
+	}
+	for i := range lines {
+		lines[i] = stripComment(lines[i])
+	}
+	lines = joinContinuations(lines)
 	var results []interface{}
 	i := 0
 
+	hd.blockDepth++
+	defer func() { hd.blockDepth-- }()
+
 	for i < len(lines) {
 		line := strings.TrimSpace(lines[i])
 
@@ -31,40 +41,80 @@ func (hd *HTTPDSLv3) ParseWithBlockSupport(code string) (interface{}, error) {
 			continue
 		}
 
-		// Check if this is an HTTP request with multiple headers
+		// Steps report the line of the top-level statement or block they belong to
+		if hd.blockDepth == 1 {
+			hd.stepLine = i + 1
+		}
+
+		// HTTP requests; header lines and continued lines are already
+		// joined to them
 		if isHTTPMethod(line) {
-			// Collect the request line and any following headers
-			requestParts := []string{line}
-			j := i + 1
-
-			// Look ahead for indented headers
-			for j < len(lines) {
-				nextLine := lines[j]
-				trimmedNext := strings.TrimSpace(nextLine)
-
-				// Check if this is an indented header
-				if strings.HasPrefix(nextLine, "    ") && strings.HasPrefix(trimmedNext, "header ") {
-					// Add this header to the request (inline)
-					requestParts = append(requestParts, trimmedNext)
-					j++
-				} else {
-					break
-				}
+			result, err := hd.ParseWithContext(line)
+			if err != nil {
+				return results, fmt.Errorf("error parsing HTTP request: %w", err)
+			}
+			if result != nil && result != "" {
+				results = append(results, result)
 			}
 
-			// Build the complete request on one line
-			fullRequest := strings.Join(requestParts, " ")
+			i++
+			continue
+		}
 
-			// Parse the full request
-			result, err := hd.ParseWithContext(fullRequest)
+		// Hook blocks are stored and run around every later request; default
+		// headers blocks run now and apply to every later request
+		if isEndBlockOpener(line) {
+			body, end, err := collectHookBody(lines, i+1, line)
 			if err != nil {
-				return results, fmt.Errorf("error parsing HTTP request: %v", err)
+				return results, fmt.Errorf("error at line %d: %w", i+1, err)
 			}
-			if result != nil && result != "" {
-				results = append(results, result)
+			if line == defaultHeadersBlock {
+				if err := hd.runDefaultHeaders(body); err != nil {
+					return results, fmt.Errorf("error at line %d: %w", i+1, err)
+				}
+				results = append(results, "Default headers set")
+			} else {
+				hd.addHook(line, body)
+				results = append(results, fmt.Sprintf("Registered %s hook", line))
 			}
+			i = end + 1
+			continue
+		}
 
-			i = j
+		// Request templates are parsed now and sent by invoke
+		if name, request, inline, ok := parseTemplateLine(line); ok {
+			end := i
+			if !inline {
+				var err error
+				if request, end, err = collectHookBody(lines, i+1, "request "+name); err != nil {
+					return results, fmt.Errorf("error at line %d: %w", i+1, err)
+				}
+			}
+			if err := hd.defineTemplate(name, request); err != nil {
+				return results, fmt.Errorf("error at line %d: %w", i+1, err)
+			}
+			results = append(results, fmt.Sprintf("Defined request %q", name))
+			i = end + 1
+			continue
+		}
+
+		// for range, repeat ... until, switch and paginate blocks
+		if forLoopHeader.MatchString(line) || isUntilOpener(line) || isSwitchOpener(line) || isPaginateOpener(line) {
+			run := hd.runForBlock
+			switch {
+			case isUntilOpener(line):
+				run = hd.runUntilBlock
+			case isSwitchOpener(line):
+				run = hd.runSwitchBlock
+			case isPaginateOpener(line):
+				run = hd.runPaginateBlock
+			}
+			loopResults, end, err := run(lines, i)
+			results = append(results, loopResults...)
+			if err != nil {
+				return results, fmt.Errorf("error at line %d: %w", i+1, err)
+			}
+			i = end + 1
 			continue
 		}
 
@@ -80,9 +130,22 @@ func (hd *HTTPDSLv3) ParseWithBlockSupport(code string) (interface{}, error) {
 			i++
 			var thenBlock []string
 			var elseBlock []string
+			var elseIfs []elseIfBranch
 			inElse := false
 			nestLevel := 1
 
+			// add puts a line in the branch being collected
+			add := func(line string) {
+				switch {
+				case inElse:
+					elseBlock = append(elseBlock, line)
+				case len(elseIfs) > 0:
+					elseIfs[len(elseIfs)-1].body = append(elseIfs[len(elseIfs)-1].body, line)
+				default:
+					thenBlock = append(thenBlock, line)
+				}
+			}
+
 			for i < len(lines) && nestLevel > 0 {
 				innerLine := strings.TrimSpace(lines[i])
 
@@ -92,47 +155,36 @@ func (hd *HTTPDSLv3) ParseWithBlockSupport(code string) (interface{}, error) {
 						break
 					}
 					// Add endif for nested blocks
-					if inElse {
-						elseBlock = append(elseBlock, lines[i])
-					} else {
-						thenBlock = append(thenBlock, lines[i])
-					}
+					add(lines[i])
 				} else if strings.HasPrefix(innerLine, "if ") && strings.HasSuffix(innerLine, " then") {
 					nestLevel++
 					// Add the nested if line
-					if inElse {
-						elseBlock = append(elseBlock, lines[i])
-					} else {
-						thenBlock = append(thenBlock, lines[i])
-					}
+					add(lines[i])
+				} else if condition, ok := elseIfCondition(innerLine); ok && nestLevel == 1 && !inElse {
+					elseIfs = append(elseIfs, elseIfBranch{condition: condition})
 				} else if innerLine == "else" && nestLevel == 1 {
 					inElse = true
 					i++
 					continue
-				} else if innerLine == "else" && nestLevel > 1 {
-					// This else belongs to a nested if
-					if inElse {
-						elseBlock = append(elseBlock, lines[i])
-					} else {
-						thenBlock = append(thenBlock, lines[i])
-					}
 				} else if innerLine != "" && !strings.HasPrefix(innerLine, "#") {
-					// Add the line with original formatting
-					if inElse {
-						elseBlock = append(elseBlock, lines[i])
-					} else {
-						thenBlock = append(thenBlock, lines[i])
-					}
+					// Add the line with original formatting; else and elseif
+					// lines of nested blocks included
+					add(lines[i])
 				}
 				i++
 			}
 
-			// Execute the appropriate block
-			var blockToExecute []string
-			if shouldExecute {
-				blockToExecute = thenBlock
-			} else {
+			// Execute the appropriate block; elseif conditions are only
+			// evaluated when no branch before them was taken
+			blockToExecute := thenBlock
+			if !shouldExecute {
 				blockToExecute = elseBlock
+				for _, branch := range elseIfs {
+					if hd.EvaluateCondition(branch.condition) {
+						blockToExecute = branch.body
+						break
+					}
+				}
 			}
 
 			// Process the block as a whole to handle nested structures properly
@@ -141,7 +193,7 @@ func (hd *HTTPDSLv3) ParseWithBlockSupport(code string) (interface{}, error) {
 				blockCode := strings.Join(blockToExecute, "\n")
 				blockResult, err := hd.ParseWithBlockSupport(blockCode)
 				if err != nil {
-					return results, fmt.Errorf("error processing block: %v", err)
+					return results, fmt.Errorf("error processing block: %w", err)
 				}
 				if blockResult != nil {
 					// Add results from block
@@ -171,7 +223,7 @@ func (hd *HTTPDSLv3) ParseWithBlockSupport(code string) (interface{}, error) {
 			// Check if it's a variable
 			if strings.HasPrefix(countStr, "$") {
 				varName := strings.TrimPrefix(countStr, "$")
-				if val, ok := hd.variables[varName]; ok {
+				if val, ok := hd.lookupVariable(varName); ok {
 					switch v := val.(type) {
 					case int:
 						count = v
@@ -201,7 +253,7 @@ func (hd *HTTPDSLv3) ParseWithBlockSupport(code string) (interface{}, error) {
 					if nestLevel == 0 {
 						break
 					}
-				} else if strings.HasSuffix(innerLine, " do") {
+				} else if isLoopOpener(innerLine) {
 					nestLevel++
 				}
 
@@ -216,11 +268,12 @@ func (hd *HTTPDSLv3) ParseWithBlockSupport(code string) (interface{}, error) {
 			for iteration := 0; iteration < count; iteration++ {
 				hd.SetVariable("_index", iteration)
 				hd.SetVariable("_iteration", iteration+1)
+				hd.emitIteration("repeat", iteration+1)
 
 				// Use the new ProcessLoopBody function
 				loopResult, err := hd.ProcessLoopBody(loopBody)
 				if err != nil {
-					return results, fmt.Errorf("error in loop iteration %d: %v", iteration+1, err)
+					return results, fmt.Errorf("error in loop iteration %d: %w", iteration+1, err)
 				}
 
 				// Append results
@@ -264,7 +317,7 @@ func (hd *HTTPDSLv3) ParseWithBlockSupport(code string) (interface{}, error) {
 					if nestLevel == 0 {
 						break
 					}
-				} else if strings.HasSuffix(innerLine, " do") {
+				} else if isLoopOpener(innerLine) {
 					nestLevel++
 				}
 
@@ -274,62 +327,38 @@ func (hd *HTTPDSLv3) ParseWithBlockSupport(code string) (interface{}, error) {
 				i++
 			}
 
+			// Parse the condition once and evaluate it again before every iteration.
+			// Conditions outside the grammar (like uppercase AND/OR chains) fall
+			// back to the string evaluator, which also re-reads variables each time.
+			condition, parseErr := hd.parseRule("condition", conditionStr)
+
 			// Execute the while loop
 			maxIterations := 1000 // Safety limit
 			iterations := 0
 
 			for iterations < maxIterations {
-				// Evaluate condition
-				shouldContinue := false
-
-				// Parse the condition (e.g., "$count < 10")
-				parts := strings.Fields(conditionStr)
-				if len(parts) == 3 {
-					varName := strings.TrimPrefix(parts[0], "$")
-					operator := parts[1]
-					compareToStr := parts[2]
-
-					if val, ok := hd.variables[varName]; ok {
-						var numVal, compareVal float64
-						switch v := val.(type) {
-						case int:
-							numVal = float64(v)
-						case float64:
-							numVal = v
-						case string:
-							fmt.Sscanf(v, "%f", &numVal)
-						default:
-							numVal = 0
-						}
-						fmt.Sscanf(compareToStr, "%f", &compareVal)
-
-						switch operator {
-						case "<":
-							shouldContinue = numVal < compareVal
-						case ">":
-							shouldContinue = numVal > compareVal
-						case "<=":
-							shouldContinue = numVal <= compareVal
-						case ">=":
-							shouldContinue = numVal >= compareVal
-						case "==":
-							shouldContinue = numVal == compareVal
-						case "!=":
-							shouldContinue = numVal != compareVal
-						}
+				var holds bool
+				if parseErr == nil {
+					var err error
+					holds, err = hd.evaluateCondition(condition)
+					if err != nil {
+						return results, fmt.Errorf("error in while loop condition: %w", err)
 					}
+				} else {
+					holds = hd.EvaluateCondition(conditionStr)
 				}
 
-				if !shouldContinue {
+				if !holds {
 					break
 				}
 
 				hd.SetVariable("_iteration", iterations+1)
+				hd.emitIteration("while", iterations+1)
 
 				// Use the new ProcessLoopBody function
 				loopResult, err := hd.ProcessLoopBody(loopBody)
 				if err != nil {
-					return results, fmt.Errorf("error in while loop iteration %d: %v", iterations+1, err)
+					return results, fmt.Errorf("error in while loop iteration %d: %w", iterations+1, err)
 				}
 
 				// Append results
@@ -339,17 +368,13 @@ func (hd *HTTPDSLv3) ParseWithBlockSupport(code string) (interface{}, error) {
 					}
 				}
 
-				// Handle continue
-				if loopResult.ShouldContinue {
-					continue // Skip to next iteration
-				}
+				// Count the iteration before continue so the safety limit still applies
+				iterations++
 
 				// Handle break
 				if loopResult.ShouldBreak {
 					break // Exit the while loop
 				}
-
-				iterations++
 			}
 
 			if iterations >= maxIterations {
@@ -368,7 +393,7 @@ func (hd *HTTPDSLv3) ParseWithBlockSupport(code string) (interface{}, error) {
 			}
 
 			itemVar := strings.TrimPrefix(strings.TrimPrefix(parts[0], "foreach "), "$")
-			listPart := strings.TrimSuffix(parts[1], " do")
+			listPart, parallel := splitParallel(strings.TrimSuffix(parts[1], " do"))
 
 			// Collect the loop body
 			i++
@@ -383,7 +408,7 @@ func (hd *HTTPDSLv3) ParseWithBlockSupport(code string) (interface{}, error) {
 					if nestLevel == 0 {
 						break
 					}
-				} else if strings.HasSuffix(innerLine, " do") {
+				} else if isLoopOpener(innerLine) {
 					nestLevel++
 				}
 
@@ -416,10 +441,20 @@ func (hd *HTTPDSLv3) ParseWithBlockSupport(code string) (interface{}, error) {
 						}
 					}
 				}
+			} else if strings.HasPrefix(listPart, "csv ") {
+				// One object per row of a CSV file, keyed by column name
+				name := hd.expandVariables(hd.unquoteString(strings.TrimSpace(strings.TrimPrefix(listPart, "csv "))))
+				rows, err := ReadCSVRows(name)
+				if err != nil {
+					return results, fmt.Errorf("foreach: %w", err)
+				}
+				for _, row := range rows {
+					items = append(items, row)
+				}
 			} else if strings.HasPrefix(listPart, "$") {
 				// It's a variable reference
 				varName := strings.TrimPrefix(listPart, "$")
-				if val, ok := hd.variables[varName]; ok {
+				if val, ok := hd.lookupVariable(varName); ok {
 					switch v := val.(type) {
 					case []interface{}:
 						items = v
@@ -427,8 +462,16 @@ func (hd *HTTPDSLv3) ParseWithBlockSupport(code string) (interface{}, error) {
 						for _, s := range v {
 							items = append(items, s)
 						}
+					case map[string]interface{}:
+						items = hd.toSlice(v)
 					case string:
 						// Try to parse as JSON array
+						if decoded, ok := decodeJSONText(v); ok {
+							if list, ok := decoded.([]interface{}); ok {
+								items = list
+								break
+							}
+						}
 						if strings.HasPrefix(v, "[") {
 							v = strings.Trim(v, "[]")
 							// Handle empty array
@@ -450,17 +493,34 @@ func (hd *HTTPDSLv3) ParseWithBlockSupport(code string) (interface{}, error) {
 				}
 			}
 
+			// foreach ... parallel N runs the iterations on N workers
+			if parallel != "" {
+				workers, err := hd.parseWorkers(parallel)
+				if err != nil {
+					return results, err
+				}
+				loopResults, iterations, err := hd.parallelForeach(itemVar, items, loopBody, workers)
+				results = append(results, loopResults...)
+				if err != nil {
+					return results, err
+				}
+				results = append(results, fmt.Sprintf("Foreach executed for %d items on %d workers", iterations, workers))
+				i++ // Skip the endloop
+				continue
+			}
+
 			// Execute the foreach loop
 			actualIterations := 0
 			for idx, item := range items {
 				hd.SetVariable(itemVar, item)
 				hd.SetVariable("_index", idx)
 				hd.SetVariable("_iteration", idx+1)
+				hd.emitIteration("foreach", idx+1)
 
 				// Use the new ProcessLoopBody function
 				loopResult, err := hd.ProcessLoopBody(loopBody)
 				if err != nil {
-					return results, fmt.Errorf("error in foreach iteration %d: %v", idx+1, err)
+					return results, fmt.Errorf("error in foreach iteration %d: %w", idx+1, err)
 				}
 
 				// Append results
@@ -488,7 +548,8 @@ func (hd *HTTPDSLv3) ParseWithBlockSupport(code string) (interface{}, error) {
 
 		} else {
 			// Special handling for single-line if/then/else to avoid double execution
-			if strings.HasPrefix(line, "if ") && strings.Contains(line, " then ") && strings.Contains(line, " else ") && !strings.Contains(line, "endif") {
+			if strings.HasPrefix(line, "if ") && strings.Contains(line, " then ") && strings.Contains(line, " else ") &&
+				!strings.Contains(line, "endif") && !strings.Contains(line, " elseif ") {
 				// Parse if/then/else manually to avoid both branches executing
 				// Find the positions of "then" and "else"
 				parts := strings.SplitN(line, " then ", 2)
@@ -499,63 +560,21 @@ func (hd *HTTPDSLv3) ParseWithBlockSupport(code string) (interface{}, error) {
 						thenStatement := restParts[0]
 						elseStatement := restParts[1]
 
-						// Evaluate the condition directly
-						shouldExecuteThen := false
-
-						// Parse the condition (e.g., "$x > 10")
-						condParts := strings.Fields(conditionPart)
-						if len(condParts) == 3 {
-							// Simple comparison like "$x > 10"
-							varName := strings.TrimPrefix(condParts[0], "$")
-							operator := condParts[1]
-							compareToStr := condParts[2]
-
-							if val, ok := hd.variables[varName]; ok {
-								var numVal, compareVal float64
-								// Convert to numbers
-								switch v := val.(type) {
-								case int:
-									numVal = float64(v)
-								case float64:
-									numVal = v
-								case string:
-									fmt.Sscanf(v, "%f", &numVal)
-								default:
-									numVal = 0
-								}
-								fmt.Sscanf(compareToStr, "%f", &compareVal)
-
-								// Evaluate comparison
-								switch operator {
-								case ">":
-									shouldExecuteThen = numVal > compareVal
-								case "<":
-									shouldExecuteThen = numVal < compareVal
-								case ">=":
-									shouldExecuteThen = numVal >= compareVal
-								case "<=":
-									shouldExecuteThen = numVal <= compareVal
-								case "==":
-									shouldExecuteThen = numVal == compareVal
-								case "!=":
-									shouldExecuteThen = numVal != compareVal
-								}
-							}
-						}
+						shouldExecuteThen := hd.EvaluateCondition(conditionPart)
 
 						// Execute the appropriate branch
 						if shouldExecuteThen {
 							// Execute ONLY the then branch
 							result, err := hd.ParseWithContext(thenStatement)
 							if err != nil {
-								return results, fmt.Errorf("error in then statement: %v", err)
+								return results, fmt.Errorf("error in then statement: %w", err)
 							}
 							results = append(results, result)
 						} else {
 							// Execute ONLY the else branch
 							result, err := hd.ParseWithContext(elseStatement)
 							if err != nil {
-								return results, fmt.Errorf("error in else statement: %v", err)
+								return results, fmt.Errorf("error in else statement: %w", err)
 							}
 							results = append(results, result)
 						}
@@ -568,7 +587,7 @@ func (hd *HTTPDSLv3) ParseWithBlockSupport(code string) (interface{}, error) {
 			// Regular line - parse normally
 			result, err := hd.ParseWithContext(line)
 			if err != nil {
-				return results, fmt.Errorf("error at line %d: %v", i+1, err)
+				return results, fmt.Errorf("error at line %d: %w", i+1, err)
 			}
 			results = append(results, result)
 			i++
@@ -577,3 +596,29 @@ func (hd *HTTPDSLv3) ParseWithBlockSupport(code string) (interface{}, error) {
 
 	return results, nil
 }
+
+// elseIfBranch is an elseif clause of a multiline if block
+type elseIfBranch struct {
+	condition string
+	body      []string
+}
+
+// elseIfCondition returns the condition of an elseif line, written either
+// elseif <condition> then or else if <condition> then
+func elseIfCondition(line string) (string, bool) {
+	if !strings.HasSuffix(line, " then") {
+		return "", false
+	}
+	for _, prefix := range []string{"elseif ", "else if "} {
+		if strings.HasPrefix(line, prefix) {
+			return strings.TrimSuffix(strings.TrimPrefix(line, prefix), " then"), true
+		}
+	}
+	return "", false
+}
+
+// isElseIf reports whether a line starts an elseif branch of an if block
+func isElseIf(line string) bool {
+	_, ok := elseIfCondition(line)
+	return ok
+}
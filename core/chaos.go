@@ -0,0 +1,133 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// errChaosFailure is the error of a request failed by chaos fail
+var errChaosFailure = errors.New("chaos: injected network failure")
+
+// chaosConfig holds the faults injected into requests, to exercise retries
+// and fallbacks. It is replaced, never changed, once an engine uses it.
+type chaosConfig struct {
+	latency            time.Duration // Delay added before a request is sent
+	latencyProbability float64       // Share of requests delayed
+	failProbability    float64       // Share of requests failed with a network error
+	random             *chaosRandom  // Draws whether a request gets a fault
+}
+
+// active reports whether the config injects anything
+func (c *chaosConfig) active() bool {
+	return c != nil && (c.latencyProbability > 0 && c.latency > 0 || c.failProbability > 0)
+}
+
+// chaosRandom is a random source safe for concurrent requests
+type chaosRandom struct {
+	mu     sync.Mutex
+	random *rand.Rand
+}
+
+// hit draws whether an event of the given probability happens
+func (r *chaosRandom) hit(probability float64) bool {
+	if probability <= 0 {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.random.Float64() < probability
+}
+
+// chaosTransport injects the faults of a config before passing requests on
+type chaosTransport struct {
+	next   http.RoundTripper
+	config *chaosConfig
+	engine *HTTPEngine
+}
+
+func (t *chaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	config := t.config
+	if config.latency > 0 && config.random.hit(config.latencyProbability) {
+		t.engine.LogInfo("Chaos: delaying %s %s by %v", req.Method, req.URL, config.latency)
+		timer := time.NewTimer(config.latency)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+	if config.random.hit(config.failProbability) {
+		t.engine.LogInfo("Chaos: failing %s %s", req.Method, req.URL)
+		return nil, errChaosFailure
+	}
+	return t.next.RoundTrip(req)
+}
+
+// SetChaosLatency delays the given share of requests, between 0 and 1, by
+// latency before they are sent. A zero probability stops the delays.
+func (he *HTTPEngine) SetChaosLatency(latency time.Duration, probability float64) error {
+	if err := checkProbability(probability); err != nil {
+		return err
+	}
+	he.updateChaos(func(c *chaosConfig) {
+		c.latency = latency
+		c.latencyProbability = probability
+	})
+	return nil
+}
+
+// SetChaosFailure fails the given share of requests, between 0 and 1, with a
+// network error instead of sending them. A zero probability stops the failures.
+func (he *HTTPEngine) SetChaosFailure(probability float64) error {
+	if err := checkProbability(probability); err != nil {
+		return err
+	}
+	he.updateChaos(func(c *chaosConfig) {
+		c.failProbability = probability
+	})
+	return nil
+}
+
+// SetChaosSeed seeds the draws of which requests get a fault, so a run can
+// be replayed with the same faults
+func (he *HTTPEngine) SetChaosSeed(seed int64) {
+	he.updateChaos(func(c *chaosConfig) {
+		c.random = &chaosRandom{random: rand.New(rand.NewSource(seed))}
+	})
+}
+
+// ClearChaos stops injecting faults
+func (he *HTTPEngine) ClearChaos() {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	he.chaos = nil
+}
+
+// updateChaos replaces the chaos config with a changed copy, so requests in
+// flight keep the one they started with
+func (he *HTTPEngine) updateChaos(fn func(c *chaosConfig)) {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	config := &chaosConfig{}
+	if he.chaos != nil {
+		*config = *he.chaos
+	}
+	if config.random == nil {
+		config.random = &chaosRandom{random: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	}
+	fn(config)
+	he.chaos = config
+}
+
+// checkProbability checks that a probability is between 0 and 1
+func checkProbability(probability float64) error {
+	if probability < 0 || probability > 1 {
+		return fmt.Errorf("probability must be between 0 and 1, got %v", probability)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPDSLv3Chaos(t *testing.T) {
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	_, err := dsl.ParseWithBlockSupport(`chaos fail probability 1
+GET "$base/" retry 2 times backoff 1 ms`)
+	if err == nil || !strings.Contains(err.Error(), "chaos: injected network failure") || !strings.Contains(err.Error(), "after 2 retries") {
+		t.Errorf("Expected retried chaos failures, got %v", err)
+	}
+	if n := received.Load(); n != 0 {
+		t.Errorf("Expected failed requests not to be sent, server got %d", n)
+	}
+
+	start := time.Now()
+	if _, err := dsl.ParseWithBlockSupport(`clear chaos
+chaos latency 50 ms
+GET "$base/"
+assert status 200`); err != nil {
+		t.Fatalf("chaos latency failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected the request to be delayed 50ms, took %v", elapsed)
+	}
+
+	// The same seed fails the same requests
+	pattern := func() string {
+		dsl := NewHTTPDSLv3()
+		dsl.SetVariable("base", server.URL)
+		if _, err := dsl.ParseWithBlockSupport("set random seed 7\nchaos fail probability 0.5"); err != nil {
+			t.Fatalf("chaos fail failed: %v", err)
+		}
+		var outcomes strings.Builder
+		for i := 0; i < 20; i++ {
+			if _, err := dsl.Parse(`GET "$base/"`); err != nil {
+				outcomes.WriteByte('x')
+			} else {
+				outcomes.WriteByte('.')
+			}
+		}
+		return outcomes.String()
+	}
+	first := pattern()
+	if second := pattern(); first != second || !strings.Contains(first, "x") || !strings.Contains(first, ".") {
+		t.Errorf("Expected the same mix of failures with the same seed, got %s and %s", first, second)
+	}
+
+	if _, err := dsl.Parse("chaos fail probability 2"); err == nil {
+		t.Error("Expected a probability over 1 to be rejected")
+	}
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"fmt"
+	"plugin"
+	"regexp"
+	"strings"
+)
+
+// CommandHandler runs a custom command registered with RegisterCommand. Args
+// holds the evaluated arguments in order: strings with variables expanded,
+// numbers as float64, and variable references as their stored values.
+type CommandHandler func(hd *HTTPDSLv3, args []interface{}) (interface{}, error)
+
+// commandWord is the form of each word in a custom command or function name
+var commandWord = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// RegisterCommand adds a statement to the grammar. Name is one or more words
+// (like "kafka publish") that become keywords, followed in scripts by exactly
+// arity arguments, each a string, number or variable. Registering a name again
+// replaces its handler. Commands should be registered before scripts run.
+//
+// Example:
+//
+//	hd.RegisterCommand("kafka publish", 2, func(hd *HTTPDSLv3, args []interface{}) (interface{}, error) {
+//	    topic, message := args[0].(string), args[1].(string)
+//	    return fmt.Sprintf("published to %s", topic), producer.Send(topic, message)
+//	})
+//
+//	// In a script: kafka publish "orders" "$payload"
+func (hd *HTTPDSLv3) RegisterCommand(name string, arity int, handler CommandHandler) error {
+	words := strings.Fields(name)
+	if err := hd.checkKeywords("command", name, words); err != nil {
+		return err
+	}
+	if arity < 0 {
+		return fmt.Errorf("invalid arity %d for command %q", arity, name)
+	}
+	if handler == nil {
+		return fmt.Errorf("command %q has no handler", name)
+	}
+
+	key := strings.ToLower(strings.Join(words, " "))
+	actionName := "command:" + key
+
+	if registered, ok := hd.commands[key]; ok && registered != arity {
+		return fmt.Errorf("command %q is already registered with %d arguments", key, registered)
+	} else if !ok {
+		if len(hd.commands) == 0 {
+			hd.dsl.Rule("statement", []string{"custom_command"}, "passthrough")
+		}
+
+		sequence := make([]string, 0, len(words)+arity)
+		for _, word := range words {
+			word = strings.ToLower(word)
+			hd.dsl.KeywordToken(word, word)
+			sequence = append(sequence, word)
+		}
+		for i := 0; i < arity; i++ {
+			sequence = append(sequence, "value")
+		}
+		hd.dsl.Rule("custom_command", sequence, actionName)
+		hd.commands[key] = arity
+	}
+
+	hd.action(actionName, func(args []interface{}) (interface{}, error) {
+		return handler(hd, args[len(words):])
+	})
+	hd.extensions = append(hd.extensions, func(fork *HTTPDSLv3) {
+		fork.RegisterCommand(name, arity, handler)
+	})
+	return nil
+}
+
+// checkKeywords verifies that the words of a command or function name can be
+// added to the grammar as keywords
+func (hd *HTTPDSLv3) checkKeywords(kind, name string, words []string) error {
+	if len(words) == 0 {
+		return fmt.Errorf("%s name is empty", kind)
+	}
+	rules := hd.newGrammarChecker().rules
+	for _, word := range words {
+		if !commandWord.MatchString(word) {
+			return fmt.Errorf("invalid %s name %q: %q is not a word", kind, name, word)
+		}
+		if _, isRule := rules[strings.ToLower(word)]; isRule {
+			return fmt.Errorf("invalid %s name %q: %q names a grammar rule", kind, name, word)
+		}
+	}
+	return nil
+}
+
+// LoadPlugin opens a Go plugin built with -buildmode=plugin and calls its
+// exported Register function, which usually adds commands with
+// RegisterCommand. The plugin must export:
+//
+//	func Register(hd *core.HTTPDSLv3) error
+//
+// Go plugins are only supported on some platforms and must be built with
+// the same Go version and module versions as the program loading them.
+func (hd *HTTPDSLv3) LoadPlugin(path string) error {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot load plugin %s: %w", path, err)
+	}
+
+	symbol, err := p.Lookup("Register")
+	if err != nil {
+		return fmt.Errorf("plugin %s: %w", path, err)
+	}
+
+	register, ok := symbol.(func(*HTTPDSLv3) error)
+	if !ok {
+		return fmt.Errorf("plugin %s: Register must be a func(*core.HTTPDSLv3) error", path)
+	}
+	return register(hd)
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHTTPDSLv3RegisterCommand(t *testing.T) {
+	dsl := NewHTTPDSLv3()
+
+	var published []string
+	err := dsl.RegisterCommand("kafka publish", 2, func(hd *HTTPDSLv3, args []interface{}) (interface{}, error) {
+		published = append(published, fmt.Sprintf("%v=%v", args[0], args[1]))
+		return "published", nil
+	})
+	if err != nil {
+		t.Fatalf("RegisterCommand failed: %v", err)
+	}
+	err = dsl.RegisterCommand("ping", 0, func(hd *HTTPDSLv3, args []interface{}) (interface{}, error) {
+		hd.SetVariable("pinged", true)
+		return "pong", nil
+	})
+	if err != nil {
+		t.Fatalf("RegisterCommand failed: %v", err)
+	}
+
+	script := `set $id 42
+kafka publish "orders" "order-$id"
+ping`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+	if len(published) != 1 || published[0] != "orders=order-42" {
+		t.Errorf("Unexpected published messages: %v", published)
+	}
+	if pinged, _ := dsl.GetVariable("pinged"); pinged != true {
+		t.Error("Expected the ping handler to run")
+	}
+
+	if problems := dsl.Validate(`kafka publish "orders"`); len(problems) != 1 {
+		t.Errorf("Expected an arity error, got %v", problems)
+	}
+	if err := dsl.RegisterCommand("kafka publish", 1, nil); err == nil {
+		t.Error("Expected an error for a missing handler")
+	}
+	if err := dsl.RegisterCommand("db-query", 1, func(*HTTPDSLv3, []interface{}) (interface{}, error) { return nil, nil }); err == nil {
+		t.Error("Expected an error for an invalid name")
+	}
+}
@@ -0,0 +1,32 @@
+package core
+
+import "strings"
+
+// splitComment splits a line into its code and an end-of-line comment
+// starting with # or //. A comment starts the line or follows whitespace, and
+// is never inside a quoted string, so URLs, fragments and "#" values are kept.
+//
+//	GET "$base/users"  # fetch users
+func splitComment(line string) (string, string) {
+	inString := false
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '#' || c == '/' && strings.HasPrefix(line[i:], "//"):
+			if i == 0 || line[i-1] == ' ' || line[i-1] == '\t' {
+				return strings.TrimRight(line[:i], " \t"), line[i:]
+			}
+		}
+	}
+	return line, ""
+}
+
+// stripComment returns a line without its end-of-line comment
+func stripComment(line string) string {
+	code, _ := splitComment(line)
+	return code
+}
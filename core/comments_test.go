@@ -0,0 +1,49 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestHTTPDSLv3EndOfLineComments(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.URL.Path+" "+r.Header.Get("X-Tag"))
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	script := `set $tag "#1 // first"  # quoted markers are kept
+GET "$base/users" header "X-Tag" "$tag"  // fetch users
+set $n 2 # two
+if $n > 1 then # more than one
+    set $many "yes"
+endif # done
+repeat 2 times do  # twice
+    GET "$base/items#top"
+endloop`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+
+	expected := []string{"/users #1 // first", "/items ", "/items "}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("Expected requests %q, got %q", expected, received)
+	}
+	if many, _ := dsl.GetVariable("many"); many != "yes" {
+		t.Errorf("Expected $many to be yes, got %v", many)
+	}
+
+	if _, err := dsl.Parse(`set $single "a#b" # comment`); err != nil {
+		t.Errorf("Parse failed: %v", err)
+	}
+	if problems := dsl.Validate(script); len(problems) != 0 {
+		t.Errorf("Expected no syntax errors, got %v", problems)
+	}
+	if formatted := dsl.Format("IF $n > 1 THEN   # check\nprint  \"x\"\nENDIF // end"); formatted != "if $n > 1 then # check\n    print \"x\"\nendif // end\n" {
+		t.Errorf("Expected comments to be kept by the formatter, got:\n%s", formatted)
+	}
+}
@@ -0,0 +1,37 @@
+package core
+
+// The DSL used to ship as four separate implementations (HTTPDSL, HTTPDSLv2,
+// HTTPDSLFixed and HTTPDSLv3), each with its own copy of the grammar. They are
+// now all backed by the v3 interpreter so fixes only have to be made once.
+// The older types are kept so existing code keeps compiling.
+
+// HTTPDSL is the original DSL entry point, kept for compatibility.
+// It runs on the v3 interpreter.
+type HTTPDSL struct {
+	*HTTPDSLv3
+}
+
+// NewHTTPDSL creates a new HTTP DSL instance
+func NewHTTPDSL() *HTTPDSL {
+	return &HTTPDSL{HTTPDSLv3: NewHTTPDSLv3()}
+}
+
+// HTTPDSLv2 is kept for compatibility. It runs on the v3 interpreter.
+type HTTPDSLv2 struct {
+	*HTTPDSLv3
+}
+
+// NewHTTPDSLv2 creates a new HTTP DSL v2 instance
+func NewHTTPDSLv2() *HTTPDSLv2 {
+	return &HTTPDSLv2{HTTPDSLv3: NewHTTPDSLv3()}
+}
+
+// HTTPDSLFixed is kept for compatibility. It runs on the v3 interpreter.
+type HTTPDSLFixed struct {
+	*HTTPDSLv3
+}
+
+// NewHTTPDSLFixed creates a new fixed HTTP DSL instance
+func NewHTTPDSLFixed() *HTTPDSLFixed {
+	return &HTTPDSLFixed{HTTPDSLv3: NewHTTPDSLv3()}
+}
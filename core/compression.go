@@ -0,0 +1,121 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// defaultAcceptEncoding is sent when neither the request nor accept encoding
+// set an Accept-Encoding header. The engine decodes these itself, so the raw
+// size of the body stays known.
+const defaultAcceptEncoding = "gzip, deflate, br"
+
+// contentEncodings are the encodings request bodies can be compressed with
+var contentEncodings = map[string]bool{"gzip": true, "deflate": true, "br": true}
+
+// SetRequestCompression compresses the bodies of later requests with gzip,
+// deflate or br and sends a matching Content-Encoding header. "off" or ""
+// sends bodies as they are.
+func (he *HTTPEngine) SetRequestCompression(encoding string) error {
+	encoding, err := parseContentEncoding(encoding)
+	if err != nil {
+		return err
+	}
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	he.requestCompression = encoding
+	return nil
+}
+
+// SetAcceptEncoding sets the Accept-Encoding header of later requests, e.g.
+// "br" or "identity". A request's own header wins. "" restores the default,
+// gzip, deflate and br. Responses in any of those are decoded.
+func (he *HTTPEngine) SetAcceptEncoding(value string) {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	he.acceptEncoding = value
+}
+
+// parseContentEncoding checks the name of a request body compression,
+// returning "" for off
+func parseContentEncoding(encoding string) (string, error) {
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	if encoding == "" || encoding == "off" {
+		return "", nil
+	}
+	if !contentEncodings[encoding] {
+		return "", fmt.Errorf("unknown compression %s, expected gzip, deflate, br or off", encoding)
+	}
+	return encoding, nil
+}
+
+// compressBody encodes a request body with a content encoding
+func compressBody(data []byte, encoding string) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("unknown compression %s", encoding)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// decodeBody returns a reader of the decoded body for the Content-Encoding of
+// a response. Bodies in other encodings, and empty bodies such as the ones of
+// HEAD requests, are returned as they are.
+func decodeBody(body io.Reader, contentEncoding string) (io.Reader, error) {
+	encoding := strings.ToLower(strings.TrimSpace(contentEncoding))
+	if !contentEncodings[encoding] {
+		return body, nil
+	}
+	buffered := bufio.NewReader(body)
+	if _, err := buffered.Peek(1); err == io.EOF {
+		return buffered, nil
+	}
+
+	switch encoding {
+	case "gzip":
+		return gzip.NewReader(buffered)
+	case "br":
+		return brotli.NewReader(buffered), nil
+	}
+
+	// deflate should be zlib wrapped, but some servers send raw deflate
+	header, err := buffered.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(buffered)
+	}
+	return flate.NewReader(buffered), nil
+}
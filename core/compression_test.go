@@ -0,0 +1,79 @@
+package core
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestHTTPDSLv3Compression(t *testing.T) {
+	payload := strings.Repeat(`{"id":1,"name":"item"},`, 50)
+	var gotEncoding, gotAccept, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAccept = r.Header.Get("Accept-Encoding")
+		gotEncoding = r.Header.Get("Content-Encoding")
+		if gotEncoding == "gzip" {
+			reader, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("Expected a gzip body: %v", err)
+				return
+			}
+			data, _ := io.ReadAll(reader)
+			gotBody = string(data)
+		}
+		if r.URL.Path == "/br" {
+			w.Header().Set("Content-Encoding", "br")
+			writer := brotli.NewWriter(w)
+			writer.Write([]byte(payload))
+			writer.Close()
+			return
+		}
+		w.Write([]byte(payload))
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	script := `compress request body gzip
+POST "$base/items" json {"name": "item"}
+GET "$base/br"
+extract size as $size
+extract raw size as $raw`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("compression script failed: %v", err)
+	}
+	if gotBody != `{"name": "item"}` {
+		t.Errorf("Expected the gzip body to decode to the JSON, got %q", gotBody)
+	}
+	if gotAccept != defaultAcceptEncoding {
+		t.Errorf("Expected Accept-Encoding %q, got %q", defaultAcceptEncoding, gotAccept)
+	}
+	if body := dsl.GetEngine().GetLastResponse(); body != payload {
+		t.Errorf("Expected the br body to be decoded, got %q", body)
+	}
+	size, _ := dsl.GetVariable("size")
+	raw, _ := dsl.GetVariable("raw")
+	rawSize, _ := strconv.Atoi(fmt.Sprint(raw))
+	if fmt.Sprint(size) != strconv.Itoa(len(payload)) || rawSize <= 0 || rawSize >= len(payload) {
+		t.Errorf("Expected a raw size below the decoded size %d, got size %v raw %v", len(payload), size, raw)
+	}
+
+	// A request can opt out, and Accept-Encoding can be set for later requests
+	if _, err := dsl.ParseWithBlockSupport("accept encoding \"identity\"\nPOST \"$base/items\" body \"plain\" compress off"); err != nil {
+		t.Fatal(err)
+	}
+	if gotEncoding != "" || gotAccept != "identity" {
+		t.Errorf("Expected a plain body with Accept-Encoding identity, got %q and %q", gotEncoding, gotAccept)
+	}
+
+	if _, err := dsl.Parse("compress request body zip"); err == nil {
+		t.Error("Expected an unknown compression to fail")
+	}
+}
@@ -1,17 +1,29 @@
 package core
 
 import (
-	"fmt"
 	"strings"
 )
 
-// EvaluateCondition evaluates a condition string that may contain AND/OR operators
+// EvaluateCondition evaluates the condition of a block. Conditions go through
+// the grammar, so and/or/not, parentheses and string comparisons work as in
+// single-line statements; a condition that refers to an undefined variable is
+// false. Conditions the grammar cannot parse fall back to the string evaluator.
 func (hd *HTTPDSLv3) EvaluateCondition(conditionStr string) bool {
+	if condition, err := hd.parseRule("condition", conditionStr); err == nil {
+		holds, err := hd.evaluateCondition(condition)
+		return err == nil && holds
+	}
+	return hd.evaluateConditionString(conditionStr)
+}
+
+// evaluateConditionString evaluates a condition string that may contain
+// AND/OR operators
+func (hd *HTTPDSLv3) evaluateConditionString(conditionStr string) bool {
 	// Handle OR operator (lower precedence)
 	if strings.Contains(conditionStr, " OR ") {
 		parts := strings.Split(conditionStr, " OR ")
 		for _, part := range parts {
-			if hd.EvaluateCondition(strings.TrimSpace(part)) {
+			if hd.evaluateConditionString(strings.TrimSpace(part)) {
 				return true
 			}
 		}
@@ -22,7 +34,7 @@ func (hd *HTTPDSLv3) EvaluateCondition(conditionStr string) bool {
 	if strings.Contains(conditionStr, " AND ") {
 		parts := strings.Split(conditionStr, " AND ")
 		for _, part := range parts {
-			if !hd.EvaluateCondition(strings.TrimSpace(part)) {
+			if !hd.evaluateConditionString(strings.TrimSpace(part)) {
 				return false
 			}
 		}
@@ -41,7 +53,7 @@ func (hd *HTTPDSLv3) EvaluateSimpleCondition(conditionStr string) bool {
 	// Handle single variable check (e.g., "if $var then")
 	if len(parts) == 1 {
 		varName := strings.TrimPrefix(parts[0], "$")
-		if val, ok := hd.variables[varName]; ok {
+		if val, ok := hd.lookupVariable(varName); ok {
 			// Check if variable exists and is truthy
 			switch v := val.(type) {
 			case bool:
@@ -72,7 +84,7 @@ func (hd *HTTPDSLv3) EvaluateSimpleCondition(conditionStr string) bool {
 	var leftVal interface{}
 	if strings.HasPrefix(leftSide, "$") {
 		varName := strings.TrimPrefix(leftSide, "$")
-		if val, ok := hd.variables[varName]; ok {
+		if val, ok := hd.lookupVariable(varName); ok {
 			leftVal = val
 		} else {
 			return false
@@ -85,7 +97,7 @@ func (hd *HTTPDSLv3) EvaluateSimpleCondition(conditionStr string) bool {
 	var rightVal interface{}
 	if strings.HasPrefix(rightSide, "$") {
 		varName := strings.TrimPrefix(rightSide, "$")
-		if val, ok := hd.variables[varName]; ok {
+		if val, ok := hd.lookupVariable(varName); ok {
 			rightVal = val
 		} else {
 			return false
@@ -98,76 +110,8 @@ func (hd *HTTPDSLv3) EvaluateSimpleCondition(conditionStr string) bool {
 	return hd.CompareValues(leftVal, operator, rightVal)
 }
 
-// CompareValues compares two values with an operator
+// CompareValues compares two values with an operator, as numbers when both
+// are numeric and as strings otherwise
 func (hd *HTTPDSLv3) CompareValues(left interface{}, operator string, right interface{}) bool {
-	// Try numeric comparison first
-	var leftNum, rightNum float64
-	var leftIsNum, rightIsNum bool
-
-	// Convert left to number
-	switch v := left.(type) {
-	case int:
-		leftNum = float64(v)
-		leftIsNum = true
-	case float64:
-		leftNum = v
-		leftIsNum = true
-	case string:
-		if _, err := fmt.Sscanf(v, "%f", &leftNum); err == nil {
-			leftIsNum = true
-		}
-	}
-
-	// Convert right to number
-	switch v := right.(type) {
-	case int:
-		rightNum = float64(v)
-		rightIsNum = true
-	case float64:
-		rightNum = v
-		rightIsNum = true
-	case string:
-		if _, err := fmt.Sscanf(v, "%f", &rightNum); err == nil {
-			rightIsNum = true
-		}
-	}
-
-	// If both are numbers, do numeric comparison
-	if leftIsNum && rightIsNum {
-		switch operator {
-		case ">":
-			return leftNum > rightNum
-		case "<":
-			return leftNum < rightNum
-		case ">=":
-			return leftNum >= rightNum
-		case "<=":
-			return leftNum <= rightNum
-		case "==":
-			return leftNum == rightNum
-		case "!=":
-			return leftNum != rightNum
-		}
-	}
-
-	// Otherwise do string comparison
-	leftStr := fmt.Sprintf("%v", left)
-	rightStr := fmt.Sprintf("%v", right)
-
-	switch operator {
-	case "==":
-		return leftStr == rightStr
-	case "!=":
-		return leftStr != rightStr
-	case ">":
-		return leftStr > rightStr
-	case "<":
-		return leftStr < rightStr
-	case ">=":
-		return leftStr >= rightStr
-	case "<=":
-		return leftStr <= rightStr
-	}
-
-	return false
+	return hd.engine.Compare(left, operator, right)
 }
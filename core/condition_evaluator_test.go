@@ -0,0 +1,82 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHTTPDSLv3BlockConditions(t *testing.T) {
+	dsl := NewHTTPDSLv3()
+	script := `set $name "admin"
+set $count 3
+set $role "guest"
+if $name == "admin" and $count > 2 then
+  set $both "yes"
+endif
+if $role == "admin" or ($name == "admin" and not $count > 5) then
+  set $grouped "yes"
+else
+  set $grouped "no"
+endif
+if $missing == "x" then
+  set $undefined "yes"
+else
+  set $undefined "no"
+endif
+if $name contains "adm" then set $short "yes" else set $short "no"
+set $i 0
+while $i < 3 and $name != "" do
+  set $i $i + 1
+endloop`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+
+	expected := map[string]string{"both": "yes", "grouped": "yes", "undefined": "no", "short": "yes", "i": "3"}
+	for name, want := range expected {
+		if value, _ := dsl.GetVariable(name); fmt.Sprint(value) != want {
+			t.Errorf("Expected $%s to be %q, got %v", name, want, value)
+		}
+	}
+}
+
+func TestHTTPDSLv3WhileStringConditions(t *testing.T) {
+	dsl := NewHTTPDSLv3()
+	script := `set $next "page2"
+set $pages 0
+while $next != "" do
+  set $pages $pages + 1
+  if $pages >= 3 then
+    set $next ""
+  endif
+endloop
+set $log "retry"
+set $retries 0
+while $log contains "retry" do
+  set $retries $retries + 1
+  set $log "ok"
+endloop
+set $polls 0
+while not $cursor exists do
+  set $polls $polls + 1
+  set $cursor "abc"
+endloop
+set $waits 0
+while $token empty do
+  set $waits $waits + 1
+  set $token "t"
+endloop`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+
+	expected := map[string]string{"pages": "3", "retries": "1", "polls": "1", "waits": "1"}
+	for name, want := range expected {
+		if value, _ := dsl.GetVariable(name); fmt.Sprint(value) != want {
+			t.Errorf("Expected $%s to be %q, got %v", name, want, value)
+		}
+	}
+	if dsl.CompareValues("12abc", "==", "12") {
+		t.Error("Expected \"12abc\" not to equal 12")
+	}
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"net/http/httptrace"
+	"time"
+)
+
+// connectionUse is how a request got its connection
+type connectionUse struct {
+	known  bool          // Whether the request got a connection at all
+	reused bool          // Taken from the pool of kept-alive connections instead of dialed
+	idle   time.Duration // How long a reused connection had been idle in the pool
+}
+
+// gotConn records the connection of a request; on a redirect the last one
+// is kept, the one the response came on
+func (t *timingTrace) gotConn(info httptrace.GotConnInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.conn = connectionUse{known: true, reused: info.Reused, idle: info.IdleTime}
+}
+
+// connection returns the connection the request got
+func (t *timingTrace) connection() connectionUse {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.conn
+}
+
+// recordConnection counts a connection in the pool metrics: connections
+// opened and reused, the share of requests that reused one, and whether the
+// last request did and how long its connection had been idle
+func (he *HTTPEngine) recordConnection(use connectionUse) {
+	if !use.known {
+		return
+	}
+	he.metricsLock.Lock()
+	defer he.metricsLock.Unlock()
+
+	opened, _ := he.metrics["connections_opened"].(int)
+	reused, _ := he.metrics["connections_reused"].(int)
+	if use.reused {
+		reused++
+	} else {
+		opened++
+	}
+	he.metrics["connections_opened"] = opened
+	he.metrics["connections_reused"] = reused
+	he.metrics["connection_reuse_rate"] = float64(reused) / float64(opened+reused)
+	he.metrics["last_connection_reused"] = use.reused
+	he.metrics["last_connection_idle_ms"] = use.idle.Milliseconds()
+}
+
+// forgetLastRequest clears what the last request left when a request fails:
+// its connection, redirects and cache status are not those of the failed one
+func (he *HTTPEngine) forgetLastRequest() {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	he.lastRedirects = nil
+	he.lastConnection = connectionUse{}
+	he.lastCacheStatus = ""
+}
+
+// GetLastConnectionReused reports whether the last request reused a
+// kept-alive connection. ok is false when it got no connection, because it
+// failed before one was made or the response cache answered it.
+func (he *HTTPEngine) GetLastConnectionReused() (reused bool, ok bool) {
+	he.mu.RLock()
+	defer he.mu.RUnlock()
+	return he.lastConnection.reused, he.lastConnection.known
+}
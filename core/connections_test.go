@@ -0,0 +1,94 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPDSLv3ConnectionReuse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/close":
+			w.Header().Set("Connection", "close")
+		case "/hop":
+			http.Redirect(w, r, "/keep", http.StatusFound)
+			return
+		case "/broken":
+			// Announced as gzip, but not
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	script := `GET "$base/keep"
+assert connection new
+GET "$base/keep"
+assert connection reused
+GET "$base/close"
+assert connection reused
+GET "$base/keep"
+assert connection new`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("connection script failed: %v", err)
+	}
+	metrics := dsl.GetEngine().GetMetrics()
+	if metrics["connections_opened"] != 2 || metrics["connections_reused"] != 2 || metrics["connection_reuse_rate"] != 0.5 {
+		t.Errorf("Unexpected pool metrics: %v", metrics)
+	}
+	if history := dsl.GetEngine().GetHistory(); !history[1].Reused || history[3].Reused {
+		t.Error("Expected the history to record which requests reused a connection")
+	}
+
+	// A failed request got no connection, whatever the one before it got
+	if _, err := dsl.Parse(`GET "http://127.0.0.1:1/down"`); err == nil {
+		t.Fatal("Expected the request to a closed port to fail")
+	}
+	if _, ok := dsl.GetEngine().GetLastConnectionReused(); ok {
+		t.Error("Expected no connection after a failed request")
+	}
+
+	// Nor does one whose body could not be read, or that a hook refused
+	for _, path := range []string{"broken", "refused"} {
+		if _, err := dsl.ParseWithBlockSupport(`GET "$base/hop"
+extract redirects count as $before`); err != nil {
+			t.Fatalf("redirected request failed: %v", err)
+		}
+		if _, err := dsl.Parse(`GET "$base/` + path + `"`); err == nil {
+			t.Fatalf("Expected the request to %s to fail", path)
+		}
+		if _, err := dsl.Parse(`extract redirects count as $after`); err != nil {
+			t.Fatalf("extract redirects count failed: %v", err)
+		}
+		before, _ := dsl.GetVariable("before")
+		after, _ := dsl.GetVariable("after")
+		if before != 1 || after != 0 {
+			t.Errorf("%s: expected 1 redirect, then none after the failure, got %v and %v", path, before, after)
+		}
+		if _, ok := dsl.GetEngine().GetLastConnectionReused(); ok {
+			t.Errorf("%s: expected no connection after a failed request", path)
+		}
+		if path == "broken" {
+			dsl.GetEngine().AddResponseHook(func(resp *http.Response) error {
+				if resp.Request.URL.Path == "/refused" {
+					return errors.New("refused")
+				}
+				return nil
+			})
+		}
+	}
+
+	_, err := dsl.ParseWithBlockSupport(`GET "$base/keep"
+assert connection new`)
+	if err == nil || !strings.Contains(err.Error(), "expected connection new, got connection reused") {
+		t.Errorf("Expected assert connection new to fail, got %v", err)
+	}
+	if _, err := dsl.Parse(`assert connection pooled`); err == nil || !strings.Contains(err.Error(), "expected assert connection reused or assert connection new") {
+		t.Errorf("Expected an invalid connection state error, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package core
+
+import "strings"
+
+// continuesOnNextLine reports whether a line ends with a \ that continues its
+// statement on the next line
+func continuesOnNextLine(line string) bool {
+	line = strings.TrimRight(line, " \t")
+	if !strings.HasSuffix(line, `\`) {
+		return false
+	}
+	rest := strings.TrimSuffix(line, `\`)
+	return rest == "" || strings.HasSuffix(rest, " ") || strings.HasSuffix(rest, "\t")
+}
+
+// joinContinuations puts statements written over several lines on their
+// first line: a line ending with \ continues on the next one, and header
+// lines continue the request above them at any indentation. The lines taken
+// are left empty, so line numbers still match the script.
+//
+//	POST "$base/users" \
+//	    json {"name": "Ada"}
+//	    header "X-Trace" "1"
+func joinContinuations(lines []string) []string {
+	joined := make([]string, len(lines))
+	request := -1 // Index of the request that header lines continue
+	for i := 0; i < len(lines); i++ {
+		start := i
+		line := lines[i]
+		for continuesOnNextLine(line) && i+1 < len(lines) {
+			i++
+			line = strings.TrimRight(strings.TrimSuffix(strings.TrimRight(line, " \t"), `\`), " \t") +
+				" " + strings.TrimSpace(lines[i])
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if request >= 0 && strings.HasPrefix(trimmed, "header ") {
+			joined[request] += " " + trimmed
+			continue
+		}
+
+		joined[start] = line
+		request = -1
+		if isHTTPMethod(trimmed) {
+			request = start
+		}
+	}
+	return joined
+}
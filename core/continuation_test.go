@@ -0,0 +1,51 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestHTTPDSLv3LineContinuation(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r.Method+" "+r.Header.Get("X-A")+r.Header.Get("X-B")+" "+string(body))
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	script := `POST "$base/users" \
+  json {"name": "Ada"} \  # the body
+  timeout 5 s
+foreach $tag in ["x", "y"] do
+  GET "$base/tags"
+  header "X-A" "$tag"
+      header "X-B" "b"
+endloop
+set $total 1 + \
+  2`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+
+	expected := []string{`POST  {"name": "Ada"}`, "GET xb ", "GET yb "}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("Expected requests %q, got %q", expected, received)
+	}
+	if total, _ := dsl.GetVariable("total"); fmt.Sprint(total) != "3" {
+		t.Errorf("Expected $total to be 3, got %v", total)
+	}
+	if problems := dsl.Validate(script); len(problems) != 0 {
+		t.Errorf("Expected no syntax errors, got %v", problems)
+	}
+
+	formatted := dsl.Format("GET \"$base/users\"   \\\nauth bearer \"t\"")
+	if formatted != "GET \"$base/users\" \\\n    auth bearer \"t\"\n" {
+		t.Errorf("Expected the continued line to be indented, got:\n%s", formatted)
+	}
+}
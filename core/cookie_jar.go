@@ -0,0 +1,167 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// cookieRecorder is the jar given to the client. It stores cookies in a
+// cookiejar.Jar and keeps a copy of each one, since cookiejar.Jar cannot list
+// its cookies for export.
+type cookieRecorder struct {
+	*cookiejar.Jar
+	mu      sync.Mutex
+	cookies map[string]recordedCookie // Keyed by host, domain, path and name
+}
+
+// recordedCookie is a cookie with the URL of the response that set it
+type recordedCookie struct {
+	url    *url.URL
+	cookie http.Cookie
+}
+
+// ExportedCookie is one cookie in the JSON written by ExportCookies
+type ExportedCookie struct {
+	URL      string     `json:"url"` // URL of the response that set the cookie
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Path     string     `json:"path,omitempty"`
+	Domain   string     `json:"domain,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"` // Nil for session cookies
+	Secure   bool       `json:"secure,omitempty"`
+	HttpOnly bool       `json:"http_only,omitempty"`
+}
+
+// SetCookies stores the cookies in the jar and records them
+func (r *cookieRecorder) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	r.Jar.SetCookies(u, cookies)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, cookie := range cookies {
+		key := u.Hostname() + "|" + cookie.Domain + "|" + cookie.Path + "|" + cookie.Name
+		if cookie.MaxAge < 0 || (!cookie.Expires.IsZero() && cookie.Expires.Before(time.Now())) {
+			delete(r.cookies, key)
+			continue
+		}
+
+		stored := *cookie
+		if stored.MaxAge > 0 {
+			stored.Expires = time.Now().Add(time.Duration(stored.MaxAge) * time.Second)
+			stored.MaxAge = 0
+		}
+		r.cookies[key] = recordedCookie{url: u, cookie: stored}
+	}
+}
+
+// export returns the recorded cookies still held by the jar, sorted by URL
+// and name
+func (r *cookieRecorder) export() []ExportedCookie {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	exported := make([]ExportedCookie, 0, len(r.cookies))
+	for _, record := range r.cookies {
+		cookie := record.cookie
+		if !cookie.Expires.IsZero() && cookie.Expires.Before(time.Now()) {
+			continue
+		}
+
+		// Look the cookie up where it applies to find its current value
+		target := *record.url
+		if cookie.Path != "" {
+			target.Path = cookie.Path
+		}
+		value, found := "", false
+		for _, held := range r.Jar.Cookies(&target) {
+			if held.Name == cookie.Name {
+				value, found = held.Value, true
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+
+		entry := ExportedCookie{
+			URL:      record.url.String(),
+			Name:     cookie.Name,
+			Value:    value,
+			Path:     cookie.Path,
+			Domain:   cookie.Domain,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+		}
+		if !cookie.Expires.IsZero() {
+			expires := cookie.Expires
+			entry.Expires = &expires
+		}
+		exported = append(exported, entry)
+	}
+
+	sort.Slice(exported, func(i, j int) bool {
+		if exported[i].URL != exported[j].URL {
+			return exported[i].URL < exported[j].URL
+		}
+		return exported[i].Name < exported[j].Name
+	})
+	return exported
+}
+
+// useJar makes jar the engine's cookie jar; the caller holds he.mu
+func (he *HTTPEngine) useJar(client *http.Client, jar *cookiejar.Jar) {
+	recorder, ok := he.cookieRecorders[jar]
+	if !ok {
+		recorder = &cookieRecorder{Jar: jar, cookies: make(map[string]recordedCookie)}
+		he.cookieRecorders[jar] = recorder
+	}
+	he.cookies = jar
+	client.Jar = recorder
+}
+
+// ExportCookies exports the cookies in the jar of the current session as a
+// JSON array of ExportedCookie, for ImportCookies to load in a later run.
+// Expired cookies are left out.
+func (he *HTTPEngine) ExportCookies() (string, error) {
+	data, err := json.MarshalIndent(he.cookieJar().export(), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ImportCookies adds the cookies from JSON written by ExportCookies to the jar
+// of the current session
+func (he *HTTPEngine) ImportCookies(jsonStr string) error {
+	var cookies []ExportedCookie
+	if err := json.Unmarshal([]byte(jsonStr), &cookies); err != nil {
+		return fmt.Errorf("invalid cookie JSON: %w", err)
+	}
+
+	jar := he.cookieJar()
+	for _, entry := range cookies {
+		u, err := url.Parse(entry.URL)
+		if err != nil {
+			return fmt.Errorf("cookie %s: %w", entry.Name, err)
+		}
+		cookie := &http.Cookie{
+			Name:     entry.Name,
+			Value:    entry.Value,
+			Path:     entry.Path,
+			Domain:   entry.Domain,
+			Secure:   entry.Secure,
+			HttpOnly: entry.HttpOnly,
+		}
+		if entry.Expires != nil {
+			cookie.Expires = *entry.Expires
+		}
+		jar.SetCookies(u, []*http.Cookie{cookie})
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPEngineExportImportCookies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "xyz", Path: "/", HttpOnly: true})
+			http.SetCookie(w, &http.Cookie{Name: "gone", Value: "1", Path: "/"})
+			http.SetCookie(w, &http.Cookie{Name: "gone", Value: "", Path: "/", MaxAge: -1})
+		}
+		if cookie, err := r.Cookie("sid"); err == nil {
+			w.Write([]byte(cookie.Value))
+		}
+	}))
+	defer server.Close()
+
+	first := NewHTTPEngine()
+	if _, err := first.Request("GET", server.URL+"/login", nil); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	exported, err := first.ExportCookies()
+	if err != nil {
+		t.Fatalf("ExportCookies failed: %v", err)
+	}
+
+	var cookies []ExportedCookie
+	if err := json.Unmarshal([]byte(exported), &cookies); err != nil {
+		t.Fatalf("Exported cookies are not valid JSON: %v", err)
+	}
+	if len(cookies) != 1 || cookies[0].Name != "sid" || !cookies[0].HttpOnly {
+		t.Fatalf("Expected only the sid cookie to be exported, got %s", exported)
+	}
+
+	second := NewHTTPEngine()
+	if err := second.ImportCookies(exported); err != nil {
+		t.Fatalf("ImportCookies failed: %v", err)
+	}
+	if _, err := second.Request("GET", server.URL+"/feature", nil); err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if body := second.GetLastResponse(); body != "xyz" {
+		t.Errorf("Expected the imported cookie to be sent, got %q", body)
+	}
+
+	second.ClearCookies()
+	if exported, _ := second.ExportCookies(); exported != "[]" {
+		t.Errorf("Expected no cookies after ClearCookies, got %s", exported)
+	}
+}
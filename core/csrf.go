@@ -0,0 +1,99 @@
+package core
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// csrfNames are the field, meta tag and cookie names frameworks use for CSRF
+// tokens, besides names containing csrf or xsrf: Rails, Laravel, ASP.NET
+// and Django
+var csrfNames = map[string]bool{
+	"authenticity_token":         true,
+	"_token":                     true,
+	"__requestverificationtoken": true,
+	"csrfmiddlewaretoken":        true,
+}
+
+// csrfHeaders are the response headers some APIs send the token in
+var csrfHeaders = []string{"X-CSRF-Token", "X-XSRF-Token", "X-CSRFToken"}
+
+// isCSRFName reports whether a field, meta tag or cookie name holds a token.
+// Rails' csrf-param meta tag holds the name of the field, not a token.
+func isCSRFName(name string) bool {
+	name = strings.ToLower(name)
+	if strings.HasSuffix(name, "param") {
+		return false
+	}
+	return csrfNames[name] || strings.Contains(name, "csrf") || strings.Contains(name, "xsrf")
+}
+
+// extractCSRF finds the CSRF token of the last response, looking in order
+// at meta tags (<meta name="csrf-token" content="...">), hidden inputs,
+// X-CSRF-Token style headers and cookies like XSRF-TOKEN or csrftoken. It
+// returns nil when there is none.
+func (he *HTTPEngine) extractCSRF(resp *http.Response, body string) interface{} {
+	if token := csrfFromHTML(body); token != "" {
+		return token
+	}
+	if resp == nil {
+		return nil
+	}
+	for _, name := range csrfHeaders {
+		if token := resp.Header.Get(name); token != "" {
+			return token
+		}
+	}
+
+	cookies := resp.Cookies()
+	if resp.Request != nil {
+		cookies = append(cookies, he.cookieJar().Cookies(resp.Request.URL)...)
+	}
+	for _, cookie := range cookies {
+		if isCSRFName(cookie.Name) && cookie.Value != "" {
+			// Laravel and Angular URL encode the cookie; a + is kept, as
+			// base64 tokens hold it and encoders write spaces as %20
+			if token, err := url.PathUnescape(cookie.Value); err == nil {
+				return token
+			}
+			return cookie.Value
+		}
+	}
+	return nil
+}
+
+// csrfFromHTML returns the token of the first CSRF meta tag of page, or else
+// of its first hidden CSRF input
+func csrfFromHTML(page string) string {
+	if !strings.Contains(page, "<") {
+		return ""
+	}
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		return ""
+	}
+
+	var meta, input string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch {
+			case n.Data == "meta" && meta == "" && isCSRFName(htmlAttr(n, "name")):
+				meta = htmlAttr(n, "content")
+			case n.Data == "input" && input == "" && strings.EqualFold(htmlAttr(n, "type"), "hidden") && isCSRFName(htmlAttr(n, "name")):
+				input = htmlAttr(n, "value")
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	if meta != "" {
+		return meta
+	}
+	return input
+}
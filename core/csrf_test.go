@@ -0,0 +1,48 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestHTTPDSLv3ExtractCSRF(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rails":
+			w.Write([]byte(`<html><head><meta name="csrf-param" content="authenticity_token"><meta name="csrf-token" content="meta-token"></head></html>`))
+		case "/django":
+			w.Write([]byte(`<form><input type="hidden" name="csrfmiddlewaretoken" value="input-token"></form>`))
+		case "/spa":
+			http.SetCookie(w, &http.Cookie{Name: "XSRF-TOKEN", Value: url.QueryEscape("cookie=token"), Path: "/"})
+			w.Write([]byte(`{"ok": true}`))
+		case "/laravel":
+			http.SetCookie(w, &http.Cookie{Name: "XSRF-TOKEN", Value: "eyJp+diI6/Ik%3D", Path: "/"})
+			w.Write([]byte(`{"ok": true}`))
+		default:
+			w.Write([]byte(`<html></html>`))
+		}
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+
+	for path, expected := range map[string]string{
+		"rails":   "meta-token",
+		"django":  "input-token",
+		"spa":     "cookie=token",
+		"laravel": "eyJp+diI6/Ik=",
+		"none":    "",
+	} {
+		dsl.GetEngine().ClearCookies()
+		if _, err := dsl.ParseWithBlockSupport(`GET "$base/` + path + `"
+extract csrf as $token`); err != nil {
+			t.Fatalf("%s: extract csrf failed: %v", path, err)
+		}
+		if token, _ := dsl.GetVariable("token"); token != expected {
+			t.Errorf("%s: expected token %q, got %v", path, expected, token)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReadCSVRows reads a CSV file whose first line names the columns and returns
+// one object per following line, keyed by column name, as foreach ... in csv
+// and the runner's --data flag iterate them. Values are kept as strings; every
+// line must have as many fields as the header.
+func ReadCSVRows(name string) ([]map[string]interface{}, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("csv %s: %w", name, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("csv %s has no header line", name)
+	}
+
+	header := records[0]
+	for i, column := range header {
+		// Spreadsheets often save a byte order mark before the first column
+		header[i] = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+		if header[i] == "" {
+			return nil, fmt.Errorf("csv %s: column %d has no name", name, i+1)
+		}
+	}
+
+	rows := make([]map[string]interface{}, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]interface{}, len(header))
+		for i, column := range header {
+			row[column] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHTTPDSLv3ForeachCSV(t *testing.T) {
+	dir := t.TempDir()
+	data := "\ufeffemail, password\na@x.io,p1\n\"b@x.io\",\"p,2\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "users.csv"), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("dir", dir)
+	dsl.SetVariable("seen", "")
+	script := `foreach $row in csv "$dir/users.csv" do
+    set $seen "$seen$row.email:$row.password;"
+endloop`
+	if problems := dsl.Validate(script); len(problems) != 0 {
+		t.Fatalf("Unexpected problems: %v", problems)
+	}
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("foreach over csv failed: %v", err)
+	}
+	if seen, _ := dsl.GetVariable("seen"); seen != "a@x.io:p1;b@x.io:p,2;" {
+		t.Errorf("Unexpected rows: %v", seen)
+	}
+
+	if _, err := dsl.ParseWithBlockSupport("foreach $row in csv \"$dir/missing.csv\" do\nendloop"); err == nil {
+		t.Error("Expected a missing csv file to fail")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ragged.csv"), []byte("a,b\n1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadCSVRows(filepath.Join(dir, "ragged.csv")); err == nil {
+		t.Error("Expected a row with missing fields to fail")
+	}
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// diffValues lists every difference between two values, one per line: - for
+// what only the left side has, + for what only the right side has and ~ for
+// values that changed. Values that both decode as JSON are compared
+// structurally, others line by line as text.
+func diffValues(left, right interface{}) []string {
+	a, errA := normalizeJSON(left)
+	b, errB := normalizeJSON(right)
+	if errA == nil && errB == nil {
+		return jsonDifferences("$", a, b)
+	}
+	return textDifferences(formatValue(left), formatValue(right))
+}
+
+// jsonDifferences walks two decoded JSON values and describes every
+// difference, with object keys in a stable order
+func jsonDifferences(path string, a, b interface{}) []string {
+	switch left := a.(type) {
+	case map[string]interface{}:
+		right, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		var diffs []string
+		for _, key := range sortedKeys(left) {
+			if value, exists := right[key]; exists {
+				diffs = append(diffs, jsonDifferences(path+"."+key, left[key], value)...)
+			} else {
+				diffs = append(diffs, fmt.Sprintf("- %s.%s: %s", path, key, jsonText(left[key])))
+			}
+		}
+		for _, key := range sortedKeys(right) {
+			if _, exists := left[key]; !exists {
+				diffs = append(diffs, fmt.Sprintf("+ %s.%s: %s", path, key, jsonText(right[key])))
+			}
+		}
+		return diffs
+
+	case []interface{}:
+		right, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		var diffs []string
+		for i := 0; i < len(left) || i < len(right); i++ {
+			item := path + "[" + strconv.Itoa(i) + "]"
+			switch {
+			case i >= len(right):
+				diffs = append(diffs, fmt.Sprintf("- %s: %s", item, jsonText(left[i])))
+			case i >= len(left):
+				diffs = append(diffs, fmt.Sprintf("+ %s: %s", item, jsonText(right[i])))
+			default:
+				diffs = append(diffs, jsonDifferences(item, left[i], right[i])...)
+			}
+		}
+		return diffs
+	}
+
+	if reflect.DeepEqual(a, b) {
+		return nil
+	}
+	return []string{fmt.Sprintf("~ %s: %s -> %s", path, jsonText(a), jsonText(b))}
+}
+
+// textDifferences compares two texts line by line
+func textDifferences(a, b string) []string {
+	left := strings.Split(a, "\n")
+	right := strings.Split(b, "\n")
+	var diffs []string
+	for i := 0; i < len(left) || i < len(right); i++ {
+		switch {
+		case i >= len(right):
+			diffs = append(diffs, fmt.Sprintf("- line %d: %s", i+1, left[i]))
+		case i >= len(left):
+			diffs = append(diffs, fmt.Sprintf("+ line %d: %s", i+1, right[i]))
+		case left[i] != right[i]:
+			diffs = append(diffs, fmt.Sprintf("~ line %d: %q -> %q", i+1, left[i], right[i]))
+		}
+	}
+	return diffs
+}
+
+// diffCommand compares two values for the diff command, failing when they
+// differ
+func diffCommand(left, right interface{}) (interface{}, error) {
+	diffs := diffValues(left, right)
+	if len(diffs) == 0 {
+		return "✓ No differences", nil
+	}
+	return nil, fmt.Errorf("diff found %d difference(s):\n  %s", len(diffs), strings.Join(diffs, "\n  "))
+}
+
+// readDiffFile reads a file compared by diff, without the line break that
+// usually ends it
+func readDiffFile(name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", fmt.Errorf("diff: %w", err)
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHTTPDSLv3Diff(t *testing.T) {
+	version := "v1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if version == "v1" {
+			fmt.Fprint(w, `{"id": 1, "name": "Ada", "tags": ["a", "b"]}`)
+		} else {
+			fmt.Fprint(w, `{"tags": ["a"], "name": "Grace", "id": 1, "email": "g@example.com"}`)
+		}
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	if _, err := dsl.ParseWithBlockSupport(`GET "$base/users/1"
+extract body as $respA`); err != nil {
+		t.Fatalf("First request failed: %v", err)
+	}
+
+	expected := filepath.Join(t.TempDir(), "expected.json")
+	if err := os.WriteFile(expected, []byte(`{"name": "Ada", "tags": ["a", "b"], "id": 1}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dsl.SetVariable("expected", expected)
+	if _, err := dsl.Parse(`diff response with file "$expected"`); err != nil {
+		t.Errorf("Expected no differences with the file, got %v", err)
+	}
+
+	version = "v2"
+	_, err := dsl.ParseWithBlockSupport(`GET "$base/users/1"
+extract body as $respB
+diff $respA $respB`)
+	if err == nil {
+		t.Fatal("Expected diff to fail")
+	}
+	for _, want := range []string{
+		"3 difference(s)",
+		`+ $.email: "g@example.com"`,
+		`~ $.name: "Ada" -> "Grace"`,
+		`- $.tags[1]: "b"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in the diff, got:\n%v", want, err)
+		}
+	}
+
+	dsl.SetVariable("a", "one\ntwo")
+	dsl.SetVariable("b", "one\nthree")
+	if _, err := dsl.Parse(`diff $a $b`); err == nil || !strings.Contains(err.Error(), `~ line 2: "two" -> "three"`) {
+		t.Errorf("Expected a text diff, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// lookupDNS resolves host for dns lookup. The record type selects what is
+// returned: "" all addresses, a or aaaa the IPv4 or IPv6 addresses, txt the
+// TXT records, all as arrays, and cname the canonical name as a string.
+func lookupDNS(ctx context.Context, host, recordType string) (interface{}, error) {
+	resolver := net.DefaultResolver
+	recordType = strings.ToLower(recordType)
+
+	switch recordType {
+	case "", "a", "aaaa":
+		network := map[string]string{"": "ip", "a": "ip4", "aaaa": "ip6"}[recordType]
+		ips, err := resolver.LookupIP(ctx, network, host)
+		if err != nil {
+			return nil, fmt.Errorf("dns lookup %s: %w", host, err)
+		}
+		addresses := make([]interface{}, len(ips))
+		for i, ip := range ips {
+			addresses[i] = ip.String()
+		}
+		return addresses, nil
+
+	case "cname":
+		cname, err := resolver.LookupCNAME(ctx, host)
+		if err != nil {
+			return nil, fmt.Errorf("dns lookup %s: %w", host, err)
+		}
+		return strings.TrimSuffix(cname, "."), nil
+
+	case "txt":
+		records, err := resolver.LookupTXT(ctx, host)
+		if err != nil {
+			return nil, fmt.Errorf("dns lookup %s: %w", host, err)
+		}
+		values := make([]interface{}, len(records))
+		for i, record := range records {
+			values[i] = record
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("unknown record type %s, expected A, AAAA, CNAME or TXT", recordType)
+}
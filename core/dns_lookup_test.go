@@ -0,0 +1,27 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTTPDSLv3DNSLookup(t *testing.T) {
+	dsl := NewHTTPDSLv3()
+	script := `dns lookup "localhost" as $ips
+dns lookup "localhost" A as $v4`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("dns lookup failed: %v", err)
+	}
+	v4, _ := dsl.GetVariable("v4")
+	if !reflect.DeepEqual(v4, []interface{}{"127.0.0.1"}) {
+		t.Errorf("Expected localhost to resolve to 127.0.0.1, got %v", v4)
+	}
+	if ips, _ := dsl.GetVariable("ips"); len(ips.([]interface{})) == 0 {
+		t.Error("Expected addresses for localhost")
+	}
+
+	if _, err := dsl.Parse(`dns lookup "localhost" MX as $mx`); err == nil || !strings.Contains(err.Error(), "unknown record type") {
+		t.Errorf("Expected an unknown record type error, got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"net/http"
+	"strings"
+)
+
+// validators are the cache validators of the last response for a URL
+type validators struct {
+	etag         string
+	lastModified string
+}
+
+// SetETagCache makes the engine remember the ETag and Last-Modified headers of
+// successful GET and HEAD responses per URL, and send them back as
+// If-None-Match and If-Modified-Since on later requests to the same URL, so
+// scripts can check that unchanged resources answer 304 Not Modified.
+// Turning it off forgets the stored validators.
+func (he *HTTPEngine) SetETagCache(enabled bool) {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	if !enabled {
+		he.etags = nil
+		return
+	}
+	if he.etags == nil {
+		he.etags = make(map[string]validators)
+	}
+}
+
+// ClearETagCache forgets the stored validators but keeps the cache on, so the
+// next request to every URL is unconditional again
+func (he *HTTPEngine) ClearETagCache() {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	if he.etags != nil {
+		he.etags = make(map[string]validators)
+	}
+}
+
+// conditional reports whether a request takes part in the ETag cache
+func conditional(method string) bool {
+	return strings.EqualFold(method, http.MethodGet) || strings.EqualFold(method, http.MethodHead)
+}
+
+// setConditionalHeaders adds the stored validators of a URL to a request.
+// Conditional headers the request sets itself are kept.
+func (v validators) setConditionalHeaders(req *http.Request) {
+	if req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return
+	}
+	if v.etag != "" {
+		req.Header.Set("If-None-Match", v.etag)
+	}
+	if v.lastModified != "" {
+		req.Header.Set("If-Modified-Since", v.lastModified)
+	}
+}
+
+// storeValidators records the validators of a response for a URL. A 304 keeps
+// the ones already stored, since the cached representation is still current.
+// Callers hold he.mu.
+func (he *HTTPEngine) storeValidators(key string, resp *http.Response) {
+	if he.etags == nil || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return
+	}
+	v := validators{
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}
+	if v.etag == "" && v.lastModified == "" {
+		delete(he.etags, key)
+		return
+	}
+	he.etags[key] = v
+}
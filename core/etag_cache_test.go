@@ -0,0 +1,47 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestHTTPDSLv3ETagCache(t *testing.T) {
+	var conditionals []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditionals = append(conditionals, r.Header.Get("If-None-Match")+"|"+r.Header.Get("If-Modified-Since"))
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Wed, 14 Oct 2026 10:00:00 GMT")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(`{"id": 1}`))
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	script := `GET "$base/items/1"
+cache etags on
+GET "$base/items/1"
+assert status 200
+GET "$base/items/1"
+assert status 304
+GET "$base/items/2" header "If-None-Match" "\"v0\""
+assert status 200
+clear etags
+GET "$base/items/1"
+assert status 200
+cache etags off
+GET "$base/items/1"
+assert status 200`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("etag script failed: %v", err)
+	}
+	expected := []string{"|", "|", `"v1"|Wed, 14 Oct 2026 10:00:00 GMT`, `"v0"|`, "|", "|"}
+	if !reflect.DeepEqual(conditionals, expected) {
+		t.Errorf("Unexpected conditional headers:\n got %q\nwant %q", conditionals, expected)
+	}
+}
@@ -0,0 +1,68 @@
+package core
+
+import "time"
+
+// Event types emitted to listeners registered with OnEvent
+const (
+	EventStatementStart   = "statement_start"   // A statement is about to run
+	EventStatementEnd     = "statement_end"     // A statement finished; Err is set when it failed
+	EventRequestSent      = "request_sent"      // A request is about to be sent
+	EventResponseReceived = "response_received" // A request finished; Err is set when it failed
+	EventAssertionPassed  = "assertion_passed"  // An assertion held
+	EventAssertionFailed  = "assertion_failed"  // An assertion did not hold; Err says why
+	EventLoopIteration    = "loop_iteration"    // A loop is starting an iteration
+)
+
+// Event describes something that happened while a script ran. Fields that do
+// not apply to an event type are left empty.
+type Event struct {
+	Type      string        // One of the Event* constants
+	Time      time.Time     // When the event happened
+	Line      int           // 1-based line of the top-level statement or block being run
+	Source    string        // Statement text, for statement and assertion events
+	Kind      string        // Statement kind, as in StepResult
+	Method    string        // Request method, for request events
+	URL       string        // Request URL, for request events
+	Status    int           // Response status code, for response_received
+	Loop      string        // repeat, while, foreach or for, for loop_iteration
+	Iteration int           // 1-based iteration number, for loop_iteration
+	Output    interface{}   // Value returned by the statement, for statement_end
+	Err       error         // Failure, for events that can fail
+	Duration  time.Duration // Time spent, for statement_end and response_received
+}
+
+// OnEvent registers a listener called for every execution event: statement
+// start and end, requests sent, responses received, assertion results and
+// loop iterations. Listeners run synchronously on the goroutine executing the
+// script, in registration order, so they should return quickly. Register them
+// before running scripts.
+//
+// Example:
+//
+//	hd.OnEvent(func(e core.Event) {
+//	    if e.Type == core.EventResponseReceived {
+//	        fmt.Printf("%s %s -> %d in %v\n", e.Method, e.URL, e.Status, e.Duration)
+//	    }
+//	})
+func (hd *HTTPDSLv3) OnEvent(listener func(Event)) {
+	hd.listeners = append(hd.listeners, listener)
+}
+
+// emit sends an event to every listener
+func (hd *HTTPDSLv3) emit(event Event) {
+	if len(hd.listeners) == 0 {
+		return
+	}
+	event.Time = time.Now()
+	if event.Line == 0 {
+		event.Line = hd.stepLine
+	}
+	for _, listener := range hd.listeners {
+		listener(event)
+	}
+}
+
+// emitIteration reports the start of a loop iteration
+func (hd *HTTPDSLv3) emitIteration(loop string, iteration int) {
+	hd.emit(Event{Type: EventLoopIteration, Loop: loop, Iteration: iteration})
+}
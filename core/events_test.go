@@ -0,0 +1,55 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTTPDSLv3OnEvent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+
+	var events []string
+	dsl.OnEvent(func(e Event) {
+		switch e.Type {
+		case EventResponseReceived:
+			events = append(events, fmt.Sprintf("%s %d", e.Type, e.Status))
+		case EventLoopIteration:
+			events = append(events, fmt.Sprintf("%s %s %d", e.Type, e.Loop, e.Iteration))
+		case EventStatementStart, EventStatementEnd:
+			events = append(events, e.Type+" "+e.Kind)
+		default:
+			events = append(events, e.Type)
+		}
+	})
+
+	script := `repeat 2 times do
+    POST "$base/items"
+endloop
+assert status 201
+assert status 200`
+	if _, err := dsl.ParseWithBlockSupport(script); err == nil {
+		t.Fatal("Expected the last assertion to fail")
+	}
+
+	expected := []string{
+		"loop_iteration repeat 1",
+		"statement_start request", "request_sent", "response_received 201", "statement_end request",
+		"loop_iteration repeat 2",
+		"statement_start request", "request_sent", "response_received 201", "statement_end request",
+		"statement_start assertion", "assertion_passed", "statement_end assertion",
+		"statement_start assertion", "assertion_failed", "statement_end assertion",
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Unexpected events:\n%s", strings.Join(events, "\n"))
+	}
+}
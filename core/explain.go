@@ -0,0 +1,136 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ExplainedStatement is the parse result of one script statement or block marker
+type ExplainedStatement struct {
+	Line     int          // 1-based line number in the script
+	Kind     string       // statement, if, else, endif, repeat, while, foreach, endloop, hook or end
+	Source   string       // Statement text, header continuations included
+	Rule     string       // Grammar rule the statement was checked against
+	Tree     *SyntaxNode  // Parse tree, nil when nothing was parsed or parsing failed
+	Err      *SyntaxError // Syntax error, nil on success
+	Expected []string     // Symbols that would have been accepted where parsing stopped
+}
+
+// ParseTree parses input as a single instance of rule and returns its tree
+// without executing any action. On failure it also returns the symbols that
+// were expected at the position where matching stopped.
+func (hd *HTTPDSLv3) ParseTree(rule, input string) (*SyntaxNode, []string, *SyntaxError) {
+	tokens, err := hd.Tokenize(input)
+	if err != nil || len(tokens) == 0 {
+		return nil, nil, hd.CheckStatement(rule, input)
+	}
+
+	gc := hd.newGrammarChecker()
+	node, ok := gc.check(rule, tokens)
+	if ok {
+		return node, nil, nil
+	}
+
+	keywords := make(map[string]bool)
+	for _, kw := range hd.Keywords() {
+		keywords[kw] = true
+	}
+	var expected []string
+	for symbol := range gc.expected {
+		if gc.isToken[symbol] {
+			symbol = gc.render([]string{symbol}, keywords)
+		}
+		expected = append(expected, symbol)
+	}
+	sort.Strings(expected)
+
+	indent := len(input) - len(strings.TrimLeft(input, " \t"))
+	return nil, expected, gc.syntaxError(input, indent)
+}
+
+// Explain parses every statement of a script without executing it and returns
+// one entry per statement or block marker. It is meant for debugging scripts
+// that fail with "no alternative matched" and for working on the grammar.
+//
+// Example:
+//
+//	for _, stmt := range hd.Explain(script) {
+//	    fmt.Printf("line %d: %s\n", stmt.Line, stmt.Source)
+//	    if stmt.Tree != nil {
+//	        fmt.Print(stmt.Tree.String())
+//	    }
+//	}
+func (hd *HTTPDSLv3) Explain(script string) []ExplainedStatement {
+	var statements []ExplainedStatement
+
+	for _, unit := range splitScript(script) {
+		stmt := ExplainedStatement{
+			Line:   unit.line + 1,
+			Kind:   unit.kind,
+			Source: unit.source,
+			Rule:   unit.rule,
+		}
+
+		if unit.problem != "" {
+			stmt.Err = &SyntaxError{Line: unit.line + 1, Column: unit.offset + 1, Message: unit.problem}
+		} else if unit.rule != "" {
+			tree, expected, err := hd.ParseTree(unit.rule, unit.text)
+			stmt.Tree, stmt.Expected = tree, expected
+			if err != nil {
+				stmt.Err = &SyntaxError{
+					Line:    unit.line + 1,
+					Column:  err.Column + unit.offset + unit.column,
+					Message: err.Message,
+				}
+			}
+		}
+
+		statements = append(statements, stmt)
+	}
+
+	return statements
+}
+
+// String renders the tree with one node per line, children indented by two
+// spaces. Rule nodes show their action; tokens where variables are substituted
+// at run time are marked as expansion points.
+func (n *SyntaxNode) String() string {
+	var sb strings.Builder
+	n.write(&sb, 0, false)
+	return sb.String()
+}
+
+// write renders the node and its children at the given depth. Assigned marks a
+// variable token that receives a value instead of being read.
+func (n *SyntaxNode) write(sb *strings.Builder, depth int, assigned bool) {
+	indent := strings.Repeat("  ", depth)
+
+	if n.Rule == "" {
+		fmt.Fprintf(sb, "%s%s %q", indent, n.Token, n.Value)
+		switch {
+		case n.Token == "VARIABLE" && assigned:
+			sb.WriteString("  <- assigned")
+		case n.Token == "VARIABLE", n.Token == "STRING" && strings.Contains(n.Value, "$"):
+			sb.WriteString("  <- expands variables")
+		}
+		sb.WriteString("\n")
+		return
+	}
+
+	fmt.Fprintf(sb, "%s%s", indent, n.Rule)
+	if n.Action != "" && n.Action != "passthrough" {
+		fmt.Fprintf(sb, " (%s)", n.Action)
+	}
+	sb.WriteString("\n")
+	for i, child := range n.Children {
+		target := false
+		if i > 0 {
+			switch n.Children[i-1].Token {
+			case "set", "var", "as", "foreach":
+				target = true
+			}
+		}
+		child.write(sb, depth+1, target)
+	}
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestHTTPDSLv3Explain tests parse trees built without executing the script
+func TestHTTPDSLv3Explain(t *testing.T) {
+	dsl := NewHTTPDSLv3()
+	script := `set $x 10
+GET "http://localhost/$x"
+    header "Accept" "application/json"
+if $x > 5 then
+    print "big"
+endif
+POST "http://localhost" foo "bar"`
+
+	statements := dsl.Explain(script)
+	if len(statements) != 6 {
+		t.Fatalf("Expected 6 statements, got %d", len(statements))
+	}
+
+	set := statements[0]
+	if set.Err != nil || set.Tree == nil {
+		t.Fatalf("Expected tree for set statement, got error %v", set.Err)
+	}
+	tree := set.Tree.String()
+	if !strings.Contains(tree, "set_var (setVariable)") || !strings.Contains(tree, `VARIABLE "$x"  <- assigned`) {
+		t.Errorf("Unexpected tree:\n%s", tree)
+	}
+
+	request := statements[1]
+	if request.Err != nil || !strings.Contains(request.Source, `header "Accept"`) {
+		t.Errorf("Expected header continuation to be joined, got %q (%v)", request.Source, request.Err)
+	}
+	if !strings.Contains(request.Tree.String(), "<- expands variables") {
+		t.Error("Expected URL to be marked as an expansion point")
+	}
+
+	if statements[2].Kind != "if" || statements[2].Rule != "condition" || statements[2].Tree == nil {
+		t.Errorf("Expected if block with parsed condition, got %+v", statements[2])
+	}
+
+	bad := statements[5]
+	if bad.Err == nil || bad.Line != 7 {
+		t.Fatalf("Expected syntax error on line 7, got %+v", bad)
+	}
+	expected := strings.Join(bad.Expected, ",")
+	if !strings.Contains(expected, "header") || !strings.Contains(expected, "end of statement") {
+		t.Errorf("Expected option keywords in expected symbols, got %v", bad.Expected)
+	}
+
+	if len(dsl.GetVariables()) != 0 {
+		t.Error("Explain should not execute statements")
+	}
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadFixture reads a JSON or YAML file into a variable value: objects become
+// maps and lists arrays, so fields read as $user.name and lists work with
+// foreach and length. Relative paths are relative to the working directory.
+func loadFixture(format, name string) (interface{}, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("load %s: %w", format, err)
+	}
+
+	var value interface{}
+	switch format {
+	case "json":
+		value, err = decodeJSON(data)
+	case "yaml":
+		err = yaml.Unmarshal(data, &value)
+		value = yamlValue(value)
+	default:
+		return nil, fmt.Errorf("load: unknown format %s", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load %s %s: %w", format, name, err)
+	}
+	return value, nil
+}
+
+// yamlValue converts decoded YAML to the types JSON decodes to, so fixtures
+// behave the same whatever their format: maps with non-string keys get string
+// keys and integers become float64
+func yamlValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = yamlValue(item)
+		}
+		return v
+	case map[interface{}]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			object[fmt.Sprint(key)] = yamlValue(item)
+		}
+		return object
+	case []interface{}:
+		for i, item := range v {
+			v[i] = yamlValue(item)
+		}
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	}
+	return value
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHTTPDSLv3LoadFixtures(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		received = string(data)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"user.json":   `{"name": "Ann", "roles": ["admin", "dev"], "age": 30}`,
+		"config.yaml": "region: eu-west\nretries: 3\nlimits:\n  1: low\nhosts:\n  - a.example.com\n  - b.example.com\n",
+		"broken.yaml": "a: [1, 2\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	dsl.SetVariable("dir", dir)
+	script := `load json "$dir/user.json" as $user
+load yaml "$dir/config.yaml" as $cfg
+set $hosts length $cfg.hosts
+set $label "${user.name}@${cfg.region}"
+POST "$base/users" json {"user": $user, "retries": $cfg.retries}`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if label, _ := dsl.GetVariable("label"); label != "Ann@eu-west" {
+		t.Errorf("Unexpected fields: %v", label)
+	}
+	if hosts, _ := dsl.GetVariable("hosts"); fmt.Sprint(hosts) != "2" {
+		t.Errorf("Expected 2 hosts, got %v", hosts)
+	}
+	cfg, _ := dsl.GetVariable("cfg")
+	if limits := cfg.(map[string]interface{})["limits"]; fmt.Sprint(limits) != "map[1:low]" {
+		t.Errorf("Expected string keys, got %#v", limits)
+	}
+	if received != `{"user": {"age":30,"name":"Ann","roles":["admin","dev"]}, "retries": 3}` {
+		t.Errorf("Unexpected body: %s", received)
+	}
+
+	if _, err := dsl.Parse(`load yaml "$dir/broken.yaml" as $x`); err == nil {
+		t.Error("Expected invalid YAML to fail")
+	}
+	if _, err := dsl.Parse(`load json "$dir/missing.json" as $x`); err == nil {
+		t.Error("Expected a missing fixture to fail")
+	}
+}
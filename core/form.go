@@ -0,0 +1,325 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// formField is one name and value a form submits, in document order
+type formField struct {
+	name  string
+	value string
+}
+
+// htmlForm is a form of an HTML page, with the values it would submit as
+// the browser leaves them: hidden inputs like CSRF tokens, default and
+// checked values, and its first named submit button
+type htmlForm struct {
+	action  string // Absolute URL the form is sent to
+	method  string // GET or POST
+	enctype string
+	fields  []formField
+}
+
+// findForm returns the form of page matching selector: #id, .class, a name
+// attribute, or "" or "form" for the first form. Relative actions resolve
+// against base, and a form without action is sent to base.
+func findForm(page string, base *url.URL, selector string) (*htmlForm, error) {
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		return nil, fmt.Errorf("parsing HTML: %w", err)
+	}
+
+	var forms []*html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "form" {
+			forms = append(forms, n)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	if len(forms) == 0 {
+		return nil, fmt.Errorf("the last response has no HTML form")
+	}
+
+	for _, node := range forms {
+		if matchesSelector(node, selector) {
+			return newHTMLForm(node, base)
+		}
+	}
+	return nil, fmt.Errorf("no form matches %q in the last response (%d forms found)", selector, len(forms))
+}
+
+// matchesSelector reports whether a form node matches a selector of findForm
+func matchesSelector(node *html.Node, selector string) bool {
+	switch {
+	case selector == "" || selector == "form":
+		return true
+	case strings.HasPrefix(selector, "#"):
+		return htmlAttr(node, "id") == selector[1:]
+	case strings.HasPrefix(selector, "."):
+		for _, class := range strings.Fields(htmlAttr(node, "class")) {
+			if class == selector[1:] {
+				return true
+			}
+		}
+		return false
+	}
+	return htmlAttr(node, "name") == selector
+}
+
+// newHTMLForm reads the action, method and values of a form node
+func newHTMLForm(node *html.Node, base *url.URL) (*htmlForm, error) {
+	action := base
+	if target := strings.TrimSpace(htmlAttr(node, "action")); target != "" {
+		resolved, err := base.Parse(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid form action %q: %w", target, err)
+		}
+		action = resolved
+	}
+	form := &htmlForm{
+		action:  action.String(),
+		method:  strings.ToUpper(htmlAttr(node, "method")),
+		enctype: strings.ToLower(htmlAttr(node, "enctype")),
+	}
+	if form.method != "POST" {
+		form.method = "GET"
+	}
+
+	submitter := false
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			name := htmlAttr(child, "name")
+			if name == "" || hasHTMLAttr(child, "disabled") {
+				walk(child)
+				continue
+			}
+			switch child.Data {
+			case "input":
+				switch strings.ToLower(htmlAttr(child, "type")) {
+				case "checkbox", "radio":
+					if hasHTMLAttr(child, "checked") {
+						value := htmlAttr(child, "value")
+						if !hasHTMLAttr(child, "value") {
+							value = "on"
+						}
+						form.add(name, value)
+					}
+				case "submit", "image":
+					if !submitter {
+						submitter = true
+						form.add(name, htmlAttr(child, "value"))
+					}
+				case "button", "reset", "file":
+				default:
+					form.add(name, htmlAttr(child, "value"))
+				}
+			case "button":
+				if t := strings.ToLower(htmlAttr(child, "type")); (t == "" || t == "submit") && !submitter {
+					submitter = true
+					form.add(name, htmlAttr(child, "value"))
+				}
+			case "textarea":
+				form.add(name, strings.TrimPrefix(nodeText(child), "\n"))
+			case "select":
+				if value, ok := selectedOption(child); ok {
+					form.add(name, value)
+				}
+			default:
+				walk(child)
+			}
+		}
+	}
+	walk(node)
+	return form, nil
+}
+
+// selectedOption returns the value of the selected option of a select, or
+// of its first option when none is selected
+func selectedOption(node *html.Node) (string, bool) {
+	var first, selected *html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == html.ElementNode && child.Data == "option" {
+				if first == nil {
+					first = child
+				}
+				if selected == nil && hasHTMLAttr(child, "selected") {
+					selected = child
+				}
+			}
+			walk(child)
+		}
+	}
+	walk(node)
+	if selected == nil {
+		selected = first
+	}
+	if selected == nil {
+		return "", false
+	}
+	if hasHTMLAttr(selected, "value") {
+		return htmlAttr(selected, "value"), true
+	}
+	return strings.TrimSpace(nodeText(selected)), true
+}
+
+// add appends a field to the form
+func (f *htmlForm) add(name, value string) {
+	f.fields = append(f.fields, formField{name, value})
+}
+
+// set gives a field a value, replacing every value it had, or adds it
+func (f *htmlForm) set(name, value string) {
+	kept := f.fields[:0]
+	found := false
+	for _, field := range f.fields {
+		if field.name != name {
+			kept = append(kept, field)
+		} else if !found {
+			found = true
+			kept = append(kept, formField{name, value})
+		}
+	}
+	f.fields = kept
+	if !found {
+		f.add(name, value)
+	}
+}
+
+// request returns the URL and the body and content type the form submits.
+// GET forms put their fields in the query and send no body.
+func (f *htmlForm) request() (string, string, string, error) {
+	if f.method == "GET" {
+		target, err := url.Parse(f.action)
+		if err != nil {
+			return "", "", "", err
+		}
+		target.RawQuery = f.encode()
+		return target.String(), "", "", nil
+	}
+
+	if f.enctype != "multipart/form-data" {
+		return f.action, f.encode(), "application/x-www-form-urlencoded", nil
+	}
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, field := range f.fields {
+		if err := writer.WriteField(field.name, field.value); err != nil {
+			return "", "", "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return "", "", "", err
+	}
+	return f.action, body.String(), writer.FormDataContentType(), nil
+}
+
+// encode returns the fields URL encoded, in document order
+func (f *htmlForm) encode() string {
+	pairs := make([]string, len(f.fields))
+	for i, field := range f.fields {
+		pairs[i] = url.QueryEscape(field.name) + "=" + url.QueryEscape(field.value)
+	}
+	return strings.Join(pairs, "&")
+}
+
+// htmlAttr returns the value of an attribute of node, "" when it has none
+func htmlAttr(node *html.Node, name string) string {
+	for _, a := range node.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// hasHTMLAttr reports whether node has an attribute, even an empty one
+func hasHTMLAttr(node *html.Node, name string) bool {
+	for _, a := range node.Attr {
+		if a.Key == name {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeText returns the text inside node
+func nodeText(node *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(node)
+	return b.String()
+}
+
+// submitForm fills the form of the last response matching selector with
+// values, name and value pairs, and sends it as a browser would
+func (hd *HTTPDSLv3) submitForm(selector string, values [][2]string, options map[string]interface{}) (interface{}, error) {
+	page, base := hd.engine.lastPage()
+	if base == nil {
+		return nil, fmt.Errorf("submit form needs an HTML page; send a request first")
+	}
+	form, err := findForm(page, base, selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values {
+		form.set(value[0], value[1])
+	}
+
+	target, body, contentType, err := form.request()
+	if err != nil {
+		return nil, fmt.Errorf("building the form submission: %w", err)
+	}
+	if options == nil {
+		options = make(map[string]interface{})
+	}
+	delete(options, "json")
+	delete(options, "form")
+	if contentType != "" {
+		options["body"] = body
+		headers, _ := options["header"].(map[string]string)
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		headers["Content-Type"] = contentType
+		options["header"] = headers
+	} else {
+		delete(options, "body")
+	}
+	hd.engine.LogInfo("Submitting form %s: %s %s", selector, form.method, target)
+	return hd.sendRequest(form.method, target, options)
+}
+
+// lastPage returns the body of the last response and the URL it came from,
+// after redirects, or a nil URL when there is no response
+func (he *HTTPEngine) lastPage() (string, *url.URL) {
+	he.mu.RLock()
+	defer he.mu.RUnlock()
+	if he.lastResponse == nil || he.lastResponse.Request == nil {
+		return "", nil
+	}
+	return he.lastResponseBody, he.lastResponse.Request.URL
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTTPDSLv3SubmitForm(t *testing.T) {
+	var submitted url.Values
+	var contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body>
+<form id="search" action="/search"><input name="q"></form>
+<form id="login" action="session" method="post">
+  <input type="hidden" name="csrf" value="tok123">
+  <input type="text" name="username" value="guest">
+  <input type="password" name="password">
+  <input type="checkbox" name="remember" checked>
+  <input type="checkbox" name="newsletter" value="yes">
+  <select name="lang"><option value="en">English</option><option value="es" selected>Spanish</option></select>
+  <textarea name="note">hello</textarea>
+  <input type="submit" name="action" value="Sign in">
+</form>
+</body></html>`))
+		case "/session":
+			contentType = r.Header.Get("Content-Type")
+			r.ParseForm()
+			submitted = r.PostForm
+			w.Write([]byte("welcome " + r.PostForm.Get("username")))
+		case "/search":
+			w.Write([]byte("results for " + r.URL.Query().Get("q")))
+		}
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	dsl.SetVariable("u", "alice")
+
+	_, err := dsl.ParseWithBlockSupport(`GET "$base/login"
+submit form "#login" with "username" "$u" "password" "s3cret"
+assert status 200
+assert response contains "welcome alice"`)
+	if err != nil {
+		t.Fatalf("submit form failed: %v", err)
+	}
+	if contentType != "application/x-www-form-urlencoded" {
+		t.Errorf("Expected a URL encoded body, got %q", contentType)
+	}
+	expected := url.Values{
+		"csrf":     {"tok123"},
+		"username": {"alice"},
+		"password": {"s3cret"},
+		"remember": {"on"},
+		"lang":     {"es"},
+		"note":     {"hello"},
+		"action":   {"Sign in"},
+	}
+	if !reflect.DeepEqual(submitted, expected) {
+		t.Errorf("Expected fields %v, got %v", expected, submitted)
+	}
+
+	// GET forms send their fields in the query
+	if _, err := dsl.ParseWithBlockSupport(`GET "$base/login"
+submit form "#search" with "q" "go dsl"
+assert response contains "results for go dsl"`); err != nil {
+		t.Errorf("GET form failed: %v", err)
+	}
+
+	_, err = dsl.ParseWithBlockSupport(`GET "$base/login"
+submit form "#missing"`)
+	if err == nil || !strings.Contains(err.Error(), "no form matches") {
+		t.Errorf("Expected a missing form error, got %v", err)
+	}
+}
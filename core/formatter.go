@@ -0,0 +1,175 @@
+package core
+
+import (
+	"strings"
+
+	"github.com/arturoeanton/go-dsl/pkg/dslbuilder"
+)
+
+// formatIndent is the indentation used for each nesting level
+const formatIndent = "    "
+
+// Format returns the canonical layout of a script. It indents if/elseif/else/endif,
+// loop and hook blocks, puts single spaces between tokens, writes keywords in
+// their canonical case, quotes bare URLs, and collapses runs of blank lines.
+// Statements are never executed. Lines that cannot be tokenized are kept as
+// written, only re-indented, and the text of multiline strings is not touched,
+// so formatting never loses content.
+//
+// Example:
+//
+//	formatted := hd.Format(script)
+//	if formatted != script {
+//	    fmt.Println("script is not formatted")
+//	}
+func (hd *HTTPDSLv3) Format(script string) string {
+	keywords := make(map[string]bool)
+	for _, kw := range hd.Keywords() {
+		keywords[kw] = true
+	}
+
+	var out []string
+	depth := 0
+	blank := false
+	heredoc := ""      // Line that closes the multiline string being copied
+	continued := false // Whether the previous line ends with \
+
+	for _, raw := range strings.Split(script, "\n") {
+		line := strings.TrimSpace(raw)
+
+		// The text of multiline strings is kept exactly as written
+		if heredoc != "" {
+			out = append(out, strings.TrimRight(raw, "\r"))
+			if line == heredoc {
+				heredoc = ""
+			}
+			continue
+		}
+
+		if line == "" {
+			blank = len(out) > 0
+			continue
+		}
+		if blank {
+			out = append(out, "")
+			blank = false
+		}
+
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			out = append(out, strings.Repeat(formatIndent, depth)+line)
+			continue
+		}
+
+		line, comment := splitComment(line)
+		continues := continuesOnNextLine(line)
+		if continues {
+			line = strings.TrimRight(strings.TrimSuffix(line, `\`), " \t")
+		}
+		formatted := hd.formatStatement(line, keywords)
+		if closer, prefix, ok := heredocCloser(line); ok {
+			formatted = hd.formatStatement(prefix, keywords) + " " + strings.TrimSpace(line[len(prefix):])
+			heredoc = closer
+		}
+
+		// Closing keywords, else, elseif and switch cases dedent before being written
+		if formatted == "endif" || formatted == "endloop" || formatted == "end" || formatted == "else" ||
+			isElseIf(formatted) || isUntilCloser(formatted) || isSwitchCloser(formatted) || isSwitchClause(formatted) || isPaginateCloser(formatted) {
+			if depth > 0 {
+				depth--
+			}
+		}
+
+		indent := strings.Repeat(formatIndent, depth)
+		if continued || strings.HasPrefix(formatted, "header ") && len(out) > 0 && isContinuationTarget(out[len(out)-1]) {
+			// Continuations of the previous statement stay one level deeper
+			indent += formatIndent
+		}
+		written := indent + formatted
+		if continues {
+			written += ` \`
+		}
+		if comment != "" {
+			written += " " + comment
+		}
+		out = append(out, written)
+		continued = continues
+
+		if formatted == "else" || isElseIf(formatted) || isSwitchClause(formatted) || isBlockOpener(formatted) {
+			depth++
+		}
+	}
+
+	if len(out) == 0 {
+		return ""
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+// formatStatement normalizes spacing, keyword case, and URL quoting of one line
+func (hd *HTTPDSLv3) formatStatement(line string, keywords map[string]bool) string {
+	tokens, err := hd.Tokenize(line)
+	if err != nil || len(tokens) == 0 {
+		return line
+	}
+
+	var sb strings.Builder
+	for i, token := range tokens {
+		if i > 0 && needsSpace(tokens[i-1], token) {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(formatToken(token, keywords))
+	}
+	return sb.String()
+}
+
+// formatToken renders a single token in its canonical form
+func formatToken(token dslbuilder.TokenMatch, keywords map[string]bool) string {
+	switch {
+	case keywords[token.TokenType]:
+		return token.TokenType
+	case token.TokenType == "URL":
+		return `"` + token.Value + `"`
+	}
+	return token.Value
+}
+
+// needsSpace decides whether a space separates two adjacent tokens.
+// Brackets hug their contents so $items[0] and grouped statements stay compact,
+// and commas follow the item before them.
+func needsSpace(prev, next dslbuilder.TokenMatch) bool {
+	switch {
+	case prev.TokenType == "[" || prev.TokenType == "(":
+		return false
+	case next.TokenType == "]" || next.TokenType == ")" || next.TokenType == ",":
+		return false
+	case next.TokenType == "[" && prev.TokenType == "VARIABLE":
+		return false
+	}
+	return true
+}
+
+// isBlockOpener reports whether a line starts a multiline block
+func isBlockOpener(line string) bool {
+	switch {
+	case strings.HasPrefix(line, "if ") && strings.HasSuffix(line, " then"):
+		return true
+	case strings.HasPrefix(line, "repeat ") && strings.HasSuffix(line, " do"):
+		return true
+	case strings.HasPrefix(line, "while ") && strings.HasSuffix(line, " do"):
+		return true
+	case strings.HasPrefix(line, "foreach ") && strings.HasSuffix(line, " do"):
+		return true
+	case strings.HasPrefix(line, "for ") && strings.HasSuffix(line, " do"):
+		return true
+	case isUntilOpener(line), isSwitchOpener(line), isPaginateOpener(line), isEndBlockOpener(line), isTemplateOpener(line):
+		return true
+	}
+	return false
+}
+
+// isContinuationTarget reports whether a formatted line is a request (or one of
+// its header continuation lines) that a following header line attaches to
+func isContinuationTarget(formatted string) bool {
+	trimmed := strings.TrimSpace(formatted)
+	return isHTTPMethod(trimmed) || strings.HasPrefix(trimmed, "header ")
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"testing"
+)
+
+// TestHTTPDSLv3Format tests the canonical script formatter
+func TestHTTPDSLv3Format(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		expected string
+	}{
+		{
+			name:     "Spacing and keyword case",
+			script:   "SET   $x    10\nPRINT \"x is $x\"",
+			expected: "set $x 10\nprint \"x is $x\"\n",
+		},
+		{
+			name:     "Block indentation",
+			script:   "if $x > 5 then\nprint \"big\"\nelse\n  print \"small\"\nendif",
+			expected: "if $x > 5 then\n    print \"big\"\nelse\n    print \"small\"\nendif\n",
+		},
+		{
+			name:     "Header continuation and URL quoting",
+			script:   "repeat 2 times do\nget https://example.com/a\nheader \"Accept\" \"application/json\"\nendloop",
+			expected: "repeat 2 times do\n    GET \"https://example.com/a\"\n        header \"Accept\" \"application/json\"\nendloop\n",
+		},
+		{
+			name:     "Comments and blank lines",
+			script:   "# setup\n\n\n\nset $x 1\n",
+			expected: "# setup\n\nset $x 1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsl := NewHTTPDSLv3()
+			formatted := dsl.Format(tt.script)
+			if formatted != tt.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s", tt.expected, formatted)
+			}
+			if again := dsl.Format(formatted); again != formatted {
+				t.Errorf("Format is not idempotent:\n%s", again)
+			}
+			if len(dsl.GetVariables()) != 0 {
+				t.Error("Format should not execute statements")
+			}
+		})
+	}
+}
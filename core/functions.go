@@ -0,0 +1,179 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// errorType is the reflect type of the error interface
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// RegisterFunction makes a Go function callable from expressions as
+// name(arg, ...), for example in set statements. Fn must be a func returning
+// one value, or a value and an error. Calls are checked against its number of
+// parameters, and arguments are converted to the parameter types: string,
+// bool, integer and float kinds, []interface{}, map[string]interface{} and
+// interface{}. Variadic functions are supported. Registering a name again
+// replaces the function.
+//
+// Example:
+//
+//	hd.RegisterFunction("slugify", func(s string) string {
+//	    return strings.ReplaceAll(strings.ToLower(s), " ", "-")
+//	})
+//
+//	// In a script: set $slug slugify("Hello World")
+func (hd *HTTPDSLv3) RegisterFunction(name string, fn interface{}) error {
+	words := strings.Fields(name)
+	if len(words) > 1 {
+		return fmt.Errorf("invalid function name %q: must be a single word", name)
+	}
+	if err := hd.checkKeywords("function", name, words); err != nil {
+		return err
+	}
+
+	value := reflect.ValueOf(fn)
+	if value.Kind() != reflect.Func {
+		return fmt.Errorf("function %q must be a func, got %T", name, fn)
+	}
+	fnType := value.Type()
+	switch {
+	case fnType.NumOut() == 1 && fnType.Out(0) != errorType:
+	case fnType.NumOut() == 2 && fnType.Out(1) == errorType:
+	default:
+		return fmt.Errorf("function %q must return a value, or a value and an error", name)
+	}
+
+	hd.registerFunction(strings.ToLower(words[0]), value)
+	hd.extensions = append(hd.extensions, func(fork *HTTPDSLv3) {
+		fork.RegisterFunction(name, fn)
+	})
+	return nil
+}
+
+// registerFunction adds the grammar and action of a checked function
+func (hd *HTTPDSLv3) registerFunction(key string, value reflect.Value) {
+	actionName := "function:" + key
+
+	if !hd.functions[key] {
+		hd.dsl.KeywordToken(key, key)
+		hd.dsl.Rule("function_call", []string{key, "(", ")"}, actionName)
+		hd.dsl.Rule("function_call", []string{key, "(", "argument_list", ")"}, actionName)
+		hd.functions[key] = true
+	}
+
+	hd.action(actionName, func(args []interface{}) (interface{}, error) {
+		var callArgs []interface{}
+		if len(args) == 4 {
+			callArgs = args[2].([]interface{})
+		}
+		return hd.callFunction(key, value, callArgs)
+	})
+}
+
+// callFunction checks the argument count, converts the arguments and calls fn
+func (hd *HTTPDSLv3) callFunction(name string, fn reflect.Value, args []interface{}) (interface{}, error) {
+	fnType := fn.Type()
+	params := fnType.NumIn()
+
+	if fnType.IsVariadic() {
+		if len(args) < params-1 {
+			return nil, fmt.Errorf("%s expects at least %d arguments, got %d", name, params-1, len(args))
+		}
+	} else if len(args) != params {
+		return nil, fmt.Errorf("%s expects %d arguments, got %d", name, params, len(args))
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var paramType reflect.Type
+		if fnType.IsVariadic() && i >= params-1 {
+			paramType = fnType.In(params - 1).Elem()
+		} else {
+			paramType = fnType.In(i)
+		}
+
+		converted, err := hd.coerceArgument(arg, paramType)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d of %s: %w", i+1, name, err)
+		}
+		in[i] = converted
+	}
+
+	out := fn.Call(in)
+	if len(out) == 2 && !out[1].IsNil() {
+		return nil, fmt.Errorf("%s: %w", name, out[1].Interface().(error))
+	}
+	return out[0].Interface(), nil
+}
+
+// coerceArgument converts a DSL value to the type of a Go parameter
+func (hd *HTTPDSLv3) coerceArgument(arg interface{}, t reflect.Type) (reflect.Value, error) {
+	if arg != nil && reflect.TypeOf(arg).AssignableTo(t) {
+		return reflect.ValueOf(arg), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		if arg == nil {
+			return reflect.Zero(t), nil
+		}
+	case reflect.String:
+		return reflect.ValueOf(formatValue(arg)).Convert(t), nil
+	case reflect.Bool:
+		return reflect.ValueOf(hd.toBool(arg)).Convert(t), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		num, err := numberArgument(arg)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if num != math.Trunc(num) {
+			return reflect.Value{}, fmt.Errorf("%v is not an integer", arg)
+		}
+		return reflect.ValueOf(num).Convert(t), nil
+	case reflect.Float32, reflect.Float64:
+		num, err := numberArgument(arg)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(num).Convert(t), nil
+	case reflect.Slice:
+		if items := hd.toSlice(arg); items != nil && reflect.TypeOf(items).AssignableTo(t) {
+			return reflect.ValueOf(items), nil
+		}
+	case reflect.Map:
+		if str, ok := arg.(string); ok && t.Key().Kind() == reflect.String {
+			if decoded, ok := decodeJSONText(str); ok {
+				if object, ok := decoded.(map[string]interface{}); ok && reflect.TypeOf(object).AssignableTo(t) {
+					return reflect.ValueOf(object), nil
+				}
+			}
+		}
+	}
+
+	return reflect.Value{}, fmt.Errorf("cannot use %s as %s", formatValue(arg), t)
+}
+
+// numberArgument converts numbers and numeric strings to float64
+func numberArgument(arg interface{}) (float64, error) {
+	switch v := arg.(type) {
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case json.Number:
+		return v.Float64()
+	case string:
+		if num, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return num, nil
+		}
+	}
+	return 0, fmt.Errorf("%s is not a number", formatValue(arg))
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHTTPDSLv3RegisterFunction(t *testing.T) {
+	dsl := NewHTTPDSLv3()
+
+	if err := dsl.RegisterFunction("slugify", func(s string) string {
+		return strings.ReplaceAll(strings.ToLower(s), " ", "-")
+	}); err != nil {
+		t.Fatalf("RegisterFunction failed: %v", err)
+	}
+	if err := dsl.RegisterFunction("repeat_text", func(s string, n int) (string, error) {
+		if n < 0 {
+			return "", errors.New("negative count")
+		}
+		return strings.Repeat(s, n), nil
+	}); err != nil {
+		t.Fatalf("RegisterFunction failed: %v", err)
+	}
+	if err := dsl.RegisterFunction("total", func(values ...float64) float64 {
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		return sum
+	}); err != nil {
+		t.Fatalf("RegisterFunction failed: %v", err)
+	}
+
+	script := `set $title "Hello World"
+set $slug slugify($title)
+set $line repeat_text("ab", "3")
+set $sum total(1, 2, $count + 1)
+set $none total()`
+	dsl.SetVariable("count", 3)
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+
+	for name, expected := range map[string]interface{}{
+		"slug": "hello-world",
+		"line": "ababab",
+		"sum":  7.0,
+		"none": 0.0,
+	} {
+		if value, _ := dsl.GetVariable(name); value != expected {
+			t.Errorf("Expected $%s = %v, got %v", name, expected, value)
+		}
+	}
+
+	failures := map[string]string{
+		`set $x slugify()`:                "slugify expects 1 arguments, got 0",
+		`set $x repeat_text("ab", 1.5)`:   "argument 2 of repeat_text: 1.5 is not an integer",
+		`set $x repeat_text("ab", "x")`:   "argument 2 of repeat_text: x is not a number",
+		`set $x repeat_text("ab", 0 - 1)`: "repeat_text: negative count",
+	}
+	for statement, message := range failures {
+		if _, err := dsl.Parse(statement); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("%s: expected error %q, got %v", statement, message, err)
+		}
+	}
+
+	if err := dsl.RegisterFunction("broken", func() {}); err == nil {
+		t.Error("Expected an error for a function without results")
+	}
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// readGraphQLQuery reads a query kept in its own file, so it can be shared
+// with the application that sends it. Relative paths are relative to the
+// working directory.
+func readGraphQLQuery(name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", fmt.Errorf("graphql: %w", err)
+	}
+	query := strings.TrimSpace(string(data))
+	if query == "" {
+		return "", fmt.Errorf("graphql: query file %s is empty", name)
+	}
+	return query, nil
+}
+
+// graphqlVariables returns the variables of a query held in a script
+// variable, either as an object or as JSON text
+func graphqlVariables(value interface{}) (map[string]interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, nil
+	case string:
+		decoded, err := decodeJSON([]byte(v))
+		if err != nil {
+			return nil, fmt.Errorf("graphql variables must be a JSON object: %w", err)
+		}
+		variables, ok := decoded.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("graphql variables must be a JSON object, got %s", typeName(decoded))
+		}
+		return variables, nil
+	}
+	return nil, fmt.Errorf("graphql variables must be an object, got %T", value)
+}
+
+// graphqlPayload encodes the JSON body of a GraphQL request
+func graphqlPayload(query string, variables map[string]interface{}) (string, error) {
+	payload := map[string]interface{}{"query": query}
+	if variables != nil {
+		payload["variables"] = variables
+	}
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(payload); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHTTPDSLv3GraphQL(t *testing.T) {
+	var received []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		received = append(received, payload)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"data": {"user": {"id": %q, "auth": %q}}}`, r.Method, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	queryFile := filepath.Join(t.TempDir(), "getUser.graphql")
+	query := "query GetUser($id: ID!) {\n  user(id: $id) { name }\n}"
+	if err := os.WriteFile(queryFile, []byte(query+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("endpoint", server.URL+"/graphql")
+	dsl.SetVariable("queries", filepath.Dir(queryFile))
+	dsl.SetVariable("id", "42")
+	dsl.SetVariable("vars", map[string]interface{}{"id": "7"})
+	script := `graphql "$endpoint" query file "$queries/getUser.graphql" variables {"id": "$id"}
+assert jsonpath "$.data.user.id" equals "POST"
+graphql "$endpoint" query "{ viewer { login } }" auth bearer "t0k"
+graphql "$endpoint" query file "$queries/getUser.graphql" variables $vars`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("graphql failed: %v", err)
+	}
+	if len(received) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(received))
+	}
+	if received[0]["query"] != query || fmt.Sprint(received[0]["variables"]) != "map[id:42]" {
+		t.Errorf("Unexpected payload from file: %v", received[0])
+	}
+	if _, ok := received[1]["variables"]; ok || received[1]["query"] != "{ viewer { login } }" {
+		t.Errorf("Unexpected inline payload: %v", received[1])
+	}
+	if auth, _ := dsl.engine.Extract("jsonpath", "$.data.user.auth").(string); auth != "" {
+		t.Errorf("Expected the last request without auth, got %q", auth)
+	}
+	if fmt.Sprint(received[2]["variables"]) != "map[id:7]" {
+		t.Errorf("Unexpected variables from a variable: %v", received[2])
+	}
+	if steps, err := dsl.RunScript(`graphql "$endpoint" query "{ viewer { login } }"`); err != nil || steps[0].Kind != "request" || steps[0].Request == nil {
+		t.Errorf("Expected a request step, got %+v (%v)", steps, err)
+	}
+
+	if _, err := dsl.Parse(`graphql "$endpoint" query file "missing.graphql"`); err == nil {
+		t.Error("Expected a missing query file to fail")
+	}
+}
@@ -0,0 +1,38 @@
+package core
+
+import "fmt"
+
+// defaultHeadersBlock opens a block of header statements that apply to every
+// later request; it is closed by a line with "end"
+const defaultHeadersBlock = "default headers"
+
+// runDefaultHeaders executes the body of a default headers block. Header
+// statements in it set default headers instead of request headers.
+func (hd *HTTPDSLv3) runDefaultHeaders(body string) error {
+	hd.hookPhase = defaultHeadersBlock
+	defer func() { hd.hookPhase = "" }()
+
+	if _, err := hd.ParseWithBlockSupport(body); err != nil {
+		return fmt.Errorf("%s: %w", defaultHeadersBlock, err)
+	}
+	return nil
+}
+
+// setDefaultHeader sets a header the engine sends with every request and
+// remembers it so clear default headers can remove it
+func (hd *HTTPDSLv3) setDefaultHeader(name, value string) string {
+	hd.engine.SetHeader(name, value)
+	hd.defaultHeaders[name] = true
+	return fmt.Sprintf("Default header %s set to %s", name, value)
+}
+
+// clearDefaultHeaders removes the headers set with default header. Headers
+// set by other means, like auth, are kept.
+func (hd *HTTPDSLv3) clearDefaultHeaders() string {
+	count := len(hd.defaultHeaders)
+	for name := range hd.defaultHeaders {
+		hd.engine.RemoveHeader(name)
+	}
+	hd.defaultHeaders = make(map[string]bool)
+	return fmt.Sprintf("Cleared %d default headers", count)
+}
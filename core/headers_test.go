@@ -0,0 +1,39 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestHTTPDSLv3DefaultHeaders(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("X-Api-Key")+"|"+r.Header.Get("X-Trace-Id"))
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	dsl.SetVariable("key", "secret")
+	script := `default header "X-Api-Key" "$key"
+default headers
+    header "X-Trace-Id" "trace-1"
+end
+GET "$base/a"
+GET "$base/b" header "X-Trace-Id" "override"
+clear default headers
+GET "$base/c"`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+
+	expected := []string{"secret|trace-1", "secret|override", "|"}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("Expected headers %v, got %v", expected, received)
+	}
+	if problems := dsl.Validate("default headers\n    header \"A\" \"1\"\nend"); len(problems) != 0 {
+		t.Errorf("Expected a default headers block to validate, got %v", problems)
+	}
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// heredocOpener matches a line ending with the start of a multiline string,
+// either """ or <<TAG:
+//
+//	POST "https://api.example.com/users" json <<EOF
+var heredocOpener = regexp.MustCompile(`\s(?:"""|<<([A-Za-z_]\w*))$`)
+
+// heredocEscapes quotes the text of a multiline string so unquoteString
+// gives it back unchanged
+var heredocEscapes = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\n", `\n`,
+	"\r", `\r`,
+)
+
+// HeredocError reports a multiline string that is never closed
+type HeredocError struct {
+	Line   int    // 0-based index of the line that opens the string
+	Closer string // Line expected to close it
+}
+
+func (e *HeredocError) Error() string {
+	return fmt.Sprintf("multiline string is never closed, missing %s", e.Closer)
+}
+
+// heredocCloser returns the line that closes a multiline string opened at the
+// end of line, and the line without its opening marker
+func heredocCloser(line string) (string, string, bool) {
+	match := heredocOpener.FindStringSubmatchIndex(line)
+	if match == nil {
+		return "", "", false
+	}
+	// """ opens a string only when it is not the end of a quoted string
+	prefix := line[:match[0]]
+	if match[2] < 0 && strings.Count(prefix, `"`)%2 != 0 {
+		return "", "", false
+	}
+	closer := `"""`
+	if match[2] >= 0 {
+		closer = line[match[2]:match[3]]
+	}
+	return closer, prefix, true
+}
+
+// joinHeredocs puts each multiline string of a script on the line that opens
+// it as a quoted string. The lines it took are left empty, so line numbers
+// still match the script. The text keeps its line breaks and loses the
+// indentation shared by all of its lines.
+func joinHeredocs(lines []string) ([]string, *HeredocError) {
+	var joined []string
+	for i := 0; i < len(lines); i++ {
+		closer, prefix, ok := heredocCloser(strings.TrimRight(stripComment(lines[i]), " \t"))
+		if !ok {
+			if joined != nil {
+				joined = append(joined, lines[i])
+			}
+			continue
+		}
+		if joined == nil {
+			joined = append([]string(nil), lines[:i]...)
+		}
+
+		end := i + 1
+		for end < len(lines) && strings.TrimSpace(lines[end]) != closer {
+			end++
+		}
+		if end == len(lines) {
+			return nil, &HeredocError{Line: i, Closer: closer}
+		}
+
+		text := dedent(lines[i+1 : end])
+		joined = append(joined, prefix+` "`+heredocEscapes.Replace(text)+`"`)
+		for ; i < end; i++ {
+			joined = append(joined, "")
+		}
+	}
+	if joined == nil {
+		return lines, nil
+	}
+	return joined, nil
+}
+
+// dedent joins lines after removing the leading whitespace they all share;
+// blank lines do not count
+func dedent(lines []string) string {
+	indent := ""
+	first := true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lead := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			indent, first = lead, false
+			continue
+		}
+		for !strings.HasPrefix(lead, indent) {
+			indent = indent[:len(indent)-1]
+		}
+	}
+
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = strings.TrimRight(strings.TrimPrefix(line, indent), "\r")
+	}
+	return strings.Join(out, "\n")
+}
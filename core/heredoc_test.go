@@ -0,0 +1,52 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTTPDSLv3Heredoc(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r.Header.Get("Content-Type")+" "+string(body))
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	script := `set $name "Ada"
+if $name exists then
+    POST "$base/notes" body """
+        Dear "$name",
+          see C:\temp
+    """
+endif
+POST "$base/users" json <<EOF
+{"name": "$name"}
+EOF`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+
+	expected := []string{" Dear \"Ada\",\n  see C:\\temp", "application/json {\"name\": \"Ada\"}"}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("Expected requests %q, got %q", expected, received)
+	}
+
+	if problems := dsl.Validate(script); len(problems) != 0 {
+		t.Errorf("Expected no syntax errors, got %v", problems)
+	}
+	if formatted := dsl.Format(script); !strings.Contains(formatted, "\n        Dear \"$name\",\n") {
+		t.Errorf("Expected the multiline string to be kept as written, got:\n%s", formatted)
+	}
+
+	_, err := dsl.ParseWithBlockSupport("POST \"$base/users\" json <<EOF\n{}")
+	if err == nil || !strings.Contains(err.Error(), "missing EOF") {
+		t.Errorf("Expected an unclosed multiline string error, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// historyEntry returns the request numbered n in the history, counting from 1
+// for the oldest request kept
+func (hd *HTTPDSLv3) historyEntry(n int) (RequestHistory, error) {
+	history := hd.engine.GetHistory()
+	if len(history) == 0 {
+		return RequestHistory{}, fmt.Errorf("no requests in history")
+	}
+	if n < 1 || n > len(history) {
+		return RequestHistory{}, fmt.Errorf("no request %d in history, it holds requests 1 to %d", n, len(history))
+	}
+	return history[n-1], nil
+}
+
+// formatHistory lists the requests in the history, one per line with the
+// redirects they followed under them
+func (hd *HTTPDSLv3) formatHistory() string {
+	history := hd.engine.GetHistory()
+	if len(history) == 0 {
+		return "No requests in history"
+	}
+
+	lines := make([]string, len(history))
+	for i, entry := range history {
+		status := "no response"
+		if entry.Response != nil {
+			status = fmt.Sprint(entry.Response.StatusCode)
+		}
+		lines[i] = fmt.Sprintf("%d. %s %s -> %s (%v)", i+1, entry.Request.Method, entry.Request.URL,
+			status, entry.Duration.Round(time.Millisecond))
+		if entry.RequestID != "" {
+			lines[i] += " id " + entry.RequestID
+		}
+		for _, hop := range entry.Redirects {
+			lines[i] += fmt.Sprintf("\n   ↪ %d %s", hop.Status, hop.Location)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatHistoryEntry shows a request from the history and its response with
+// headers and bodies
+func (hd *HTTPDSLv3) formatHistoryEntry(n int) (string, error) {
+	entry, err := hd.historyEntry(n)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Request %d at %s (%v)", n, entry.Timestamp.Format(time.RFC3339), entry.Duration.Round(time.Millisecond))
+	if entry.Reused {
+		b.WriteString(" on a reused connection")
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "%s %s\n", entry.Request.Method, entry.Request.URL)
+	writeHeaders(&b, entry.Request.Header)
+	if entry.RequestBody != "" {
+		fmt.Fprintf(&b, "\n%s\n", entry.RequestBody)
+	}
+	if len(entry.Redirects) > 0 {
+		b.WriteString("\nRedirects:\n")
+		for _, hop := range entry.Redirects {
+			fmt.Fprintf(&b, "%d %s %s -> %s\n", hop.Status, hop.Method, hop.URL, hop.Location)
+		}
+	}
+	if entry.Response != nil {
+		fmt.Fprintf(&b, "\n%s %s\n", entry.Response.Proto, entry.Response.Status)
+		writeHeaders(&b, entry.Response.Header)
+		if entry.ResponseBody != "" {
+			fmt.Fprintf(&b, "\n%s\n", entry.ResponseBody)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+// writeHeaders writes headers one per line, sorted by name
+func writeHeaders(b *strings.Builder, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(b, "%s: %s\n", name, strings.Join(header[name], ", "))
+	}
+}
+
+// replay sends a request from the history again with the same method, URL,
+// headers and body. Cookies come from the jar as it is now, and before
+// request and after response hooks do not run.
+func (hd *HTTPDSLv3) replay(n int) (interface{}, error) {
+	entry, err := hd.historyEntry(n)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string]string, len(entry.Request.Header))
+	for name, values := range entry.Request.Header {
+		if name == "Cookie" || name == "Content-Length" {
+			continue
+		}
+		// A replay is a new request, so it gets new trace headers
+		if hd.engine.TraceIDs() && (name == http.CanonicalHeaderKey(requestIDHeader) || name == traceparentHeader) {
+			continue
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+
+	options := map[string]interface{}{"header": headers}
+	if entry.RequestBody != "" {
+		options["body"] = entry.RequestBody
+	}
+	return hd.doRequest(entry.Request.Method, entry.Request.URL.String(), options)
+}
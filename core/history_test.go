@@ -0,0 +1,60 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTTPDSLv3HistoryReplay(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r.Method+" "+r.URL.Path+" "+r.Header.Get("X-Trace")+" "+string(body))
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	script := `POST "$base/orders" header "X-Trace" "t1" body "abc"
+GET "$base/health"
+replay 1`
+	steps, err := dsl.RunScript(script)
+	if err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+
+	expected := []string{"POST /orders t1 abc", "GET /health  ", "POST /orders t1 abc"}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("Expected requests %v, got %v", expected, received)
+	}
+	if last := steps[len(steps)-1]; last.Kind != "request" || last.Request == nil || last.Request.Method != "POST" {
+		t.Errorf("Expected replay to be a POST request step, got %+v", last)
+	}
+
+	listing, err := dsl.Parse("history")
+	if err != nil {
+		t.Fatalf("history failed: %v", err)
+	}
+	if lines := strings.Split(listing.(string), "\n"); len(lines) != 3 || !strings.HasPrefix(lines[1], "2. GET "+server.URL+"/health -> 200") {
+		t.Errorf("Unexpected history listing:\n%v", listing)
+	}
+
+	shown, err := dsl.Parse("history show 1")
+	if err != nil {
+		t.Fatalf("history show failed: %v", err)
+	}
+	for _, want := range []string{"POST " + server.URL + "/orders", "X-Trace: t1", "abc", "200 OK", `{"ok": true}`} {
+		if !strings.Contains(shown.(string), want) {
+			t.Errorf("Expected history show to contain %q, got:\n%s", want, shown)
+		}
+	}
+
+	if _, err := dsl.Parse("replay 9"); err == nil {
+		t.Error("Expected replaying a request missing from history to fail")
+	}
+}
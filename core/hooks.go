@@ -0,0 +1,167 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Hook block openers; each block is closed by a line with "end"
+const (
+	beforeRequestHook = "before request"
+	afterResponseHook = "after response"
+)
+
+// isEndBlockOpener reports whether a line starts a block closed by "end": a
+// hook block or a default headers block
+func isEndBlockOpener(line string) bool {
+	return line == beforeRequestHook || line == afterResponseHook || line == defaultHeadersBlock
+}
+
+// collectHookBody returns the lines of a hook block starting at index start,
+// and the index of the line that closes it
+func collectHookBody(lines []string, start int, opener string) (string, int, error) {
+	var body []string
+	for i := start; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "end" {
+			return strings.Join(body, "\n"), i, nil
+		}
+		body = append(body, lines[i])
+	}
+	return "", len(lines), fmt.Errorf("%s block is never closed, missing end", opener)
+}
+
+// addHook registers the body of a hook block. Blocks of the same kind run in
+// the order they were defined.
+func (hd *HTTPDSLv3) addHook(opener, body string) {
+	if opener == beforeRequestHook {
+		hd.beforeHooks = append(hd.beforeHooks, body)
+	} else {
+		hd.afterHooks = append(hd.afterHooks, body)
+	}
+}
+
+// sendRequest runs the before request hooks, sends the request, and runs the
+// after response hooks. Before hooks see the request as the $request object
+// (method, url, headers, body) and may change it with set $request.<field>
+// and header statements. Requests sent from inside a hook skip the hooks.
+func (hd *HTTPDSLv3) sendRequest(method, url string, options map[string]interface{}) (interface{}, error) {
+	if hd.hookPhase != "" || hd.taking != nil || (len(hd.beforeHooks) == 0 && len(hd.afterHooks) == 0) {
+		return hd.doRequest(method, url, options)
+	}
+
+	if options == nil {
+		options = make(map[string]interface{})
+	}
+
+	headers := make(map[string]interface{})
+	if optHeaders, ok := options["header"].(map[string]string); ok {
+		for key, value := range optHeaders {
+			headers[key] = value
+		}
+	}
+	body, isJSON := options["json"].(string)
+	if !isJSON {
+		body, _ = options["body"].(string)
+	}
+
+	hd.SetVariable("request", map[string]interface{}{
+		"method":  method,
+		"url":     url,
+		"headers": headers,
+		"body":    body,
+	})
+
+	if err := hd.runHooks(beforeRequestHook, hd.beforeHooks); err != nil {
+		return nil, err
+	}
+
+	value, _ := hd.GetVariable("request")
+	request, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("before request hook: $request must be an object, got %s", formatValue(value))
+	}
+
+	method = strings.ToUpper(formatValue(request["method"]))
+	url = formatValue(request["url"])
+	if fields, ok := request["headers"].(map[string]interface{}); ok {
+		finalHeaders := make(map[string]string, len(fields))
+		for key, value := range fields {
+			finalHeaders[key] = formatValue(value)
+		}
+		options["header"] = finalHeaders
+	}
+	if finalBody := formatValue(request["body"]); isJSON {
+		options["json"] = finalBody
+	} else if finalBody != "" {
+		options["body"] = finalBody
+	}
+
+	result, err := hd.doRequest(method, url, options)
+	if err != nil {
+		return result, err
+	}
+
+	if err := hd.runHooks(afterResponseHook, hd.afterHooks); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// doRequest sends a request through the engine and reports it to listeners.
+// While takeRequest runs, the request is only taken.
+func (hd *HTTPDSLv3) doRequest(method, url string, options map[string]interface{}) (interface{}, error) {
+	if taken := hd.taking; taken != nil {
+		taken.method, taken.url, taken.options = method, url, options
+		return nil, nil
+	}
+
+	hd.emit(Event{Type: EventRequestSent, Method: method, URL: url})
+
+	start := time.Now()
+	result, err := hd.engine.RequestWithRetryContext(hd.ctx, method, url, options)
+
+	event := Event{Type: EventResponseReceived, Method: method, URL: url, Err: err, Duration: time.Since(start)}
+	if response, ok := result.(map[string]interface{}); ok {
+		event.Status, _ = response["status"].(int)
+		if finalURL, ok := response["url"].(string); ok {
+			event.URL = finalURL
+		}
+	}
+	hd.emit(event)
+
+	return result, err
+}
+
+// runHooks executes the bodies of one kind of hook block in order
+func (hd *HTTPDSLv3) runHooks(phase string, hooks []string) error {
+	hd.hookPhase = phase
+	defer func() { hd.hookPhase = "" }()
+
+	for _, body := range hooks {
+		if _, err := hd.ParseWithBlockSupport(body); err != nil {
+			return fmt.Errorf("%s hook: %w", phase, err)
+		}
+	}
+	return nil
+}
+
+// takenRequest is a request built by a statement but not sent, for commands
+// like benchmark and paginate that send it themselves
+type takenRequest struct {
+	method  string
+	url     string
+	options map[string]interface{}
+}
+
+// takeRequest evaluates a request node without sending the request. Request
+// hooks do not run for it.
+func (hd *HTTPDSLv3) takeRequest(request interface{}) (*takenRequest, error) {
+	taken := &takenRequest{}
+	hd.taking = taken
+	defer func() { hd.taking = nil }()
+	if _, err := hd.evaluate(request); err != nil {
+		return nil, err
+	}
+	return taken, nil
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestHTTPDSLv3RequestHooks(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.URL.RequestURI()+" "+r.Header.Get("X-Signature"))
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	script := `before request
+    header "X-Signature" "sig-${request.body}"
+    set $request.url "${request.url}?signed=1"
+end
+after response
+    set $after_count $after_count + 1
+    assert status 200
+end
+set $after_count 0
+POST "$base/orders" body "abc"
+GET "$base/health"`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+
+	expected := []string{"/orders?signed=1 sig-abc", "/health?signed=1 sig-"}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("Expected signed requests %v, got %v", expected, received)
+	}
+	if count, _ := dsl.GetVariable("after_count"); fmt.Sprint(count) != "2" {
+		t.Errorf("Expected the after response hook to run twice, got %v", count)
+	}
+
+	if _, err := dsl.Parse(`header "X-Test" "1"`); err == nil {
+		t.Error("Expected header outside a before request block to fail")
+	}
+	if problems := dsl.Validate("before request\n    log \"x\""); len(problems) != 1 {
+		t.Errorf("Expected an unclosed hook block to be reported, got %v", problems)
+	}
+}
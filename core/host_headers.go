@@ -0,0 +1,104 @@
+package core
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// hostHeaders are headers sent only to hosts matching a pattern, so the
+// credentials of one origin never reach another one, not even through a
+// redirect. Like the host policy, it is replaced, never changed, once an
+// engine uses it.
+type hostHeaders struct {
+	rules []hostHeader // In the order they were set; later ones win
+}
+
+// hostHeader is a header for the hosts matching a pattern
+type hostHeader struct {
+	pattern string
+	name    string
+	value   string
+}
+
+// forHost returns the headers of the rules matching a host
+func (h *hostHeaders) forHost(host string) http.Header {
+	header := make(http.Header)
+	if h == nil {
+		return header
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	for _, rule := range h.rules {
+		if hostMatches(rule.pattern, host) {
+			header.Set(rule.name, rule.value)
+		}
+	}
+	return header
+}
+
+// apply sets the headers of the rules matching the host of a request
+func (h *hostHeaders) apply(req *http.Request) {
+	for name, values := range h.forHost(req.URL.Hostname()) {
+		req.Header[name] = values
+	}
+}
+
+// checkRedirect wraps a client's redirect check so a redirect drops the
+// headers set for the host of the first request and gets those of its own
+// host. The client copies the headers of the first request to every
+// redirect; a header the request set itself, with another value, is kept.
+func (h *hostHeaders) checkRedirect(next func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		for name, values := range h.forHost(via[0].URL.Hostname()) {
+			if req.Header.Get(name) == values[0] {
+				req.Header.Del(name)
+			}
+		}
+		h.apply(req)
+		if next != nil {
+			return next(req, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+}
+
+// SetHostHeader sets a header sent only to hosts matching a pattern, where a
+// * stands for any labels, as in "*.internal.example.com". It wins over
+// global headers; a request's own headers win over it.
+func (he *HTTPEngine) SetHostHeader(pattern, name, value string) {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	headers := &hostHeaders{}
+	if he.hostHeaders != nil {
+		headers.rules = append(headers.rules, he.hostHeaders.rules...)
+	}
+	headers.rules = append(headers.rules, hostHeader{
+		pattern: strings.ToLower(pattern),
+		name:    http.CanonicalHeaderKey(name),
+		value:   value,
+	})
+	he.hostHeaders = headers
+}
+
+// SetHostBasicAuth sends basic authentication credentials only to hosts
+// matching a pattern
+func (he *HTTPEngine) SetHostBasicAuth(pattern, username, password string) {
+	encoded := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	he.SetHostHeader(pattern, "Authorization", "Basic "+encoded)
+}
+
+// SetHostBearerToken sends a bearer token only to hosts matching a pattern
+func (he *HTTPEngine) SetHostBearerToken(pattern, token string) {
+	he.SetHostHeader(pattern, "Authorization", "Bearer "+token)
+}
+
+// ClearHostHeaders removes the headers and credentials set for hosts
+func (he *HTTPEngine) ClearHostHeaders() {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	he.hostHeaders = nil
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTTPDSLv3HostHeaders(t *testing.T) {
+	var seen []string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, "other|"+r.Header.Get("X-Api-Key")+"|"+r.Header.Get("X-Internal")+"|"+r.Header.Get("Authorization"))
+	}))
+	defer other.Close()
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, "api|"+r.Header.Get("X-Api-Key")+"|"+r.Header.Get("X-Internal")+"|"+r.Header.Get("Authorization"))
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, otherURL+"/landing", http.StatusFound)
+		}
+	}))
+	defer api.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("api", api.URL)
+	dsl.SetVariable("other", otherURL)
+	script := `for host "127.0.0.1" header "X-Api-Key" "k1"
+for host "127.0.0.1" auth basic "ada" "secret"
+for host "localhost" header "X-Internal" "1"
+GET "$api/users"
+GET "$other/users"
+GET "$api/redirect"
+GET "$api/users" header "X-Api-Key" "own"
+clear host headers
+GET "$api/users"`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("host headers script failed: %v", err)
+	}
+	basic := "Basic YWRhOnNlY3JldA=="
+	expected := []string{
+		"api|k1||" + basic,
+		"other||1|",
+		"api|k1||" + basic,
+		"other||1|",
+		"api|own||" + basic,
+		"api|||",
+	}
+	if !reflect.DeepEqual(seen, expected) {
+		t.Errorf("Unexpected headers:\n got %q\nwant %q", seen, expected)
+	}
+}
@@ -0,0 +1,186 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"syscall"
+)
+
+// hostPolicy limits the hosts requests may reach, so a script run against
+// the wrong environment fails instead of hitting production or internal
+// services. It is replaced, never changed, once an engine uses it.
+type hostPolicy struct {
+	allow       []string // Host patterns; when set, other hosts are refused
+	deny        []string // Host patterns refused even when allowed
+	denyPrivate bool     // Refuse loopback, private, link-local and unspecified addresses
+}
+
+// policyError is a request refused by the host policy. It is not a network
+// error: sending the request again would be refused too.
+type policyError struct {
+	msg string
+}
+
+func (e *policyError) Error() string {
+	return e.msg
+}
+
+// active reports whether the policy refuses anything
+func (p *hostPolicy) active() bool {
+	return p != nil && (len(p.allow) > 0 || len(p.deny) > 0 || p.denyPrivate)
+}
+
+// checkHost checks a URL host against the patterns, and its addresses against
+// the private networks rule. Names are resolved here because a request sent
+// through a proxy never dials its host; a name that does not resolve is left
+// to fail on its own, or to the proxy.
+func (p *hostPolicy) checkHost(ctx context.Context, host string) error {
+	if !p.active() {
+		return nil
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	for _, pattern := range p.deny {
+		if hostMatches(pattern, host) {
+			return &policyError{fmt.Sprintf("host %s is denied by host policy (%s)", host, pattern)}
+		}
+	}
+	if len(p.allow) > 0 {
+		allowed := false
+		for _, pattern := range p.allow {
+			if hostMatches(pattern, host) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return &policyError{fmt.Sprintf("host %s is not in the allowed hosts %s", host, strings.Join(p.allow, ", "))}
+		}
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return p.checkIP(ip)
+	}
+	if !p.denyPrivate {
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if isPrivateIP(addr.IP) {
+			return &policyError{fmt.Sprintf("host %s resolves to %s, in a private network, denied by host policy", host, addr.IP)}
+		}
+	}
+	return nil
+}
+
+// checkIP refuses private addresses when the policy denies them
+func (p *hostPolicy) checkIP(ip net.IP) error {
+	if p.active() && p.denyPrivate && isPrivateIP(ip) {
+		return &policyError{fmt.Sprintf("address %s is in a private network, denied by host policy", ip)}
+	}
+	return nil
+}
+
+// dialControl checks the address a connection is about to use, after DNS, so
+// names that resolve to private addresses are refused too. Dials to the
+// proxy of a request are not checked: the proxy is configured, not reached
+// by the script, and checkHost has checked the host behind it.
+func (p *hostPolicy) dialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return p.checkIP(ip)
+	}
+	return nil
+}
+
+// checkRedirect wraps a client's redirect check so every redirect target is
+// checked too; without one, the http.Client limit of 10 redirects applies
+func (p *hostPolicy) checkRedirect(next func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if err := p.checkHost(req.Context(), req.URL.Hostname()); err != nil {
+			return err
+		}
+		if next != nil {
+			return next(req, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+}
+
+// isPrivateIP reports whether ip is loopback, private (RFC 1918 and RFC 4193),
+// link-local (which holds cloud metadata services) or unspecified
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
+
+// hostMatches matches a host against a pattern: a name where * stands for
+// any labels ("*.staging.example.com"), or a CIDR block for IP hosts
+func hostMatches(pattern, host string) bool {
+	if _, block, err := net.ParseCIDR(pattern); err == nil {
+		ip := net.ParseIP(host)
+		return ip != nil && block.Contains(ip)
+	}
+	matched, _ := path.Match(strings.ToLower(pattern), host)
+	return matched
+}
+
+// AllowHosts adds host patterns requests may reach; once any are set, every
+// other host is refused. A * matches any labels, as in
+// "*.staging.example.com", and CIDR blocks match IP hosts.
+func (he *HTTPEngine) AllowHosts(patterns ...string) {
+	he.updateHostPolicy(func(p *hostPolicy) {
+		p.allow = append(p.allow, patterns...)
+	})
+}
+
+// DenyHosts adds host patterns requests may never reach, even when allowed
+func (he *HTTPEngine) DenyHosts(patterns ...string) {
+	he.updateHostPolicy(func(p *hostPolicy) {
+		p.deny = append(p.deny, patterns...)
+	})
+}
+
+// SetDenyPrivateNetworks refuses connections to loopback, private,
+// link-local and unspecified addresses. Hosts are resolved before the
+// request is sent, and the address is checked again when the connection is
+// made, so a request through a proxy is checked too.
+func (he *HTTPEngine) SetDenyPrivateNetworks(deny bool) {
+	he.updateHostPolicy(func(p *hostPolicy) {
+		p.denyPrivate = deny
+	})
+}
+
+// ClearHostPolicy removes every host rule
+func (he *HTTPEngine) ClearHostPolicy() {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	he.hostPolicy = nil
+}
+
+// updateHostPolicy replaces the host policy with a changed copy, so requests
+// in flight keep the one they started with
+func (he *HTTPEngine) updateHostPolicy(fn func(p *hostPolicy)) {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	policy := &hostPolicy{}
+	if he.hostPolicy != nil {
+		policy.allow = append([]string(nil), he.hostPolicy.allow...)
+		policy.deny = append([]string(nil), he.hostPolicy.deny...)
+		policy.denyPrivate = he.hostPolicy.denyPrivate
+	}
+	fn(policy)
+	he.hostPolicy = policy
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestHTTPDSLv3HostPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://metadata.internal/latest", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	dsl.SetVariable("local", strings.Replace(server.URL, "127.0.0.1", "localhost", 1))
+	script := `allow hosts "127.0.0.0/8" "*.internal"
+GET "$base/ok"
+assert status 200`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("Allowed host failed: %v", err)
+	}
+
+	if _, err := dsl.Parse(`GET "$local/ok"`); err == nil || !strings.Contains(err.Error(), "not in the allowed hosts") {
+		t.Errorf("Expected a host outside the allowlist to be refused, got %v", err)
+	}
+	if _, err := dsl.ParseWithBlockSupport("deny hosts \"metadata.internal\"\nGET \"$base/redirect\""); err == nil || !strings.Contains(err.Error(), "denied by host policy") {
+		t.Errorf("Expected a redirect to a denied host to be refused, got %v", err)
+	}
+
+	// Names are checked after DNS, so localhost is refused as a private address
+	dsl.Parse("clear host policy")
+	if _, err := dsl.ParseWithBlockSupport("deny private networks\nGET \"$local/ok\""); err == nil || !strings.Contains(err.Error(), "private network") {
+		t.Errorf("Expected a private address to be refused, got %v", err)
+	}
+	dsl.Parse("clear host policy")
+	if _, err := dsl.Parse(`GET "$local/ok"`); err != nil {
+		t.Errorf("Expected no policy after clear, got %v", err)
+	}
+}
+
+func TestHTTPDSLv3HostPolicyThroughProxy(t *testing.T) {
+	var proxied []string
+	var mu sync.Mutex
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		proxied = append(proxied, r.URL.Host)
+		mu.Unlock()
+		w.Write([]byte("proxied " + r.URL.Host))
+	}))
+	defer proxy.Close()
+
+	dsl := NewHTTPDSLv3()
+	if err := dsl.GetEngine().SetProxy(proxy.URL); err != nil {
+		t.Fatalf("SetProxy failed: %v", err)
+	}
+	dsl.Parse("deny private networks")
+
+	// The proxy is on loopback, but only the host behind it is checked
+	if _, err := dsl.Parse(`GET "http://api.example.test/items"`); err != nil {
+		t.Fatalf("Expected a proxy on a private network to be usable, got %v", err)
+	}
+	if body := dsl.GetEngine().GetLastResponse(); body != "proxied api.example.test" {
+		t.Errorf("Expected the proxy to answer, got %q", body)
+	}
+
+	// The proxy would reach localhost for the script, so the name is resolved first
+	_, err := dsl.Parse(`GET "http://localhost:8080/admin"`)
+	if err == nil || !strings.Contains(err.Error(), "private network") {
+		t.Errorf("Expected a name resolving to a private address to be refused, got %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(proxied) != 1 {
+		t.Errorf("Expected only the allowed request to reach the proxy, got %v", proxied)
+	}
+}
@@ -259,7 +259,7 @@ func TestHTTPDSLVariables(t *testing.T) {
 			name:     "Set number variable",
 			input:    `set $count 42`,
 			varName:  "count",
-			expected: float64(42),
+			expected: 42,
 		},
 		{
 			name:     "Alternative var syntax",
@@ -567,7 +567,7 @@ func TestHTTPDSLUtilities(t *testing.T) {
 		{
 			name:     "Sleep command",
 			input:    `sleep 0.1 s`,
-			expected: "Waited 100s",
+			expected: "Waited 100ms",
 		},
 		{
 			name:     "Log message",
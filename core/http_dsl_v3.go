@@ -29,15 +29,29 @@
 package core
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/arturoeanton/go-dsl/pkg/dslbuilder"
 )
 
+// methodName matches the characters allowed in an HTTP method (RFC 9110 token)
+var methodName = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
 // HTTPDSLv3 represents the production-ready HTTP DSL implementation.
 // It provides a complete domain-specific language for HTTP testing and automation,
 // supporting methods, headers, variables, control flow, and data extraction.
@@ -51,11 +65,46 @@ import (
 //   - Array operations with indexing
 //   - JSON/regex/XPath extraction
 //   - Command-line argument support
+//
+// An instance runs one script at a time. While it runs, variables can be read
+// and set from other goroutines, and the engine can serve concurrent requests.
 type HTTPDSLv3 struct {
-	dsl       *dslbuilder.DSL        // DSL parser and tokenizer
-	engine    *HTTPEngine            // HTTP request execution engine
-	variables map[string]interface{} // Script variables storage
-	context   map[string]interface{} // Execution context (break/continue flags)
+	dsl       *dslbuilder.DSL                  // DSL parser and tokenizer
+	engine    *HTTPEngine                      // HTTP request execution engine
+	variables map[string]interface{}           // Script variables storage
+	varsLock  sync.RWMutex                     // Guards variables for readers on other goroutines
+	context   map[string]interface{}           // Execution context (break/continue flags)
+	actions   map[string]dslbuilder.ActionFunc // Actions run by the evaluator
+	lazy      map[string]bool                  // Actions that receive unevaluated arguments
+	lists     map[string]dslbuilder.ActionFunc // List builders run while parsing
+	ctx       context.Context                  // Cancels running requests, waits and statements
+
+	beforeHooks []string               // Bodies of before request blocks
+	afterHooks  []string               // Bodies of after response blocks
+	hookPhase   string                 // Hook or default headers block running now, empty outside them
+	templates   map[string]interface{} // Parsed requests of request templates, by name
+
+	taking        *takenRequest   // Takes the request being built instead of sending it
+	lastBenchmark *benchmarkStats // Statistics of the last benchmark, for assert benchmark
+	debugLevel    *LogLevel       // Log level debug off restores, nil outside debug on
+
+	snapshotDir     string // Directory of snapshot files, defaultSnapshotDir when empty
+	updateSnapshots bool   // Whether snapshot overwrites stored snapshots
+
+	commands       map[string]int     // Custom command names and their arity
+	functions      map[string]bool    // Custom function names
+	defaultHeaders map[string]bool    // Headers set with default header
+	extensions     []func(*HTTPDSLv3) // Replay the custom commands and functions on a fork
+
+	recording  bool          // Whether executed statements are recorded as steps
+	steps      []StepResult  // Steps recorded by RunScript
+	blockDepth int           // Nesting of ParseWithBlockSupport calls
+	stepLine   int           // Top-level script line being executed
+	listeners  []func(Event) // Called for every execution event
+
+	random     *rand.Rand // Source of the random functions
+	randomSeed int64      // Seed of random, to replay a run
+	usedRandom bool       // Whether the script drew random values
 }
 
 // NewHTTPDSLv3 creates a new HTTP DSL v3 instance.
@@ -76,8 +125,19 @@ func NewHTTPDSLv3() *HTTPDSLv3 {
 		engine:    NewHTTPEngine(),
 		variables: make(map[string]interface{}),
 		context:   make(map[string]interface{}),
+		actions:   make(map[string]dslbuilder.ActionFunc),
+		lazy:      make(map[string]bool),
+		lists:     make(map[string]dslbuilder.ActionFunc),
+		ctx:       context.Background(),
+		commands:  make(map[string]int),
+		functions: make(map[string]bool),
+
+		defaultHeaders: make(map[string]bool),
+		templates:      make(map[string]interface{}),
 	}
 	hd.setupGrammar()
+	hd.registerRandomFunctions()
+	hd.SetRandomSeed(time.Now().UnixNano())
 	return hd
 }
 
@@ -95,9 +155,16 @@ func (hd *HTTPDSLv3) setupGrammar() {
 	hd.dsl.KeywordToken("OPTIONS", "OPTIONS")
 	hd.dsl.KeywordToken("CONNECT", "CONNECT")
 	hd.dsl.KeywordToken("TRACE", "TRACE")
+	hd.dsl.KeywordToken("METHOD", "METHOD")
+	hd.dsl.KeywordToken("graphql", "graphql")
+	hd.dsl.KeywordToken("query", "query")
+	hd.dsl.KeywordToken("variables", "variables")
+	hd.dsl.KeywordToken("submit", "submit")
+	hd.dsl.KeywordToken("invoke", "invoke")
 
 	// Keywords - High priority (90)
 	hd.dsl.KeywordToken("header", "header")
+	hd.dsl.KeywordToken("headers", "headers")
 	hd.dsl.KeywordToken("body", "body")
 	hd.dsl.KeywordToken("json", "json")
 	hd.dsl.KeywordToken("form", "form")
@@ -105,6 +172,11 @@ func (hd *HTTPDSLv3) setupGrammar() {
 	hd.dsl.KeywordToken("basic", "basic")
 	hd.dsl.KeywordToken("bearer", "bearer")
 	hd.dsl.KeywordToken("timeout", "timeout")
+	hd.dsl.KeywordToken("retry", "retry")
+	hd.dsl.KeywordToken("backoff", "backoff")
+	hd.dsl.KeywordToken("jitter", "jitter")
+	hd.dsl.KeywordToken("read", "read")
+	hd.dsl.KeywordToken("tls", "tls")
 	hd.dsl.KeywordToken("ms", "ms")
 	hd.dsl.KeywordToken("s", "s")
 
@@ -113,20 +185,25 @@ func (hd *HTTPDSLv3) setupGrammar() {
 	hd.dsl.KeywordToken("var", "var")
 	hd.dsl.KeywordToken("print", "print")
 	hd.dsl.KeywordToken("length", "length")
+	hd.dsl.KeywordToken("bytes", "bytes")
 	hd.dsl.KeywordToken("split", "split")
 	hd.dsl.KeywordToken("at", "at")
 	hd.dsl.KeywordToken("extract", "extract")
 	hd.dsl.KeywordToken("from", "from")
 	hd.dsl.KeywordToken("as", "as")
+	hd.dsl.KeywordToken("all", "all")
 	hd.dsl.KeywordToken("jsonpath", "jsonpath")
 	hd.dsl.KeywordToken("xpath", "xpath")
 	hd.dsl.KeywordToken("regex", "regex")
+	hd.dsl.KeywordToken("csrf", "csrf")
+	hd.dsl.KeywordToken("links", "links")
 	hd.dsl.KeywordToken("status", "status")
 	hd.dsl.KeywordToken("response", "response")
 
 	// Conditionals
 	hd.dsl.KeywordToken("if", "if")
 	hd.dsl.KeywordToken("then", "then")
+	hd.dsl.KeywordToken("elseif", "elseif")
 	hd.dsl.KeywordToken("else", "else")
 	hd.dsl.KeywordToken("endif", "endif")
 	hd.dsl.KeywordToken("contains", "contains")
@@ -145,17 +222,94 @@ func (hd *HTTPDSLv3) setupGrammar() {
 	hd.dsl.KeywordToken("while", "while")
 	hd.dsl.KeywordToken("foreach", "foreach")
 	hd.dsl.KeywordToken("in", "in")
+	hd.dsl.KeywordToken("for", "for")
+	hd.dsl.KeywordToken("step", "step")
+	hd.dsl.KeywordToken("until", "until")
+	hd.dsl.KeywordToken("switch", "switch")
+	hd.dsl.KeywordToken("case", "case")
+	hd.dsl.KeywordToken("endswitch", "endswitch")
 	hd.dsl.KeywordToken("break", "break")
 	hd.dsl.KeywordToken("continue", "continue")
+	hd.dsl.KeywordToken("paginate", "paginate")
+	hd.dsl.KeywordToken("endpaginate", "endpaginate")
+	hd.dsl.KeywordToken("next", "next")
+	hd.dsl.KeywordToken("param", "param")
+	hd.dsl.KeywordToken("items", "items")
+	hd.dsl.KeywordToken("max", "max")
+	hd.dsl.KeywordToken("pages", "pages")
 
 	// Assertions
 	hd.dsl.KeywordToken("assert", "assert")
 	hd.dsl.KeywordToken("expect", "expect")
 	hd.dsl.KeywordToken("time", "time")
+	hd.dsl.KeywordToken("ttfb", "ttfb")
+	hd.dsl.KeywordToken("size", "size")
+	hd.dsl.KeywordToken("link", "link")
+	hd.dsl.KeywordToken("rel", "rel")
+	hd.dsl.KeywordToken("ignoring", "ignoring")
+	hd.dsl.KeywordToken("count", "count")
 
 	// Utilities
 	hd.dsl.KeywordToken("wait", "wait")
 	hd.dsl.KeywordToken("sleep", "sleep")
+	hd.dsl.KeywordToken("interval", "interval")
+	hd.dsl.KeywordToken("eventually", "eventually")
+	hd.dsl.KeywordToken("benchmark", "benchmark")
+	hd.dsl.KeywordToken("concurrency", "concurrency")
+	hd.dsl.KeywordToken("snapshot", "snapshot")
+	hd.dsl.KeywordToken("diff", "diff")
+	hd.dsl.KeywordToken("with", "with")
+	hd.dsl.KeywordToken("file", "file")
+	hd.dsl.KeywordToken("binary", "binary")
+	hd.dsl.KeywordToken("template", "template")
+	hd.dsl.KeywordToken("load", "load")
+	hd.dsl.KeywordToken("yaml", "yaml")
+	hd.dsl.KeywordToken("save", "save")
+	hd.dsl.KeywordToken("compress", "compress")
+	hd.dsl.KeywordToken("request", "request")
+	hd.dsl.KeywordToken("accept", "accept")
+	hd.dsl.KeywordToken("encoding", "encoding")
+	hd.dsl.KeywordToken("raw", "raw")
+	hd.dsl.KeywordToken("cache", "cache")
+	hd.dsl.KeywordToken("etags", "etags")
+	hd.dsl.KeywordToken("seed", "seed")
+	hd.dsl.KeywordToken("dns", "dns")
+	hd.dsl.KeywordToken("lookup", "lookup")
+	hd.dsl.KeywordToken("allow", "allow")
+	hd.dsl.KeywordToken("deny", "deny")
+	hd.dsl.KeywordToken("host", "host")
+	hd.dsl.KeywordToken("hosts", "hosts")
+	hd.dsl.KeywordToken("redirect", "redirect")
+	hd.dsl.KeywordToken("redirects", "redirects")
+	hd.dsl.KeywordToken("cross-host", "cross-host")
+	hd.dsl.KeywordToken("connection", "connection")
+	hd.dsl.KeywordToken("private", "private")
+	hd.dsl.KeywordToken("networks", "networks")
+	hd.dsl.KeywordToken("policy", "policy")
+	hd.dsl.KeywordToken("proxy", "proxy")
+	hd.dsl.KeywordToken("chaos", "chaos")
+	hd.dsl.KeywordToken("throttle", "throttle")
+	hd.dsl.KeywordToken("oidc", "oidc")
+	hd.dsl.KeywordToken("negotiate", "negotiate")
+	hd.dsl.KeywordToken("keytab", "keytab")
+	hd.dsl.KeywordToken("principal", "principal")
+	hd.dsl.KeywordToken("client_credentials", "client_credentials")
+	hd.dsl.KeywordToken("client", "client")
+	hd.dsl.KeywordToken("secret", "secret")
+	hd.dsl.KeywordToken("scope", "scope")
+	hd.dsl.KeywordToken("azure", "azure")
+	hd.dsl.KeywordToken("tenant", "tenant")
+	hd.dsl.KeywordToken("google", "google")
+	hd.dsl.KeywordToken("service", "service")
+	hd.dsl.KeywordToken("account", "account")
+	hd.dsl.KeywordToken("stream", "stream")
+	hd.dsl.KeywordToken("receives", "receives")
+	hd.dsl.KeywordToken("within", "within")
+	hd.dsl.KeywordToken("lines", "lines")
+	hd.dsl.KeywordToken("latency", "latency")
+	hd.dsl.KeywordToken("probability", "probability")
+	hd.dsl.KeywordToken("fail", "fail")
+	hd.dsl.KeywordToken("environment", "environment")
 	hd.dsl.KeywordToken("log", "log")
 	hd.dsl.KeywordToken("debug", "debug")
 	hd.dsl.KeywordToken("clear", "clear")
@@ -163,6 +317,21 @@ func (hd *HTTPDSLv3) setupGrammar() {
 	hd.dsl.KeywordToken("reset", "reset")
 	hd.dsl.KeywordToken("base", "base")
 	hd.dsl.KeywordToken("url", "url")
+	hd.dsl.KeywordToken("resolve", "resolve")
+	hd.dsl.KeywordToken("to", "to")
+	hd.dsl.KeywordToken("unix", "unix")
+	hd.dsl.KeywordToken("socket", "socket")
+	hd.dsl.KeywordToken("default", "default")
+	hd.dsl.KeywordToken("unset", "unset")
+	hd.dsl.KeywordToken("increment", "increment")
+	hd.dsl.KeywordToken("decrement", "decrement")
+	hd.dsl.KeywordToken("by", "by")
+	hd.dsl.KeywordToken("vars", "vars")
+	hd.dsl.KeywordToken("jq", "jq")
+	hd.dsl.KeywordToken("pretty", "pretty")
+	hd.dsl.KeywordToken("history", "history")
+	hd.dsl.KeywordToken("show", "show")
+	hd.dsl.KeywordToken("replay", "replay")
 
 	// Operators
 	hd.dsl.KeywordToken("and", "and")
@@ -181,13 +350,19 @@ func (hd *HTTPDSLv3) setupGrammar() {
 	// String with escape sequences - handles \n, \t, \", etc.
 	hd.dsl.Token("STRING", `"(?:[^"\\]|\\.)*"`)
 	hd.dsl.Token("NUMBER", `[0-9]+(\.[0-9]+)?`)
-	hd.dsl.Token("VARIABLE", `\$[a-zA-Z_][a-zA-Z0-9_]*`)
+	hd.dsl.Token("VARIABLE", `\$[a-zA-Z_][a-zA-Z0-9_]*(?:\.[a-zA-Z0-9_]+)*`)
 	hd.dsl.Token("URL", `https?://[^\s]+`)
 	hd.dsl.Token("COMPARISON", `==|!=|>=|<=|>|<`)
+	hd.dsl.Token("ASSIGN_OP", `\+=|-=|\*=|/=`)
 	hd.dsl.Token("ARITHMETIC", `\+|\-|\*|\/`)
 	hd.dsl.Token("ID", `[a-zA-Z_][a-zA-Z0-9_]*`)
 	hd.dsl.Token("(", `\(`)
 	hd.dsl.Token(")", `\)`)
+	hd.dsl.Token(",", `,`)
+	hd.dsl.Token("%", `%`)
+	hd.dsl.Token("??", `\?\?`)
+	hd.dsl.Token("?", `\?`)
+	hd.dsl.Token(":", `:`)
 	hd.dsl.Token("[", `\[`)
 	hd.dsl.Token("]", `\]`)
 
@@ -198,42 +373,51 @@ func (hd *HTTPDSLv3) setupGrammar() {
 	// - action: function name to execute when matched
 	// Rules are tried in order until one matches.
 
-	// Main program rule - accepts single statement OR multiple statements
-	hd.dsl.Rule("program", []string{"statement"}, "executeSingleStatement")
+	// Main program rule - accepts multiple statements OR a single statement.
+	// The longer form comes first, otherwise a single statement would match a
+	// prefix of the input and the rest would be reported as unexpected.
 	hd.dsl.Rule("program", []string{"statements"}, "executeProgram")
+	hd.dsl.Rule("program", []string{"statement"}, "executeSingleStatement")
 
 	// Statements (supports multiple statements)
 	hd.dsl.Rule("statements", []string{"statement", "statements"}, "multipleStatements")
 	hd.dsl.Rule("statements", []string{"statement"}, "singleStatement")
 
 	// DEVELOPER GUIDE: Actions
-	// Actions are functions that execute when rules match.
-	// They receive matched tokens/values as args[].
-	// Return value becomes the rule's result.
+	// Parsing never executes anything. Actions registered with hd.action are
+	// recorded as deferred nodes while parsing, and the evaluator runs them
+	// afterwards with their arguments already evaluated (see evaluate).
+	// hd.lazyAction receives the arguments unevaluated, so conditionals, loops,
+	// and and/or decide what runs and how often.
+	// hd.listAction is only used for pure list building that can run while parsing.
 	// To add new functionality:
 	// 1. Define tokens (if needed)
 	// 2. Create rules that use those tokens
-	// 3. Write actions to process the matched data
+	// 3. Register an action to process the matched data
 
-	hd.dsl.Action("executeSingleStatement", func(args []interface{}) (interface{}, error) {
+	hd.action("executeSingleStatement", func(args []interface{}) (interface{}, error) {
 		return args[0], nil
 	})
 
-	hd.dsl.Action("singleStatement", func(args []interface{}) (interface{}, error) {
+	hd.listAction("singleStatement", func(args []interface{}) (interface{}, error) {
 		return []interface{}{args[0]}, nil
 	})
 
-	hd.dsl.Action("multipleStatements", func(args []interface{}) (interface{}, error) {
+	hd.listAction("multipleStatements", func(args []interface{}) (interface{}, error) {
 		stmt := args[0]
 		stmts := args[1].([]interface{})
 		return append([]interface{}{stmt}, stmts...), nil
 	})
 
-	hd.dsl.Action("executeProgram", func(args []interface{}) (interface{}, error) {
+	hd.lazyAction("executeProgram", func(args []interface{}) (interface{}, error) {
 		statements := args[0].([]interface{})
 		var lastResult interface{}
 		for _, stmt := range statements {
-			lastResult = stmt
+			result, err := hd.evaluate(stmt)
+			if err != nil {
+				return nil, err
+			}
+			lastResult = result
 			// Handle control flow
 			if hd.context["break"] == true {
 				break
@@ -256,7 +440,7 @@ func (hd *HTTPDSLv3) setupGrammar() {
 	hd.dsl.Rule("statement", []string{"utility"}, "passthrough")
 	hd.dsl.Rule("statement", []string{"control_flow"}, "passthrough")
 
-	hd.dsl.Action("passthrough", func(args []interface{}) (interface{}, error) {
+	hd.action("passthrough", func(args []interface{}) (interface{}, error) {
 		if len(args) > 0 {
 			return args[0], nil
 		}
@@ -272,12 +456,12 @@ func (hd *HTTPDSLv3) setupGrammar() {
 	hd.dsl.Rule("control_flow", []string{"break"}, "breakCmd")
 	hd.dsl.Rule("control_flow", []string{"continue"}, "continueCmd")
 
-	hd.dsl.Action("breakCmd", func(args []interface{}) (interface{}, error) {
+	hd.action("breakCmd", func(args []interface{}) (interface{}, error) {
 		hd.context["break"] = true // Set flag for loop to check
 		return "break", nil
 	})
 
-	hd.dsl.Action("continueCmd", func(args []interface{}) (interface{}, error) {
+	hd.action("continueCmd", func(args []interface{}) (interface{}, error) {
 		hd.context["continue"] = true // Set flag to skip to next iteration
 		return "continue", nil
 	})
@@ -290,17 +474,46 @@ func (hd *HTTPDSLv3) setupGrammar() {
 	// HTTP Requests - Order matters! Longer patterns first
 	hd.dsl.Rule("http_request", []string{"http_method", "url_value", "option_list"}, "httpWithOptions")
 	hd.dsl.Rule("http_request", []string{"http_method", "url_value"}, "httpSimple")
+	hd.dsl.Rule("http_request", []string{"replay", "NUMBER"}, "replayCmd")
+
+	// GraphQL requests POST the query and its variables as JSON. The query
+	// is sent as written: its $names are GraphQL variables, not script ones.
+	hd.dsl.Rule("http_request", []string{"graphql", "url_value", "graphql_query", "graphql_variables", "option_list"}, "graphqlRequest")
+	hd.dsl.Rule("http_request", []string{"graphql", "url_value", "graphql_query", "graphql_variables"}, "graphqlRequest")
+	hd.dsl.Rule("http_request", []string{"graphql", "url_value", "graphql_query", "option_list"}, "graphqlRequest")
+	hd.dsl.Rule("http_request", []string{"graphql", "url_value", "graphql_query"}, "graphqlRequest")
+	// submit form "#login" with "username" "$u" fills a form of the last HTML
+	// response, keeping its hidden fields, and sends it to its action
+	hd.dsl.Rule("http_request", []string{"submit", "form", "STRING", "with", "form_values", "option_list"}, "submitForm")
+	hd.dsl.Rule("http_request", []string{"submit", "form", "STRING", "with", "form_values"}, "submitForm")
+	hd.dsl.Rule("http_request", []string{"submit", "form", "STRING", "option_list"}, "submitForm")
+	hd.dsl.Rule("http_request", []string{"submit", "form", "STRING"}, "submitForm")
+	// invoke "create_user" with $name "Bob" sends a request defined with a
+	// request "create_user" ... end block
+	hd.dsl.Rule("http_request", []string{"invoke", "STRING", "with", "template_overrides"}, "invokeTemplate")
+	hd.dsl.Rule("http_request", []string{"invoke", "STRING"}, "invokeTemplate")
+	hd.dsl.Rule("template_overrides", []string{"template_override"}, "firstField")
+	hd.dsl.Rule("template_overrides", []string{"template_overrides", "template_override"}, "appendField")
+	hd.dsl.Rule("template_override", []string{"VARIABLE", "value"}, "templateOverride")
+	hd.dsl.Rule("form_values", []string{"form_value"}, "firstField")
+	hd.dsl.Rule("form_values", []string{"form_values", "form_value"}, "appendField")
+	hd.dsl.Rule("form_value", []string{"STRING", "STRING"}, "formValue")
+	hd.dsl.Rule("form_value", []string{"STRING", "VARIABLE"}, "formValue")
+	hd.dsl.Rule("graphql_query", []string{"query", "file", "STRING"}, "graphqlQueryFile")
+	hd.dsl.Rule("graphql_query", []string{"query", "STRING"}, "graphqlQuery")
+	hd.dsl.Rule("graphql_variables", []string{"variables", "JSON_INLINE"}, "graphqlVariablesInline")
+	hd.dsl.Rule("graphql_variables", []string{"variables", "VARIABLE"}, "graphqlVariablesVar")
 
 	// Option list - using LEFT recursion (now supported by improved parser)
 	// Left recursion is more efficient for building lists
 	hd.dsl.Rule("option_list", []string{"option"}, "firstOption")
 	hd.dsl.Rule("option_list", []string{"option_list", "option"}, "appendOption")
 
-	hd.dsl.Action("firstOption", func(args []interface{}) (interface{}, error) {
+	hd.listAction("firstOption", func(args []interface{}) (interface{}, error) {
 		return []interface{}{args[0]}, nil
 	})
 
-	hd.dsl.Action("appendOption", func(args []interface{}) (interface{}, error) {
+	hd.listAction("appendOption", func(args []interface{}) (interface{}, error) {
 		// With left recursion: list comes first, then the new option
 		list := args[0].([]interface{})
 		option := args[1]
@@ -310,11 +523,52 @@ func (hd *HTTPDSLv3) setupGrammar() {
 	// Individual options
 	hd.dsl.Rule("option", []string{"header", "STRING", "STRING"}, "headerOption")
 	hd.dsl.Rule("option", []string{"body", "STRING"}, "bodyOption")
+	hd.dsl.Rule("option", []string{"body", "from", "response"}, "bodyFromOption")
+	hd.dsl.Rule("option", []string{"body", "from", "jsonpath", "STRING"}, "bodyFromOption")
+	hd.dsl.Rule("option", []string{"json", "from", "response"}, "bodyFromOption")
+	hd.dsl.Rule("option", []string{"json", "from", "jsonpath", "STRING"}, "bodyFromOption")
+	hd.dsl.Rule("option", []string{"body", "file", "STRING", "binary"}, "bodyFileOption")
+	hd.dsl.Rule("option", []string{"body", "file", "STRING"}, "bodyFileOption")
+	hd.dsl.Rule("option", []string{"json", "file", "STRING"}, "bodyFileOption")
+	hd.dsl.Rule("option", []string{"body", "template", "STRING"}, "bodyTemplateOption")
+	hd.dsl.Rule("option", []string{"json", "template", "STRING"}, "bodyTemplateOption")
 	hd.dsl.Rule("option", []string{"json", "STRING"}, "jsonStringOption")
 	hd.dsl.Rule("option", []string{"json", "JSON_INLINE"}, "jsonInlineOption")
 	hd.dsl.Rule("option", []string{"auth", "basic", "STRING", "STRING"}, "authBasicOption")
 	hd.dsl.Rule("option", []string{"auth", "bearer", "STRING"}, "authBearerOption")
+
+	// auth negotiate sends a Kerberos ticket of the credentials cache left
+	// by kinit; keytab and principal log in without one, as on CI
+	hd.dsl.Rule("option", []string{"auth", "negotiate", "keytab", "STRING", "principal", "STRING"}, "authNegotiateOption")
+	hd.dsl.Rule("option", []string{"auth", "negotiate"}, "authNegotiateOption")
+	hd.dsl.Rule("option", []string{"save", "to", "STRING"}, "saveToOption")
+	hd.dsl.Rule("option", []string{"compress", "ID"}, "compressOption")
+	hd.dsl.Rule("option", []string{"timeout", "timeout_phases"}, "timeoutPhasesOption")
 	hd.dsl.Rule("option", []string{"timeout", "NUMBER", "time_unit"}, "timeoutOption")
+	hd.dsl.Rule("option", []string{"CONNECT", "timeout", "NUMBER", "time_unit"}, "timeoutOption")
+	hd.dsl.Rule("option", []string{"read", "timeout", "NUMBER", "time_unit"}, "timeoutOption")
+	hd.dsl.Rule("option", []string{"tls", "timeout", "NUMBER", "time_unit"}, "timeoutOption")
+
+	// retry 3 times backoff 500 ms max backoff 10 s jitter 20% sends the
+	// request again on network errors and 429, 502, 503 and 504
+	hd.dsl.Rule("option", []string{"retry", "NUMBER", "times"}, "retryOption")
+	hd.dsl.Rule("option", []string{"backoff", "NUMBER", "time_unit"}, "backoffOption")
+	hd.dsl.Rule("option", []string{"max", "backoff", "NUMBER", "time_unit"}, "backoffOption")
+	hd.dsl.Rule("option", []string{"jitter", "NUMBER", "%"}, "jitterOption")
+
+	// throttle download 256 kbps limits the bandwidth of this request
+	hd.dsl.Rule("option", []string{"throttle", "ID", "NUMBER", "ID"}, "throttleOption")
+
+	// stream reads the body in the background as it arrives, for assert
+	// stream receives and extract stream lines
+	hd.dsl.Rule("option", []string{"stream"}, "streamOption")
+
+	// timeout connect 2 s read 10 s bounds each phase of the request
+	hd.dsl.Rule("timeout_phases", []string{"timeout_phase"}, "firstOption")
+	hd.dsl.Rule("timeout_phases", []string{"timeout_phases", "timeout_phase"}, "appendOption")
+	hd.dsl.Rule("timeout_phase", []string{"CONNECT", "NUMBER", "time_unit"}, "timeoutOption")
+	hd.dsl.Rule("timeout_phase", []string{"read", "NUMBER", "time_unit"}, "timeoutOption")
+	hd.dsl.Rule("timeout_phase", []string{"tls", "NUMBER", "time_unit"}, "timeoutOption")
 
 	// HTTP methods
 	hd.dsl.Rule("http_method", []string{"GET"}, "methodType")
@@ -326,30 +580,40 @@ func (hd *HTTPDSLv3) setupGrammar() {
 	hd.dsl.Rule("http_method", []string{"OPTIONS"}, "methodType")
 	hd.dsl.Rule("http_method", []string{"CONNECT"}, "methodType")
 	hd.dsl.Rule("http_method", []string{"TRACE"}, "methodType")
+	hd.dsl.Rule("http_method", []string{"METHOD", "STRING"}, "methodCustom")
 
-	hd.dsl.Action("methodType", func(args []interface{}) (interface{}, error) {
+	hd.action("methodType", func(args []interface{}) (interface{}, error) {
 		return args[0], nil
 	})
 
+	// METHOD "PROPFIND" sends any other method, such as WebDAV verbs or PURGE
+	hd.action("methodCustom", func(args []interface{}) (interface{}, error) {
+		method := strings.ToUpper(hd.expandVariables(hd.unquoteString(args[1].(string))))
+		if !methodName.MatchString(method) {
+			return nil, fmt.Errorf("invalid HTTP method %q", method)
+		}
+		return method, nil
+	})
+
 	// URL values with proper variable expansion
 	hd.dsl.Rule("url_value", []string{"STRING"}, "urlString")
 	hd.dsl.Rule("url_value", []string{"URL"}, "urlDirect")
 	hd.dsl.Rule("url_value", []string{"VARIABLE"}, "urlVariable")
 
-	hd.dsl.Action("urlString", func(args []interface{}) (interface{}, error) {
+	hd.action("urlString", func(args []interface{}) (interface{}, error) {
 		url := hd.unquoteString(args[0].(string))
 		// Expand variables in URL
 		return hd.expandVariables(url), nil
 	})
 
-	hd.dsl.Action("urlDirect", func(args []interface{}) (interface{}, error) {
+	hd.action("urlDirect", func(args []interface{}) (interface{}, error) {
 		return hd.expandVariables(args[0].(string)), nil
 	})
 
-	hd.dsl.Action("urlVariable", func(args []interface{}) (interface{}, error) {
+	hd.action("urlVariable", func(args []interface{}) (interface{}, error) {
 		varName := strings.TrimPrefix(args[0].(string), "$")
-		if val, ok := hd.variables[varName]; ok {
-			return fmt.Sprintf("%v", val), nil
+		if val, ok := hd.lookupVariable(varName); ok {
+			return formatValue(val), nil
 		}
 		return "", fmt.Errorf("variable $%s not found", varName)
 	})
@@ -358,12 +622,12 @@ func (hd *HTTPDSLv3) setupGrammar() {
 	hd.dsl.Rule("time_unit", []string{"ms"}, "timeUnit")
 	hd.dsl.Rule("time_unit", []string{"s"}, "timeUnit")
 
-	hd.dsl.Action("timeUnit", func(args []interface{}) (interface{}, error) {
+	hd.action("timeUnit", func(args []interface{}) (interface{}, error) {
 		return args[0], nil
 	})
 
 	// Option actions
-	hd.dsl.Action("headerOption", func(args []interface{}) (interface{}, error) {
+	hd.action("headerOption", func(args []interface{}) (interface{}, error) {
 		return map[string]interface{}{
 			"type":  "header",
 			"key":   hd.unquoteString(args[1].(string)),
@@ -371,30 +635,138 @@ func (hd *HTTPDSLv3) setupGrammar() {
 		}, nil
 	})
 
-	hd.dsl.Action("bodyOption", func(args []interface{}) (interface{}, error) {
+	hd.action("bodyOption", func(args []interface{}) (interface{}, error) {
 		return map[string]interface{}{
 			"type":  "body",
 			"value": hd.expandVariables(hd.unquoteString(args[1].(string))),
 		}, nil
 	})
 
-	hd.dsl.Action("jsonStringOption", func(args []interface{}) (interface{}, error) {
-		jsonStr := hd.expandVariables(hd.unquoteString(args[1].(string)))
+	// body from and json from forward the last response, or part of it, as
+	// the body of the next request
+	hd.action("bodyFromOption", func(args []interface{}) (interface{}, error) {
+		optType := strings.ToLower(args[0].(string))
+		path := ""
+		if len(args) > 3 {
+			path = hd.expandVariables(hd.unquoteString(args[3].(string)))
+		}
+		value, err := hd.bodyFromResponse(path, optType == "json")
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"type":  optType,
+			"value": value,
+		}, nil
+	})
+
+	// body file and json file read the body from a file, expanding variables
+	// as inline bodies do; binary sends the bytes as they are, with a
+	// Content-Type guessed from the extension unless a header sets one
+	hd.action("bodyFileOption", func(args []interface{}) (interface{}, error) {
+		optType := strings.ToLower(args[0].(string))
+		name := hd.expandVariables(hd.unquoteString(args[2].(string)))
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s file: %w", optType, err)
+		}
+
+		if len(args) > 3 {
+			contentType := mime.TypeByExtension(filepath.Ext(name))
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+			return map[string]interface{}{
+				"type":        "binary",
+				"value":       string(data),
+				"contentType": contentType,
+			}, nil
+		}
+
+		var value string
+		if optType == "json" {
+			if value, err = hd.expandJSON(string(data)); err != nil {
+				return nil, fmt.Errorf("json file %s: %w", name, err)
+			}
+		} else {
+			value = hd.expandVariables(string(data))
+		}
+		return map[string]interface{}{
+			"type":  optType,
+			"value": value,
+		}, nil
+	})
+
+	// body template and json template render a text/template file with the
+	// variables, then expand $name and ${name} references like body file
+	hd.action("bodyTemplateOption", func(args []interface{}) (interface{}, error) {
+		optType := strings.ToLower(args[0].(string))
+		name := hd.expandVariables(hd.unquoteString(args[2].(string)))
+		rendered, err := hd.renderTemplate(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s template %s: %w", optType, name, err)
+		}
+
+		var value string
+		if optType == "json" {
+			if value, err = hd.expandJSON(rendered); err != nil {
+				return nil, fmt.Errorf("json template %s: %w", name, err)
+			}
+		} else {
+			value = hd.expandVariables(rendered)
+		}
+		return map[string]interface{}{
+			"type":  optType,
+			"value": value,
+		}, nil
+	})
+
+	// JSON bodies are templates: substituted values are escaped for the place
+	// they land in, and the final payload must be valid JSON to be sent
+	hd.action("jsonStringOption", func(args []interface{}) (interface{}, error) {
+		jsonStr, err := hd.expandJSON(hd.unquoteString(args[1].(string)))
+		if err != nil {
+			return nil, err
+		}
 		return map[string]interface{}{
 			"type":  "json",
 			"value": jsonStr,
 		}, nil
 	})
 
-	hd.dsl.Action("jsonInlineOption", func(args []interface{}) (interface{}, error) {
-		jsonStr := hd.expandVariables(args[1].(string))
+	hd.action("jsonInlineOption", func(args []interface{}) (interface{}, error) {
+		jsonStr, err := hd.expandJSON(args[1].(string))
+		if err != nil {
+			return nil, err
+		}
 		return map[string]interface{}{
 			"type":  "json",
 			"value": jsonStr,
 		}, nil
 	})
 
-	hd.dsl.Action("authBasicOption", func(args []interface{}) (interface{}, error) {
+	// compress gzip sends this request's body gzip, deflate or br encoded;
+	// compress off sends it as is despite compress request body
+	hd.action("compressOption", func(args []interface{}) (interface{}, error) {
+		encoding, err := parseContentEncoding(args[1].(string))
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"type":  "compress",
+			"value": encoding,
+		}, nil
+	})
+
+	// save to streams the response body to a file instead of keeping it
+	hd.action("saveToOption", func(args []interface{}) (interface{}, error) {
+		return map[string]interface{}{
+			"type":  "save_to",
+			"value": hd.expandVariables(hd.unquoteString(args[2].(string))),
+		}, nil
+	})
+
+	hd.action("authBasicOption", func(args []interface{}) (interface{}, error) {
 		return map[string]interface{}{
 			"type":     "auth",
 			"authType": "basic",
@@ -403,7 +775,21 @@ func (hd *HTTPDSLv3) setupGrammar() {
 		}, nil
 	})
 
-	hd.dsl.Action("authBearerOption", func(args []interface{}) (interface{}, error) {
+	hd.action("authNegotiateOption", func(args []interface{}) (interface{}, error) {
+		option := map[string]interface{}{
+			"type":      "auth",
+			"authType":  "negotiate",
+			"keytab":    "",
+			"principal": "",
+		}
+		if len(args) > 2 {
+			option["keytab"] = hd.expandVariables(hd.unquoteString(args[3].(string)))
+			option["principal"] = hd.expandVariables(hd.unquoteString(args[5].(string)))
+		}
+		return option, nil
+	})
+
+	hd.action("authBearerOption", func(args []interface{}) (interface{}, error) {
 		return map[string]interface{}{
 			"type":     "auth",
 			"authType": "bearer",
@@ -411,79 +797,231 @@ func (hd *HTTPDSLv3) setupGrammar() {
 		}, nil
 	})
 
-	hd.dsl.Action("timeoutOption", func(args []interface{}) (interface{}, error) {
-		value, _ := strconv.ParseFloat(args[1].(string), 64)
-		unit := args[2].(string)
+	// timeout, connect timeout and read timeout - all scoped to one request
+	hd.action("timeoutOption", func(args []interface{}) (interface{}, error) {
+		value, _ := strconv.ParseFloat(args[len(args)-2].(string), 64)
+		unit := args[len(args)-1].(string)
 		if unit == "s" {
 			value = value * 1000
 		}
+		optType := "timeout"
+		if phase := strings.ToLower(args[0].(string)); phase != "timeout" {
+			optType = phase + "_timeout"
+		}
+		return map[string]interface{}{
+			"type":  optType,
+			"value": int(value),
+		}, nil
+	})
+
+	hd.action("retryOption", func(args []interface{}) (interface{}, error) {
+		retries, err := strconv.Atoi(args[1].(string))
+		if err != nil {
+			return nil, fmt.Errorf("retry expects a whole number of times, got %s", args[1])
+		}
+		return map[string]interface{}{
+			"type":  "retry",
+			"value": retries,
+		}, nil
+	})
+
+	hd.action("backoffOption", func(args []interface{}) (interface{}, error) {
+		value, _ := strconv.ParseFloat(args[len(args)-2].(string), 64)
+		if args[len(args)-1].(string) == "s" {
+			value = value * 1000
+		}
+		optType := "backoff"
+		if len(args) == 4 {
+			optType = "max_backoff"
+		}
 		return map[string]interface{}{
-			"type":  "timeout",
+			"type":  optType,
 			"value": int(value),
 		}, nil
 	})
 
-	hd.dsl.Action("httpSimple", func(args []interface{}) (interface{}, error) {
+	hd.action("jitterOption", func(args []interface{}) (interface{}, error) {
+		percent, _ := strconv.ParseFloat(args[1].(string), 64)
+		if percent > 100 {
+			return nil, fmt.Errorf("jitter must be at most 100%%, got %s%%", args[1])
+		}
+		return map[string]interface{}{
+			"type":  "jitter",
+			"value": percent / 100,
+		}, nil
+	})
+
+	hd.action("throttleOption", func(args []interface{}) (interface{}, error) {
+		direction, rate, err := throttleArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"type":  "throttle_" + direction,
+			"value": rate,
+		}, nil
+	})
+
+	hd.action("streamOption", func(args []interface{}) (interface{}, error) {
+		return map[string]interface{}{
+			"type":  "stream",
+			"value": true,
+		}, nil
+	})
+
+	hd.action("timeoutPhasesOption", func(args []interface{}) (interface{}, error) {
+		return map[string]interface{}{
+			"type":  "timeouts",
+			"value": args[1],
+		}, nil
+	})
+
+	hd.action("httpSimple", func(args []interface{}) (interface{}, error) {
 		method := args[0].(string)
 		url := args[1].(string)
-		return hd.engine.Request(method, url, nil)
+		return hd.sendRequest(method, url, nil)
 	})
 
-	hd.dsl.Action("httpWithOptions", func(args []interface{}) (interface{}, error) {
+	// replay re-sends a request from the history, numbered as in history
+	hd.action("replayCmd", func(args []interface{}) (interface{}, error) {
+		n, _ := strconv.Atoi(args[1].(string))
+		return hd.replay(n)
+	})
+
+	hd.action("httpWithOptions", func(args []interface{}) (interface{}, error) {
 		method := args[0].(string)
 		url := args[1].(string)
+		return hd.sendRequest(method, url, requestOptions(args[2].([]interface{})))
+	})
 
-		// Process options list
-		optionsList := args[2].([]interface{})
-		requestOptions := make(map[string]interface{})
-		headers := make(map[string]string)
-
-		for _, opt := range optionsList {
-			option := opt.(map[string]interface{})
-			optType := option["type"].(string)
-
-			switch optType {
-			case "header":
-				headers[option["key"].(string)] = option["value"].(string)
-			case "body":
-				requestOptions["body"] = option["value"]
-			case "json":
-				requestOptions["json"] = option["value"]
-			case "auth":
-				authType := option["authType"].(string)
-				if authType == "basic" {
-					requestOptions["auth"] = map[string]string{
-						"type": "basic",
-						"user": option["user"].(string),
-						"pass": option["pass"].(string),
-					}
-				} else if authType == "bearer" {
-					requestOptions["auth"] = map[string]string{
-						"type":  "bearer",
-						"token": option["token"].(string),
+	hd.action("graphqlRequest", func(args []interface{}) (interface{}, error) {
+		url := args[1].(string)
+		query := args[2].(string)
+		var variables map[string]interface{}
+		options := make(map[string]interface{})
+		for _, arg := range args[3:] {
+			switch value := arg.(type) {
+			case map[string]interface{}:
+				variables = value
+			case []interface{}:
+				options = requestOptions(value)
+			}
+		}
+		payload, err := graphqlPayload(query, variables)
+		if err != nil {
+			return nil, err
+		}
+		delete(options, "body")
+		options["json"] = payload
+		return hd.sendRequest("POST", url, options)
+	})
+
+	hd.action("graphqlQuery", func(args []interface{}) (interface{}, error) {
+		return hd.unquoteString(args[1].(string)), nil
+	})
+
+	hd.action("submitForm", func(args []interface{}) (interface{}, error) {
+		selector := hd.expandVariables(hd.unquoteString(args[2].(string)))
+		var values [][2]string
+		var options map[string]interface{}
+		for _, arg := range args[3:] {
+			list, ok := arg.([]interface{})
+			if !ok {
+				continue
+			}
+			if len(list) > 0 {
+				if _, isValue := list[0].([2]string); isValue {
+					for _, value := range list {
+						values = append(values, value.([2]string))
 					}
+					continue
 				}
-			case "timeout":
-				requestOptions["timeout"] = option["value"]
+			}
+			options = requestOptions(list)
+		}
+		return hd.submitForm(selector, values, options)
+	})
+
+	hd.action("formValue", func(args []interface{}) (interface{}, error) {
+		return [2]string{
+			hd.expandVariables(hd.unquoteString(args[0].(string))),
+			hd.expandVariables(hd.unquoteString(args[1].(string))),
+		}, nil
+	})
+
+	// invoke "name" with $var value ... sends a request template with the
+	// variables set for it
+	hd.action("invokeTemplate", func(args []interface{}) (interface{}, error) {
+		name := hd.expandVariables(hd.unquoteString(args[1].(string)))
+		var overrides []templateOverride
+		if len(args) > 2 {
+			for _, override := range args[3].([]interface{}) {
+				overrides = append(overrides, override.(templateOverride))
 			}
 		}
+		return hd.invokeTemplate(name, overrides)
+	})
+
+	hd.action("templateOverride", func(args []interface{}) (interface{}, error) {
+		return templateOverride{name: strings.TrimPrefix(args[0].(string), "$"), value: args[1]}, nil
+	})
+
+	hd.action("graphqlQueryFile", func(args []interface{}) (interface{}, error) {
+		return readGraphQLQuery(hd.expandVariables(hd.unquoteString(args[2].(string))))
+	})
 
-		if len(headers) > 0 {
-			requestOptions["header"] = headers
+	hd.action("graphqlVariablesInline", func(args []interface{}) (interface{}, error) {
+		text, err := hd.expandJSON(args[1].(string))
+		if err != nil {
+			return nil, err
 		}
+		return graphqlVariables(text)
+	})
 
-		return hd.engine.Request(method, url, requestOptions)
+	hd.action("graphqlVariablesVar", func(args []interface{}) (interface{}, error) {
+		varName := strings.TrimPrefix(args[1].(string), "$")
+		value, ok := hd.lookupVariable(varName)
+		if !ok {
+			return nil, fmt.Errorf("variable $%s not found", varName)
+		}
+		return graphqlVariables(value)
 	})
 
 	// Variable operations
 	hd.dsl.Rule("variable_op", []string{"set_var"}, "passthrough")
 	hd.dsl.Rule("variable_op", []string{"extract_var"}, "passthrough")
-
-	// Set variable with expression support
+	hd.dsl.Rule("variable_op", []string{"load", "json", "STRING", "as", "VARIABLE"}, "loadVariable")
+	hd.dsl.Rule("variable_op", []string{"load", "yaml", "STRING", "as", "VARIABLE"}, "loadVariable")
+	hd.dsl.Rule("variable_op", []string{"dns", "lookup", "STRING", "ID", "as", "VARIABLE"}, "dnsLookupVariable")
+	hd.dsl.Rule("variable_op", []string{"dns", "lookup", "STRING", "as", "VARIABLE"}, "dnsLookupVariable")
+
+	// Set variable with expression support. The conditional form
+	// set $x <condition> ? <a> : <b> evaluates only the chosen expression.
+	hd.dsl.Rule("set_var", []string{"set", "VARIABLE", "condition", "?", "expression", ":", "expression"}, "setConditional")
+	hd.dsl.Rule("set_var", []string{"var", "VARIABLE", "condition", "?", "expression", ":", "expression"}, "setConditional")
 	hd.dsl.Rule("set_var", []string{"set", "VARIABLE", "expression"}, "setVariable")
 	hd.dsl.Rule("set_var", []string{"var", "VARIABLE", "expression"}, "setVariable")
 
+	// set $resp GET "..." sends the request and keeps its response in $resp,
+	// which later requests do not replace
+	hd.dsl.Rule("set_var", []string{"set", "VARIABLE", "http_request"}, "setResponse")
+	hd.dsl.Rule("set_var", []string{"var", "VARIABLE", "http_request"}, "setResponse")
+
+	// default $x <expression> sets $x only when it is undefined, so values
+	// from --var win; unset $x removes it
+	hd.dsl.Rule("set_var", []string{"default", "VARIABLE", "expression"}, "defaultVariable")
+	hd.dsl.Rule("set_var", []string{"unset", "VARIABLE"}, "unsetVariable")
+
+	// increment $n, decrement $n by 2 and $n += 2 (or -=, *=, /=) update a
+	// number in place; an undefined variable counts as 0
+	hd.dsl.Rule("set_var", []string{"increment", "VARIABLE", "by", "expression"}, "updateVariable")
+	hd.dsl.Rule("set_var", []string{"increment", "VARIABLE"}, "updateVariable")
+	hd.dsl.Rule("set_var", []string{"decrement", "VARIABLE", "by", "expression"}, "updateVariable")
+	hd.dsl.Rule("set_var", []string{"decrement", "VARIABLE"}, "updateVariable")
+	hd.dsl.Rule("set_var", []string{"VARIABLE", "ASSIGN_OP", "expression"}, "updateVariable")
+
 	// Expressions (supports arithmetic and string concatenation)
+	hd.dsl.Rule("expression", []string{"VARIABLE", "??", "expression"}, "coalesce")
 	hd.dsl.Rule("expression", []string{"array_access"}, "passthrough")
 	hd.dsl.Rule("expression", []string{"function_call"}, "passthrough")
 	hd.dsl.Rule("expression", []string{"expression", "ARITHMETIC", "term"}, "arithmeticOp")
@@ -491,25 +1029,17 @@ func (hd *HTTPDSLv3) setupGrammar() {
 
 	hd.dsl.Rule("term", []string{"value"}, "passthrough")
 
-	hd.dsl.Action("arithmeticOp", func(args []interface{}) (interface{}, error) {
-		left := hd.toNumber(args[0])
-		op := args[1].(string)
-		right := hd.toNumber(args[2])
-
-		switch op {
-		case "+":
-			return left + right, nil
-		case "-":
-			return left - right, nil
-		case "*":
-			return left * right, nil
-		case "/":
-			if right == 0 {
-				return nil, fmt.Errorf("division by zero")
-			}
-			return left / right, nil
+	// $maybe ?? "fallback" is the variable unless it is undefined, null or
+	// empty, and the fallback otherwise
+	hd.action("coalesce", func(args []interface{}) (interface{}, error) {
+		if value, ok := hd.lookupVariable(strings.TrimPrefix(args[0].(string), "$")); ok && !isMissing(value) {
+			return value, nil
 		}
-		return nil, fmt.Errorf("unknown operator: %s", op)
+		return args[2], nil
+	})
+
+	hd.action("arithmeticOp", func(args []interface{}) (interface{}, error) {
+		return hd.arithmetic(args[0], args[1].(string), args[2])
 	})
 
 	hd.dsl.Rule("value", []string{"STRING"}, "valueString")
@@ -521,10 +1051,26 @@ func (hd *HTTPDSLv3) setupGrammar() {
 	// 1. Add keyword token for function name
 	// 2. Create rule: Rule("function_call", [funcname, ...args], actionName)
 	// 3. Implement action to process the function
+	// Embedders add functions without touching the grammar via RegisterFunction.
 
 	// Function calls
 	hd.dsl.Rule("function_call", []string{"length", "VARIABLE"}, "lengthFunction")
+	hd.dsl.Rule("function_call", []string{"length", "jsonpath", "STRING"}, "lengthJSONPath")
+	hd.dsl.Rule("function_call", []string{"bytes", "VARIABLE"}, "bytesFunction")
 	hd.dsl.Rule("function_call", []string{"split", "VARIABLE", "STRING"}, "splitFunction")
+	hd.dsl.Rule("function_call", []string{"jq", "STRING"}, "jqFunction")
+
+	// Arguments of functions added with RegisterFunction: name(arg, ...)
+	hd.dsl.Rule("argument_list", []string{"argument_list", ",", "expression"}, "appendArgument")
+	hd.dsl.Rule("argument_list", []string{"expression"}, "firstArgument")
+
+	hd.listAction("firstArgument", func(args []interface{}) (interface{}, error) {
+		return []interface{}{args[0]}, nil
+	})
+
+	hd.listAction("appendArgument", func(args []interface{}) (interface{}, error) {
+		return append(args[0].([]interface{}), args[2]), nil
+	})
 
 	// DEVELOPER GUIDE: Array Indexing
 	// Arrays use bracket notation: $array[index]
@@ -535,19 +1081,18 @@ func (hd *HTTPDSLv3) setupGrammar() {
 	hd.dsl.Rule("array_access", []string{"VARIABLE", "[", "NUMBER", "]"}, "arrayAccess")
 	hd.dsl.Rule("array_access", []string{"VARIABLE", "[", "VARIABLE", "]"}, "arrayAccessVar")
 
-	hd.dsl.Action("valueString", func(args []interface{}) (interface{}, error) {
+	hd.action("valueString", func(args []interface{}) (interface{}, error) {
 		str := hd.unquoteString(args[0].(string))
 		return hd.expandVariables(str), nil
 	})
 
-	hd.dsl.Action("valueNumber", func(args []interface{}) (interface{}, error) {
-		num, _ := strconv.ParseFloat(args[0].(string), 64)
-		return num, nil
+	hd.action("valueNumber", func(args []interface{}) (interface{}, error) {
+		return parseNumber(args[0].(string))
 	})
 
-	hd.dsl.Action("valueVariable", func(args []interface{}) (interface{}, error) {
+	hd.action("valueVariable", func(args []interface{}) (interface{}, error) {
 		varName := strings.TrimPrefix(args[0].(string), "$")
-		if val, ok := hd.variables[varName]; ok {
+		if val, ok := hd.lookupVariable(varName); ok {
 			return val, nil
 		}
 		return nil, fmt.Errorf("variable $%s not found", varName)
@@ -557,29 +1102,27 @@ func (hd *HTTPDSLv3) setupGrammar() {
 	// Functions operate on variables and return computed values.
 	// They can handle different data types (arrays, strings, etc.).
 
-	hd.dsl.Action("lengthFunction", func(args []interface{}) (interface{}, error) {
+	hd.action("lengthFunction", func(args []interface{}) (interface{}, error) {
 		varName := strings.TrimPrefix(args[1].(string), "$")
-		if val, ok := hd.variables[varName]; ok {
+		if val, ok := hd.lookupVariable(varName); ok {
 			// Handle different types: arrays, strings, JSON arrays
 			switch v := val.(type) {
 			case []interface{}:
 				return len(v), nil
 			case []string:
 				return len(v), nil
+			case map[string]interface{}:
+				return len(v), nil
 			case string:
-				// Try to parse as JSON array
-				if strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]") {
-					// Count elements
-					trimmed := strings.Trim(v, "[]")
-					if strings.TrimSpace(trimmed) == "" {
-						return 0, nil
-					}
-					// Simple count of comma-separated elements
-					parts := strings.Split(trimmed, ",")
-					return len(parts), nil
+				// JSON arrays and objects kept as text count their elements
+				switch decoded, _ := decodeJSONText(v); d := decoded.(type) {
+				case []interface{}:
+					return len(d), nil
+				case map[string]interface{}:
+					return len(d), nil
 				}
-				// Return string length
-				return len(v), nil
+				// Return string length in characters
+				return utf8.RuneCountInString(v), nil
 			default:
 				return 0, nil
 			}
@@ -587,11 +1130,36 @@ func (hd *HTTPDSLv3) setupGrammar() {
 		return 0, nil
 	})
 
-	hd.dsl.Action("splitFunction", func(args []interface{}) (interface{}, error) {
+	// length jsonpath "$.items" counts the value at a path of the last
+	// response without extracting it first
+	hd.action("lengthJSONPath", func(args []interface{}) (interface{}, error) {
+		return hd.jsonPathLength(hd.unquoteString(args[2].(string)))
+	})
+
+	// bytes counts the UTF-8 bytes of a value, where length counts characters
+	hd.action("bytesFunction", func(args []interface{}) (interface{}, error) {
+		varName := strings.TrimPrefix(args[1].(string), "$")
+		if val, ok := hd.lookupVariable(varName); ok {
+			return len(formatValue(val)), nil
+		}
+		return 0, nil
+	})
+
+	// jq "$.users[].name" queries the last response with a jq-like
+	// expression, see queryJQ
+	hd.action("jqFunction", func(args []interface{}) (interface{}, error) {
+		body := hd.engine.GetLastResponse()
+		if body == "" {
+			return nil, fmt.Errorf("no response to query with jq")
+		}
+		return queryJQ(body, hd.unquoteString(args[1].(string)))
+	})
+
+	hd.action("splitFunction", func(args []interface{}) (interface{}, error) {
 		varName := strings.TrimPrefix(args[1].(string), "$")
 		delimiter := hd.unquoteString(args[2].(string))
-		
-		if val, ok := hd.variables[varName]; ok {
+
+		if val, ok := hd.lookupVariable(varName); ok {
 			// Convert value to string if needed
 			strVal := ""
 			switch v := val.(type) {
@@ -600,28 +1168,28 @@ func (hd *HTTPDSLv3) setupGrammar() {
 			default:
 				strVal = fmt.Sprintf("%v", v)
 			}
-			
+
 			// Split the string
 			parts := strings.Split(strVal, delimiter)
-			
+
 			// Convert to interface array for consistency
 			result := make([]interface{}, len(parts))
 			for i, part := range parts {
 				result[i] = part
 			}
-			
+
 			return result, nil
 		}
 		return nil, fmt.Errorf("variable $%s not found", varName)
 	})
 
-	hd.dsl.Action("arrayAccess", func(args []interface{}) (interface{}, error) {
+	hd.action("arrayAccess", func(args []interface{}) (interface{}, error) {
 		varName := strings.TrimPrefix(args[0].(string), "$")
 		// Parse index from NUMBER token (now at position 2 with brackets)
 		indexStr := args[2].(string)
 		index, _ := strconv.Atoi(indexStr)
 
-		if val, ok := hd.variables[varName]; ok {
+		if val, ok := hd.lookupVariable(varName); ok {
 			switch v := val.(type) {
 			case []interface{}:
 				if index >= 0 && index < len(v) {
@@ -648,9 +1216,9 @@ func (hd *HTTPDSLv3) setupGrammar() {
 					}
 					return nil, fmt.Errorf("array index out of bounds: %d", index)
 				}
-				// String character access
-				if index >= 0 && index < len(v) {
-					return string(v[index]), nil
+				// String character access counts characters, not bytes
+				if chars := []rune(v); index >= 0 && index < len(chars) {
+					return string(chars[index]), nil
 				}
 				return nil, fmt.Errorf("string index out of bounds: %d", index)
 			default:
@@ -660,13 +1228,13 @@ func (hd *HTTPDSLv3) setupGrammar() {
 		return nil, fmt.Errorf("variable $%s not found", varName)
 	})
 
-	hd.dsl.Action("arrayAccessVar", func(args []interface{}) (interface{}, error) {
+	hd.action("arrayAccessVar", func(args []interface{}) (interface{}, error) {
 		varName := strings.TrimPrefix(args[0].(string), "$")
 		indexVarName := strings.TrimPrefix(args[2].(string), "$")
 
 		// Get index from variable
 		var index int
-		if idxVal, ok := hd.variables[indexVarName]; ok {
+		if idxVal, ok := hd.lookupVariable(indexVarName); ok {
 			switch v := idxVal.(type) {
 			case float64:
 				index = int(v)
@@ -682,7 +1250,7 @@ func (hd *HTTPDSLv3) setupGrammar() {
 		}
 
 		// Now use the same logic as arrayAccess
-		if val, ok := hd.variables[varName]; ok {
+		if val, ok := hd.lookupVariable(varName); ok {
 			switch v := val.(type) {
 			case []interface{}:
 				if index >= 0 && index < len(v) {
@@ -709,9 +1277,9 @@ func (hd *HTTPDSLv3) setupGrammar() {
 					}
 					return nil, fmt.Errorf("array index out of bounds: %d", index)
 				}
-				// String character access
-				if index >= 0 && index < len(v) {
-					return string(v[index]), nil
+				// String character access counts characters, not bytes
+				if chars := []rune(v); index >= 0 && index < len(chars) {
+					return string(chars[index]), nil
 				}
 				return nil, fmt.Errorf("string index out of bounds: %d", index)
 			default:
@@ -721,86 +1289,267 @@ func (hd *HTTPDSLv3) setupGrammar() {
 		return nil, fmt.Errorf("variable $%s not found", varName)
 	})
 
-	hd.dsl.Action("setVariable", func(args []interface{}) (interface{}, error) {
+	hd.action("setVariable", func(args []interface{}) (interface{}, error) {
 		varName := strings.TrimPrefix(args[1].(string), "$")
 		value := args[2]
-		hd.variables[varName] = value
+		hd.setVariablePath(varName, value)
+		return fmt.Sprintf("Variable $%s set to %v", varName, value), nil
+	})
+
+	hd.action("setResponse", func(args []interface{}) (interface{}, error) {
+		varName := strings.TrimPrefix(args[1].(string), "$")
+		result, ok := args[2].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("no response to store in $%s", varName)
+		}
+		value := responseValue(result)
+		hd.setVariablePath(varName, value)
+		return value, nil
+	})
+
+	hd.lazyAction("defaultVariable", func(args []interface{}) (interface{}, error) {
+		varName := strings.TrimPrefix(args[1].(string), "$")
+		if value, ok := hd.lookupVariable(varName); ok {
+			return fmt.Sprintf("Variable $%s already set to %v", varName, value), nil
+		}
+		value, err := hd.evaluate(args[2])
+		if err != nil {
+			return nil, err
+		}
+		hd.setVariablePath(varName, value)
+		return fmt.Sprintf("Variable $%s set to %v", varName, value), nil
+	})
+
+	hd.action("unsetVariable", func(args []interface{}) (interface{}, error) {
+		varName := strings.TrimPrefix(args[1].(string), "$")
+		hd.unsetVariablePath(varName)
+		return fmt.Sprintf("Variable $%s unset", varName), nil
+	})
+
+	hd.action("updateVariable", func(args []interface{}) (interface{}, error) {
+		var name, op string
+		var delta interface{} = 1
+		switch args[0] {
+		case "increment", "decrement":
+			name = strings.TrimPrefix(args[1].(string), "$")
+			op = "+"
+			if args[0] == "decrement" {
+				op = "-"
+			}
+			if len(args) == 4 {
+				delta = args[3]
+			}
+		default:
+			name = strings.TrimPrefix(args[0].(string), "$")
+			op = strings.TrimSuffix(args[1].(string), "=")
+			delta = args[2]
+		}
+		value, err := hd.updateVariable(name, op, delta)
+		if err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("Variable $%s set to %s", name, formatValue(value)), nil
+	})
+
+	hd.lazyAction("setConditional", func(args []interface{}) (interface{}, error) {
+		condition, err := hd.evaluateCondition(args[2])
+		if err != nil {
+			return nil, err
+		}
+		chosen := args[6]
+		if condition {
+			chosen = args[4]
+		}
+		value, err := hd.evaluate(chosen)
+		if err != nil {
+			return nil, err
+		}
+
+		varName := strings.TrimPrefix(args[1].(string), "$")
+		hd.setVariablePath(varName, value)
 		return fmt.Sprintf("Variable $%s set to %v", varName, value), nil
 	})
 
 	// Print command with variable expansion
 	hd.dsl.Rule("print_cmd", []string{"print", "VARIABLE"}, "printVariable")
 	hd.dsl.Rule("print_cmd", []string{"print", "STRING"}, "printString")
+	hd.dsl.Rule("print_cmd", []string{"vars"}, "printVars")
 
-	hd.dsl.Action("printVariable", func(args []interface{}) (interface{}, error) {
+	hd.action("printVariable", func(args []interface{}) (interface{}, error) {
 		varName := strings.TrimPrefix(args[1].(string), "$")
-		if val, ok := hd.variables[varName]; ok {
-			return fmt.Sprintf("$%s = %v", varName, val), nil
+		if val, ok := hd.lookupVariable(varName); ok {
+			return fmt.Sprintf("$%s = %s", varName, formatValue(val)), nil
 		}
 		return fmt.Sprintf("Variable $%s not found", varName), nil
 	})
 
-	hd.dsl.Action("printString", func(args []interface{}) (interface{}, error) {
+	hd.action("printString", func(args []interface{}) (interface{}, error) {
 		str := hd.unquoteString(args[1].(string))
 		return hd.expandVariables(str), nil
 	})
 
-	// Extract variable
+	// vars lists every variable with its type, secrets masked; the result is
+	// the masked map, which prints as one line per variable
+	hd.action("printVars", func(args []interface{}) (interface{}, error) {
+		return hd.DumpVariables(), nil
+	})
+
+	// load json and load yaml read a fixture file into a variable
+	hd.action("loadVariable", func(args []interface{}) (interface{}, error) {
+		format := strings.ToLower(args[1].(string))
+		name := hd.expandVariables(hd.unquoteString(args[2].(string)))
+		varName := strings.TrimPrefix(args[4].(string), "$")
+		value, err := loadFixture(format, name)
+		if err != nil {
+			return nil, err
+		}
+		hd.SetVariable(varName, value)
+		return fmt.Sprintf("Loaded %s into $%s", name, varName), nil
+	})
+
+	// dns lookup "api.example.com" as $ips resolves a host, so scripts can
+	// check DNS during cutovers; A, AAAA, CNAME or TXT picks the records
+	hd.action("dnsLookupVariable", func(args []interface{}) (interface{}, error) {
+		host := hd.expandVariables(hd.unquoteString(args[2].(string)))
+		recordType := ""
+		if len(args) == 6 {
+			recordType = args[3].(string)
+		}
+		varName := strings.TrimPrefix(args[len(args)-1].(string), "$")
+		value, err := lookupDNS(hd.ctx, host, recordType)
+		if err != nil {
+			return nil, err
+		}
+		hd.SetVariable(varName, value)
+		return fmt.Sprintf("Set $%s = %s", varName, formatValue(value)), nil
+	})
+
+	// Extract variable - "all" stores every match as an array
+	hd.dsl.Rule("extract_var", []string{"extract", "extract_type", "STRING", "all", "as", "VARIABLE"}, "extractAllVariable")
+	hd.dsl.Rule("extract_var", []string{"extract", "extract_type", "STRING", "as", "VARIABLE", "default", "value"}, "extractVariable")
 	hd.dsl.Rule("extract_var", []string{"extract", "extract_type", "STRING", "as", "VARIABLE"}, "extractVariable")
+	hd.dsl.Rule("extract_var", []string{"extract", "stream", "lines", "as", "VARIABLE"}, "extractStreamLines")
+	hd.dsl.Rule("extract_var", []string{"extract", "extract_type", "as", "VARIABLE", "default", "value"}, "extractVariableNoPattern")
 	hd.dsl.Rule("extract_var", []string{"extract", "extract_type", "as", "VARIABLE"}, "extractVariableNoPattern")
 
 	hd.dsl.Rule("extract_type", []string{"jsonpath"}, "extractType")
 	hd.dsl.Rule("extract_type", []string{"xpath"}, "extractType")
 	hd.dsl.Rule("extract_type", []string{"regex"}, "extractType")
 	hd.dsl.Rule("extract_type", []string{"header"}, "extractType")
+	hd.dsl.Rule("extract_type", []string{"headers"}, "extractType")
 	hd.dsl.Rule("extract_type", []string{"status"}, "extractType")
-
-	hd.dsl.Action("extractType", func(args []interface{}) (interface{}, error) {
+	hd.dsl.Rule("extract_type", []string{"time"}, "extractType")
+	hd.dsl.Rule("extract_type", []string{"size"}, "extractType")
+	hd.dsl.Rule("extract_type", []string{"raw", "size"}, "extractRawSizeType")
+	hd.dsl.Rule("extract_type", []string{"redirects", "count"}, "extractRedirectsCountType")
+	hd.dsl.Rule("extract_type", []string{"redirects"}, "extractType")
+	hd.dsl.Rule("extract_type", []string{"body"}, "extractType")
+	hd.dsl.Rule("extract_type", []string{"csrf"}, "extractType")
+	hd.dsl.Rule("extract_type", []string{"links"}, "extractType")
+	hd.dsl.Rule("extract_type", []string{"link", "rel"}, "extractLinkType")
+
+	hd.action("extractType", func(args []interface{}) (interface{}, error) {
 		return args[0], nil
 	})
 
-	hd.dsl.Action("extractVariable", func(args []interface{}) (interface{}, error) {
+	// extract raw size as $n reads the size of the body before decoding
+	hd.action("extractRawSizeType", func(args []interface{}) (interface{}, error) {
+		return "raw_size", nil
+	})
+
+	// extract redirects count as $n reads how many redirects the last
+	// request followed
+	hd.action("extractRedirectsCountType", func(args []interface{}) (interface{}, error) {
+		return "redirects_count", nil
+	})
+
+	// extract link rel "next" as $next reads the Link header
+	hd.action("extractLinkType", func(args []interface{}) (interface{}, error) {
+		return "link", nil
+	})
+
+	hd.action("extractVariable", func(args []interface{}) (interface{}, error) {
 		extractType := args[1].(string)
 		pattern := hd.unquoteString(args[2].(string))
 		varName := strings.TrimPrefix(args[4].(string), "$")
 
+		// A default replaces missing values instead of an empty string
+		var fallback interface{} = ""
+		if len(args) > 6 {
+			fallback = args[6]
+		}
+
 		// Check if there's a response to extract from
 		if hd.engine.GetLastResponse() == "" {
-			hd.variables[varName] = ""
-			return fmt.Sprintf("Warning: No response available for extraction. Variable $%s set to empty.", varName), nil
+			hd.SetVariable(varName, fallback)
+			return fmt.Sprintf("Warning: No response available for extraction. Variable $%s set to %s.", varName, describeFallback(fallback)), nil
 		}
 
 		value := hd.engine.Extract(extractType, pattern)
-		if value == nil {
-			value = ""
+		if isMissing(value) {
+			value = fallback
 		}
-		hd.variables[varName] = value
+		hd.SetVariable(varName, value)
 
 		return fmt.Sprintf("Extracted %s using %s and stored in $%s", pattern, extractType, varName), nil
 	})
 
-	hd.dsl.Action("extractVariableNoPattern", func(args []interface{}) (interface{}, error) {
+	hd.action("extractAllVariable", func(args []interface{}) (interface{}, error) {
 		extractType := args[1].(string)
-		varName := strings.TrimPrefix(args[3].(string), "$")
+		pattern := hd.unquoteString(args[2].(string))
+		varName := strings.TrimPrefix(args[5].(string), "$")
 
 		// Check if there's a response to extract from
 		if hd.engine.GetLastResponse() == "" {
-			hd.variables[varName] = ""
-			return fmt.Sprintf("Warning: No response available for extraction. Variable $%s set to empty.", varName), nil
+			hd.SetVariable(varName, []interface{}{})
+			return fmt.Sprintf("Warning: No response available for extraction. Variable $%s set to empty array.", varName), nil
+		}
+
+		values := hd.engine.ExtractAll(extractType, pattern)
+		hd.SetVariable(varName, values)
+
+		return fmt.Sprintf("Extracted %d matches of %s using %s and stored in $%s", len(values), pattern, extractType, varName), nil
+	})
+
+	hd.action("extractVariableNoPattern", func(args []interface{}) (interface{}, error) {
+		extractType := args[1].(string)
+		varName := strings.TrimPrefix(args[3].(string), "$")
+
+		var fallback interface{} = ""
+		if len(args) > 5 {
+			fallback = args[5]
+		}
+
+		// Check if there's a response to extract from; status, size and time
+		// are known even when the body is empty or was saved to a file
+		if hd.engine.GetLastResponse() == "" && hd.engine.GetLastStatusCode() == 0 {
+			hd.SetVariable(varName, fallback)
+			return fmt.Sprintf("Warning: No response available for extraction. Variable $%s set to %s.", varName, describeFallback(fallback)), nil
 		}
 
 		value := hd.engine.Extract(extractType, "")
-		if value == nil {
-			value = ""
+		if isMissing(value) {
+			value = fallback
 		}
-		hd.variables[varName] = value
+		hd.SetVariable(varName, value)
 
 		return fmt.Sprintf("Extracted %s and stored in $%s", extractType, varName), nil
 	})
 
-	// Improved conditionals - fixed to handle single line if/then without else
-	hd.dsl.Rule("conditional", []string{"if", "condition", "then", "statement", "else", "statement"}, "ifElse")
-	hd.dsl.Rule("conditional", []string{"if", "condition", "then", "statement"}, "ifSimple")
+	// extract stream lines as $lines stores the lines received so far by the
+	// last stream request
+	hd.action("extractStreamLines", func(args []interface{}) (interface{}, error) {
+		varName := strings.TrimPrefix(args[4].(string), "$")
+		lines, err := hd.engine.StreamLines()
+		if err != nil {
+			return nil, err
+		}
+		hd.SetVariable(varName, lines)
+		return fmt.Sprintf("Extracted %d stream lines and stored in $%s", len(lines), varName), nil
+	})
+
+	// Conditionals - block forms first so a single-line if does not match a
+	// prefix of an if/endif block
 	hd.dsl.Rule("conditional", []string{"if", "condition", "then", "statements", "else", "statements", "endif"}, "ifElseBlock")
 	hd.dsl.Rule("conditional", []string{"if", "condition", "then", "statements", "endif"}, "ifBlock")
 
@@ -808,7 +1557,16 @@ func (hd *HTTPDSLv3) setupGrammar() {
 	hd.dsl.Rule("conditional", []string{"if", "condition", "then", "(", "statements", ")", "else", "(", "statements", ")"}, "ifGroupedElse")
 	hd.dsl.Rule("conditional", []string{"if", "condition", "then", "(", "statements", ")"}, "ifGrouped")
 
-	// Conditions with logical operators
+	// Single line if/then with elseif branches, with and without else
+	hd.dsl.Rule("conditional", []string{"if", "condition", "then", "statement", "elseif", "elseif_branch"}, "ifElseIf")
+	hd.dsl.Rule("conditional", []string{"if", "condition", "then", "statement", "else", "statement"}, "ifElse")
+	hd.dsl.Rule("conditional", []string{"if", "condition", "then", "statement"}, "ifSimple")
+
+	hd.dsl.Rule("elseif_branch", []string{"condition", "then", "statement", "elseif", "elseif_branch"}, "elseIfChain")
+	hd.dsl.Rule("elseif_branch", []string{"condition", "then", "statement", "else", "statement"}, "elseIfElse")
+	hd.dsl.Rule("elseif_branch", []string{"condition", "then", "statement"}, "elseIfSimple")
+
+	// Conditions with logical operators
 	hd.dsl.Rule("condition", []string{"condition", "and", "simple_condition"}, "andCondition")
 	hd.dsl.Rule("condition", []string{"condition", "or", "simple_condition"}, "orCondition")
 	hd.dsl.Rule("condition", []string{"not", "condition"}, "notCondition")
@@ -816,99 +1574,172 @@ func (hd *HTTPDSLv3) setupGrammar() {
 
 	hd.dsl.Rule("simple_condition", []string{"value", "COMPARISON", "value"}, "comparison")
 	hd.dsl.Rule("simple_condition", []string{"value", "contains", "value"}, "containsCheck")
+	// A variable that is not defined is empty and does not exist, where
+	// evaluating it as a value would fail
+	hd.dsl.Rule("simple_condition", []string{"VARIABLE", "empty"}, "variableEmptyCheck")
+	hd.dsl.Rule("simple_condition", []string{"VARIABLE", "exists"}, "variableExistsCheck")
 	hd.dsl.Rule("simple_condition", []string{"value", "empty"}, "emptyCheck")
 	hd.dsl.Rule("simple_condition", []string{"value", "exists"}, "existsCheck")
+	hd.dsl.Rule("simple_condition", []string{"not", "simple_condition"}, "notCondition")
+	hd.dsl.Rule("simple_condition", []string{"(", "condition", ")"}, "groupedCondition")
 
-	hd.dsl.Action("comparison", func(args []interface{}) (interface{}, error) {
+	hd.action("comparison", func(args []interface{}) (interface{}, error) {
 		left := args[0]
 		op := args[1].(string)
 		right := args[2]
 		return hd.engine.Compare(left, op, right), nil
 	})
 
-	hd.dsl.Action("containsCheck", func(args []interface{}) (interface{}, error) {
+	hd.action("containsCheck", func(args []interface{}) (interface{}, error) {
 		haystack := fmt.Sprintf("%v", args[0])
 		needle := fmt.Sprintf("%v", args[2])
 		return strings.Contains(haystack, needle), nil
 	})
 
-	hd.dsl.Action("emptyCheck", func(args []interface{}) (interface{}, error) {
+	hd.action("emptyCheck", func(args []interface{}) (interface{}, error) {
 		val := fmt.Sprintf("%v", args[0])
 		return val == "" || val == "0" || val == "false" || val == "<nil>", nil
 	})
 
-	hd.dsl.Action("existsCheck", func(args []interface{}) (interface{}, error) {
+	hd.action("existsCheck", func(args []interface{}) (interface{}, error) {
 		return args[0] != nil, nil
 	})
 
-	hd.dsl.Action("andCondition", func(args []interface{}) (interface{}, error) {
-		left := hd.toBool(args[0])
-		right := hd.toBool(args[2])
-		return left && right, nil
+	hd.action("variableEmptyCheck", func(args []interface{}) (interface{}, error) {
+		val, ok := hd.lookupVariable(strings.TrimPrefix(args[0].(string), "$"))
+		if !ok {
+			return true, nil
+		}
+		str := fmt.Sprintf("%v", val)
+		return str == "" || str == "0" || str == "false" || str == "<nil>", nil
+	})
+
+	hd.action("variableExistsCheck", func(args []interface{}) (interface{}, error) {
+		val, ok := hd.lookupVariable(strings.TrimPrefix(args[0].(string), "$"))
+		return ok && val != nil, nil
+	})
+
+	hd.action("groupedCondition", func(args []interface{}) (interface{}, error) {
+		return args[1], nil
+	})
+
+	// and/or short-circuit: the right side is only evaluated when needed
+	hd.lazyAction("andCondition", func(args []interface{}) (interface{}, error) {
+		left, err := hd.evaluateCondition(args[0])
+		if err != nil || !left {
+			return false, err
+		}
+		return hd.evaluateCondition(args[2])
 	})
 
-	hd.dsl.Action("orCondition", func(args []interface{}) (interface{}, error) {
-		left := hd.toBool(args[0])
-		right := hd.toBool(args[2])
-		return left || right, nil
+	hd.lazyAction("orCondition", func(args []interface{}) (interface{}, error) {
+		left, err := hd.evaluateCondition(args[0])
+		if err != nil || left {
+			return left, err
+		}
+		return hd.evaluateCondition(args[2])
 	})
 
-	hd.dsl.Action("notCondition", func(args []interface{}) (interface{}, error) {
+	hd.action("notCondition", func(args []interface{}) (interface{}, error) {
 		cond := hd.toBool(args[1])
 		return !cond, nil
 	})
 
-	hd.dsl.Action("ifSimple", func(args []interface{}) (interface{}, error) {
-		condition := hd.toBool(args[1])
+	hd.lazyAction("ifSimple", func(args []interface{}) (interface{}, error) {
+		condition, err := hd.evaluateCondition(args[1])
+		if err != nil || !condition {
+			return nil, err
+		}
+		return hd.executeStatement(args[3])
+	})
+
+	hd.lazyAction("ifElse", func(args []interface{}) (interface{}, error) {
+		condition, err := hd.evaluateCondition(args[1])
+		if err != nil {
+			return nil, err
+		}
 		if condition {
 			return hd.executeStatement(args[3])
 		}
-		return nil, nil
+		return hd.executeStatement(args[5])
 	})
 
-	hd.dsl.Action("ifElse", func(args []interface{}) (interface{}, error) {
-		// args[1] should be the condition result
-		condition := hd.toBool(args[1])
-
-		// Debug: Let's see what we're getting
-		// fmt.Printf("DEBUG ifElse: condition=%v, args[3]=%v, args[5]=%v\n", condition, args[3], args[5])
+	// elseif branches are evaluated only when the conditions before them do
+	// not hold
+	hd.lazyAction("ifElseIf", func(args []interface{}) (interface{}, error) {
+		condition, err := hd.evaluateCondition(args[1])
+		if err != nil {
+			return nil, err
+		}
+		if condition {
+			return hd.executeStatement(args[3])
+		}
+		return hd.evaluate(args[5])
+	})
 
+	hd.lazyAction("elseIfChain", func(args []interface{}) (interface{}, error) {
+		condition, err := hd.evaluateCondition(args[0])
+		if err != nil {
+			return nil, err
+		}
 		if condition {
-			// Execute the "then" statement (args[3])
-			return args[3], nil // Return the then statement result directly
-		} else {
-			// Execute the "else" statement (args[5])
-			return args[5], nil // Return the else statement result directly
+			return hd.executeStatement(args[2])
 		}
+		return hd.evaluate(args[4])
 	})
 
-	hd.dsl.Action("ifBlock", func(args []interface{}) (interface{}, error) {
-		condition := hd.toBool(args[1])
+	hd.lazyAction("elseIfElse", func(args []interface{}) (interface{}, error) {
+		condition, err := hd.evaluateCondition(args[0])
+		if err != nil {
+			return nil, err
+		}
 		if condition {
-			return hd.executeStatements(args[3])
+			return hd.executeStatement(args[2])
 		}
-		return nil, nil
+		return hd.executeStatement(args[4])
+	})
+
+	hd.lazyAction("elseIfSimple", func(args []interface{}) (interface{}, error) {
+		condition, err := hd.evaluateCondition(args[0])
+		if err != nil || !condition {
+			return nil, err
+		}
+		return hd.executeStatement(args[2])
+	})
+
+	hd.lazyAction("ifBlock", func(args []interface{}) (interface{}, error) {
+		condition, err := hd.evaluateCondition(args[1])
+		if err != nil || !condition {
+			return nil, err
+		}
+		return hd.executeStatements(args[3])
 	})
 
-	hd.dsl.Action("ifElseBlock", func(args []interface{}) (interface{}, error) {
-		condition := hd.toBool(args[1])
+	hd.lazyAction("ifElseBlock", func(args []interface{}) (interface{}, error) {
+		condition, err := hd.evaluateCondition(args[1])
+		if err != nil {
+			return nil, err
+		}
 		if condition {
 			return hd.executeStatements(args[3])
 		}
 		return hd.executeStatements(args[5])
 	})
 
-	hd.dsl.Action("ifGrouped", func(args []interface{}) (interface{}, error) {
-		condition := hd.toBool(args[1])
-		if condition {
-			// args[4] contains the statements (skipping "(" and ")")
-			return hd.executeStatements(args[4])
+	hd.lazyAction("ifGrouped", func(args []interface{}) (interface{}, error) {
+		condition, err := hd.evaluateCondition(args[1])
+		if err != nil || !condition {
+			return nil, err
 		}
-		return nil, nil
+		// args[4] contains the statements (skipping "(" and ")")
+		return hd.executeStatements(args[4])
 	})
 
-	hd.dsl.Action("ifGroupedElse", func(args []interface{}) (interface{}, error) {
-		condition := hd.toBool(args[1])
+	hd.lazyAction("ifGroupedElse", func(args []interface{}) (interface{}, error) {
+		condition, err := hd.evaluateCondition(args[1])
+		if err != nil {
+			return nil, err
+		}
 		if condition {
 			// args[4] contains the then statements
 			return hd.executeStatements(args[4])
@@ -921,22 +1752,73 @@ func (hd *HTTPDSLv3) setupGrammar() {
 	hd.dsl.Rule("loop_stmt", []string{"repeat", "NUMBER", "times", "do", "statements", "endloop"}, "repeatLoop")
 	hd.dsl.Rule("loop_stmt", []string{"while", "condition", "do", "statements", "endloop"}, "whileLoop")
 	hd.dsl.Rule("loop_stmt", []string{"foreach", "VARIABLE", "in", "VARIABLE", "do", "statements", "endloop"}, "foreachLoop")
+	hd.dsl.Rule("loop_stmt", []string{"for", "VARIABLE", "in", "value", "to", "value", "step", "value", "do", "statements", "endloop"}, "forStepLoop")
+	hd.dsl.Rule("loop_stmt", []string{"for", "VARIABLE", "in", "value", "to", "value", "do", "statements", "endloop"}, "forLoop")
+	hd.dsl.Rule("loop_stmt", []string{"repeat", "statements", "until", "condition"}, "repeatUntilLoop")
+
+	// First line of a paginate block; the block handler runs its body once
+	// for every page
+	hd.dsl.Rule("paginate_header", []string{"paginate", "http_request", "next", "jsonpath", "STRING", "paginate_options", "do"}, "paginateHeader")
+	hd.dsl.Rule("paginate_header", []string{"paginate", "http_request", "next", "jsonpath", "STRING", "do"}, "paginateHeader")
+	hd.dsl.Rule("paginate_header", []string{"paginate", "http_request", "next", "link", "paginate_options", "do"}, "paginateLinkHeader")
+	hd.dsl.Rule("paginate_header", []string{"paginate", "http_request", "next", "link", "do"}, "paginateLinkHeader")
+	hd.dsl.Rule("paginate_options", []string{"paginate_option"}, "firstOption")
+	hd.dsl.Rule("paginate_options", []string{"paginate_options", "paginate_option"}, "appendOption")
+	hd.dsl.Rule("paginate_option", []string{"param", "STRING"}, "paginateOption")
+	hd.dsl.Rule("paginate_option", []string{"items", "jsonpath", "STRING"}, "paginateOption")
+	hd.dsl.Rule("paginate_option", []string{"max", "NUMBER", "pages"}, "paginateOption")
+
+	// The request stays unevaluated until the block sends it
+	hd.lazyAction("paginateHeader", func(args []interface{}) (interface{}, error) {
+		var options []interface{}
+		if len(args) > 6 {
+			value, err := hd.evaluate(args[5])
+			if err != nil {
+				return nil, err
+			}
+			options = value.([]interface{})
+		}
+		return hd.newPaginateSpec(args[1], hd.expandVariables(hd.unquoteString(args[4].(string))), options)
+	})
 
-	hd.dsl.Action("repeatLoop", func(args []interface{}) (interface{}, error) {
+	// next link follows the rel="next" entry of the Link header
+	hd.lazyAction("paginateLinkHeader", func(args []interface{}) (interface{}, error) {
+		var options []interface{}
+		if len(args) > 5 {
+			value, err := hd.evaluate(args[4])
+			if err != nil {
+				return nil, err
+			}
+			options = value.([]interface{})
+		}
+		return hd.newPaginateSpec(args[1], "", options)
+	})
+
+	hd.action("paginateOption", func(args []interface{}) (interface{}, error) {
+		optType := strings.ToLower(args[0].(string))
+		value := args[1].(string) // max NUMBER pages
+		if optType != "max" {
+			value = hd.expandVariables(hd.unquoteString(args[len(args)-1].(string)))
+		}
+		return map[string]interface{}{
+			"type":  optType,
+			"value": value,
+		}, nil
+	})
+
+	hd.lazyAction("repeatLoop", func(args []interface{}) (interface{}, error) {
 		times, _ := strconv.Atoi(args[1].(string))
 		statements := args[4]
 
-		var results []interface{}
 		for i := 0; i < times; i++ {
-			hd.variables["_index"] = i
-			hd.variables["_iteration"] = i + 1
-
-			result, _ := hd.executeStatements(statements)
-			results = append(results, result)
+			hd.SetVariable("_index", i)
+			hd.SetVariable("_iteration", i+1)
+			hd.emitIteration("repeat", i+1)
 
-			// Check for break
-			if hd.context["break"] == true {
-				hd.context["break"] = false
+			if _, err := hd.executeStatements(statements); err != nil {
+				return nil, err
+			}
+			if hd.endIteration() {
 				break
 			}
 		}
@@ -944,191 +1826,1477 @@ func (hd *HTTPDSLv3) setupGrammar() {
 		return fmt.Sprintf("Repeated %d times", times), nil
 	})
 
-	hd.dsl.Action("whileLoop", func(args []interface{}) (interface{}, error) {
+	hd.lazyAction("whileLoop", func(args []interface{}) (interface{}, error) {
 		maxIterations := 1000 // Safety limit
 		iterations := 0
 		statements := args[3]
 
 		for iterations < maxIterations {
 			// Re-evaluate condition each time
-			condition := hd.evaluateCondition(args[1])
+			condition, err := hd.evaluateCondition(args[1])
+			if err != nil {
+				return nil, err
+			}
 			if !condition {
 				break
 			}
 
-			hd.variables["_iteration"] = iterations + 1
-			_, _ = hd.executeStatements(statements)
+			hd.SetVariable("_iteration", iterations+1)
+			hd.emitIteration("while", iterations+1)
+			if _, err := hd.executeStatements(statements); err != nil {
+				return nil, err
+			}
 			iterations++
 
-			// Check for break
-			if hd.context["break"] == true {
-				hd.context["break"] = false
+			if hd.endIteration() {
+				break
+			}
+		}
+
+		if iterations >= maxIterations {
+			return nil, fmt.Errorf("while loop exceeded maximum iterations (%d)", maxIterations)
+		}
+
+		return fmt.Sprintf("While loop executed %d times", iterations), nil
+	})
+
+	hd.lazyAction("foreachLoop", func(args []interface{}) (interface{}, error) {
+		itemVar := strings.TrimPrefix(args[1].(string), "$")
+		listVar := strings.TrimPrefix(args[3].(string), "$")
+		statements := args[5]
+
+		list, ok := hd.lookupVariable(listVar)
+		if !ok {
+			return nil, fmt.Errorf("list variable $%s not found", listVar)
+		}
+
+		// Convert to slice if possible
+		items := hd.toSlice(list)
+		if items == nil {
+			return nil, fmt.Errorf("variable $%s is not iterable", listVar)
+		}
+
+		for i, item := range items {
+			hd.SetVariable(itemVar, item)
+			hd.SetVariable("_index", i)
+			hd.emitIteration("foreach", i+1)
+			if _, err := hd.executeStatements(statements); err != nil {
+				return nil, err
+			}
+			if hd.endIteration() {
 				break
 			}
 		}
 
-		if iterations >= maxIterations {
-			return nil, fmt.Errorf("while loop exceeded maximum iterations (%d)", maxIterations)
+		return fmt.Sprintf("Foreach completed for $%s", listVar), nil
+	})
+
+	hd.lazyAction("forLoop", func(args []interface{}) (interface{}, error) {
+		return hd.forRange(args[1].(string), args[3], args[5], nil, args[7])
+	})
+
+	hd.lazyAction("forStepLoop", func(args []interface{}) (interface{}, error) {
+		return hd.forRange(args[1].(string), args[3], args[5], args[7], args[9])
+	})
+
+	hd.lazyAction("repeatUntilLoop", func(args []interface{}) (interface{}, error) {
+		statements := args[1]
+
+		for i := 0; ; i++ {
+			if i >= maxUntilIterations {
+				return nil, fmt.Errorf("repeat until loop exceeded maximum iterations (%d)", maxUntilIterations)
+			}
+			hd.SetVariable("_index", i)
+			hd.SetVariable("_iteration", i+1)
+			hd.emitIteration("repeat", i+1)
+
+			if _, err := hd.executeStatements(statements); err != nil {
+				return nil, err
+			}
+			if hd.endIteration() {
+				return fmt.Sprintf("Repeat until executed %d times", i+1), nil
+			}
+
+			done, err := hd.evaluateCondition(args[3])
+			if err != nil {
+				return nil, err
+			}
+			if done {
+				return fmt.Sprintf("Repeat until executed %d times", i+1), nil
+			}
+		}
+	})
+
+	// Assertions - fixed to work as standalone statements
+	// assert eventually retries until the assertion passes, sending the given
+	// request or the last one again before every check
+	hd.dsl.Rule("assertion", []string{"assert", "eventually", "http_request", "assertion_type", "timeout", "NUMBER", "time_unit", "interval", "NUMBER", "time_unit"}, "assertEventually")
+	hd.dsl.Rule("assertion", []string{"assert", "eventually", "http_request", "assertion_type", "timeout", "NUMBER", "time_unit"}, "assertEventually")
+	hd.dsl.Rule("assertion", []string{"assert", "eventually", "http_request", "assertion_type"}, "assertEventually")
+	hd.dsl.Rule("assertion", []string{"assert", "eventually", "assertion_type", "timeout", "NUMBER", "time_unit", "interval", "NUMBER", "time_unit"}, "assertEventually")
+	hd.dsl.Rule("assertion", []string{"assert", "eventually", "assertion_type", "timeout", "NUMBER", "time_unit"}, "assertEventually")
+	hd.dsl.Rule("assertion", []string{"assert", "eventually", "assertion_type"}, "assertEventually")
+	hd.dsl.Rule("assertion", []string{"assert", "stream", "receives", "STRING", "within", "NUMBER", "time_unit"}, "assertStreamReceives")
+	hd.dsl.Rule("assertion", []string{"assert", "assertion_type"}, "doAssertion")
+	hd.dsl.Rule("assertion", []string{"expect", "assertion_type"}, "doAssertion")
+
+	hd.dsl.Rule("assertion_type", []string{"status", "NUMBER"}, "assertStatus")
+	hd.dsl.Rule("assertion_type", []string{"time", "less", "NUMBER", "ms"}, "assertTime")
+	hd.dsl.Rule("assertion_type", []string{"ttfb", "less", "NUMBER", "ms"}, "assertTTFB")
+	hd.dsl.Rule("assertion_type", []string{"response", "contains", "STRING"}, "assertContains")
+	hd.dsl.Rule("assertion_type", []string{"header", "STRING", "matches", "STRING"}, "assertHeaderMatches")
+	hd.dsl.Rule("assertion_type", []string{"benchmark", "ID", "less", "NUMBER", "ms"}, "assertBenchmark")
+	hd.dsl.Rule("assertion_type", []string{"benchmark", "ID", "less", "NUMBER"}, "assertBenchmark")
+
+	// Structural JSON comparison - key order never matters
+	hd.dsl.Rule("assertion_type", []string{"json", "equals", "json_document", "ignoring", "field_list"}, "assertJSONEqualsIgnoring")
+	hd.dsl.Rule("assertion_type", []string{"json", "equals", "json_document"}, "assertJSONEquals")
+	hd.dsl.Rule("assertion_type", []string{"jsonpath", "STRING", "equals", "json", "json_document", "ignoring", "field_list"}, "assertJSONPathEqualsIgnoring")
+	hd.dsl.Rule("assertion_type", []string{"jsonpath", "STRING", "equals", "json", "json_document"}, "assertJSONPathEquals")
+	hd.dsl.Rule("assertion_type", []string{"jsonpath", "STRING", "equals", "value"}, "assertJSONPathValue")
+
+	// Structure checks - whether a jsonpath matches and how many items it has
+	hd.dsl.Rule("assertion_type", []string{"jsonpath", "STRING", "exists"}, "assertJSONPathExists")
+	hd.dsl.Rule("assertion_type", []string{"jsonpath", "STRING", "not", "exists"}, "assertJSONPathNotExists")
+	hd.dsl.Rule("assertion_type", []string{"jsonpath", "STRING", "count", "COMPARISON", "NUMBER"}, "assertJSONPathCountCompare")
+	hd.dsl.Rule("assertion_type", []string{"jsonpath", "STRING", "count", "NUMBER"}, "assertJSONPathCount")
+	hd.dsl.Rule("assertion_type", []string{"length", "jsonpath", "STRING", "COMPARISON", "NUMBER"}, "assertJSONPathLength")
+
+	// Whether the last GET was answered by the response cache of cache on
+	hd.dsl.Rule("assertion_type", []string{"cache", "ID"}, "assertCache")
+
+	// Whether the last request reused a kept-alive connection or opened one
+	hd.dsl.Rule("assertion_type", []string{"connection", "ID"}, "assertConnection")
+
+	hd.dsl.Rule("json_document", []string{"JSON_INLINE"}, "jsonDocument")
+	hd.dsl.Rule("json_document", []string{"STRING"}, "jsonDocumentString")
+
+	// Field list - left recursive like option_list
+	hd.dsl.Rule("field_list", []string{"STRING"}, "firstField")
+	hd.dsl.Rule("field_list", []string{"field_list", "STRING"}, "appendField")
+
+	hd.action("assertStatus", func(args []interface{}) (interface{}, error) {
+		expectedCode, _ := strconv.Atoi(args[1].(string))
+		actualCode := hd.engine.GetLastStatusCode()
+		if actualCode == expectedCode {
+			return fmt.Sprintf("✓ Status code is %d", expectedCode), nil
+		}
+		return nil, fmt.Errorf("assertion failed: expected status %d, got %d", expectedCode, actualCode)
+	})
+
+	hd.action("assertTime", func(args []interface{}) (interface{}, error) {
+		maxTime, _ := strconv.ParseFloat(args[2].(string), 64)
+		actualTime := hd.engine.GetLastResponseTime()
+		if actualTime < maxTime {
+			return fmt.Sprintf("✓ Response time %.2fms < %.2fms", actualTime, maxTime), nil
+		}
+		return nil, fmt.Errorf("assertion failed: response time %.2fms exceeds %.2fms", actualTime, maxTime)
+	})
+
+	hd.action("assertTTFB", func(args []interface{}) (interface{}, error) {
+		maxTime, _ := strconv.ParseFloat(args[2].(string), 64)
+		ttfb := float64(hd.engine.GetLastTiming().TTFB.Microseconds()) / 1000
+		if ttfb < maxTime {
+			return fmt.Sprintf("✓ Time to first byte %.2fms < %.2fms", ttfb, maxTime), nil
+		}
+		return nil, fmt.Errorf("assertion failed: time to first byte %.2fms exceeds %.2fms", ttfb, maxTime)
+	})
+
+	// assert benchmark checks a statistic of the last benchmark: a latency
+	// in ms, or error_rate as a percentage
+	hd.action("assertBenchmark", func(args []interface{}) (interface{}, error) {
+		if hd.lastBenchmark == nil {
+			return nil, fmt.Errorf("assertion failed: no benchmark has run")
+		}
+		name := args[1].(string)
+		limit, _ := strconv.ParseFloat(args[3].(string), 64)
+		value, ok := hd.lastBenchmark.stat(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown benchmark statistic %s", name)
+		}
+		unit := "ms"
+		if name == "error_rate" {
+			unit = "%"
+		}
+		if value < limit {
+			return fmt.Sprintf("✓ Benchmark %s %.2f%s < %.2f%s", name, value, unit, limit, unit), nil
+		}
+		return nil, fmt.Errorf("assertion failed: benchmark %s %.2f%s exceeds %.2f%s", name, value, unit, limit, unit)
+	})
+
+	hd.action("assertContains", func(args []interface{}) (interface{}, error) {
+		expected := hd.expandVariables(hd.unquoteString(args[2].(string)))
+		response := hd.engine.GetLastResponse()
+		if strings.Contains(response, expected) {
+			return fmt.Sprintf("✓ Response contains '%s'", expected), nil
+		}
+		return nil, fmt.Errorf("assertion failed: response does not contain '%s'", expected)
+	})
+
+	// assert header "Location" matches "/orders/[0-9]+" passes when a value
+	// of the header has a match of the regex
+	hd.action("assertHeaderMatches", func(args []interface{}) (interface{}, error) {
+		name := hd.expandVariables(hd.unquoteString(args[1].(string)))
+		pattern := hd.expandVariables(hd.unquoteString(args[3].(string)))
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", pattern, err)
+		}
+		values := hd.engine.ExtractAll("header", name)
+		if len(values) == 0 {
+			return nil, fmt.Errorf("assertion failed: header %s is missing", name)
+		}
+		for _, value := range values {
+			if hd.engine.Matches(value.(string), pattern) {
+				return fmt.Sprintf("✓ Header %s '%s' matches '%s'", name, value, pattern), nil
+			}
+		}
+		return nil, fmt.Errorf("assertion failed: header %s %s does not match '%s'", name, formatValue(values), pattern)
+	})
+
+	hd.action("jsonDocument", func(args []interface{}) (interface{}, error) {
+		return hd.expandJSON(args[0].(string))
+	})
+
+	// A quoted document that is no JSON object or array, like "Ada", is the
+	// JSON string it holds, to compare string leaves with
+	hd.action("jsonDocumentString", func(args []interface{}) (interface{}, error) {
+		text := hd.unquoteString(args[0].(string))
+		document, err := hd.expandJSON(text)
+		if err != nil && !strings.HasPrefix(strings.TrimSpace(text), "{") && !strings.HasPrefix(strings.TrimSpace(text), "[") {
+			encoded, _ := json.Marshal(hd.expandVariables(text))
+			return string(encoded), nil
+		}
+		return document, err
+	})
+
+	hd.listAction("firstField", func(args []interface{}) (interface{}, error) {
+		return []interface{}{args[0]}, nil
+	})
+
+	hd.listAction("appendField", func(args []interface{}) (interface{}, error) {
+		return append(args[0].([]interface{}), args[1]), nil
+	})
+
+	hd.action("assertJSONEquals", func(args []interface{}) (interface{}, error) {
+		return hd.assertJSON("response", args[2].(string), nil)
+	})
+
+	hd.action("assertJSONEqualsIgnoring", func(args []interface{}) (interface{}, error) {
+		return hd.assertJSON("response", args[2].(string), args[4].([]interface{}))
+	})
+
+	hd.action("assertJSONPathEquals", func(args []interface{}) (interface{}, error) {
+		return hd.assertJSON(hd.unquoteString(args[1].(string)), args[4].(string), nil)
+	})
+
+	hd.action("assertJSONPathEqualsIgnoring", func(args []interface{}) (interface{}, error) {
+		return hd.assertJSON(hd.unquoteString(args[1].(string)), args[4].(string), args[6].([]interface{}))
+	})
+
+	// jsonpath equals a value compares as numbers when both are numeric
+	hd.action("assertJSONPathValue", func(args []interface{}) (interface{}, error) {
+		path := hd.unquoteString(args[1].(string))
+		matches := hd.engine.ExtractAll("jsonpath", path)
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("assertion failed: jsonpath %s not found in response", path)
+		}
+		if !caseMatches(matches[0], args[3]) {
+			return nil, fmt.Errorf("assertion failed: jsonpath %s is %s, expected %s", path, jsonText(matches[0]), formatValue(args[3]))
+		}
+		return fmt.Sprintf("✓ jsonpath %s equals %s", path, formatValue(args[3])), nil
+	})
+
+	hd.action("assertCache", func(args []interface{}) (interface{}, error) {
+		expected := strings.ToLower(args[1].(string))
+		if expected != cacheHit && expected != cacheMiss {
+			return nil, fmt.Errorf("expected assert cache hit or assert cache miss")
+		}
+		actual := hd.engine.GetLastCacheStatus()
+		if actual == "" {
+			return nil, fmt.Errorf("assertion failed: expected cache %s, but the last request was not a GET sent with cache on", expected)
+		}
+		if actual != expected {
+			return nil, fmt.Errorf("assertion failed: expected cache %s, got cache %s", expected, actual)
+		}
+		return fmt.Sprintf("✓ Cache %s", actual), nil
+	})
+
+	hd.action("assertConnection", func(args []interface{}) (interface{}, error) {
+		expected := strings.ToLower(args[1].(string))
+		if expected != "reused" && expected != "new" {
+			return nil, fmt.Errorf("expected assert connection reused or assert connection new")
+		}
+		reused, ok := hd.engine.GetLastConnectionReused()
+		if !ok {
+			return nil, fmt.Errorf("assertion failed: expected connection %s, but the last request got no connection", expected)
+		}
+		actual := "new"
+		if reused {
+			actual = "reused"
+		}
+		if actual != expected {
+			return nil, fmt.Errorf("assertion failed: expected connection %s, got connection %s", expected, actual)
+		}
+		return fmt.Sprintf("✓ Connection %s", actual), nil
+	})
+
+	hd.action("assertJSONPathExists", func(args []interface{}) (interface{}, error) {
+		path := hd.unquoteString(args[1].(string))
+		if len(hd.engine.ExtractAll("jsonpath", path)) > 0 {
+			return fmt.Sprintf("✓ jsonpath %s exists", path), nil
+		}
+		return nil, fmt.Errorf("assertion failed: jsonpath %s not found in response", path)
+	})
+
+	hd.action("assertJSONPathNotExists", func(args []interface{}) (interface{}, error) {
+		path := hd.unquoteString(args[1].(string))
+		if matches := hd.engine.ExtractAll("jsonpath", path); len(matches) > 0 {
+			return nil, fmt.Errorf("assertion failed: jsonpath %s exists with value %s", path, jsonText(matches[0]))
+		}
+		return fmt.Sprintf("✓ jsonpath %s does not exist", path), nil
+	})
+
+	hd.action("assertJSONPathCount", func(args []interface{}) (interface{}, error) {
+		return hd.assertJSONPathCount(hd.unquoteString(args[1].(string)), "==", args[3].(string))
+	})
+
+	hd.action("assertJSONPathCountCompare", func(args []interface{}) (interface{}, error) {
+		return hd.assertJSONPathCount(hd.unquoteString(args[1].(string)), args[3].(string), args[4].(string))
+	})
+
+	// assert length jsonpath "$.items" == 25 compares the length of an
+	// array, object or string of the last response
+	hd.action("assertJSONPathLength", func(args []interface{}) (interface{}, error) {
+		path := hd.unquoteString(args[2].(string))
+		operator, expected := args[3].(string), args[4].(string)
+		length, err := hd.jsonPathLength(path)
+		if err != nil {
+			return nil, fmt.Errorf("assertion failed: %w", err)
+		}
+		if hd.CompareValues(length, operator, expected) {
+			return fmt.Sprintf("✓ length of jsonpath %s %d %s %s", path, length, operator, expected), nil
+		}
+		return nil, fmt.Errorf("assertion failed: expected length of jsonpath %s %s %s, got %d", path, operator, expected, length)
+	})
+
+	hd.action("doAssertion", func(args []interface{}) (interface{}, error) {
+		return args[1], nil
+	})
+
+	// assert stream receives "text" within 5 s waits for the text on the
+	// response of the last stream request
+	hd.action("assertStreamReceives", func(args []interface{}) (interface{}, error) {
+		expected := hd.expandVariables(hd.unquoteString(args[3].(string)))
+		timeout, err := hd.pollDuration(args[5], args[6])
+		if err != nil {
+			return nil, err
+		}
+		if err := hd.engine.AwaitStream(hd.ctx, expected, timeout); err != nil {
+			if errors.Is(err, errNoStream) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("assertion failed: %w", err)
+		}
+		return fmt.Sprintf("✓ Stream received '%s'", expected), nil
+	})
+
+	hd.lazyAction("assertEventually", func(args []interface{}) (interface{}, error) {
+		var request interface{}
+		rest := args[2:]
+		if node, ok := rest[0].(*astNode); ok && actionKind(node.action) == "request" {
+			request, rest = rest[0], rest[1:]
+		}
+		timeout, interval := defaultPollTimeout, defaultPollInterval
+		var err error
+		if len(rest) > 1 {
+			if timeout, err = hd.pollDuration(rest[2], rest[3]); err != nil {
+				return nil, err
+			}
+		}
+		if len(rest) > 4 {
+			if interval, err = hd.pollDuration(rest[5], rest[6]); err != nil {
+				return nil, err
+			}
+		}
+		return hd.assertEventually(request, rest[0], timeout, interval)
+	})
+
+	// Utilities
+	hd.dsl.Rule("utility", []string{"wait", "for", "http_request", "status", "NUMBER", "timeout", "NUMBER", "time_unit", "interval", "NUMBER", "time_unit"}, "waitFor")
+	hd.dsl.Rule("utility", []string{"wait", "for", "http_request", "status", "NUMBER", "timeout", "NUMBER", "time_unit"}, "waitFor")
+	hd.dsl.Rule("utility", []string{"wait", "for", "http_request", "status", "NUMBER"}, "waitFor")
+	hd.dsl.Rule("utility", []string{"benchmark", "NUMBER", "times", "http_request", "concurrency", "NUMBER"}, "benchmarkCmd")
+	hd.dsl.Rule("utility", []string{"benchmark", "NUMBER", "times", "http_request"}, "benchmarkCmd")
+	hd.dsl.Rule("utility", []string{"snapshot", "STRING", "ignoring", "field_list"}, "snapshotCmd")
+	hd.dsl.Rule("utility", []string{"snapshot", "STRING"}, "snapshotCmd")
+	hd.dsl.Rule("utility", []string{"diff", "diff_operand", "with", "diff_operand"}, "diffCmd")
+	hd.dsl.Rule("utility", []string{"diff", "diff_operand", "diff_operand"}, "diffCmd")
+	hd.dsl.Rule("diff_operand", []string{"response"}, "diffResponse")
+	hd.dsl.Rule("diff_operand", []string{"file", "STRING"}, "diffFile")
+	hd.dsl.Rule("diff_operand", []string{"VARIABLE"}, "valueVariable")
+	hd.dsl.Rule("utility", []string{"TRACE", "ID", "ID"}, "traceIDCmd")
+	hd.dsl.Rule("utility", []string{"TRACE", "ID"}, "wireTraceCmd")
+	hd.dsl.Rule("utility", []string{"set", "random", "seed", "value"}, "randomSeedCmd")
+	hd.dsl.Rule("utility", []string{"cache", "etags", "ID"}, "cacheETagsCmd")
+	hd.dsl.Rule("utility", []string{"clear", "etags"}, "clearETagsCmd")
+
+	// cache on keeps fresh GET responses and answers later GETs from them,
+	// honoring Cache-Control and Expires
+	hd.dsl.Rule("utility", []string{"cache", "ID"}, "cacheCmd")
+	hd.dsl.Rule("utility", []string{"clear", "cache"}, "clearCacheCmd")
+	hd.dsl.Rule("utility", []string{"wait", "NUMBER", "time_unit"}, "waitCmd")
+	hd.dsl.Rule("utility", []string{"sleep", "NUMBER", "time_unit"}, "waitCmd")
+	hd.dsl.Rule("utility", []string{"log", "STRING"}, "logCmd")
+	hd.dsl.Rule("utility", []string{"debug", "STRING"}, "debugCmd")
+	hd.dsl.Rule("utility", []string{"debug", "ID"}, "debugModeCmd")
+	hd.dsl.Rule("utility", []string{"log", "ID", "debug"}, "logLevelCmd")
+	hd.dsl.Rule("utility", []string{"log", "ID", "ID"}, "logLevelCmd")
+	hd.dsl.Rule("utility", []string{"clear", "cookies"}, "clearCookies")
+	hd.dsl.Rule("utility", []string{"reset"}, "resetCmd")
+	hd.dsl.Rule("utility", []string{"base", "url", "STRING"}, "setBaseURL")
+	hd.dsl.Rule("utility", []string{"set", "max", "response", "size", "NUMBER", "ID"}, "maxResponseSizeCmd")
+	hd.dsl.Rule("utility", []string{"set", "max", "response", "size", "NUMBER"}, "maxResponseSizeCmd")
+	hd.dsl.Rule("utility", []string{"set", "max", "response", "size", "ID"}, "maxResponseSizeCmd")
+	hd.dsl.Rule("utility", []string{"compress", "request", "body", "ID"}, "compressRequestCmd")
+	hd.dsl.Rule("utility", []string{"accept", "encoding", "STRING"}, "acceptEncodingCmd")
+	hd.dsl.Rule("utility", []string{"resolve", "STRING", "to", "STRING"}, "resolveCmd")
+	hd.dsl.Rule("utility", []string{"unix", "socket", "STRING"}, "unixSocketCmd")
+	hd.dsl.Rule("utility", []string{"allow", "hosts", "field_list"}, "hostPolicyCmd")
+	hd.dsl.Rule("utility", []string{"deny", "hosts", "field_list"}, "hostPolicyCmd")
+	hd.dsl.Rule("utility", []string{"deny", "private", "networks"}, "denyPrivateNetworksCmd")
+	hd.dsl.Rule("utility", []string{"clear", "host", "policy"}, "clearHostPolicyCmd")
+
+	// Headers and credentials sent only to one host, even across redirects
+	hd.dsl.Rule("utility", []string{"for", "host", "STRING", "header", "STRING", "STRING"}, "hostHeaderCmd")
+	hd.dsl.Rule("utility", []string{"for", "host", "STRING", "auth", "basic", "STRING", "STRING"}, "hostAuthCmd")
+	hd.dsl.Rule("utility", []string{"for", "host", "STRING", "auth", "bearer", "STRING"}, "hostAuthCmd")
+	hd.dsl.Rule("utility", []string{"clear", "host", "headers"}, "clearHostHeadersCmd")
+
+	// on redirect follow, log or stop; cross-host redirects fail when denied
+	hd.dsl.Rule("utility", []string{"ID", "redirect", "log"}, "redirectModeCmd")
+	hd.dsl.Rule("utility", []string{"ID", "redirect", "ID"}, "redirectModeCmd")
+	hd.dsl.Rule("utility", []string{"deny", "cross-host", "redirects"}, "crossHostRedirectsCmd")
+	hd.dsl.Rule("utility", []string{"allow", "cross-host", "redirects"}, "crossHostRedirectsCmd")
+	hd.dsl.Rule("utility", []string{"proxy", "from", "environment"}, "proxyFromEnvironmentCmd")
+	hd.dsl.Rule("utility", []string{"clear", "proxy"}, "clearProxyCmd")
+	hd.dsl.Rule("utility", []string{"chaos", "latency", "NUMBER", "time_unit", "probability", "NUMBER"}, "chaosLatencyCmd")
+	hd.dsl.Rule("utility", []string{"chaos", "latency", "NUMBER", "time_unit"}, "chaosLatencyCmd")
+	hd.dsl.Rule("utility", []string{"chaos", "fail", "probability", "NUMBER"}, "chaosFailCmd")
+	hd.dsl.Rule("utility", []string{"clear", "chaos"}, "clearChaosCmd")
+	hd.dsl.Rule("utility", []string{"throttle", "ID", "NUMBER", "ID"}, "throttleCmd")
+	hd.dsl.Rule("utility", []string{"clear", "throttle"}, "clearThrottleCmd")
+
+	// OAuth2 grants without a user get a token sent as bearer on later
+	// requests: client credentials, Azure AD apps and Google service accounts
+	hd.dsl.Rule("utility", []string{"auth", "oidc", "client_credentials", "STRING", "client", "STRING", "secret", "STRING", "scope", "STRING"}, "oidcClientCredentialsCmd")
+	hd.dsl.Rule("utility", []string{"auth", "oidc", "client_credentials", "STRING", "client", "STRING", "secret", "STRING"}, "oidcClientCredentialsCmd")
+	hd.dsl.Rule("utility", []string{"auth", "azure", "tenant", "STRING", "client", "STRING", "secret", "STRING", "scope", "STRING"}, "azureADCmd")
+	hd.dsl.Rule("utility", []string{"auth", "google", "service", "account", "STRING", "scope", "STRING"}, "googleServiceAccountCmd")
+	hd.dsl.Rule("utility", []string{"header", "STRING", "STRING"}, "hookHeader")
+	hd.dsl.Rule("utility", []string{"default", "header", "STRING", "STRING"}, "defaultHeader")
+	hd.dsl.Rule("utility", []string{"clear", "default", "headers"}, "clearDefaultHeaders")
+	hd.dsl.Rule("utility", []string{"history", "show", "NUMBER"}, "historyShowCmd")
+	hd.dsl.Rule("utility", []string{"history"}, "historyCmd")
+	hd.dsl.Rule("utility", []string{"show", "response", "pretty"}, "showResponseCmd")
+	hd.dsl.Rule("utility", []string{"show", "response"}, "showResponseCmd")
+
+	hd.action("waitCmd", func(args []interface{}) (interface{}, error) {
+		duration, _ := strconv.ParseFloat(args[1].(string), 64)
+		unit := args[2].(string)
+		if unit == "s" {
+			duration = duration * 1000
+		}
+		if err := hd.engine.WaitContext(hd.ctx, int(duration)); err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("Waited %.0fms", duration), nil
+	})
+
+	// wait for sends the request again until it answers with the status, so
+	// args[2] stays unevaluated
+	hd.lazyAction("waitFor", func(args []interface{}) (interface{}, error) {
+		status, err := strconv.Atoi(args[4].(string))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status %s", args[4])
+		}
+		timeout, interval := defaultPollTimeout, defaultPollInterval
+		if len(args) > 5 {
+			if timeout, err = hd.pollDuration(args[6], args[7]); err != nil {
+				return nil, err
+			}
+		}
+		if len(args) > 8 {
+			if interval, err = hd.pollDuration(args[9], args[10]); err != nil {
+				return nil, err
+			}
+		}
+		return hd.waitForStatus(args[2], status, timeout, interval)
+	})
+
+	// benchmark stores its statistics in $benchmark
+	hd.lazyAction("benchmarkCmd", func(args []interface{}) (interface{}, error) {
+		n, err := strconv.Atoi(args[1].(string))
+		if err != nil {
+			return nil, fmt.Errorf("invalid request count %s", args[1])
+		}
+		concurrency := 1
+		if len(args) > 4 {
+			if concurrency, err = strconv.Atoi(args[5].(string)); err != nil {
+				return nil, fmt.Errorf("invalid concurrency %s", args[5])
+			}
+		}
+		stats, err := hd.benchmark(args[3], n, concurrency)
+		if err != nil {
+			return nil, err
+		}
+		hd.lastBenchmark = stats
+		hd.SetVariable("benchmark", stats.variable())
+		return stats.String(), nil
+	})
+
+	hd.action("snapshotCmd", func(args []interface{}) (interface{}, error) {
+		var ignoreFields []string
+		if len(args) > 2 {
+			for _, field := range args[3].([]interface{}) {
+				ignoreFields = append(ignoreFields, hd.unquoteString(field.(string)))
+			}
+		}
+		return hd.snapshot(hd.expandVariables(hd.unquoteString(args[1].(string))), ignoreFields)
+	})
+
+	// diff compares two responses, variables or files, failing when they differ
+	hd.action("diffCmd", func(args []interface{}) (interface{}, error) {
+		return diffCommand(args[1], args[len(args)-1])
+	})
+
+	hd.action("diffResponse", func(args []interface{}) (interface{}, error) {
+		return hd.engine.GetLastResponse(), nil
+	})
+
+	hd.action("diffFile", func(args []interface{}) (interface{}, error) {
+		return readDiffFile(hd.expandVariables(hd.unquoteString(args[1].(string))))
+	})
+
+	// trace id auto gives every later request generated X-Request-ID and
+	// traceparent headers; trace id off stops it
+	hd.action("traceIDCmd", func(args []interface{}) (interface{}, error) {
+		setting := strings.ToLower(args[2].(string))
+		if strings.ToLower(args[1].(string)) != "id" || (setting != "auto" && setting != "off") {
+			return nil, fmt.Errorf("expected trace id auto or trace id off")
+		}
+		hd.engine.SetTraceIDs(setting == "auto")
+		if setting == "off" {
+			return "Trace IDs off", nil
+		}
+		return "Trace IDs on", nil
+	})
+
+	// trace on dumps requests and responses like curl -v, to stderr, for the
+	// section of the script up to trace off
+	hd.action("wireTraceCmd", func(args []interface{}) (interface{}, error) {
+		setting := strings.ToLower(args[1].(string))
+		if setting != "on" && setting != "off" {
+			return nil, fmt.Errorf("expected trace on or trace off")
+		}
+		hd.engine.SetWireTrace(setting == "on")
+		if setting == "off" {
+			return "Wire trace off", nil
+		}
+		return "Wire trace on", nil
+	})
+
+	// cache etags on sends the ETag and Last-Modified of the last response
+	// for a URL back as If-None-Match and If-Modified-Since
+	hd.action("cacheETagsCmd", func(args []interface{}) (interface{}, error) {
+		setting := strings.ToLower(args[2].(string))
+		if setting != "on" && setting != "off" {
+			return nil, fmt.Errorf("expected cache etags on or cache etags off")
+		}
+		hd.engine.SetETagCache(setting == "on")
+		if setting == "off" {
+			return "ETag cache off", nil
+		}
+		return "ETag cache on", nil
+	})
+
+	hd.action("clearETagsCmd", func(args []interface{}) (interface{}, error) {
+		hd.engine.ClearETagCache()
+		return "ETag cache cleared", nil
+	})
+
+	hd.action("cacheCmd", func(args []interface{}) (interface{}, error) {
+		setting := strings.ToLower(args[1].(string))
+		if setting != "on" && setting != "off" {
+			return nil, fmt.Errorf("expected cache on or cache off")
+		}
+		hd.engine.SetResponseCache(setting == "on")
+		if setting == "off" {
+			return "Response cache off", nil
+		}
+		return "Response cache on", nil
+	})
+
+	hd.action("clearCacheCmd", func(args []interface{}) (interface{}, error) {
+		hd.engine.ClearResponseCache()
+		return "Response cache cleared", nil
+	})
+
+	hd.action("logCmd", func(args []interface{}) (interface{}, error) {
+		message := hd.expandVariables(hd.unquoteString(args[1].(string)))
+		hd.engine.Log(message)
+		return fmt.Sprintf("Logged: %s", message), nil
+	})
+
+	hd.action("debugCmd", func(args []interface{}) (interface{}, error) {
+		message := hd.expandVariables(hd.unquoteString(args[1].(string)))
+		hd.engine.Debug(message)
+		return fmt.Sprintf("Debug: %s", message), nil
+	})
+
+	// debug on raises logging to debug and prints it, like --debug, for the
+	// section of the script up to debug off, which restores the log level
+	hd.action("debugModeCmd", func(args []interface{}) (interface{}, error) {
+		switch strings.ToLower(args[1].(string)) {
+		case "on":
+			if hd.debugLevel == nil {
+				level := hd.engine.GetLogLevel()
+				hd.debugLevel = &level
+			}
+			if hd.engine.GetLogLevel() < LogDebug {
+				hd.engine.SetLogLevel(LogDebug)
+			}
+			hd.engine.SetDebug(true)
+			return "Debug on", nil
+		case "off":
+			if hd.debugLevel != nil {
+				hd.engine.SetLogLevel(*hd.debugLevel)
+				hd.debugLevel = nil
+			}
+			hd.engine.SetDebug(false)
+			return "Debug off", nil
+		}
+		return nil, fmt.Errorf("expected debug on or debug off")
+	})
+
+	// log level error, warn, info, debug or verbose sets how much is logged
+	hd.action("logLevelCmd", func(args []interface{}) (interface{}, error) {
+		if strings.ToLower(args[1].(string)) != "level" {
+			return nil, fmt.Errorf("expected log level followed by error, warn, info, debug or verbose")
+		}
+		level, err := ParseLogLevel(args[2].(string))
+		if err != nil {
+			return nil, err
+		}
+		hd.engine.SetLogLevel(level)
+		return fmt.Sprintf("Log level %s", strings.ToLower(level.String())), nil
+	})
+
+	hd.action("clearCookies", func(args []interface{}) (interface{}, error) {
+		hd.engine.ClearCookies()
+		return "Cookies cleared", nil
+	})
+
+	hd.action("resetCmd", func(args []interface{}) (interface{}, error) {
+		hd.engine.Reset()
+		hd.ClearVariables()
+		hd.context = make(map[string]interface{})
+		hd.beforeHooks, hd.afterHooks = nil, nil
+		hd.defaultHeaders = make(map[string]bool)
+		return "Reset complete", nil
+	})
+
+	hd.action("setBaseURL", func(args []interface{}) (interface{}, error) {
+		url := hd.expandVariables(hd.unquoteString(args[2].(string)))
+		hd.engine.SetBaseURL(url)
+		return fmt.Sprintf("Base URL set to %s", url), nil
+	})
+
+	// set max response size 10 mb keeps at most that much of every body;
+	// a size of 0 or off removes the limit
+	hd.action("randomSeedCmd", func(args []interface{}) (interface{}, error) {
+		seed, err := numberArgument(args[3])
+		if err != nil || seed != math.Trunc(seed) {
+			return nil, fmt.Errorf("random seed must be an integer, got %s", formatValue(args[3]))
+		}
+		hd.SetRandomSeed(int64(seed))
+		return fmt.Sprintf("Random seed set to %d", int64(seed)), nil
+	})
+
+	hd.action("maxResponseSizeCmd", func(args []interface{}) (interface{}, error) {
+		size, err := parseByteSize(args[4].(string), args[5:])
+		if err != nil {
+			return nil, err
+		}
+		hd.engine.SetMaxResponseSize(size)
+		if size == 0 {
+			return "Max response size removed", nil
+		}
+		return fmt.Sprintf("Max response size set to %d bytes", size), nil
+	})
+
+	hd.action("compressRequestCmd", func(args []interface{}) (interface{}, error) {
+		if err := hd.engine.SetRequestCompression(args[3].(string)); err != nil {
+			return nil, err
+		}
+		if encoding, _ := parseContentEncoding(args[3].(string)); encoding != "" {
+			return fmt.Sprintf("Compressing request bodies with %s", encoding), nil
+		}
+		return "Request body compression off", nil
+	})
+
+	hd.action("acceptEncodingCmd", func(args []interface{}) (interface{}, error) {
+		value := hd.expandVariables(hd.unquoteString(args[2].(string)))
+		hd.engine.SetAcceptEncoding(value)
+		if value == "" {
+			return fmt.Sprintf("Accept-Encoding reset to %s", defaultAcceptEncoding), nil
+		}
+		return fmt.Sprintf("Accept-Encoding set to %s", value), nil
+	})
+
+	hd.action("resolveCmd", func(args []interface{}) (interface{}, error) {
+		hostPort := hd.expandVariables(hd.unquoteString(args[1].(string)))
+		address := hd.expandVariables(hd.unquoteString(args[3].(string)))
+		if err := hd.engine.SetResolve(hostPort, address); err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("Resolving %s to %s", hostPort, address), nil
+	})
+
+	// header sets a header on the pending request inside a before request
+	// block, or a default header inside a default headers block
+	hd.action("hookHeader", func(args []interface{}) (interface{}, error) {
+		name := hd.unquoteString(args[1].(string))
+		value := hd.expandVariables(hd.unquoteString(args[2].(string)))
+		if hd.hookPhase == defaultHeadersBlock {
+			return hd.setDefaultHeader(name, value), nil
+		}
+		if hd.hookPhase != beforeRequestHook {
+			return nil, fmt.Errorf("header is only allowed in a before request or default headers block")
+		}
+		hd.varsLock.Lock()
+		defer hd.varsLock.Unlock()
+		request, _ := hd.variables["request"].(map[string]interface{})
+		headers, ok := request["headers"].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("$request.headers must be an object")
+		}
+		headers[name] = value
+		return fmt.Sprintf("Header %s set to %s", name, value), nil
+	})
+
+	hd.action("defaultHeader", func(args []interface{}) (interface{}, error) {
+		name := hd.unquoteString(args[2].(string))
+		value := hd.expandVariables(hd.unquoteString(args[3].(string)))
+		return hd.setDefaultHeader(name, value), nil
+	})
+
+	hd.action("clearDefaultHeaders", func(args []interface{}) (interface{}, error) {
+		return hd.clearDefaultHeaders(), nil
+	})
+
+	hd.action("historyCmd", func(args []interface{}) (interface{}, error) {
+		return hd.formatHistory(), nil
+	})
+
+	hd.action("historyShowCmd", func(args []interface{}) (interface{}, error) {
+		n, _ := strconv.Atoi(args[2].(string))
+		return hd.formatHistoryEntry(n)
+	})
+
+	// show response prints the last body; pretty indents JSON
+	hd.action("showResponseCmd", func(args []interface{}) (interface{}, error) {
+		body := hd.engine.GetLastResponse()
+		if body == "" {
+			return nil, fmt.Errorf("no response to show")
+		}
+		if len(args) > 2 {
+			return prettyBody(body), nil
+		}
+		return body, nil
+	})
+
+	// allow hosts and deny hosts keep scripts away from hosts they must not
+	// reach; deny private networks refuses internal addresses after DNS
+	hd.action("hostPolicyCmd", func(args []interface{}) (interface{}, error) {
+		var patterns []string
+		for _, pattern := range args[2].([]interface{}) {
+			patterns = append(patterns, hd.expandVariables(hd.unquoteString(pattern.(string))))
+		}
+		if strings.ToLower(args[0].(string)) == "allow" {
+			hd.engine.AllowHosts(patterns...)
+			return fmt.Sprintf("Allowed hosts: %s", strings.Join(patterns, ", ")), nil
+		}
+		hd.engine.DenyHosts(patterns...)
+		return fmt.Sprintf("Denied hosts: %s", strings.Join(patterns, ", ")), nil
+	})
+
+	hd.action("denyPrivateNetworksCmd", func(args []interface{}) (interface{}, error) {
+		hd.engine.SetDenyPrivateNetworks(true)
+		return "Private networks denied", nil
+	})
+
+	hd.action("clearHostPolicyCmd", func(args []interface{}) (interface{}, error) {
+		hd.engine.ClearHostPolicy()
+		return "Host policy cleared", nil
+	})
+
+	// for host sets headers and credentials that only requests to matching
+	// hosts get, so they do not leak to other origins
+	hd.action("hostHeaderCmd", func(args []interface{}) (interface{}, error) {
+		pattern := hd.expandVariables(hd.unquoteString(args[2].(string)))
+		name := hd.unquoteString(args[4].(string))
+		value := hd.expandVariables(hd.unquoteString(args[5].(string)))
+		hd.engine.SetHostHeader(pattern, name, value)
+		return fmt.Sprintf("Header %s set for host %s", name, pattern), nil
+	})
+
+	hd.action("hostAuthCmd", func(args []interface{}) (interface{}, error) {
+		pattern := hd.expandVariables(hd.unquoteString(args[2].(string)))
+		if strings.ToLower(args[4].(string)) == "basic" {
+			user := hd.expandVariables(hd.unquoteString(args[5].(string)))
+			pass := hd.expandVariables(hd.unquoteString(args[6].(string)))
+			hd.engine.SetHostBasicAuth(pattern, user, pass)
+			return fmt.Sprintf("Basic auth set for host %s", pattern), nil
+		}
+		token := hd.expandVariables(hd.unquoteString(args[5].(string)))
+		hd.engine.SetHostBearerToken(pattern, token)
+		return fmt.Sprintf("Bearer auth set for host %s", pattern), nil
+	})
+
+	hd.action("clearHostHeadersCmd", func(args []interface{}) (interface{}, error) {
+		hd.engine.ClearHostHeaders()
+		return "Host headers cleared", nil
+	})
+
+	// on redirect follow follows redirects quietly, on redirect log prints
+	// each one and on redirect stop makes the redirect itself the response
+	hd.action("redirectModeCmd", func(args []interface{}) (interface{}, error) {
+		mode := strings.ToLower(args[2].(string))
+		if strings.ToLower(args[0].(string)) != "on" {
+			return nil, fmt.Errorf("expected on redirect follow, log or stop")
+		}
+		switch mode {
+		case "follow", "log":
+			hd.engine.SetFollowRedirects(true)
+			hd.engine.SetRedirectLogging(mode == "log")
+			if mode == "log" {
+				return "Following and logging redirects", nil
+			}
+			return "Following redirects", nil
+		case "stop":
+			hd.engine.SetFollowRedirects(false)
+			return "Not following redirects", nil
+		}
+		return nil, fmt.Errorf("expected on redirect follow, log or stop, got %s", mode)
+	})
+
+	hd.action("crossHostRedirectsCmd", func(args []interface{}) (interface{}, error) {
+		deny := strings.ToLower(args[0].(string)) == "deny"
+		hd.engine.SetDenyCrossHostRedirects(deny)
+		if deny {
+			return "Cross-host redirects denied", nil
+		}
+		return "Cross-host redirects allowed", nil
+	})
+
+	// New engines already use the proxies of the environment; proxy from
+	// environment reads the variables again, clear proxy goes direct
+	hd.action("proxyFromEnvironmentCmd", func(args []interface{}) (interface{}, error) {
+		hd.engine.UseEnvironmentProxy()
+		return "Using proxies from the environment", nil
+	})
+
+	hd.action("clearProxyCmd", func(args []interface{}) (interface{}, error) {
+		hd.engine.ClearProxy()
+		return "Proxy cleared", nil
+	})
+
+	// Chaos draws come from the script's random source, so --seed replays
+	// the same faults
+	hd.action("chaosLatencyCmd", func(args []interface{}) (interface{}, error) {
+		latency, _ := strconv.ParseFloat(args[2].(string), 64)
+		if args[3].(string) == "s" {
+			latency = latency * 1000
+		}
+		probability := 1.0
+		if len(args) == 6 {
+			probability, _ = strconv.ParseFloat(args[5].(string), 64)
+		}
+		hd.engine.SetChaosSeed(hd.random.Int63())
+		if err := hd.engine.SetChaosLatency(time.Duration(latency)*time.Millisecond, probability); err != nil {
+			return nil, err
+		}
+		hd.usedRandom = true
+		return fmt.Sprintf("Chaos: delaying %.0f%% of requests by %.0fms", probability*100, latency), nil
+	})
+
+	hd.action("chaosFailCmd", func(args []interface{}) (interface{}, error) {
+		probability, _ := strconv.ParseFloat(args[3].(string), 64)
+		hd.engine.SetChaosSeed(hd.random.Int63())
+		if err := hd.engine.SetChaosFailure(probability); err != nil {
+			return nil, err
+		}
+		hd.usedRandom = true
+		return fmt.Sprintf("Chaos: failing %.0f%% of requests", probability*100), nil
+	})
+
+	hd.action("clearChaosCmd", func(args []interface{}) (interface{}, error) {
+		hd.engine.ClearChaos()
+		return "Chaos cleared", nil
+	})
+
+	hd.action("throttleCmd", func(args []interface{}) (interface{}, error) {
+		direction, rate, err := throttleArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		if direction == "download" {
+			hd.engine.SetDownloadRate(rate)
+		} else {
+			hd.engine.SetUploadRate(rate)
+		}
+		return fmt.Sprintf("Throttling %ss to %d bytes/s", direction, rate), nil
+	})
+
+	hd.action("clearThrottleCmd", func(args []interface{}) (interface{}, error) {
+		hd.engine.SetDownloadRate(0)
+		hd.engine.SetUploadRate(0)
+		return "Throttle cleared", nil
+	})
+
+	hd.action("oidcClientCredentialsCmd", func(args []interface{}) (interface{}, error) {
+		config := &OAuth2Config{
+			TokenURL:     hd.expandVariables(hd.unquoteString(args[3].(string))),
+			ClientID:     hd.expandVariables(hd.unquoteString(args[5].(string))),
+			ClientSecret: hd.expandVariables(hd.unquoteString(args[7].(string))),
+		}
+		if len(args) > 9 {
+			config.Scopes = strings.Fields(hd.expandVariables(hd.unquoteString(args[9].(string))))
+		}
+		hd.engine.SetOAuth2Config(config)
+		if err := hd.engine.OAuth2ClientCredentials(hd.ctx); err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("Authenticated client %s with client credentials", config.ClientID), nil
+	})
+
+	hd.action("azureADCmd", func(args []interface{}) (interface{}, error) {
+		tenant := hd.expandVariables(hd.unquoteString(args[3].(string)))
+		clientID := hd.expandVariables(hd.unquoteString(args[5].(string)))
+		secret := hd.expandVariables(hd.unquoteString(args[7].(string)))
+		scopes := strings.Fields(hd.expandVariables(hd.unquoteString(args[9].(string))))
+		hd.engine.SetOAuth2Config(AzureADConfig(tenant, clientID, secret, scopes))
+		if err := hd.engine.OAuth2ClientCredentials(hd.ctx); err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("Authenticated Azure AD app %s of tenant %s", clientID, tenant), nil
+	})
+
+	hd.action("googleServiceAccountCmd", func(args []interface{}) (interface{}, error) {
+		keyFile := hd.expandVariables(hd.unquoteString(args[4].(string)))
+		scopes := strings.Fields(hd.expandVariables(hd.unquoteString(args[6].(string))))
+		if err := hd.engine.OAuth2ServiceAccount(hd.ctx, keyFile, scopes); err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("Authenticated Google service account %s", hd.engine.oauth2().ClientID), nil
+	})
+
+	hd.action("unixSocketCmd", func(args []interface{}) (interface{}, error) {
+		path := hd.expandVariables(hd.unquoteString(args[2].(string)))
+		hd.engine.SetUnixSocket(path)
+		if path == "" {
+			return "Unix socket cleared", nil
+		}
+		return fmt.Sprintf("Using unix socket %s", path), nil
+	})
+}
+
+// Helper methods for internal use
+
+// assertJSON compares the last response, or the value at a jsonpath when path
+// is not "response", with the expected JSON document
+func (hd *HTTPDSLv3) assertJSON(path, expected string, ignored []interface{}) (interface{}, error) {
+	var ignoreFields []string
+	for _, field := range ignored {
+		ignoreFields = append(ignoreFields, hd.unquoteString(field.(string)))
+	}
+
+	var actual interface{}
+	if path == "response" {
+		actual = hd.engine.GetLastResponse()
+		if actual == "" {
+			return nil, fmt.Errorf("assertion failed: no response to compare")
+		}
+	} else {
+		value := hd.engine.Extract("jsonpath", path)
+		if value == nil {
+			return nil, fmt.Errorf("assertion failed: jsonpath %s not found in response", path)
+		}
+		// Encoded, since CompareJSON reads strings as JSON text and the
+		// value may be a string leaf
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("assertion failed: value at %s is not JSON: %v", path, err)
+		}
+		actual = encoded
+	}
+
+	if err := CompareJSON(expected, actual, ignoreFields); err != nil {
+		return nil, fmt.Errorf("assertion failed: JSON mismatch: %v", err)
+	}
+	if path == "response" {
+		return "✓ Response JSON matches", nil
+	}
+	return fmt.Sprintf("✓ JSON at %s matches", path), nil
+}
+
+// responseValue turns the result of a request into a variable value with
+// method, url, status, body, headers, time and size. Header names are
+// lowercase with - as _, like $resp.headers.content_type, and repeated
+// headers are joined with commas.
+func responseValue(result map[string]interface{}) map[string]interface{} {
+	headers := make(map[string]interface{})
+	if header, ok := result["headers"].(http.Header); ok {
+		for name, values := range header {
+			key := strings.ReplaceAll(strings.ToLower(name), "-", "_")
+			headers[key] = strings.Join(values, ", ")
+		}
+	}
+	return map[string]interface{}{
+		"method":  result["method"],
+		"url":     result["url"],
+		"status":  result["status"],
+		"body":    result["body"],
+		"headers": headers,
+		"time":    result["time"],
+		"size":    result["size"],
+	}
+}
+
+// bodyFromResponse returns the last response body, or the value at a
+// jsonpath in it, as the body of another request. Extracted strings are sent
+// as they are in a raw body; everything else is sent as JSON.
+func (hd *HTTPDSLv3) bodyFromResponse(path string, asJSON bool) (string, error) {
+	body := hd.engine.GetLastResponse()
+	if body == "" {
+		return "", fmt.Errorf("no response to take the body from")
+	}
+	if path == "" {
+		if asJSON && !json.Valid([]byte(body)) {
+			return "", fmt.Errorf("last response is not valid JSON")
+		}
+		return body, nil
+	}
+
+	matches := hd.engine.ExtractAll("jsonpath", path)
+	if len(matches) == 0 {
+		return "", fmt.Errorf("jsonpath %s not found in response", path)
+	}
+	if text, ok := matches[0].(string); ok && !asJSON {
+		return text, nil
+	}
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(matches[0]); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// assertJSONPathCount compares the number of items at a jsonpath with expected.
+// A path selecting one array or object counts its items; wildcard and filter
+// paths count their matches.
+func (hd *HTTPDSLv3) assertJSONPathCount(path, operator, expected string) (interface{}, error) {
+	matches := hd.engine.ExtractAll("jsonpath", path)
+	if len(matches) == 0 && !strings.ContainsAny(path, "*?") {
+		return nil, fmt.Errorf("assertion failed: jsonpath %s not found in response", path)
+	}
+
+	count := len(matches)
+	if !strings.ContainsAny(path, "*?") {
+		switch v := matches[0].(type) {
+		case []interface{}:
+			count = len(v)
+		case map[string]interface{}:
+			count = len(v)
+		default:
+			return nil, fmt.Errorf("assertion failed: jsonpath %s is %s, not an array", path, jsonText(v))
+		}
+	}
+
+	if hd.CompareValues(count, operator, expected) {
+		return fmt.Sprintf("✓ jsonpath %s count %d %s %s", path, count, operator, expected), nil
+	}
+	return nil, fmt.Errorf("assertion failed: expected jsonpath %s count %s %s, got %d", path, operator, expected, count)
+}
+
+// jsonPathLength returns the length of the value at a jsonpath of the last
+// response, as length counts variables: the elements of an array, the fields
+// of an object or the characters of a string. Paths with * or a filter count
+// their matches.
+func (hd *HTTPDSLv3) jsonPathLength(path string) (int, error) {
+	matches := hd.engine.ExtractAll("jsonpath", path)
+	if strings.ContainsAny(path, "*?") {
+		return len(matches), nil
+	}
+	if len(matches) == 0 {
+		return 0, fmt.Errorf("jsonpath %s not found in response", path)
+	}
+	switch v := matches[0].(type) {
+	case []interface{}:
+		return len(v), nil
+	case map[string]interface{}:
+		return len(v), nil
+	case string:
+		return utf8.RuneCountInString(v), nil
+	}
+	return 0, fmt.Errorf("jsonpath %s is %s, which has no length", path, jsonText(matches[0]))
+}
+
+// unquoteString removes surrounding quotes and processes escape sequences.
+// Handles standard escape sequences like \n, \t, \r, and escaped quotes.
+func (hd *HTTPDSLv3) unquoteString(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		// Remove quotes and handle escape sequences in a single pass so that
+		// an escaped backslash is never combined with the character after it
+		s = stringEscapes.Replace(s[1 : len(s)-1])
+	}
+	return s
+}
+
+// stringEscapes resolves the escape sequences allowed in quoted strings.
+// Unknown sequences such as \d are kept as written for regex patterns.
+var stringEscapes = strings.NewReplacer(
+	`\"`, `"`,
+	`\\`, `\`,
+	`\n`, "\n",
+	`\t`, "\t",
+	`\r`, "\r",
+)
+
+// expandVariables replaces $name and ${name} references with their actual values.
+// Names are matched greedily, so $user never replaces part of $username; write
+// ${user}name to join a value with the text after it. \$ produces a literal
+// dollar sign, and references to unknown variables are kept as written.
+// Used throughout the DSL to enable variable interpolation in strings.
+//
+// DEVELOPER GUIDE: Variable System
+// Variables are stored in hd.variables map.
+// They're expanded in strings before execution.
+// To add special variables (like $ARGC), set them during initialization.
+// Variables persist across statements but are cleared on Reset.
+func (hd *HTTPDSLv3) expandVariables(s string) string {
+	if !strings.Contains(s, "$") {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == '$':
+			sb.WriteByte('$')
+			i += 2
+		case s[i] == '$':
+			if value, end, ok := hd.variableRef(s, i); ok {
+				sb.WriteString(formatValue(value))
+				i = end
+				continue
+			}
+			sb.WriteByte('$')
+			i++
+		default:
+			sb.WriteByte(s[i])
+			i++
+		}
+	}
+	return sb.String()
+}
+
+// expandJSON expands variables in a JSON template. Values substituted inside a
+// string literal are escaped as string content, so quotes and newlines stay
+// valid. Values outside strings are written as JSON: numbers, booleans, arrays,
+// and objects as they are, and other text as a quoted string. The result is
+// validated before it is returned.
+//
+// Example:
+//
+//	hd.SetVariable("name", `Ann "The Admin"`)
+//	body, err := hd.expandJSON(`{"name": "$name", "id": $id}`)
+func (hd *HTTPDSLv3) expandJSON(template string) (string, error) {
+	var sb strings.Builder
+	inString := false
+
+	for i := 0; i < len(template); {
+		c := template[i]
+		switch {
+		case c == '\\' && i+1 < len(template) && template[i+1] == '$':
+			sb.WriteByte('$')
+			i += 2
+			continue
+		case c == '\\' && inString && i+1 < len(template):
+			// Keep JSON escapes such as \" intact
+			sb.WriteString(template[i : i+2])
+			i += 2
+			continue
+		case c == '"':
+			inString = !inString
+		case c == '$':
+			if value, end, ok := hd.variableRef(template, i); ok {
+				if inString {
+					sb.WriteString(jsonEscape(formatValue(value)))
+				} else {
+					sb.WriteString(jsonLiteral(value))
+				}
+				i = end
+				continue
+			}
+		}
+		sb.WriteByte(c)
+		i++
+	}
+
+	result := sb.String()
+	if err := hd.ValidateJSON(result); err != nil {
+		return "", fmt.Errorf("invalid JSON body: %v", err)
+	}
+	return result, nil
+}
+
+// jsonEscape escapes s for use inside a JSON string literal
+func jsonEscape(s string) string {
+	var buf strings.Builder
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	quoted := strings.TrimSuffix(buf.String(), "\n")
+	return quoted[1 : len(quoted)-1]
+}
+
+// jsonLiteral renders a variable value as a standalone JSON value. Strings
+// that already hold JSON (like an extracted number or object) are kept as is.
+func jsonLiteral(value interface{}) string {
+	if str, ok := value.(string); ok {
+		if trimmed := strings.TrimSpace(str); trimmed != "" && json.Valid([]byte(trimmed)) {
+			return trimmed
+		}
+		return `"` + jsonEscape(str) + `"`
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return `"` + jsonEscape(fmt.Sprintf("%v", value)) + `"`
+	}
+	return string(data)
+}
+
+// variableRef resolves the variable reference starting at s[start], which
+// must be a '$'. It returns the value and the index just past the reference.
+func (hd *HTTPDSLv3) variableRef(s string, start int) (interface{}, int, bool) {
+	if start+1 < len(s) && s[start+1] == '{' {
+		closing := strings.IndexByte(s[start+2:], '}')
+		if closing < 0 {
+			return nil, 0, false
+		}
+		name := s[start+2 : start+2+closing]
+		value, ok := hd.lookupVariable(name)
+		return value, start + 3 + closing, ok
+	}
+
+	end := start + 1
+	for end < len(s) && isIdentifierChar(s[end], end == start+1) {
+		end++
+	}
+	if end == start+1 {
+		return nil, 0, false
+	}
+	name := s[start+1 : end]
+
+	// Indexed names such as ARGV[0] are stored as variables of their own
+	if end < len(s) && s[end] == '[' {
+		if closing := strings.IndexByte(s[end:], ']'); closing > 0 {
+			if value, ok := hd.GetVariable(s[start+1 : end+closing+1]); ok {
+				return value, end + closing + 1, true
+			}
+		}
+	}
+
+	// Dotted segments select fields while the value has them, so "$item.id"
+	// reads a field but "$host.com" keeps the ".com"
+	value, ok := hd.GetVariable(name)
+	for ok && end+1 < len(s) && s[end] == '.' && isIdentifierChar(s[end+1], false) {
+		fieldEnd := end + 1
+		for fieldEnd < len(s) && isIdentifierChar(s[fieldEnd], false) {
+			fieldEnd++
 		}
+		field, found := fieldValue(value, s[end+1:fieldEnd])
+		if !found {
+			break
+		}
+		value, end = field, fieldEnd
+	}
+	return value, end, ok
+}
 
-		return fmt.Sprintf("While loop executed %d times", iterations), nil
-	})
-
-	hd.dsl.Action("foreachLoop", func(args []interface{}) (interface{}, error) {
-		itemVar := strings.TrimPrefix(args[1].(string), "$")
-		listVar := strings.TrimPrefix(args[3].(string), "$")
-		statements := args[5]
+// lookupVariable returns the value of a variable reference without the '$'.
+// Dotted names like "item.id" select fields of objects, or array elements by
+// index, so items of an extracted JSON array can be read field by field.
+func (hd *HTTPDSLv3) lookupVariable(name string) (interface{}, bool) {
+	if value, ok := hd.GetVariable(name); ok {
+		return value, true
+	}
 
-		list, ok := hd.variables[listVar]
+	parts := strings.Split(name, ".")
+	value, ok := hd.GetVariable(parts[0])
+	for _, field := range parts[1:] {
 		if !ok {
-			return nil, fmt.Errorf("list variable $%s not found", listVar)
+			break
 		}
+		value, ok = fieldValue(value, field)
+	}
+	return value, ok
+}
 
-		// Convert to slice if possible
-		items := hd.toSlice(list)
-		if items == nil {
-			return nil, fmt.Errorf("variable $%s is not iterable", listVar)
+// setVariablePath sets a variable. A dotted name like "request.url" assigns a
+// field of an existing object variable; when the parent is not an object the
+// dotted name is set as a plain variable, as before.
+func (hd *HTTPDSLv3) setVariablePath(name string, value interface{}) {
+	parts := strings.Split(name, ".")
+	if len(parts) > 1 {
+		hd.varsLock.Lock()
+		parent, ok := hd.variables[parts[0]].(map[string]interface{})
+		for _, field := range parts[1 : len(parts)-1] {
+			if !ok {
+				break
+			}
+			parent, ok = parent[field].(map[string]interface{})
+		}
+		if ok {
+			parent[parts[len(parts)-1]] = value
 		}
+		hd.varsLock.Unlock()
+		if ok {
+			return
+		}
+	}
+	hd.SetVariable(name, value)
+}
 
-		for i, item := range items {
-			hd.variables[itemVar] = item
-			hd.variables["_index"] = i
-			_, _ = hd.executeStatements(statements)
+// updateVariable applies an arithmetic operator to a number variable and
+// delta, as in increment $n or $n += 2, and stores the result. An undefined
+// or null variable counts as 0.
+func (hd *HTTPDSLv3) updateVariable(name, op string, delta interface{}) (interface{}, error) {
+	current, ok := hd.lookupVariable(name)
+	if !ok || current == nil {
+		current = 0
+	}
+	if _, err := numberArgument(current); err != nil {
+		return nil, fmt.Errorf("cannot update $%s: %s is not a number", name, formatValue(current))
+	}
+	if _, err := numberArgument(delta); err != nil {
+		return nil, fmt.Errorf("cannot update $%s by %s, which is not a number", name, formatValue(delta))
+	}
+	value, err := hd.arithmetic(current, op, delta)
+	if err != nil {
+		return nil, fmt.Errorf("cannot update $%s: %w", name, err)
+	}
+	hd.setVariablePath(name, value)
+	return value, nil
+}
 
-			// Check for break
-			if hd.context["break"] == true {
-				hd.context["break"] = false
+// unsetVariablePath removes a variable, or the field of an object variable
+// for dotted names like user.token
+func (hd *HTTPDSLv3) unsetVariablePath(name string) {
+	parts := strings.Split(name, ".")
+	if len(parts) > 1 {
+		hd.varsLock.Lock()
+		parent, ok := hd.variables[parts[0]].(map[string]interface{})
+		for _, field := range parts[1 : len(parts)-1] {
+			if !ok {
 				break
 			}
+			parent, ok = parent[field].(map[string]interface{})
 		}
-
-		return fmt.Sprintf("Foreach completed for $%s", listVar), nil
-	})
-
-	// Assertions - fixed to work as standalone statements
-	hd.dsl.Rule("assertion", []string{"assert", "assertion_type"}, "doAssertion")
-	hd.dsl.Rule("assertion", []string{"expect", "assertion_type"}, "doAssertion")
-
-	hd.dsl.Rule("assertion_type", []string{"status", "NUMBER"}, "assertStatus")
-	hd.dsl.Rule("assertion_type", []string{"time", "less", "NUMBER", "ms"}, "assertTime")
-	hd.dsl.Rule("assertion_type", []string{"response", "contains", "STRING"}, "assertContains")
-
-	hd.dsl.Action("assertStatus", func(args []interface{}) (interface{}, error) {
-		expectedCode, _ := strconv.Atoi(args[1].(string))
-		actualCode := hd.engine.GetLastStatusCode()
-		if actualCode == expectedCode {
-			return fmt.Sprintf("✓ Status code is %d", expectedCode), nil
+		if ok {
+			delete(parent, parts[len(parts)-1])
 		}
-		return nil, fmt.Errorf("assertion failed: expected status %d, got %d", expectedCode, actualCode)
-	})
-
-	hd.dsl.Action("assertTime", func(args []interface{}) (interface{}, error) {
-		maxTime, _ := strconv.ParseFloat(args[2].(string), 64)
-		actualTime := hd.engine.GetLastResponseTime()
-		if actualTime < maxTime {
-			return fmt.Sprintf("✓ Response time %.2fms < %.2fms", actualTime, maxTime), nil
+		hd.varsLock.Unlock()
+		if ok {
+			return
 		}
-		return nil, fmt.Errorf("assertion failed: response time %.2fms exceeds %.2fms", actualTime, maxTime)
-	})
+	}
+	hd.DeleteVariable(name)
+}
 
-	hd.dsl.Action("assertContains", func(args []interface{}) (interface{}, error) {
-		expected := hd.expandVariables(hd.unquoteString(args[2].(string)))
-		response := hd.engine.GetLastResponse()
-		if strings.Contains(response, expected) {
-			return fmt.Sprintf("✓ Response contains '%s'", expected), nil
+// fieldValue returns a field of an object or an element of an array. Values
+// still held as JSON text are decoded first.
+func fieldValue(value interface{}, field string) (interface{}, bool) {
+	if str, ok := value.(string); ok {
+		if decoded, ok := decodeJSONText(str); ok {
+			value = decoded
 		}
-		return nil, fmt.Errorf("assertion failed: response does not contain '%s'", expected)
-	})
-
-	hd.dsl.Action("doAssertion", func(args []interface{}) (interface{}, error) {
-		return args[1], nil
-	})
-
-	// Utilities
-	hd.dsl.Rule("utility", []string{"wait", "NUMBER", "time_unit"}, "waitCmd")
-	hd.dsl.Rule("utility", []string{"sleep", "NUMBER", "time_unit"}, "waitCmd")
-	hd.dsl.Rule("utility", []string{"log", "STRING"}, "logCmd")
-	hd.dsl.Rule("utility", []string{"debug", "STRING"}, "debugCmd")
-	hd.dsl.Rule("utility", []string{"clear", "cookies"}, "clearCookies")
-	hd.dsl.Rule("utility", []string{"reset"}, "resetCmd")
-	hd.dsl.Rule("utility", []string{"base", "url", "STRING"}, "setBaseURL")
+	}
 
-	hd.dsl.Action("waitCmd", func(args []interface{}) (interface{}, error) {
-		duration, _ := strconv.ParseFloat(args[1].(string), 64)
-		unit := args[2].(string)
-		if unit == "s" {
-			duration = duration * 1000
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result, ok := v[field]
+		return result, ok
+	case []interface{}:
+		if index, err := strconv.Atoi(field); err == nil && index >= 0 && index < len(v) {
+			return v[index], true
 		}
-		hd.engine.Wait(int(duration))
-		return fmt.Sprintf("Waited %.0fms", duration), nil
-	})
-
-	hd.dsl.Action("logCmd", func(args []interface{}) (interface{}, error) {
-		message := hd.expandVariables(hd.unquoteString(args[1].(string)))
-		hd.engine.Log(message)
-		return fmt.Sprintf("Logged: %s", message), nil
-	})
-
-	hd.dsl.Action("debugCmd", func(args []interface{}) (interface{}, error) {
-		message := hd.expandVariables(hd.unquoteString(args[1].(string)))
-		hd.engine.Debug(message)
-		return fmt.Sprintf("Debug: %s", message), nil
-	})
-
-	hd.dsl.Action("clearCookies", func(args []interface{}) (interface{}, error) {
-		hd.engine.ClearCookies()
-		return "Cookies cleared", nil
-	})
+	}
+	return nil, false
+}
 
-	hd.dsl.Action("resetCmd", func(args []interface{}) (interface{}, error) {
-		hd.engine.Reset()
-		hd.variables = make(map[string]interface{})
-		hd.context = make(map[string]interface{})
-		return "Reset complete", nil
-	})
+// decodeJSONText decodes s when it holds a JSON array or object
+func decodeJSONText(s string) (interface{}, bool) {
+	trimmed := strings.TrimSpace(s)
+	if !strings.HasPrefix(trimmed, "[") && !strings.HasPrefix(trimmed, "{") {
+		return nil, false
+	}
+	decoded, err := decodeJSON([]byte(trimmed))
+	if err != nil {
+		return nil, false
+	}
+	return decoded, true
+}
 
-	hd.dsl.Action("setBaseURL", func(args []interface{}) (interface{}, error) {
-		url := hd.expandVariables(hd.unquoteString(args[2].(string)))
-		hd.engine.SetBaseURL(url)
-		return fmt.Sprintf("Base URL set to %s", url), nil
-	})
+// isMissing reports whether a value stands for nothing: nil, which JSON null
+// and failed extractions give, or an empty string
+func isMissing(value interface{}) bool {
+	return value == nil || value == ""
 }
 
-// Helper methods for internal use
+// describeFallback names the value a failed extraction stores
+func describeFallback(value interface{}) string {
+	if value == "" {
+		return "empty"
+	}
+	return formatValue(value)
+}
 
-// unquoteString removes surrounding quotes and processes escape sequences.
-// Handles standard escape sequences like \n, \t, \r, and escaped quotes.
-func (hd *HTTPDSLv3) unquoteString(s string) string {
-	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
-		// Remove quotes and handle escape sequences
-		s = s[1 : len(s)-1]
-		s = strings.ReplaceAll(s, `\"`, `"`)
-		s = strings.ReplaceAll(s, `\\`, `\`)
-		s = strings.ReplaceAll(s, `\n`, "\n")
-		s = strings.ReplaceAll(s, `\t`, "\t")
-		s = strings.ReplaceAll(s, `\r`, "\r")
+// formatValue renders a variable value as text. Arrays and objects are written
+// as JSON so they can be printed or sent on without losing their structure.
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}, []interface{}:
+		return jsonText(value)
+	case float64:
+		return formatNumber(v)
 	}
-	return s
+	return fmt.Sprintf("%v", value)
 }
 
-// expandVariables replaces $variable references with their actual values.
-// Scans the string for $name patterns and substitutes them with variable values.
-// Used throughout the DSL to enable variable interpolation in strings.
-//
-// DEVELOPER GUIDE: Variable System
-// Variables are stored in hd.variables map.
-// They're expanded in strings before execution.
-// To add special variables (like $ARGC), set them during initialization.
-// Variables persist across statements but are cleared on Reset.
-func (hd *HTTPDSLv3) expandVariables(s string) string {
-	// Expand variables in the string
-	result := s
-	for name, value := range hd.variables {
-		placeholder := "$" + name
-		replacement := fmt.Sprintf("%v", value)
-		result = strings.ReplaceAll(result, placeholder, replacement)
+// isIdentifierChar reports whether c can appear in a variable name.
+// Digits are not allowed as the first character.
+func isIdentifierChar(c byte, first bool) bool {
+	switch {
+	case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return true
+	case c >= '0' && c <= '9':
+		return !first
 	}
-	return result
+	return false
 }
 
 // toBool converts various types to boolean.
@@ -1142,6 +3310,8 @@ func (hd *HTTPDSLv3) toBool(v interface{}) bool {
 		return val != "" && val != "false" && val != "0"
 	case int, int64, float64:
 		return val != 0
+	case json.Number:
+		return val != "0"
 	default:
 		return v != nil
 	}
@@ -1158,6 +3328,10 @@ func (hd *HTTPDSLv3) toNumber(v interface{}) float64 {
 		return float64(val)
 	case int64:
 		return float64(val)
+	case json.Number:
+		if num, err := val.Float64(); err == nil {
+			return num
+		}
 	case string:
 		if num, err := strconv.ParseFloat(val, 64); err == nil {
 			return num
@@ -1185,7 +3359,20 @@ func (hd *HTTPDSLv3) toSlice(v interface{}) []interface{} {
 			result[i] = v
 		}
 		return result
+	case map[string]interface{}:
+		// Objects iterate over their keys in sorted order
+		result := make([]interface{}, 0, len(val))
+		for _, key := range sortedKeys(val) {
+			result = append(result, key)
+		}
+		return result
 	case string:
+		// JSON arrays keep their items, objects included
+		if decoded, ok := decodeJSONText(val); ok {
+			if items, ok := decoded.([]interface{}); ok {
+				return items
+			}
+		}
 		// Split by comma for simple lists
 		parts := strings.Split(val, ",")
 		result := make([]interface{}, len(parts))
@@ -1197,33 +3384,108 @@ func (hd *HTTPDSLv3) toSlice(v interface{}) []interface{} {
 	return nil
 }
 
-// executeStatement processes a single DSL statement.
-// It handles both pre-parsed statements and string commands that need parsing.
-// Used internally by the execution engine.
-func (hd *HTTPDSLv3) executeStatement(stmt interface{}) (interface{}, error) {
-	// If the statement is already executed (is the result), return it
-	if stmt == nil {
-		return nil, nil
-	}
+// action registers fn to run in the evaluation pass. While parsing, the
+// grammar only records a deferred call to it (an astNode).
+func (hd *HTTPDSLv3) action(name string, fn dslbuilder.ActionFunc) {
+	hd.actions[name] = fn
+	hd.dsl.Action(name, func(args []interface{}) (interface{}, error) {
+		return &astNode{action: name, args: append([]interface{}(nil), args...)}, nil
+	})
+}
+
+// lazyAction registers an action that receives its arguments unevaluated.
+// Used by constructs that decide what runs and how often, like loops.
+func (hd *HTTPDSLv3) lazyAction(name string, fn dslbuilder.ActionFunc) {
+	hd.action(name, fn)
+	hd.lazy[name] = true
+}
+
+// listAction registers a list building action. It has no side effects, so
+// unlike other actions it runs while parsing.
+func (hd *HTTPDSLv3) listAction(name string, fn dslbuilder.ActionFunc) {
+	hd.lists[name] = fn
+	hd.dsl.Action(name, fn)
+}
 
-	// If it's a string that looks like a command, parse and execute it
-	if cmdStr, ok := stmt.(string); ok {
-		if strings.HasPrefix(cmdStr, "set ") || strings.HasPrefix(cmdStr, "print ") {
-			// This is a command that needs to be executed
-			result, err := hd.ParseWithContext(cmdStr)
+// astNode is a deferred action call built while parsing. Its arguments are
+// kept as parsed: token text, nested nodes, or statement and option lists.
+type astNode struct {
+	action string
+	args   []interface{}
+}
+
+// evaluate executes a value produced by the parser. Deferred nodes run their
+// action, lists are evaluated element by element, and token text is returned as is.
+func (hd *HTTPDSLv3) evaluate(v interface{}) (interface{}, error) {
+	switch node := v.(type) {
+	case *astNode:
+		// Stop between steps once the script's context is done
+		if err := hd.ctx.Err(); err != nil {
+			return nil, err
+		}
+		fn, ok := hd.actions[node.action]
+		if !ok {
+			return nil, fmt.Errorf("unknown action: %s", node.action)
+		}
+		if hd.lazy[node.action] {
+			return fn(node.args)
+		}
+		args := make([]interface{}, len(node.args))
+		for i, arg := range node.args {
+			value, err := hd.evaluate(arg)
 			if err != nil {
 				return nil, err
 			}
-			return result, nil
+			args[i] = value
 		}
+		return fn(args)
+	case []interface{}:
+		values := make([]interface{}, len(node))
+		for i, item := range node {
+			value, err := hd.evaluate(item)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	}
+	return v, nil
+}
+
+// evaluateResults runs the deferred statements held by parse results in order.
+// Each *dslbuilder.Result keeps the tree in AST and gets the value in Output.
+func (hd *HTTPDSLv3) evaluateResults(v interface{}) (interface{}, error) {
+	switch result := v.(type) {
+	case *dslbuilder.Result:
+		output, err := hd.evaluate(result.Output)
+		if err != nil {
+			return nil, err
+		}
+		result.Output = output
+		return result, nil
+	case []interface{}:
+		for _, item := range result {
+			if _, err := hd.evaluateResults(item); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
 	}
+	return hd.evaluate(v)
+}
 
-	// Otherwise return the statement as-is (it's already the result)
-	return stmt, nil
+// executeStatement evaluates a single parsed statement.
+// Used internally by conditionals and loops.
+func (hd *HTTPDSLv3) executeStatement(stmt interface{}) (interface{}, error) {
+	if stmt == nil {
+		return nil, nil
+	}
+	return hd.evaluate(stmt)
 }
 
-// executeStatements processes a list of DSL statements sequentially.
-// It handles control flow (break/continue) and returns the last result.
+// executeStatements evaluates a list of parsed statements sequentially.
+// It stops at break/continue and returns the last result.
 // Used internally for processing multi-statement scripts.
 func (hd *HTTPDSLv3) executeStatements(stmts interface{}) (interface{}, error) {
 	statements, ok := stmts.([]interface{})
@@ -1247,12 +3509,25 @@ func (hd *HTTPDSLv3) executeStatements(stmts interface{}) (interface{}, error) {
 	return lastResult, nil
 }
 
-// evaluateCondition converts a condition result to boolean.
-// Used by control flow statements (if/while) to determine execution path.
-func (hd *HTTPDSLv3) evaluateCondition(cond interface{}) bool {
-	// Re-evaluate the condition (for while loops)
-	// This would need to re-parse the condition in a real implementation
-	return hd.toBool(cond)
+// endIteration clears the continue flag after a loop iteration and reports
+// whether break was requested, clearing it as well.
+func (hd *HTTPDSLv3) endIteration() bool {
+	hd.context["continue"] = false
+	if hd.context["break"] == true {
+		hd.context["break"] = false
+		return true
+	}
+	return false
+}
+
+// evaluateCondition evaluates a parsed condition and converts it to boolean.
+// Conditions are evaluated again on every call, so while loops see variable changes.
+func (hd *HTTPDSLv3) evaluateCondition(cond interface{}) (bool, error) {
+	value, err := hd.evaluate(cond)
+	if err != nil {
+		return false, err
+	}
+	return hd.toBool(value), nil
 }
 
 // Parse processes a single line of DSL input and returns the result.
@@ -1265,15 +3540,7 @@ func (hd *HTTPDSLv3) Parse(input string) (interface{}, error) {
 	// Clear context for new parse
 	hd.context = make(map[string]interface{})
 
-	result, err := hd.dsl.Parse(input)
-	if err != nil {
-		// Provide better error messages
-		if parseErr, ok := err.(*dslbuilder.ParseError); ok {
-			return nil, fmt.Errorf("%s", parseErr.DetailedError())
-		}
-		return nil, err
-	}
-	return result.Output, nil
+	return hd.ParseWithContext(input)
 }
 
 // ParseMultiline parses multiple HTTP DSL statements separated by newlines.
@@ -1291,6 +3558,7 @@ func (hd *HTTPDSLv3) ParseMultiline(input string) ([]interface{}, error) {
 	// Clear context for new parse
 	hd.context = make(map[string]interface{})
 
+	// Every line is parsed before any of them runs
 	results, err := hd.dsl.ParseMultiline(input)
 	if err != nil {
 		// Provide better error messages
@@ -1300,6 +3568,9 @@ func (hd *HTTPDSLv3) ParseMultiline(input string) ([]interface{}, error) {
 		return nil, err
 	}
 
+	if _, err := hd.evaluateResults(results); err != nil {
+		return nil, err
+	}
 	return results, nil
 }
 
@@ -1318,7 +3589,7 @@ func (hd *HTTPDSLv3) ParseAuto(input string) (interface{}, error) {
 		return nil, err
 	}
 
-	return result, nil
+	return hd.evaluateResults(result)
 }
 
 // ParseWithBlocks handles multiline blocks with if/then/endif structures.
@@ -1339,7 +3610,7 @@ func (hd *HTTPDSLv3) ParseWithBlocks(input string) (interface{}, error) {
 		return nil, err
 	}
 
-	return result, nil
+	return hd.evaluateResults(result)
 }
 
 // ParseWithContext parses DSL input without clearing the execution context.
@@ -1347,7 +3618,35 @@ func (hd *HTTPDSLv3) ParseWithBlocks(input string) (interface{}, error) {
 // Primarily used internally for recursive parsing within blocks.
 func (hd *HTTPDSLv3) ParseWithContext(input string) (interface{}, error) {
 	// DO NOT clear context - keep existing variables
-	result, err := hd.dsl.Parse(input)
+	tree, err := hd.ParseOnly(input)
+	kind := stepKind(tree)
+	step := hd.startStep(input, kind)
+	if err != nil {
+		hd.finishStep(step, input, kind, nil, err, 0)
+		return nil, err
+	}
+
+	start := time.Now()
+	result, err := hd.evaluate(tree)
+	if kind == "assertion" {
+		err = hd.withRequestID(err)
+	}
+	hd.finishStep(step, input, kind, result, err, time.Since(start))
+	return result, err
+}
+
+// ParseOnly parses a single statement without executing it and returns the
+// deferred statement tree. Run it later with Execute, possibly several times.
+//
+// Example:
+//
+//	stmt, err := hd.ParseOnly(`GET "https://api.example.com/health"`)
+//	if err != nil {
+//	    return err // syntax error, nothing was sent
+//	}
+//	result, err := hd.Execute(stmt)
+func (hd *HTTPDSLv3) ParseOnly(input string) (interface{}, error) {
+	result, err := hd.dsl.Parse(stripComment(input))
 	if err != nil {
 		// Provide better error messages
 		if parseErr, ok := err.(*dslbuilder.ParseError); ok {
@@ -1358,6 +3657,78 @@ func (hd *HTTPDSLv3) ParseWithContext(input string) (interface{}, error) {
 	return result.Output, nil
 }
 
+// parseRule parses input as one instance of a grammar rule, such as a
+// condition, and returns the deferred tree without executing it.
+func (hd *HTTPDSLv3) parseRule(rule, input string) (interface{}, error) {
+	node, _, syntaxErr := hd.ParseTree(rule, input)
+	if syntaxErr != nil {
+		return nil, syntaxErr
+	}
+	return hd.buildTree(node)
+}
+
+// buildTree converts a parse tree into the deferred form the parser produces
+func (hd *HTTPDSLv3) buildTree(node *SyntaxNode) (interface{}, error) {
+	if node.Rule == "" {
+		return node.Value, nil
+	}
+
+	args := make([]interface{}, len(node.Children))
+	for i, child := range node.Children {
+		arg, err := hd.buildTree(child)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = arg
+	}
+
+	if fn, ok := hd.lists[node.Action]; ok {
+		return fn(args)
+	}
+	return &astNode{action: node.Action, args: args}, nil
+}
+
+// Execute runs a statement tree returned by ParseOnly using the current
+// variables and engine state.
+func (hd *HTTPDSLv3) Execute(stmt interface{}) (interface{}, error) {
+	return hd.evaluate(stmt)
+}
+
+// ExecuteContext is like Execute but stops when ctx is cancelled or its
+// deadline passes, aborting any request or wait in progress.
+func (hd *HTTPDSLv3) ExecuteContext(ctx context.Context, stmt interface{}) (interface{}, error) {
+	return hd.withContext(ctx, func() (interface{}, error) {
+		return hd.evaluate(stmt)
+	})
+}
+
+// ParseContext runs a script like ParseWithBlockSupport, bound to ctx. When
+// ctx is cancelled or its deadline passes, the request or wait in progress is
+// aborted and no further statement runs; the returned error wraps ctx's error.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+//	defer cancel()
+//	_, err := hd.ParseContext(ctx, script)
+//	if errors.Is(err, context.DeadlineExceeded) {
+//	    log.Println("script took too long")
+//	}
+func (hd *HTTPDSLv3) ParseContext(ctx context.Context, script string) (interface{}, error) {
+	return hd.withContext(ctx, func() (interface{}, error) {
+		return hd.ParseWithBlockSupport(script)
+	})
+}
+
+// withContext runs fn with ctx as the execution context and restores the
+// previous one afterwards
+func (hd *HTTPDSLv3) withContext(ctx context.Context, fn func() (interface{}, error)) (interface{}, error) {
+	previous := hd.ctx
+	hd.ctx = ctx
+	defer func() { hd.ctx = previous }()
+	return fn()
+}
+
 // GetEngine returns the underlying HTTP execution engine.
 // The engine handles actual HTTP requests, responses, and network operations.
 func (hd *HTTPDSLv3) GetEngine() *HTTPEngine {
@@ -1373,6 +3744,8 @@ func (hd *HTTPDSLv3) GetEngine() *HTTPEngine {
 //	    fmt.Printf("Username: %v\n", val)
 //	}
 func (hd *HTTPDSLv3) GetVariable(name string) (interface{}, bool) {
+	hd.varsLock.RLock()
+	defer hd.varsLock.RUnlock()
 	val, ok := hd.variables[name]
 	return val, ok
 }
@@ -1385,19 +3758,37 @@ func (hd *HTTPDSLv3) GetVariable(name string) (interface{}, bool) {
 //	hd.SetVariable("baseURL", "https://api.example.com")
 //	hd.SetVariable("timeout", 5000)
 func (hd *HTTPDSLv3) SetVariable(name string, value interface{}) {
+	hd.varsLock.Lock()
+	defer hd.varsLock.Unlock()
 	hd.variables[name] = value
 }
 
+// DeleteVariable removes a variable from the DSL context, so $name is
+// undefined again. Removing an undefined variable does nothing.
+func (hd *HTTPDSLv3) DeleteVariable(name string) {
+	hd.varsLock.Lock()
+	defer hd.varsLock.Unlock()
+	delete(hd.variables, name)
+}
+
 // ClearVariables removes all variables from the DSL context.
 // Useful for resetting state between script executions.
 func (hd *HTTPDSLv3) ClearVariables() {
+	hd.varsLock.Lock()
+	defer hd.varsLock.Unlock()
 	hd.variables = make(map[string]interface{})
 }
 
 // GetVariables returns a copy of all current variables.
 // The returned map can be used for debugging or state inspection.
 func (hd *HTTPDSLv3) GetVariables() map[string]interface{} {
-	return hd.variables
+	hd.varsLock.RLock()
+	defer hd.varsLock.RUnlock()
+	variables := make(map[string]interface{}, len(hd.variables))
+	for name, value := range hd.variables {
+		variables[name] = value
+	}
+	return variables
 }
 
 // ValidateJSON validates that a string contains valid JSON.
@@ -1425,3 +3816,75 @@ func (hd *HTTPDSLv3) MatchesPattern(str, pattern string) bool {
 	matched, _ := regexp.MatchString(pattern, str)
 	return matched
 }
+
+// requestOptions turns the options of a request line into the options of
+// HTTPEngine.Request
+func requestOptions(optionsList []interface{}) map[string]interface{} {
+	options := make(map[string]interface{})
+	headers := make(map[string]string)
+	contentType := ""
+
+	for _, opt := range optionsList {
+		option := opt.(map[string]interface{})
+		optType := option["type"].(string)
+
+		switch optType {
+		case "header":
+			headers[option["key"].(string)] = option["value"].(string)
+		case "body":
+			options["body"] = option["value"]
+		case "json":
+			options["json"] = option["value"]
+		case "binary":
+			options["body"] = option["value"]
+			contentType = option["contentType"].(string)
+		case "auth":
+			authType := option["authType"].(string)
+			if authType == "basic" {
+				options["auth"] = map[string]string{
+					"type": "basic",
+					"user": option["user"].(string),
+					"pass": option["pass"].(string),
+				}
+			} else if authType == "bearer" {
+				options["auth"] = map[string]string{
+					"type":  "bearer",
+					"token": option["token"].(string),
+				}
+			} else if authType == "negotiate" {
+				options["auth"] = map[string]string{
+					"type":      "negotiate",
+					"keytab":    option["keytab"].(string),
+					"principal": option["principal"].(string),
+				}
+			}
+		case "timeout", "connect_timeout", "read_timeout", "tls_timeout", "save_to", "compress",
+			"retry", "backoff", "max_backoff", "jitter", "throttle_download", "throttle_upload", "stream":
+			options[optType] = option["value"]
+		case "timeouts":
+			for _, phase := range option["value"].([]interface{}) {
+				phase := phase.(map[string]interface{})
+				options[phase["type"].(string)] = phase["value"]
+			}
+		}
+	}
+
+	if contentType != "" && !hasHeader(headers, "Content-Type") {
+		headers["Content-Type"] = contentType
+	}
+	if len(headers) > 0 {
+		options["header"] = headers
+	}
+
+	return options
+}
+
+// hasHeader reports whether headers set a header, whatever its case
+func hasHeader(headers map[string]string, name string) bool {
+	for key := range headers {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
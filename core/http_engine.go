@@ -7,6 +7,7 @@ import (
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -14,6 +15,7 @@ import (
 	"net"
 	"net/http"
 	"net/http/cookiejar"
+	"net/http/httptrace"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -23,6 +25,7 @@ import (
 	"sync"
 	"time"
 
+	"golang.org/x/net/http/httpproxy"
 	"golang.org/x/net/proxy"
 )
 
@@ -37,6 +40,28 @@ const (
 	LogVerbose
 )
 
+// logLevelNames are the names of the log levels, in order
+var logLevelNames = []string{"ERROR", "WARN", "INFO", "DEBUG", "VERBOSE"}
+
+// String returns the name of the level, like DEBUG
+func (l LogLevel) String() string {
+	if l < LogError || l > LogVerbose {
+		return fmt.Sprintf("LogLevel(%d)", int(l))
+	}
+	return logLevelNames[l]
+}
+
+// ParseLogLevel returns the level named error, warn, info, debug or verbose,
+// in any case
+func ParseLogLevel(name string) (LogLevel, error) {
+	for level, levelName := range logLevelNames {
+		if strings.EqualFold(name, levelName) {
+			return LogLevel(level), nil
+		}
+	}
+	return LogInfo, fmt.Errorf("unknown log level %q, expected error, warn, info, debug or verbose", name)
+}
+
 // RequestHistory stores request/response pairs
 type RequestHistory struct {
 	Request      *http.Request
@@ -44,45 +69,93 @@ type RequestHistory struct {
 	RequestBody  string
 	ResponseBody string
 	Duration     time.Duration
+	Timing       RequestTiming
 	Timestamp    time.Time
+	RequestID    string        // X-Request-ID header sent, empty when there was none
+	Redirects    []RedirectHop // Redirects followed to the response, in order
+	Reused       bool          // Whether the request reused a kept-alive connection
 }
 
-// RetryPolicy defines retry behavior
-type RetryPolicy struct {
-	MaxRetries     int
-	InitialBackoff time.Duration
-	MaxBackoff     time.Duration
-	Multiplier     float64
-	RetryOn        []int // Status codes to retry on
+// RequestTiming breaks a request down into phases. Phases that did not happen,
+// such as DNS and connect on a reused connection, are zero.
+type RequestTiming struct {
+	DNS      time.Duration // DNS lookup
+	Connect  time.Duration // TCP connect
+	TLS      time.Duration // TLS handshake
+	TTFB     time.Duration // From the start of the request to the first response byte
+	Download time.Duration // From the first response byte to the end of the body
+	Total    time.Duration // Whole request, body included
 }
 
-// HTTPEngine handles HTTP requests and responses
+// RetryPolicy defines retry behavior
+type RetryPolicy struct {
+	MaxRetries        int
+	InitialBackoff    time.Duration
+	MaxBackoff        time.Duration // Longest wait between attempts, 0 for no limit
+	Multiplier        float64
+	Jitter            float64 // Fraction of each wait added or taken at random, 0.2 for ±20%
+	RetryOn           []int   // Status codes to retry on
+	SkipNetworkErrors bool    // Whether requests that got no response, like refused connections or timeouts, fail without retries
+}
+
+// HTTPEngine handles HTTP requests and responses.
+// It is safe for concurrent use: requests can run from several goroutines, and
+// configuration changes only affect requests started after them. The "last
+// response" is whichever response was stored most recently.
 type HTTPEngine struct {
-	client           *http.Client
-	baseURL          string
-	lastResponse     *http.Response
-	lastResponseBody string
-	lastStatusCode   int
-	lastResponseTime float64
-	cookies          *cookiejar.Jar
-	headers          map[string]string
-	debug            bool
-	logs             []string
-	logLevel         LogLevel
-	history          []RequestHistory
-	maxHistory       int
-	retryPolicy      *RetryPolicy
-	proxy            string
-	tlsConfig        *tls.Config
-	requestHooks     []func(*http.Request) error
-	responseHooks    []func(*http.Response) error
-	rateLimit        time.Duration
-	lastRequestTime  time.Time
-	metrics          map[string]interface{}
-	metricsLock      sync.RWMutex
-	sessions         map[string]*Session
-	currentSession   string
-	oauth2Config     *OAuth2Config
+	mu                 sync.RWMutex // Guards every field below except the metrics
+	client             *http.Client
+	baseURL            string
+	lastResponse       *http.Response
+	lastResponseBody   string
+	lastStatusCode     int
+	lastResponseTime   float64
+	lastTiming         RequestTiming
+	cookies            *cookiejar.Jar
+	cookieRecorders    map[*cookiejar.Jar]*cookieRecorder // Recorder wrapping each jar used
+	headers            map[string]string
+	debug              bool
+	logs               []string
+	logLevel           LogLevel
+	history            []RequestHistory
+	maxHistory         int
+	retryPolicy        *RetryPolicy
+	proxy              string
+	tlsConfig          *tls.Config
+	requestHooks       []func(*http.Request) error
+	responseHooks      []func(*http.Response) error
+	rateLimit          time.Duration
+	lastRequestTime    time.Time
+	metrics            map[string]interface{}
+	metricsLock        sync.RWMutex
+	sessions           map[string]*Session
+	currentSession     string
+	oauth2Config       *OAuth2Config
+	resolves           map[string]string          // host:port -> address dialed instead
+	unixSocket         string                     // When set, every connection dials this socket
+	traceIDs           bool                       // Whether requests get generated X-Request-ID and traceparent headers
+	maxResponseSize    int64                      // Bytes of a body kept in memory, 0 for no limit
+	lastResponseSize   int                        // Bytes received in the last body, even when not kept
+	hostPolicy         *hostPolicy                // Hosts requests may reach, nil for any
+	hostHeaders        *hostHeaders               // Headers sent only to some hosts, nil for none
+	redirects          redirectPolicy             // How redirects are followed
+	lastRedirects      []RedirectHop              // Redirects followed by the last request
+	lastConnection     connectionUse              // Connection the last request got
+	requestCompression string                     // Encoding of request bodies, "" to send them as is
+	acceptEncoding     string                     // Accept-Encoding sent, "" for defaultAcceptEncoding
+	lastRawSize        int                        // Bytes of the last body as received, before decoding
+	etags              map[string]validators      // Validators by URL while the ETag cache is on, nil when off
+	chaos              *chaosConfig               // Faults injected into requests, nil for none
+	downloadRate       int64                      // Bytes per second responses are read at, 0 for no limit
+	uploadRate         int64                      // Bytes per second request bodies are sent at, 0 for no limit
+	stream             *responseStream            // Body of the last request sent with the stream option
+	kerberos           kerberosLogins             // Kerberos clients of auth negotiate
+	wireTrace          bool                       // Whether requests and responses are dumped like curl -v
+	wireOutput         io.Writer                  // Where wire traces go, nil for stderr
+	logOutput          io.Writer                  // Where printed log lines and redirects go, nil for stdout
+	outputLock         sync.Mutex                 // Serializes the writes of forks to wireOutput and logOutput
+	responses          map[string]*cachedResponse // Responses by URL while the response cache is on, nil when off
+	lastCacheStatus    string                     // Cache status of the last request, "" when not cached
 }
 
 // Session represents a named HTTP session with its own state
@@ -110,41 +183,106 @@ type OAuth2Config struct {
 // NewHTTPEngine creates a new HTTP engine instance
 func NewHTTPEngine() *HTTPEngine {
 	jar, _ := cookiejar.New(nil)
-	transport := &http.Transport{
+
+	he := &HTTPEngine{
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		cookieRecorders: make(map[*cookiejar.Jar]*cookieRecorder),
+		headers:         make(map[string]string),
+		logs:            make([]string, 0),
+		logLevel:        LogInfo,
+		history:         make([]RequestHistory, 0),
+		maxHistory:      100,
+		metrics:         make(map[string]interface{}),
+		sessions:        make(map[string]*Session),
+		requestHooks:    make([]func(*http.Request) error, 0),
+		responseHooks:   make([]func(*http.Response) error, 0),
+		resolves:        make(map[string]string),
+	}
+	he.client.Transport = he.newTransport()
+	he.useJar(he.client, jar)
+
+	return he
+}
+
+// NewHTTPEngineWithClient creates an engine that sends requests through a copy
+// of client, e.g. one with an instrumented or recording transport. A nil
+// transport is replaced by the engine's default one. The client's jar is kept
+// when it is a *cookiejar.Jar and replaced by a new jar otherwise, since the
+// cookie and session commands work on that type.
+func NewHTTPEngineWithClient(client *http.Client) *HTTPEngine {
+	he := NewHTTPEngine()
+
+	c := *client
+	if c.Transport == nil {
+		c.Transport = he.client.Transport
+	}
+	jar, ok := c.Jar.(*cookiejar.Jar)
+	if !ok {
+		jar = he.cookies
+	}
+	he.useJar(&c, jar)
+	he.client = &c
+
+	return he
+}
+
+// newTransport returns the transport a new engine starts with. It goes
+// through the proxies of the environment, see UseEnvironmentProxy.
+func (he *HTTPEngine) newTransport() *http.Transport {
+	return &http.Transport{
+		Proxy:                 environmentProxy(),
+		DialContext:           he.dialContext,
 		MaxIdleConns:          100,
 		MaxIdleConnsPerHost:   10,
 		IdleConnTimeout:       90 * time.Second,
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
 	}
-
-	return &HTTPEngine{
-		client: &http.Client{
-			Jar:       jar,
-			Timeout:   30 * time.Second,
-			Transport: transport,
-		},
-		cookies:       jar,
-		headers:       make(map[string]string),
-		logs:          make([]string, 0),
-		logLevel:      LogInfo,
-		history:       make([]RequestHistory, 0),
-		maxHistory:    100,
-		metrics:       make(map[string]interface{}),
-		sessions:      make(map[string]*Session),
-		requestHooks:  make([]func(*http.Request) error, 0),
-		responseHooks: make([]func(*http.Response) error, 0),
-	}
 }
 
 // Request performs an HTTP request with the given method, URL, and options
 func (he *HTTPEngine) Request(method, urlStr string, options map[string]interface{}) (interface{}, error) {
+	return he.RequestContext(context.Background(), method, urlStr, options)
+}
+
+// RequestContext is like Request but the request is bound to ctx: cancelling
+// ctx or reaching its deadline aborts the request, including the body read.
+func (he *HTTPEngine) RequestContext(ctx context.Context, method, urlStr string, options map[string]interface{}) (interface{}, error) {
 	// Enforce rate limiting
 	he.enforceRateLimit()
 
+	// Take the settings this request uses so concurrent changes do not race
+	he.mu.RLock()
+	client := he.client
+	baseURL := he.baseURL
+	globalHeaders := make(map[string]string, len(he.headers))
+	for key, value := range he.headers {
+		globalHeaders[key] = value
+	}
+	requestHooks := he.requestHooks
+	responseHooks := he.responseHooks
+	logLevel := he.logLevel
+	traceIDs := he.traceIDs
+	maxResponseSize := he.maxResponseSize
+	policy := he.hostPolicy
+	scopedHeaders := he.hostHeaders
+	redirects := he.redirects
+	compression := he.requestCompression
+	acceptEncoding := he.acceptEncoding
+	chaos := he.chaos
+	downloadRate := he.downloadRate
+	uploadRate := he.uploadRate
+	var dump io.Writer
+	if he.wireTrace {
+		dump = he.wireWriter()
+	}
+	he.mu.RUnlock()
+
 	// Combine with base URL if it's a relative path
-	if he.baseURL != "" && !strings.HasPrefix(urlStr, "http") {
-		urlStr = he.baseURL + urlStr
+	if baseURL != "" && !strings.HasPrefix(urlStr, "http") {
+		urlStr = baseURL + urlStr
 	}
 
 	// Parse the URL
@@ -154,6 +292,20 @@ func (he *HTTPEngine) Request(method, urlStr string, options map[string]interfac
 		return nil, fmt.Errorf("invalid URL: %w", err)
 	}
 
+	if err := policy.checkHost(ctx, parsedURL.Hostname()); err != nil {
+		he.LogError("Request refused: %s", err)
+		return nil, fmt.Errorf("request refused: %w", err)
+	}
+
+	// Validators stored by the ETag cache for this URL
+	cacheKey := parsedURL.String()
+	var cached validators
+	if conditional(method) {
+		he.mu.RLock()
+		cached = he.etags[cacheKey]
+		he.mu.RUnlock()
+	}
+
 	// Create request body
 	var body io.Reader
 	var bodyStr string
@@ -175,8 +327,33 @@ func (he *HTTPEngine) Request(method, urlStr string, options map[string]interfac
 		}
 	}
 
-	// Create the request
-	req, err := http.NewRequest(method, parsedURL.String(), body)
+	// Compress the body, with the request's own compress option first
+	if encoding, ok := options["compress"].(string); ok {
+		compression = encoding
+	}
+	if body == nil {
+		compression = ""
+	}
+	if compression != "" {
+		compressed, err := compressBody([]byte(bodyStr), compression)
+		if err != nil {
+			he.LogError("Failed to compress request body: %s", err)
+			return nil, fmt.Errorf("failed to compress request body: %w", err)
+		}
+		body = bytes.NewReader(compressed)
+	}
+
+	// Create the request. A streamed body is read after this returns, so
+	// its context is released when the stream ends instead.
+	stream, _ := options["stream"].(bool)
+	keepOpen := false
+	ctx, done := requestContext(ctx, options)
+	defer func() {
+		if !keepOpen {
+			done()
+		}
+	}()
+	req, err := http.NewRequestWithContext(ctx, method, parsedURL.String(), body)
 	if err != nil {
 		he.LogError("Failed to create request: %s", err)
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -184,11 +361,19 @@ func (he *HTTPEngine) Request(method, urlStr string, options map[string]interfac
 
 	// Set default headers
 	req.Header.Set("User-Agent", "HTTPDSL/2.0")
+	if acceptEncoding == "" {
+		acceptEncoding = defaultAcceptEncoding
+	}
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	if compression != "" {
+		req.Header.Set("Content-Encoding", compression)
+	}
 
-	// Apply global headers
-	for key, value := range he.headers {
+	// Apply global headers, then those of the host
+	for key, value := range globalHeaders {
 		req.Header.Set(key, value)
 	}
+	scopedHeaders.apply(req)
 
 	// Apply request-specific options
 	if options != nil {
@@ -215,117 +400,651 @@ func (he *HTTPEngine) Request(method, urlStr string, options map[string]interfac
 				req.SetBasicAuth(auth["user"], auth["pass"])
 			} else if auth["type"] == "bearer" {
 				req.Header.Set("Authorization", "Bearer "+auth["token"])
+			} else if auth["type"] == "negotiate" {
+				if err := he.setNegotiateHeader(req, auth); err != nil {
+					he.LogError("Kerberos authentication failed: %s", err)
+					return nil, fmt.Errorf("kerberos authentication failed: %w", err)
+				}
 			}
 		}
+	}
+
+	cached.setConditionalHeaders(req)
 
-		// Timeout
-		if timeout, ok := options["timeout"].(int); ok {
-			he.client.Timeout = time.Duration(timeout) * time.Millisecond
+	// Trace headers come last so a request can still send its own
+	if traceIDs {
+		traceID, traceparent := newTraceContext()
+		if req.Header.Get(requestIDHeader) == "" {
+			req.Header.Set(requestIDHeader, traceID)
+		}
+		if req.Header.Get(traceparentHeader) == "" {
+			req.Header.Set(traceparentHeader, traceparent)
 		}
 	}
+	requestID := req.Header.Get(requestIDHeader)
+
+	// A request timeout applies to this request only. The copy shares the
+	// transport and cookie jar, so connections are still reused.
+	if timeout, ok := options["timeout"].(int); ok && timeout > 0 {
+		scoped := *client
+		scoped.Timeout = time.Duration(timeout) * time.Millisecond
+		client = &scoped
+	} else if stream {
+		// The client timeout would cut a stream short; a stream request
+		// only times out with its own timeout option
+		scoped := *client
+		scoped.Timeout = 0
+		client = &scoped
+	}
+
+	// Redirects are held to the host policy too
+	if policy.active() {
+		scoped := *client
+		scoped.CheckRedirect = policy.checkRedirect(scoped.CheckRedirect)
+		client = &scoped
+	}
+
+	// Redirects to another host do not carry the headers of this one
+	if scopedHeaders != nil {
+		scoped := *client
+		scoped.CheckRedirect = scopedHeaders.checkRedirect(scoped.CheckRedirect)
+		client = &scoped
+	}
+
+	// Redirects follow the redirect policy and those followed are recorded
+	var hops []RedirectHop
+	scoped := *client
+	scoped.CheckRedirect = he.checkRedirect(redirects, &hops, scoped.CheckRedirect)
+	client = &scoped
+
+	// Chaos faults wrap the transport of this request only
+	if chaos.active() {
+		scoped := *client
+		transport := scoped.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		scoped.Transport = &chaosTransport{next: transport, config: chaos, engine: he}
+		client = &scoped
+	}
 
 	// Apply request hooks
-	for _, hook := range he.requestHooks {
+	for _, hook := range requestHooks {
 		if err := hook(req); err != nil {
 			he.LogError("Request hook failed: %s", err)
 			return nil, fmt.Errorf("request hook failed: %w", err)
 		}
 	}
 
+	// A fresh response of the response cache answers without a request
+	if entry, ok := he.cachedFor(cacheKey, req); ok {
+		if logLevel >= LogDebug {
+			he.logRequest(req)
+		}
+		if dump != nil {
+			traceRequest(dump, req)
+		}
+		return he.serveCached(req, entry, bodyStr, dump), nil
+	}
+
 	// Log the request if debug is enabled
-	if he.logLevel >= LogDebug {
+	if logLevel >= LogDebug {
 		he.logRequest(req)
 	}
+	if dump != nil {
+		traceRequest(dump, req)
+	}
+
+	// Throttled bodies count toward the request timeout, as on a slow network
+	var deadline time.Time
+	if client.Timeout > 0 {
+		deadline = time.Now().Add(client.Timeout)
+	}
+	if rate, ok := options["throttle_upload"].(int64); ok {
+		uploadRate = rate
+	}
+	if uploadRate > 0 {
+		throttleRequestBody(ctx, req, uploadRate, deadline)
+	}
 
 	// Perform the request
+	timing := &timingTrace{}
+	reqCtx := httptrace.WithClientTrace(req.Context(), timing.clientTrace())
+	if proxyURL := requestProxy(client, req); proxyURL != nil {
+		reqCtx = context.WithValue(reqCtx, proxyDialKey{}, proxyAddress(proxyURL))
+	}
+	req = req.WithContext(reqCtx)
 	startTime := time.Now()
-	resp, err := he.client.Do(req)
+	timing.start = startTime
+	resp, err := client.Do(req)
 	duration := time.Since(startTime)
-	he.lastResponseTime = float64(duration.Milliseconds())
+	responseTime := float64(duration.Milliseconds())
 
 	if err != nil {
+		he.mu.Lock()
+		he.lastResponseTime = responseTime
+		he.mu.Unlock()
+		he.forgetLastRequest()
+		err = timeoutCause(ctx, err)
+		if proxyURL := proxyFor(client, req); proxyURL != "" {
+			err = fmt.Errorf("%w (via proxy %s)", err, proxyURL)
+		}
 		he.LogError("Request failed: %s", err)
-		return nil, fmt.Errorf("request failed: %w", err)
+		if dump != nil {
+			fmt.Fprintf(dump, "* %s\n", err)
+		}
+		if requestID != "" {
+			err = fmt.Errorf("request failed (request id %s): %w", requestID, err)
+		} else {
+			err = fmt.Errorf("request failed: %w", err)
+		}
+		var denied *policyError
+		if ctx.Err() == nil && !errors.As(err, &denied) {
+			err = &networkError{err}
+		}
+		return nil, err
 	}
-	defer resp.Body.Close()
+	defer func() {
+		if !keepOpen {
+			resp.Body.Close()
+		}
+	}()
 
 	// Apply response hooks
-	for _, hook := range he.responseHooks {
+	for _, hook := range responseHooks {
 		if err := hook(resp); err != nil {
+			he.forgetLastRequest()
 			he.LogError("Response hook failed: %s", err)
 			return nil, fmt.Errorf("response hook failed: %w", err)
 		}
 	}
 
-	// Read response body
-	bodyBytes, err := io.ReadAll(resp.Body)
+	// Read response body, or stream it to the file of the "save_to" option
+	// without keeping it. gzip, deflate and br bodies are decoded; raw counts
+	// the bytes as received.
+	var bodyBytes []byte
+	var size int
+	truncated := false
+	saveTo, _ := options["save_to"].(string)
+	var wire io.Reader = resp.Body
+	if rate, ok := options["throttle_download"].(int64); ok {
+		downloadRate = rate
+	}
+	if downloadRate > 0 {
+		wire = &throttledReader{ctx: ctx, r: resp.Body, rate: downloadRate, deadline: deadline}
+	}
+	raw := &countingReader{r: wire}
+	rawSize := 0
+	if stream {
+		// The body is read in the background as it arrives, for assert
+		// stream receives, and stays out of the last response
+		responses := newResponseStream(maxResponseSize, done)
+		go responses.read(resp.Body, raw, resp.Header.Get("Content-Encoding"))
+		he.setStream(responses)
+		keepOpen = true
+	} else {
+		var decoded io.Reader
+		decoded, err = decodeBody(raw, resp.Header.Get("Content-Encoding"))
+		if err != nil {
+			err = fmt.Errorf("decoding %s body: %w", resp.Header.Get("Content-Encoding"), err)
+		} else if saveTo != "" {
+			written, saveErr := saveBody(decoded, saveTo)
+			size, err = int(written), saveErr
+		} else {
+			bodyBytes, truncated, err = readBody(decoded, maxResponseSize)
+			size = len(bodyBytes)
+		}
+		rawSize = int(raw.n)
+	}
 	if err != nil {
+		he.forgetLastRequest()
+		err = timeoutCause(ctx, err)
 		he.LogError("Failed to read response: %s", err)
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
+	if truncated {
+		he.LogWarn("Response of %s %s truncated at %d bytes", method, urlStr, maxResponseSize)
+		bodyBytes = append(bodyBytes, fmt.Sprintf(truncationMarker, maxResponseSize)...)
+	}
 
-	// Store response data
+	phases := timing.finish()
+	connection := timing.connection()
+
+	// Store response data and add it to history
+	he.mu.Lock()
 	he.lastResponse = resp
 	he.lastResponseBody = string(bodyBytes)
+	he.lastResponseSize = size
+	he.lastRawSize = rawSize
+	if conditional(method) {
+		he.storeValidators(cacheKey, resp)
+	}
+	he.storeResponse(cacheKey, req, resp, bodyBytes, rawSize, !stream && saveTo == "" && !truncated)
+	cacheStatus := he.lastCacheStatus
 	he.lastStatusCode = resp.StatusCode
-
-	// Add to history
-	he.addToHistory(req, resp, bodyStr, string(bodyBytes), duration)
+	he.lastResponseTime = responseTime
+	he.lastTiming = phases
+	he.lastRedirects = hops
+	he.lastConnection = connection
+	he.addToHistory(req, resp, bodyStr, string(bodyBytes), duration, phases, hops, connection.reused)
+	he.mu.Unlock()
 
 	// Record metrics
 	he.RecordMetric("last_request_duration_ms", duration.Milliseconds())
 	he.RecordMetric("last_status_code", resp.StatusCode)
-	he.RecordMetric("last_response_size", len(bodyBytes))
+	he.RecordMetric("last_response_size", size)
+	he.RecordMetric("last_raw_response_size", rawSize)
+	he.RecordMetric("last_ttfb_ms", phases.TTFB.Milliseconds())
+	he.recordConnection(connection)
 
 	// Log the response if debug is enabled
-	if he.logLevel >= LogDebug {
+	if logLevel >= LogDebug {
 		he.logResponse(resp, string(bodyBytes))
 	}
+	if dump != nil {
+		traceResponse(dump, resp, bodyBytes, stream || saveTo != "")
+	}
 
 	he.LogInfo("%s %s - Status: %d, Time: %.2fms, Size: %d bytes",
-		method, urlStr, resp.StatusCode, he.lastResponseTime, len(bodyBytes))
+		method, urlStr, resp.StatusCode, responseTime, size)
 
 	// Return response data
-	return map[string]interface{}{
-		"status":  resp.StatusCode,
-		"body":    string(bodyBytes),
-		"headers": resp.Header,
-		"time":    he.lastResponseTime,
-		"size":    len(bodyBytes),
-	}, nil
+	result := map[string]interface{}{
+		"method":   method,
+		"url":      urlStr,
+		"status":   resp.StatusCode,
+		"body":     string(bodyBytes),
+		"headers":  resp.Header,
+		"time":     responseTime,
+		"size":     size,
+		"raw_size": rawSize,
+		"timing":   phases,
+	}
+	if truncated {
+		result["truncated"] = true
+	}
+	if saveTo != "" {
+		result["saved_to"] = saveTo
+	}
+	if stream {
+		result["stream"] = true
+	}
+	if requestID != "" {
+		result["request_id"] = requestID
+	}
+	if cacheStatus != "" {
+		result["cache"] = cacheStatus
+	}
+	if len(hops) > 0 {
+		result["redirects"] = redirectValues(hops)
+	}
+	return result, nil
+}
+
+// timingTrace records the phases of one request through httptrace. Hooks can
+// run on transport goroutines, so the timestamps are guarded by a lock.
+type timingTrace struct {
+	mu                        sync.Mutex
+	start                     time.Time
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	firstByte                 time.Time
+	conn                      connectionUse
+}
+
+// clientTrace returns the hooks that fill in the timestamps
+func (t *timingTrace) clientTrace() *httptrace.ClientTrace {
+	mark := func(field *time.Time) {
+		t.mu.Lock()
+		*field = time.Now()
+		t.mu.Unlock()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { mark(&t.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { mark(&t.dnsDone) },
+		ConnectStart:         func(string, string) { mark(&t.connectStart) },
+		ConnectDone:          func(string, string, error) { mark(&t.connectDone) },
+		TLSHandshakeStart:    func() { mark(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { mark(&t.tlsDone) },
+		GotFirstResponseByte: func() { mark(&t.firstByte) },
+		GotConn:              t.gotConn,
+	}
+}
+
+// finish computes the phase durations once the body has been read
+func (t *timingTrace) finish() RequestTiming {
+	end := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	between := func(from, to time.Time) time.Duration {
+		if from.IsZero() || to.IsZero() {
+			return 0
+		}
+		return to.Sub(from)
+	}
+	return RequestTiming{
+		DNS:      between(t.dnsStart, t.dnsDone),
+		Connect:  between(t.connectStart, t.connectDone),
+		TLS:      between(t.tlsStart, t.tlsDone),
+		TTFB:     between(t.start, t.firstByte),
+		Download: between(t.firstByte, end),
+		Total:    end.Sub(t.start),
+	}
+}
+
+// requestContext returns the context for a single request. The
+// "connect_timeout" option bounds the time to get a connection and
+// "read_timeout" the time from the request being sent until the response
+// body is read, while "tls_timeout" bounds the TLS handshake alone. The
+// returned function releases the timers.
+func requestContext(parent context.Context, options map[string]interface{}) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+	connect, _ := options["connect_timeout"].(int)
+	read, _ := options["read_timeout"].(int)
+	handshake, _ := options["tls_timeout"].(int)
+	if connect <= 0 && read <= 0 && handshake <= 0 {
+		return ctx, func() { cancel(nil) }
+	}
+
+	var mu sync.Mutex
+	var timers []*time.Timer
+	after := func(ms int, kind string) *time.Timer {
+		mu.Lock()
+		defer mu.Unlock()
+		timer := time.AfterFunc(time.Duration(ms)*time.Millisecond, func() {
+			cancel(fmt.Errorf("%s timeout after %dms", kind, ms))
+		})
+		timers = append(timers, timer)
+		return timer
+	}
+
+	trace := &httptrace.ClientTrace{}
+	if connect > 0 {
+		var connectTimer *time.Timer
+		trace.GetConn = func(string) {
+			connectTimer = after(connect, "connect")
+		}
+		trace.GotConn = func(httptrace.GotConnInfo) {
+			if connectTimer != nil {
+				connectTimer.Stop()
+			}
+		}
+	}
+	if handshake > 0 {
+		var handshakeTimer *time.Timer
+		trace.TLSHandshakeStart = func() {
+			handshakeTimer = after(handshake, "tls handshake")
+		}
+		trace.TLSHandshakeDone = func(tls.ConnectionState, error) {
+			if handshakeTimer != nil {
+				handshakeTimer.Stop()
+			}
+		}
+	}
+	if read > 0 {
+		trace.WroteRequest = func(httptrace.WroteRequestInfo) {
+			after(read, "read")
+		}
+	}
+
+	return httptrace.WithClientTrace(ctx, trace), func() {
+		mu.Lock()
+		for _, timer := range timers {
+			timer.Stop()
+		}
+		mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// timeoutCause replaces a cancellation error with the timeout that caused it
+func timeoutCause(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); cause != nil && cause != context.Canceled && cause != context.DeadlineExceeded {
+		return cause
+	}
+	return err
 }
 
 // Extract extracts data from the last response using the specified method
 func (he *HTTPEngine) Extract(extractType, pattern string) interface{} {
+	he.mu.RLock()
+	lastResponse := he.lastResponse
+	body := he.lastResponseBody
+	size := he.lastResponseSize
+	rawSize := he.lastRawSize
+	statusCode := he.lastStatusCode
+	responseTime := he.lastResponseTime
+	redirects := he.lastRedirects
+	he.mu.RUnlock()
+
 	switch extractType {
 	case "status":
-		return he.lastStatusCode
+		return statusCode
+
+	case "time":
+		// Response time in milliseconds
+		return responseTime
+
+	case "size":
+		// Response body size in bytes, without a truncation marker; a body
+		// saved to a file counts the bytes written
+		return size
+
+	case "raw_size":
+		// Body size as received, before gzip, deflate or br decoding
+		return rawSize
+
+	case "body":
+		// The whole response body, to keep it after the next request
+		return body
+
+	case "redirects":
+		// Redirects followed by the last request, as objects with method,
+		// url, status and location
+		return redirectValues(redirects)
+
+	case "redirects_count":
+		return len(redirects)
 
 	case "header":
-		if he.lastResponse != nil {
-			return he.lastResponse.Header.Get(pattern)
+		if lastResponse != nil {
+			return lastResponse.Header.Get(pattern)
+		}
+
+	case "link":
+		// Target of the first Link header entry with the rel in pattern
+		if lastResponse != nil {
+			if targets := linksByRel(lastResponse, pattern); len(targets) > 0 {
+				return targets[0]
+			}
 		}
 
+	case "headers":
+		// All headers by canonical name, repeated values joined with ", "
+		headers := make(map[string]interface{})
+		if lastResponse != nil {
+			for name, values := range lastResponse.Header {
+				headers[name] = strings.Join(values, ", ")
+			}
+		}
+		return headers
+
 	case "jsonpath":
-		return he.extractJSONPath(pattern)
+		return extractJSONPath(body, pattern)
 
 	case "xpath":
 		// Simplified XPath-like extraction for demonstration
-		return he.extractXPath(pattern)
+		return extractXPath(body, pattern)
 
 	case "regex":
-		return he.extractRegex(pattern)
+		return extractRegex(body, pattern)
+
+	case "csrf":
+		return he.extractCSRF(lastResponse, body)
+
+	case "links":
+		// Absolute URLs of the href and src attributes of an HTML body
+		if lastResponse != nil && lastResponse.Request != nil {
+			return extractLinks(body, lastResponse.Request.URL)
+		}
+		return []interface{}{}
 	}
 
 	return nil
 }
 
-// extractJSONPath extracts data using a simple JSON path
-func (he *HTTPEngine) extractJSONPath(path string) interface{} {
-	var data interface{}
-	if err := json.Unmarshal([]byte(he.lastResponseBody), &data); err != nil {
+// ExtractAll extracts every match from the last response instead of only the
+// first one. Jsonpath wildcards ([*] and .*) collect one value per element,
+// regex and xpath return every match, header returns every value sent for that
+// name and link every link with the relation type. The result is empty, never nil, when nothing matched.
+func (he *HTTPEngine) ExtractAll(extractType, pattern string) []interface{} {
+	he.mu.RLock()
+	lastResponse := he.lastResponse
+	body := he.lastResponseBody
+	statusCode := he.lastStatusCode
+	he.mu.RUnlock()
+
+	matches := []interface{}{}
+
+	switch extractType {
+	case "status":
+		matches = append(matches, statusCode)
+
+	case "header":
+		if lastResponse != nil {
+			for _, value := range lastResponse.Header.Values(pattern) {
+				matches = append(matches, value)
+			}
+		}
+
+	case "link":
+		if lastResponse != nil {
+			for _, target := range linksByRel(lastResponse, pattern) {
+				matches = append(matches, target)
+			}
+		}
+
+	case "jsonpath":
+		if strings.HasPrefix(pattern, "$[?(") {
+			switch result := extractJSONPath(body, pattern).(type) {
+			case nil:
+			case []interface{}:
+				matches = append(matches, result...)
+			default:
+				matches = append(matches, result)
+			}
+			break
+		}
+		if data, err := decodeJSON([]byte(body)); err == nil {
+			matches = append(matches, jsonPathAll(data, jsonPathSegments(pattern))...)
+		}
+
+	case "xpath":
+		if strings.HasPrefix(pattern, "//") {
+			tagName := strings.TrimPrefix(pattern, "//")
+			if strings.Contains(tagName, "/") {
+				tagName = tagName[:strings.Index(tagName, "/")]
+			}
+			tagName = regexp.QuoteMeta(tagName)
+			re := regexp.MustCompile(fmt.Sprintf("<%s[^>]*>(.*?)</%s>", tagName, tagName))
+			matches = append(matches, regexMatches(re, body)...)
+		}
+
+	case "regex":
+		if re, err := regexp.Compile(pattern); err == nil {
+			matches = append(matches, regexMatches(re, body)...)
+		}
+	}
+
+	return matches
+}
+
+// regexMatches returns the first capturing group of every match, or the whole
+// match when the pattern has no groups
+func regexMatches(re *regexp.Regexp, text string) []interface{} {
+	var matches []interface{}
+	for _, match := range re.FindAllStringSubmatch(text, -1) {
+		if len(match) > 1 {
+			matches = append(matches, match[1])
+		} else {
+			matches = append(matches, match[0])
+		}
+	}
+	return matches
+}
+
+// jsonPathSegments splits a path like "$.users[*].email" into the steps
+// "users", "*" and "email". Array indexes become steps of their own.
+func jsonPathSegments(path string) []string {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	var segments []string
+	for _, part := range strings.Split(path, ".") {
+		for part != "" {
+			open := strings.Index(part, "[")
+			if open < 0 {
+				segments = append(segments, part)
+				break
+			}
+			if open > 0 {
+				segments = append(segments, part[:open])
+			}
+			closing := strings.Index(part[open:], "]")
+			if closing < 0 {
+				segments = append(segments, part[open:])
+				break
+			}
+			segments = append(segments, part[open+1:open+closing])
+			part = part[open+closing+1:]
+		}
+	}
+	return segments
+}
+
+// jsonPathAll returns every value reached by following segments from data.
+// A "*" step fans out over all elements of an array or values of an object.
+func jsonPathAll(data interface{}, segments []string) []interface{} {
+	if len(segments) == 0 {
+		return []interface{}{data}
+	}
+
+	step, rest := segments[0], segments[1:]
+	var results []interface{}
+
+	switch current := data.(type) {
+	case []interface{}:
+		if step == "*" {
+			for _, item := range current {
+				results = append(results, jsonPathAll(item, rest)...)
+			}
+		} else if index, err := strconv.Atoi(step); err == nil && index >= 0 && index < len(current) {
+			results = jsonPathAll(current[index], rest)
+		}
+	case map[string]interface{}:
+		if step == "*" {
+			for _, key := range sortedKeys(current) {
+				results = append(results, jsonPathAll(current[key], rest)...)
+			}
+		} else if value, ok := current[step]; ok {
+			results = jsonPathAll(value, rest)
+		}
+	}
+
+	return results
+}
+
+// extractJSONPath extracts data from a JSON body using a simple JSON path
+func extractJSONPath(body, path string) interface{} {
+	data, err := decodeJSON([]byte(body))
+	if err != nil {
 		return nil
 	}
+	return lookupJSONPath(data, path)
+}
+
+// lookupJSONPath follows a simple JSON path through decoded data
+func lookupJSONPath(data interface{}, path string) interface{} {
 
 	// Handle array at root with filter (e.g., "$[?(@.userId == 1)].title")
 	if strings.HasPrefix(path, "$[?(@.") {
@@ -417,6 +1136,11 @@ func (he *HTTPEngine) extractJSONPath(path string) interface{} {
 		return nil
 	}
 
+	// Wildcards can match several values, which are returned as an array
+	if strings.Contains(path, "*") {
+		return jsonPathAll(data, jsonPathSegments(path))
+	}
+
 	// Handle array at root (e.g., "$[0].id")
 	if strings.HasPrefix(path, "$[") {
 		indexEnd := strings.Index(path, "]")
@@ -428,13 +1152,8 @@ func (he *HTTPEngine) extractJSONPath(path string) interface{} {
 					current := arr[index]
 					// Check if there's more path after the array index
 					if indexEnd+1 < len(path) && path[indexEnd+1] == '.' {
-						remainingPath := "$" + path[indexEnd+1:]
 						// Recursively extract from the array element
-						he.lastResponseBody = mustMarshalJSON(current)
-						result := he.extractJSONPath(remainingPath)
-						// Restore original response body
-						he.lastResponseBody = mustMarshalJSON(data)
-						return result
+						return lookupJSONPath(current, "$"+path[indexEnd+1:])
 					}
 					return current
 				}
@@ -484,7 +1203,7 @@ func mustMarshalJSON(v interface{}) string {
 }
 
 // extractXPath extracts data using a simplified XPath-like syntax
-func (he *HTTPEngine) extractXPath(path string) interface{} {
+func extractXPath(body, path string) interface{} {
 	// This is a simplified implementation for demonstration
 	// In a real implementation, you'd use a proper HTML/XML parser
 
@@ -495,9 +1214,10 @@ func (he *HTTPEngine) extractXPath(path string) interface{} {
 			tagName = tagName[:strings.Index(tagName, "/")]
 		}
 
+		tagName = regexp.QuoteMeta(tagName)
 		pattern := fmt.Sprintf("<%s[^>]*>(.*?)</%s>", tagName, tagName)
 		re := regexp.MustCompile(pattern)
-		matches := re.FindStringSubmatch(he.lastResponseBody)
+		matches := re.FindStringSubmatch(body)
 		if len(matches) > 1 {
 			return matches[1]
 		}
@@ -507,13 +1227,13 @@ func (he *HTTPEngine) extractXPath(path string) interface{} {
 }
 
 // extractRegex extracts data using a regular expression
-func (he *HTTPEngine) extractRegex(pattern string) interface{} {
+func extractRegex(body, pattern string) interface{} {
 	re, err := regexp.Compile(pattern)
 	if err != nil {
 		return nil
 	}
 
-	matches := re.FindStringSubmatch(he.lastResponseBody)
+	matches := re.FindStringSubmatch(body)
 	if len(matches) > 1 {
 		return matches[1] // Return first capturing group
 	} else if len(matches) == 1 {
@@ -525,9 +1245,27 @@ func (he *HTTPEngine) extractRegex(pattern string) interface{} {
 
 // Compare performs a comparison operation
 func (he *HTTPEngine) Compare(left interface{}, op string, right interface{}) bool {
+	// Integers compare exactly, even beyond the precision of float64
+	if cmp, ok := compareIntegers(left, right); ok {
+		switch op {
+		case "==":
+			return cmp == 0
+		case "!=":
+			return cmp != 0
+		case ">":
+			return cmp > 0
+		case ">=":
+			return cmp >= 0
+		case "<":
+			return cmp < 0
+		case "<=":
+			return cmp <= 0
+		}
+	}
+
 	// Convert to strings for comparison
-	leftStr := fmt.Sprintf("%v", left)
-	rightStr := fmt.Sprintf("%v", right)
+	leftStr := formatValue(left)
+	rightStr := formatValue(right)
 
 	// Try numeric comparison first
 	leftNum, leftErr := strconv.ParseFloat(leftStr, 64)
@@ -584,44 +1322,114 @@ func (he *HTTPEngine) Wait(milliseconds int) {
 	time.Sleep(time.Duration(milliseconds) * time.Millisecond)
 }
 
+// WaitContext pauses like Wait but returns early with ctx's error when ctx is
+// cancelled first
+func (he *HTTPEngine) WaitContext(ctx context.Context, milliseconds int) error {
+	timer := time.NewTimer(time.Duration(milliseconds) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Log adds a message to the log
 func (he *HTTPEngine) Log(message string) {
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
 	logEntry := fmt.Sprintf("[%s] %s", timestamp, message)
+	he.mu.Lock()
 	he.logs = append(he.logs, logEntry)
-	if he.debug {
+	debug := he.debug
+	he.mu.Unlock()
+	if debug {
 		fmt.Println(logEntry)
 	}
 }
 
 // Debug adds a debug message to the log
 func (he *HTTPEngine) Debug(message string) {
-	if he.debug {
+	he.mu.Lock()
+	debug := he.debug
+	if debug {
 		timestamp := time.Now().Format("2006-01-02 15:04:05")
-		debugEntry := fmt.Sprintf("[%s] DEBUG: %s", timestamp, message)
-		he.logs = append(he.logs, debugEntry)
-		fmt.Println(debugEntry)
+		message = fmt.Sprintf("[%s] DEBUG: %s", timestamp, message)
+		he.logs = append(he.logs, message)
+	}
+	he.mu.Unlock()
+	if debug {
+		fmt.Println(message)
 	}
 }
 
 // ClearCookies clears all cookies
 func (he *HTTPEngine) ClearCookies() {
 	jar, _ := cookiejar.New(nil)
-	he.cookies = jar
-	he.client.Jar = jar
+	he.updateClient(func(client *http.Client) {
+		he.useJar(client, jar)
+	})
 }
 
 // Reset resets the engine to its initial state
 func (he *HTTPEngine) Reset() {
-	he.ClearCookies()
-	he.headers = make(map[string]string)
-	he.baseURL = ""
-	he.lastResponse = nil
-	he.lastResponseBody = ""
-	he.lastStatusCode = 0
-	he.lastResponseTime = 0
-	he.logs = make([]string, 0)
-	he.client.Timeout = 30 * time.Second
+	he.CloseStream()
+	jar, _ := cookiejar.New(nil)
+	he.updateClient(func(client *http.Client) {
+		he.useJar(client, jar)
+		client.Timeout = 30 * time.Second
+		he.headers = make(map[string]string)
+		he.hostHeaders = nil
+		he.redirects = redirectPolicy{}
+		he.lastRedirects = nil
+		he.lastConnection = connectionUse{}
+		he.baseURL = ""
+		he.lastResponse = nil
+		he.lastResponseBody = ""
+		he.lastResponseSize = 0
+		he.lastRawSize = 0
+		he.lastStatusCode = 0
+		he.lastResponseTime = 0
+		he.lastTiming = RequestTiming{}
+		he.lastCacheStatus = ""
+		he.logs = make([]string, 0)
+	})
+}
+
+// updateClient replaces the client with a modified copy while holding the
+// lock, so requests already in flight keep the client they started with
+func (he *HTTPEngine) updateClient(fn func(client *http.Client)) {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	client := *he.client
+	fn(&client)
+	he.client = &client
+}
+
+// updateTransport is like updateClient for transport settings. The cloned
+// transport starts with an empty connection pool.
+func (he *HTTPEngine) updateTransport(fn func(transport *http.Transport)) {
+	he.updateClient(func(client *http.Client) {
+		if transport, ok := client.Transport.(*http.Transport); ok {
+			transport = transport.Clone()
+			fn(transport)
+			client.Transport = transport
+		}
+	})
+}
+
+// SetTransport replaces the round tripper that sends requests, e.g. with an
+// instrumented or recording transport, or one that serves an http.Handler in
+// memory. Proxy, TLS, resolve and unix socket settings configure the engine's
+// own *http.Transport and have no effect on other round trippers. A nil rt
+// restores the default transport.
+func (he *HTTPEngine) SetTransport(rt http.RoundTripper) {
+	if rt == nil {
+		rt = he.newTransport()
+	}
+	he.updateClient(func(client *http.Client) {
+		client.Transport = rt
+	})
 }
 
 // SetBaseURL sets the base URL for relative requests
@@ -629,79 +1437,113 @@ func (he *HTTPEngine) SetBaseURL(url string) {
 	if !strings.HasSuffix(url, "/") {
 		url += "/"
 	}
+	he.mu.Lock()
 	he.baseURL = url
+	he.mu.Unlock()
 }
 
 // GetLastStatusCode returns the status code of the last response
 func (he *HTTPEngine) GetLastStatusCode() int {
+	he.mu.RLock()
+	defer he.mu.RUnlock()
 	return he.lastStatusCode
 }
 
 // GetLastResponseTime returns the response time of the last request in milliseconds
 func (he *HTTPEngine) GetLastResponseTime() float64 {
+	he.mu.RLock()
+	defer he.mu.RUnlock()
 	return he.lastResponseTime
 }
 
+// GetLastTiming returns the phase breakdown of the last request
+func (he *HTTPEngine) GetLastTiming() RequestTiming {
+	he.mu.RLock()
+	defer he.mu.RUnlock()
+	return he.lastTiming
+}
+
 // GetLastResponse returns the body of the last response
 func (he *HTTPEngine) GetLastResponse() string {
+	he.mu.RLock()
+	defer he.mu.RUnlock()
 	return he.lastResponseBody
 }
 
 // SetHeader sets a global header for all requests
 func (he *HTTPEngine) SetHeader(key, value string) {
+	he.mu.Lock()
 	he.headers[key] = value
+	he.mu.Unlock()
+}
+
+// RemoveHeader removes a global header
+func (he *HTTPEngine) RemoveHeader(key string) {
+	he.mu.Lock()
+	delete(he.headers, key)
+	he.mu.Unlock()
 }
 
 // GetHeader gets a global header value
 func (he *HTTPEngine) GetHeader(key string) string {
+	he.mu.RLock()
+	defer he.mu.RUnlock()
 	return he.headers[key]
 }
 
 // SetDebug enables or disables debug mode
 func (he *HTTPEngine) SetDebug(enabled bool) {
+	he.mu.Lock()
 	he.debug = enabled
+	he.mu.Unlock()
 }
 
-// GetLogs returns all logged messages
+// GetLogs returns a copy of all logged messages
 func (he *HTTPEngine) GetLogs() []string {
-	return he.logs
+	he.mu.RLock()
+	defer he.mu.RUnlock()
+	return append([]string(nil), he.logs...)
 }
 
-// logRequest logs request details
+// logRequest logs request details at LogDebug
 func (he *HTTPEngine) logRequest(req *http.Request) {
-	he.Debug(fmt.Sprintf("Request: %s %s", req.Method, req.URL.String()))
+	he.LogDebug("Request: %s %s", req.Method, req.URL.String())
 	for key, values := range req.Header {
 		for _, value := range values {
-			he.Debug(fmt.Sprintf("  Header: %s: %s", key, value))
+			he.LogDebug("  Header: %s: %s", key, value)
 		}
 	}
-	if req.Body != nil {
-		bodyBytes, _ := io.ReadAll(req.Body)
-		req.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-		if len(bodyBytes) > 0 {
-			he.Debug(fmt.Sprintf("  Body: %s", string(bodyBytes)))
-		}
+	if bodyBytes := peekRequestBody(req); len(bodyBytes) > 0 {
+		he.LogDebug("  Body: %s", string(bodyBytes))
 	}
 }
 
-// logResponse logs response details
+// peekRequestBody returns the body of req, leaving it to be sent
+func peekRequestBody(req *http.Request) []byte {
+	if req.Body == nil {
+		return nil
+	}
+	bodyBytes, _ := io.ReadAll(req.Body)
+	req.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+	return bodyBytes
+}
+
+// logResponse logs response details at LogDebug
 func (he *HTTPEngine) logResponse(resp *http.Response, body string) {
-	he.Debug(fmt.Sprintf("Response: %d %s", resp.StatusCode, resp.Status))
+	he.LogDebug("Response: %s", resp.Status)
 	for key, values := range resp.Header {
 		for _, value := range values {
-			he.Debug(fmt.Sprintf("  Header: %s: %s", key, value))
+			he.LogDebug("  Header: %s: %s", key, value)
 		}
 	}
-	if len(body) > 500 {
-		he.Debug(fmt.Sprintf("  Body: %s... (truncated)", body[:500]))
-	} else {
-		he.Debug(fmt.Sprintf("  Body: %s", body))
-	}
+	he.LogDebug("  Body: %s", body)
 }
 
 // SetTimeout sets the client timeout
 func (he *HTTPEngine) SetTimeout(seconds int) {
-	he.client.Timeout = time.Duration(seconds) * time.Second
+	he.updateClient(func(client *http.Client) {
+		client.Timeout = time.Duration(seconds) * time.Second
+	})
 }
 
 // AddCookie adds a cookie to the jar
@@ -717,7 +1559,7 @@ func (he *HTTPEngine) AddCookie(urlStr, name, value string) error {
 		Path:  "/",
 	}
 
-	he.cookies.SetCookies(u, []*http.Cookie{cookie})
+	he.cookieJar().SetCookies(u, []*http.Cookie{cookie})
 	return nil
 }
 
@@ -727,7 +1569,14 @@ func (he *HTTPEngine) GetCookies(urlStr string) ([]*http.Cookie, error) {
 	if err != nil {
 		return nil, err
 	}
-	return he.cookies.Cookies(u), nil
+	return he.cookieJar().Cookies(u), nil
+}
+
+// cookieJar returns the jar of the current session
+func (he *HTTPEngine) cookieJar() *cookieRecorder {
+	he.mu.RLock()
+	defer he.mu.RUnlock()
+	return he.cookieRecorders[he.cookies]
 }
 
 // SetBasicAuth sets basic authentication credentials
@@ -750,7 +1599,7 @@ func (he *HTTPEngine) SetCookie(urlStr string, cookie *http.Cookie) error {
 	if err != nil {
 		return err
 	}
-	he.cookies.SetCookies(u, []*http.Cookie{cookie})
+	he.cookieJar().SetCookies(u, []*http.Cookie{cookie})
 	return nil
 }
 
@@ -761,7 +1610,7 @@ func (he *HTTPEngine) DeleteCookie(urlStr, name string) error {
 		return err
 	}
 
-	cookies := he.cookies.Cookies(u)
+	cookies := he.cookieJar().Cookies(u)
 	newCookies := make([]*http.Cookie, 0)
 	for _, cookie := range cookies {
 		if cookie.Name != name {
@@ -771,9 +1620,10 @@ func (he *HTTPEngine) DeleteCookie(urlStr, name string) error {
 
 	// Clear and reset cookies
 	jar, _ := cookiejar.New(nil)
-	jar.SetCookies(u, newCookies)
-	he.cookies = jar
-	he.client.Jar = jar
+	he.updateClient(func(client *http.Client) {
+		he.useJar(client, jar)
+	})
+	he.cookieJar().SetCookies(u, newCookies)
 
 	return nil
 }
@@ -793,40 +1643,78 @@ func (he *HTTPEngine) GetCookie(urlStr, name string) (*http.Cookie, error) {
 	return nil, fmt.Errorf("cookie %s not found", name)
 }
 
-// ExportCookies exports all cookies to JSON
-func (he *HTTPEngine) ExportCookies() (string, error) {
-	// This would need custom implementation as cookiejar doesn't expose all cookies
-	return "{}", nil
-}
-
-// ImportCookies imports cookies from JSON
-func (he *HTTPEngine) ImportCookies(jsonStr string) error {
-	// This would need custom implementation
-	return nil
-}
-
 // Advanced Logging
 
 // SetLogLevel sets the logging verbosity
 func (he *HTTPEngine) SetLogLevel(level LogLevel) {
+	he.mu.Lock()
 	he.logLevel = level
+	he.mu.Unlock()
+}
+
+// GetLogLevel returns the logging verbosity
+func (he *HTTPEngine) GetLogLevel() LogLevel {
+	he.mu.RLock()
+	defer he.mu.RUnlock()
+	return he.logLevel
 }
 
 // LogWithLevel logs a message at a specific level
 func (he *HTTPEngine) LogWithLevel(level LogLevel, format string, args ...interface{}) {
-	if level <= he.logLevel {
-		message := fmt.Sprintf(format, args...)
-		timestamp := time.Now().Format("2006-01-02 15:04:05.000")
-		levelStr := []string{"ERROR", "WARN", "INFO", "DEBUG", "VERBOSE"}[level]
-		logEntry := fmt.Sprintf("[%s] [%s] %s", timestamp, levelStr, message)
-		he.logs = append(he.logs, logEntry)
+	he.mu.Lock()
+	if level > he.logLevel {
+		he.mu.Unlock()
+		return
+	}
+	message := fmt.Sprintf(format, args...)
+	timestamp := time.Now().Format("2006-01-02 15:04:05.000")
+	logEntry := fmt.Sprintf("[%s] [%s] %s", timestamp, level, message)
+	he.logs = append(he.logs, logEntry)
+	debug := he.debug
+	out := he.logWriter()
+	he.mu.Unlock()
 
-		if he.debug || level <= LogWarn {
-			fmt.Println(logEntry)
-		}
+	if debug || level <= LogWarn {
+		fmt.Fprintln(out, logEntry)
 	}
 }
 
+// SetLogOutput sets where log lines and logged redirects are printed, nil
+// for stdout
+func (he *HTTPEngine) SetLogOutput(w io.Writer) {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	he.logOutput = w
+}
+
+// logWriter returns where log lines are printed; he.mu must be held
+func (he *HTTPEngine) logWriter() io.Writer {
+	if he.logOutput != nil {
+		return he.logOutput
+	}
+	return os.Stdout
+}
+
+// writeLog writes lines printed elsewhere, like by the iterations of a
+// parallel foreach, to the log output
+func (he *HTTPEngine) writeLog(lines []byte) {
+	if len(lines) == 0 {
+		return
+	}
+	he.mu.RLock()
+	out := he.logWriter()
+	he.mu.RUnlock()
+	out.Write(lines)
+}
+
+// printLine prints a line to the log output whatever the log level
+func (he *HTTPEngine) printLine(format string, args ...interface{}) {
+	he.mu.RLock()
+	out := he.logWriter()
+	he.mu.RUnlock()
+	fmt.Fprintf(out, format+"\n", args...)
+}
+
 // LogError logs an error message
 func (he *HTTPEngine) LogError(format string, args ...interface{}) {
 	he.LogWithLevel(LogError, format, args...)
@@ -856,22 +1744,31 @@ func (he *HTTPEngine) LogVerbose(format string, args ...interface{}) {
 
 // SetTLSConfig sets custom TLS configuration
 func (he *HTTPEngine) SetTLSConfig(config *tls.Config) {
-	he.tlsConfig = config
-	if transport, ok := he.client.Transport.(*http.Transport); ok {
+	he.updateTransport(func(transport *http.Transport) {
+		he.tlsConfig = config
 		transport.TLSClientConfig = config
-	}
+	})
 }
 
 // SetInsecureSkipVerify disables SSL certificate verification
 func (he *HTTPEngine) SetInsecureSkipVerify(skip bool) {
-	if he.tlsConfig == nil {
-		he.tlsConfig = &tls.Config{}
-	}
-	he.tlsConfig.InsecureSkipVerify = skip
+	he.updateTLSConfig(func(config *tls.Config) {
+		config.InsecureSkipVerify = skip
+	})
+}
 
-	if transport, ok := he.client.Transport.(*http.Transport); ok {
-		transport.TLSClientConfig = he.tlsConfig
-	}
+// updateTLSConfig applies fn to a copy of the TLS configuration, so handshakes
+// in progress keep reading the configuration they started with
+func (he *HTTPEngine) updateTLSConfig(fn func(config *tls.Config)) {
+	he.updateTransport(func(transport *http.Transport) {
+		config := &tls.Config{}
+		if he.tlsConfig != nil {
+			config = he.tlsConfig.Clone()
+		}
+		fn(config)
+		he.tlsConfig = config
+		transport.TLSClientConfig = config
+	})
 }
 
 // SetClientCertificate sets client certificate for mutual TLS
@@ -881,14 +1778,9 @@ func (he *HTTPEngine) SetClientCertificate(certFile, keyFile string) error {
 		return err
 	}
 
-	if he.tlsConfig == nil {
-		he.tlsConfig = &tls.Config{}
-	}
-	he.tlsConfig.Certificates = []tls.Certificate{cert}
-
-	if transport, ok := he.client.Transport.(*http.Transport); ok {
-		transport.TLSClientConfig = he.tlsConfig
-	}
+	he.updateTLSConfig(func(config *tls.Config) {
+		config.Certificates = []tls.Certificate{cert}
+	})
 
 	return nil
 }
@@ -903,14 +1795,9 @@ func (he *HTTPEngine) SetCustomCA(caFile string) error {
 	caCertPool := x509.NewCertPool()
 	caCertPool.AppendCertsFromPEM(caCert)
 
-	if he.tlsConfig == nil {
-		he.tlsConfig = &tls.Config{}
-	}
-	he.tlsConfig.RootCAs = caCertPool
-
-	if transport, ok := he.client.Transport.(*http.Transport); ok {
-		transport.TLSClientConfig = he.tlsConfig
-	}
+	he.updateTLSConfig(func(config *tls.Config) {
+		config.RootCAs = caCertPool
+	})
 
 	return nil
 }
@@ -919,20 +1806,87 @@ func (he *HTTPEngine) SetCustomCA(caFile string) error {
 
 // SetProxy sets HTTP/HTTPS proxy
 func (he *HTTPEngine) SetProxy(proxyURL string) error {
-	he.proxy = proxyURL
-
 	parsedURL, err := url.Parse(proxyURL)
 	if err != nil {
 		return err
 	}
 
-	if transport, ok := he.client.Transport.(*http.Transport); ok {
+	he.updateTransport(func(transport *http.Transport) {
+		he.proxy = proxyURL
 		transport.Proxy = http.ProxyURL(parsedURL)
-	}
+	})
 
 	return nil
 }
 
+// UseEnvironmentProxy sends requests through the proxies named by the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables (or their lower
+// case forms), read when it is called. New engines start with it; ClearProxy
+// turns it off.
+func (he *HTTPEngine) UseEnvironmentProxy() {
+	proxyFunc := environmentProxy()
+	he.updateTransport(func(transport *http.Transport) {
+		he.proxy = ""
+		transport.Proxy = proxyFunc
+	})
+}
+
+// environmentProxy returns the proxy function of the environment as it is
+// now. http.ProxyFromEnvironment reads the variables only once per process.
+func environmentProxy() func(*http.Request) (*url.URL, error) {
+	proxyFunc := httpproxy.FromEnvironment().ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+}
+
+// proxyFor returns the proxy a request is sent through, with its password
+// hidden, or "" when it goes direct
+func proxyFor(client *http.Client, req *http.Request) string {
+	if proxyURL := requestProxy(client, req); proxyURL != nil {
+		return proxyURL.Redacted()
+	}
+	return ""
+}
+
+// requestProxy returns the proxy a request is sent through, or nil when it
+// goes direct
+func requestProxy(client *http.Client, req *http.Request) *url.URL {
+	rt := client.Transport
+	if chaos, ok := rt.(*chaosTransport); ok {
+		rt = chaos.next
+	}
+	transport, ok := rt.(*http.Transport)
+	if !ok || transport.Proxy == nil {
+		return nil
+	}
+	proxyURL, err := transport.Proxy(req)
+	if err != nil {
+		return nil
+	}
+	return proxyURL
+}
+
+// proxyDialKey is the context key of the address of a request's proxy, the
+// one dial of the request the host policy leaves unchecked
+type proxyDialKey struct{}
+
+// proxyAddress returns the host:port the transport dials for a proxy
+func proxyAddress(proxyURL *url.URL) string {
+	port := proxyURL.Port()
+	if port == "" {
+		switch proxyURL.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(proxyURL.Hostname(), port)
+}
+
 // SetSOCKS5Proxy sets SOCKS5 proxy
 func (he *HTTPEngine) SetSOCKS5Proxy(host string, auth *proxy.Auth) error {
 	dialer, err := proxy.SOCKS5("tcp", host, auth, proxy.Direct)
@@ -940,22 +1894,103 @@ func (he *HTTPEngine) SetSOCKS5Proxy(host string, auth *proxy.Auth) error {
 		return err
 	}
 
-	if transport, ok := he.client.Transport.(*http.Transport); ok {
+	he.updateTransport(func(transport *http.Transport) {
 		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
-			return dialer.Dial(network, addr)
+			return dialer.Dial(network, he.resolveAddress(addr))
 		}
-	}
+	})
 
 	return nil
 }
 
-// ClearProxy removes proxy configuration
+// ClearProxy removes proxy configuration, including the proxies of the
+// environment, so requests go direct
 func (he *HTTPEngine) ClearProxy() {
-	he.proxy = ""
-	if transport, ok := he.client.Transport.(*http.Transport); ok {
+	he.updateTransport(func(transport *http.Transport) {
+		he.proxy = ""
 		transport.Proxy = nil
-		transport.DialContext = nil
+		transport.DialContext = he.dialContext
+	})
+}
+
+// Host Resolution
+
+// defaultDialer has the connect settings of http.DefaultTransport
+var defaultDialer = &net.Dialer{
+	Timeout:   30 * time.Second,
+	KeepAlive: 30 * time.Second,
+}
+
+// SetResolve makes connections to hostPort go to address instead, like curl's
+// --resolve. The URL, Host header and TLS server name keep the original host.
+// An address without a port keeps the port of hostPort; an empty address
+// removes the override.
+func (he *HTTPEngine) SetResolve(hostPort, address string) error {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil || host == "" || port == "" {
+		return fmt.Errorf("resolve: %q must be host:port", hostPort)
+	}
+	key := net.JoinHostPort(strings.ToLower(host), port)
+
+	he.mu.Lock()
+	defer he.mu.Unlock()
+
+	if address == "" {
+		delete(he.resolves, key)
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(strings.Trim(address, "[]"), port)
+	}
+	he.resolves[key] = address
+	return nil
+}
+
+// resolveAddress returns the address to dial for addr, applying SetResolve overrides
+func (he *HTTPEngine) resolveAddress(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	he.mu.RLock()
+	defer he.mu.RUnlock()
+
+	if target, ok := he.resolves[net.JoinHostPort(strings.ToLower(host), port)]; ok {
+		return target
+	}
+	return addr
+}
+
+// SetUnixSocket sends every request over the Unix domain socket at path, like
+// curl's --unix-socket. The URL host only fills the Host header, so requests
+// are usually written as "http://unix/...". An empty path goes back to TCP.
+func (he *HTTPEngine) SetUnixSocket(path string) {
+	// A new transport drops idle connections made through the previous socket
+	he.updateTransport(func(transport *http.Transport) {
+		he.unixSocket = path
+	})
+}
+
+// dialContext is the transport's dialer; it honors SetUnixSocket and
+// SetResolve overrides and checks addresses against the host policy, except
+// the address of the request's proxy
+func (he *HTTPEngine) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	he.mu.RLock()
+	socket := he.unixSocket
+	policy := he.hostPolicy
+	he.mu.RUnlock()
+
+	if socket != "" {
+		return defaultDialer.DialContext(ctx, "unix", socket)
+	}
+	dialer := defaultDialer
+	if proxy, _ := ctx.Value(proxyDialKey{}).(string); policy.active() && proxy != addr {
+		checked := *defaultDialer
+		checked.Control = policy.dialControl
+		dialer = &checked
 	}
+	return dialer.DialContext(ctx, network, he.resolveAddress(addr))
 }
 
 // Multipart/Form-Data Support
@@ -1004,12 +2039,10 @@ func (he *HTTPEngine) RequestWithFile(method, urlStr string, files map[string]st
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
 	// Apply headers
-	for key, value := range he.headers {
-		req.Header.Set(key, value)
-	}
+	client := he.applyGlobalHeaders(req)
 
 	// Execute request
-	resp, err := he.client.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -1024,119 +2057,104 @@ func (he *HTTPEngine) RequestWithFile(method, urlStr string, files map[string]st
 	}, nil
 }
 
+// applyGlobalHeaders sets the global headers on req and returns the client to
+// send it with
+func (he *HTTPEngine) applyGlobalHeaders(req *http.Request) *http.Client {
+	he.mu.RLock()
+	defer he.mu.RUnlock()
+	for key, value := range he.headers {
+		req.Header.Set(key, value)
+	}
+	return he.client
+}
+
 // Request/Response Interceptors
 
 // AddRequestHook adds a request interceptor
 func (he *HTTPEngine) AddRequestHook(hook func(*http.Request) error) {
+	he.mu.Lock()
 	he.requestHooks = append(he.requestHooks, hook)
+	he.mu.Unlock()
 }
 
 // AddResponseHook adds a response interceptor
 func (he *HTTPEngine) AddResponseHook(hook func(*http.Response) error) {
+	he.mu.Lock()
 	he.responseHooks = append(he.responseHooks, hook)
+	he.mu.Unlock()
 }
 
 // ClearHooks removes all interceptors
 func (he *HTTPEngine) ClearHooks() {
+	he.mu.Lock()
 	he.requestHooks = make([]func(*http.Request) error, 0)
 	he.responseHooks = make([]func(*http.Response) error, 0)
+	he.mu.Unlock()
 }
 
 // Retry Policies
 
 // SetRetryPolicy configures retry behavior
 func (he *HTTPEngine) SetRetryPolicy(policy *RetryPolicy) {
+	he.mu.Lock()
 	he.retryPolicy = policy
+	he.mu.Unlock()
 }
 
-// RequestWithRetry performs a request with retry logic
+// RequestWithRetry performs a request with the retry policy of the engine.
+// See RequestWithRetryContext.
 func (he *HTTPEngine) RequestWithRetry(method, urlStr string, options map[string]interface{}) (interface{}, error) {
-	if he.retryPolicy == nil {
-		return he.Request(method, urlStr, options)
-	}
-
-	var lastErr error
-	backoff := he.retryPolicy.InitialBackoff
-
-	for attempt := 0; attempt <= he.retryPolicy.MaxRetries; attempt++ {
-		if attempt > 0 {
-			he.LogInfo("Retry attempt %d/%d after %v", attempt, he.retryPolicy.MaxRetries, backoff)
-			time.Sleep(backoff)
-
-			// Calculate next backoff
-			backoff = time.Duration(float64(backoff) * he.retryPolicy.Multiplier)
-			if backoff > he.retryPolicy.MaxBackoff {
-				backoff = he.retryPolicy.MaxBackoff
-			}
-		}
-
-		result, err := he.Request(method, urlStr, options)
-		if err == nil {
-			// Check if status code requires retry
-			if response, ok := result.(map[string]interface{}); ok {
-				if status, ok := response["status"].(int); ok {
-					shouldRetry := false
-					for _, retryStatus := range he.retryPolicy.RetryOn {
-						if status == retryStatus {
-							shouldRetry = true
-							break
-						}
-					}
-					if !shouldRetry {
-						return result, nil
-					}
-				}
-			}
-		}
-
-		lastErr = err
-	}
-
-	return nil, fmt.Errorf("max retries exceeded: %v", lastErr)
+	return he.RequestWithRetryContext(context.Background(), method, urlStr, options)
 }
 
 // Connection Management
 
 // SetMaxIdleConnections sets the maximum number of idle connections
 func (he *HTTPEngine) SetMaxIdleConnections(max int) {
-	if transport, ok := he.client.Transport.(*http.Transport); ok {
+	he.updateTransport(func(transport *http.Transport) {
 		transport.MaxIdleConns = max
-	}
+	})
 }
 
 // SetMaxConnectionsPerHost sets the maximum connections per host
 func (he *HTTPEngine) SetMaxConnectionsPerHost(max int) {
-	if transport, ok := he.client.Transport.(*http.Transport); ok {
+	he.updateTransport(func(transport *http.Transport) {
 		transport.MaxIdleConnsPerHost = max
-	}
+	})
 }
 
 // SetKeepAlive enables/disables connection keep-alive
 func (he *HTTPEngine) SetKeepAlive(enabled bool) {
-	if transport, ok := he.client.Transport.(*http.Transport); ok {
+	he.updateTransport(func(transport *http.Transport) {
 		transport.DisableKeepAlives = !enabled
-	}
+	})
 }
 
 // History Management
 
-// GetHistory returns request/response history
+// GetHistory returns a copy of the request/response history
 func (he *HTTPEngine) GetHistory() []RequestHistory {
-	return he.history
+	he.mu.RLock()
+	defer he.mu.RUnlock()
+	return append([]RequestHistory(nil), he.history...)
 }
 
 // ClearHistory clears the request history
 func (he *HTTPEngine) ClearHistory() {
+	he.mu.Lock()
 	he.history = make([]RequestHistory, 0)
+	he.mu.Unlock()
 }
 
 // SetMaxHistory sets the maximum history size
 func (he *HTTPEngine) SetMaxHistory(max int) {
+	he.mu.Lock()
 	he.maxHistory = max
+	he.mu.Unlock()
 }
 
-// addToHistory adds a request/response to history
-func (he *HTTPEngine) addToHistory(req *http.Request, resp *http.Response, reqBody, respBody string, duration time.Duration) {
+// addToHistory adds a request/response to history. The caller holds the lock.
+func (he *HTTPEngine) addToHistory(req *http.Request, resp *http.Response, reqBody, respBody string, duration time.Duration, timing RequestTiming, redirects []RedirectHop, reused bool) {
 	if he.maxHistory <= 0 {
 		return
 	}
@@ -1147,7 +2165,11 @@ func (he *HTTPEngine) addToHistory(req *http.Request, resp *http.Response, reqBo
 		RequestBody:  reqBody,
 		ResponseBody: respBody,
 		Duration:     duration,
+		Timing:       timing,
 		Timestamp:    time.Now(),
+		RequestID:    req.Header.Get(requestIDHeader),
+		Redirects:    redirects,
+		Reused:       reused,
 	}
 
 	he.history = append(he.history, history)
@@ -1162,6 +2184,9 @@ func (he *HTTPEngine) addToHistory(req *http.Request, resp *http.Response, reqBo
 
 // CreateSession creates a new named session
 func (he *HTTPEngine) CreateSession(name string) error {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+
 	if _, exists := he.sessions[name]; exists {
 		return fmt.Errorf("session %s already exists", name)
 	}
@@ -1181,6 +2206,9 @@ func (he *HTTPEngine) CreateSession(name string) error {
 
 // SwitchSession switches to a named session
 func (he *HTTPEngine) SwitchSession(name string) error {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+
 	session, exists := he.sessions[name]
 	if !exists {
 		return fmt.Errorf("session %s not found", name)
@@ -1197,8 +2225,9 @@ func (he *HTTPEngine) SwitchSession(name string) error {
 
 	// Load new session
 	he.currentSession = name
-	he.cookies = session.Cookies
-	he.client.Jar = session.Cookies
+	client := *he.client
+	he.useJar(&client, session.Cookies)
+	he.client = &client
 	he.headers = session.Headers
 	he.history = session.History
 
@@ -1207,6 +2236,9 @@ func (he *HTTPEngine) SwitchSession(name string) error {
 
 // DeleteSession removes a session
 func (he *HTTPEngine) DeleteSession(name string) error {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+
 	if name == he.currentSession {
 		return fmt.Errorf("cannot delete active session")
 	}
@@ -1217,6 +2249,9 @@ func (he *HTTPEngine) DeleteSession(name string) error {
 
 // ListSessions returns all session names
 func (he *HTTPEngine) ListSessions() []string {
+	he.mu.RLock()
+	defer he.mu.RUnlock()
+
 	names := make([]string, 0, len(he.sessions))
 	for name := range he.sessions {
 		names = append(names, name)
@@ -1228,21 +2263,27 @@ func (he *HTTPEngine) ListSessions() []string {
 
 // SetRateLimit sets minimum time between requests
 func (he *HTTPEngine) SetRateLimit(duration time.Duration) {
+	he.mu.Lock()
 	he.rateLimit = duration
+	he.mu.Unlock()
 }
 
-// enforceRateLimit waits if necessary to respect rate limit
+// enforceRateLimit waits if necessary to respect rate limit. Each request
+// reserves the next free slot, so concurrent requests are spaced out too.
 func (he *HTTPEngine) enforceRateLimit() {
+	he.mu.Lock()
 	if he.rateLimit <= 0 {
+		he.mu.Unlock()
 		return
 	}
-
-	elapsed := time.Since(he.lastRequestTime)
-	if elapsed < he.rateLimit {
-		time.Sleep(he.rateLimit - elapsed)
+	slot := he.lastRequestTime.Add(he.rateLimit)
+	if now := time.Now(); slot.Before(now) {
+		slot = now
 	}
+	he.lastRequestTime = slot
+	he.mu.Unlock()
 
-	he.lastRequestTime = time.Now()
+	time.Sleep(time.Until(slot))
 }
 
 // Metrics and Performance
@@ -1269,6 +2310,9 @@ func (he *HTTPEngine) RecordMetric(name string, value interface{}) {
 
 // GetAverageResponseTime calculates average response time from history
 func (he *HTTPEngine) GetAverageResponseTime() float64 {
+	he.mu.RLock()
+	defer he.mu.RUnlock()
+
 	if len(he.history) == 0 {
 		return 0
 	}
@@ -1285,97 +2329,65 @@ func (he *HTTPEngine) GetAverageResponseTime() float64 {
 
 // SetOAuth2Config configures OAuth 2.0
 func (he *HTTPEngine) SetOAuth2Config(config *OAuth2Config) {
+	he.mu.Lock()
 	he.oauth2Config = config
+	he.mu.Unlock()
+}
+
+// oauth2 returns the OAuth 2.0 configuration
+func (he *HTTPEngine) oauth2() *OAuth2Config {
+	he.mu.RLock()
+	defer he.mu.RUnlock()
+	return he.oauth2Config
 }
 
 // OAuth2Authorize initiates OAuth 2.0 authorization flow
 func (he *HTTPEngine) OAuth2Authorize() string {
-	if he.oauth2Config == nil {
+	config := he.oauth2()
+	if config == nil {
 		return ""
 	}
 
 	params := url.Values{}
-	params.Set("client_id", he.oauth2Config.ClientID)
-	params.Set("redirect_uri", he.oauth2Config.RedirectURL)
+	params.Set("client_id", config.ClientID)
+	params.Set("redirect_uri", config.RedirectURL)
 	params.Set("response_type", "code")
-	params.Set("scope", strings.Join(he.oauth2Config.Scopes, " "))
+	params.Set("scope", strings.Join(config.Scopes, " "))
 
-	return he.oauth2Config.AuthURL + "?" + params.Encode()
+	return config.AuthURL + "?" + params.Encode()
 }
 
 // OAuth2ExchangeCode exchanges authorization code for access token
 func (he *HTTPEngine) OAuth2ExchangeCode(code string) error {
-	if he.oauth2Config == nil {
+	config := he.oauth2()
+	if config == nil {
 		return fmt.Errorf("OAuth2 not configured")
 	}
 
 	data := url.Values{}
 	data.Set("grant_type", "authorization_code")
 	data.Set("code", code)
-	data.Set("client_id", he.oauth2Config.ClientID)
-	data.Set("client_secret", he.oauth2Config.ClientSecret)
-	data.Set("redirect_uri", he.oauth2Config.RedirectURL)
-
-	resp, err := http.PostForm(he.oauth2Config.TokenURL, data)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return err
-	}
+	data.Set("client_id", config.ClientID)
+	data.Set("client_secret", config.ClientSecret)
+	data.Set("redirect_uri", config.RedirectURL)
 
-	if token, ok := result["access_token"].(string); ok {
-		he.oauth2Config.AccessToken = token
-		he.SetBearerToken(token)
-	}
-
-	if refresh, ok := result["refresh_token"].(string); ok {
-		he.oauth2Config.RefreshToken = refresh
-	}
-
-	if expiresIn, ok := result["expires_in"].(float64); ok {
-		he.oauth2Config.Expiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
-	}
-
-	return nil
+	return he.fetchOAuth2Token(context.Background(), config, data)
 }
 
 // OAuth2RefreshToken refreshes the access token
 func (he *HTTPEngine) OAuth2RefreshToken() error {
-	if he.oauth2Config == nil || he.oauth2Config.RefreshToken == "" {
+	config := he.oauth2()
+	if config == nil || config.RefreshToken == "" {
 		return fmt.Errorf("refresh token not available")
 	}
 
 	data := url.Values{}
 	data.Set("grant_type", "refresh_token")
-	data.Set("refresh_token", he.oauth2Config.RefreshToken)
-	data.Set("client_id", he.oauth2Config.ClientID)
-	data.Set("client_secret", he.oauth2Config.ClientSecret)
-
-	resp, err := http.PostForm(he.oauth2Config.TokenURL, data)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
+	data.Set("refresh_token", config.RefreshToken)
+	data.Set("client_id", config.ClientID)
+	data.Set("client_secret", config.ClientSecret)
 
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return err
-	}
-
-	if token, ok := result["access_token"].(string); ok {
-		he.oauth2Config.AccessToken = token
-		he.SetBearerToken(token)
-	}
-
-	if expiresIn, ok := result["expires_in"].(float64); ok {
-		he.oauth2Config.Expiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
-	}
-
-	return nil
+	return he.fetchOAuth2Token(context.Background(), config, data)
 }
 
 // GraphQL Support
@@ -1422,11 +2434,9 @@ func (he *HTTPEngine) StreamRequest(method, urlStr string, callback func([]byte)
 	}
 
 	// Apply headers
-	for key, value := range he.headers {
-		req.Header.Set(key, value)
-	}
+	client := he.applyGlobalHeaders(req)
 
-	resp, err := he.client.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,272 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// queryJQ evaluates a jq-like expression over a JSON body. The expression is
+// a path like .users[].name or $.users[*].name, where [] collects the values
+// of every element into an array, optionally piped with | through more paths
+// or through length, keys, values, first, last, sort, unique, reverse, add,
+// min, max, flatten, map(path) and join("sep"). Every stage gets the result
+// of the one before.
+func queryJQ(body, expr string) (interface{}, error) {
+	data, err := decodeJSON([]byte(body))
+	if err != nil {
+		return nil, fmt.Errorf("jq needs a JSON response: %w", err)
+	}
+	for _, stage := range splitJQ(expr) {
+		if data, err = jqStage(data, stage); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// splitJQ splits an expression at the pipes outside quotes and parentheses
+func splitJQ(expr string) []string {
+	var stages []string
+	depth, start := 0, 0
+	var quote rune
+	for i, r := range expr {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == '|' && depth == 0:
+			stages = append(stages, strings.TrimSpace(expr[start:i]))
+			start = i + 1
+		}
+	}
+	return append(stages, strings.TrimSpace(expr[start:]))
+}
+
+// jqStage applies one stage of an expression to data
+func jqStage(data interface{}, stage string) (interface{}, error) {
+	if strings.HasPrefix(stage, ".") || strings.HasPrefix(stage, "$") {
+		return jqPath(data, stage), nil
+	}
+	if name, arg, ok := strings.Cut(stage, "("); ok && strings.HasSuffix(arg, ")") {
+		arg = strings.TrimSpace(strings.TrimSuffix(arg, ")"))
+		switch name {
+		case "map":
+			items, ok := data.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("jq map needs an array, got %s", typeName(data))
+			}
+			mapped := make([]interface{}, len(items))
+			for i, item := range items {
+				mapped[i] = jqPath(item, arg)
+			}
+			return mapped, nil
+		case "join":
+			items, ok := data.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("jq join needs an array, got %s", typeName(data))
+			}
+			parts := make([]string, len(items))
+			for i, item := range items {
+				parts[i] = formatValue(item)
+			}
+			return strings.Join(parts, strings.Trim(arg, `"'`)), nil
+		}
+		return nil, fmt.Errorf("unknown jq function %s", name)
+	}
+
+	switch stage {
+	case "length":
+		switch v := data.(type) {
+		case []interface{}:
+			return len(v), nil
+		case map[string]interface{}:
+			return len(v), nil
+		case string:
+			return utf8.RuneCountInString(v), nil
+		case nil:
+			return 0, nil
+		}
+		return nil, fmt.Errorf("jq length of a %s", typeName(data))
+	case "keys", "values":
+		object, ok := data.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("jq %s needs an object, got %s", stage, typeName(data))
+		}
+		results := []interface{}{}
+		for _, key := range sortedKeys(object) {
+			if stage == "keys" {
+				results = append(results, key)
+			} else {
+				results = append(results, object[key])
+			}
+		}
+		return results, nil
+	}
+
+	items, ok := data.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unknown jq stage %q for a %s", stage, typeName(data))
+	}
+	switch stage {
+	case "first", "last":
+		if len(items) == 0 {
+			return nil, nil
+		}
+		if stage == "first" {
+			return items[0], nil
+		}
+		return items[len(items)-1], nil
+	case "sort", "unique":
+		sorted := append([]interface{}(nil), items...)
+		sort.SliceStable(sorted, func(i, j int) bool { return jqLess(sorted[i], sorted[j]) })
+		if stage == "sort" {
+			return sorted, nil
+		}
+		unique := []interface{}{}
+		for i, item := range sorted {
+			if i == 0 || jqLess(sorted[i-1], item) {
+				unique = append(unique, item)
+			}
+		}
+		return unique, nil
+	case "reverse":
+		reversed := make([]interface{}, len(items))
+		for i, item := range items {
+			reversed[len(items)-1-i] = item
+		}
+		return reversed, nil
+	case "min", "max":
+		if len(items) == 0 {
+			return nil, nil
+		}
+		best := items[0]
+		for _, item := range items[1:] {
+			if stage == "min" && jqLess(item, best) || stage == "max" && jqLess(best, item) {
+				best = item
+			}
+		}
+		return best, nil
+	case "add":
+		return jqAdd(items)
+	case "flatten":
+		flat := []interface{}{}
+		for _, item := range items {
+			if nested, ok := item.([]interface{}); ok {
+				flat = append(flat, nested...)
+			} else {
+				flat = append(flat, item)
+			}
+		}
+		return flat, nil
+	}
+	return nil, fmt.Errorf("unknown jq stage %q", stage)
+}
+
+// jqPath follows a path through data. Paths with [] or [*] return an array
+// of every value reached, others the value or nil.
+func jqPath(data interface{}, path string) interface{} {
+	segments := jsonPathSegments(strings.TrimPrefix(strings.TrimSpace(path), "$"))
+	fansOut := false
+	for i, segment := range segments {
+		if segment == "" || segment == "*" {
+			segments[i] = "*"
+			fansOut = true
+		}
+	}
+	values := jsonPathAll(data, segments)
+	if fansOut {
+		return append([]interface{}{}, values...)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+// jqLess orders numbers by value and anything else by its text
+func jqLess(a, b interface{}) bool {
+	x, aNum := jqNumber(a)
+	y, bNum := jqNumber(b)
+	if aNum && bNum {
+		return x < y
+	}
+	return formatValue(a) < formatValue(b)
+}
+
+// jqNumber returns the value of a number of decoded JSON
+func jqNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// jqAdd sums numbers, or concatenates strings or arrays
+func jqAdd(items []interface{}) (interface{}, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+	switch items[0].(type) {
+	case int, float64, json.Number:
+		var sum interface{} = 0
+		for _, item := range items {
+			if _, ok := jqNumber(item); !ok {
+				return nil, fmt.Errorf("jq add of a number and a %s", typeName(item))
+			}
+			if a, ok := sum.(int); ok {
+				if b, ok := item.(int); ok && (a+b > a) == (b > 0) {
+					sum = a + b
+					continue
+				}
+			}
+			x, _ := jqNumber(sum)
+			y, _ := jqNumber(item)
+			sum = x + y
+		}
+		return sum, nil
+	case string:
+		var b strings.Builder
+		for _, item := range items {
+			b.WriteString(formatValue(item))
+		}
+		return b.String(), nil
+	case []interface{}:
+		joined := []interface{}{}
+		for _, item := range items {
+			nested, ok := item.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("jq add of an array and a %s", typeName(item))
+			}
+			joined = append(joined, nested...)
+		}
+		return joined, nil
+	}
+	return nil, fmt.Errorf("jq add of %s values", typeName(items[0]))
+}
+
+// prettyBody indents a JSON body, keeping its key order; other bodies are
+// returned as they are
+func prettyBody(body string) string {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, []byte(strings.TrimSpace(body)), "", "  "); err != nil {
+		return body
+	}
+	return indented.String()
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTTPDSLv3JQAndShowResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"users":[{"name":"ada","age":36,"tags":["a","b"]},{"name":"bob","age":25,"tags":["b"]}],"total":2}`))
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+
+	script := `GET "$base/users"
+set $names jq "$.users[].name"
+set $first jq ".users[0].name"
+set $count jq ".users | length"
+set $oldest jq ".users | map(.age) | max"
+set $tags jq ".users[].tags | flatten | unique | join(\", \")"
+set $keys jq ". | keys"
+set $missing jq ".nothing"`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("jq failed: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"names":   []interface{}{"ada", "bob"},
+		"first":   "ada",
+		"count":   2,
+		"oldest":  36,
+		"tags":    "a, b",
+		"keys":    []interface{}{"total", "users"},
+		"missing": nil,
+	}
+	for name, want := range expected {
+		if got, _ := dsl.GetVariable(name); !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected $%s to be %#v, got %#v", name, want, got)
+		}
+	}
+
+	if _, err := dsl.Parse(`set $bad jq ".users | explode"`); err == nil {
+		t.Error("Expected an unknown jq stage to fail")
+	}
+
+	result, err := dsl.Parse("show response pretty")
+	if err != nil {
+		t.Fatalf("show response pretty failed: %v", err)
+	}
+	pretty := result.(string)
+	if !strings.HasPrefix(pretty, "{\n  \"users\": [\n    {\n      \"name\": \"ada\",") {
+		t.Errorf("Expected indented JSON in key order, got:\n%s", pretty)
+	}
+
+	if _, err := NewHTTPDSLv3().Parse("show response"); err == nil {
+		t.Error("Expected show response without a response to fail")
+	}
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// CompareJSON compares two JSON documents structurally. Object key order does
+// not matter and keys listed in ignoreFields are skipped at any depth. Each
+// side may be JSON text, as a string or []byte, or already decoded data (like
+// a jsonpath extraction); a decoded string must be encoded first, since
+// strings are read as JSON text. The returned error describes the first
+// difference found.
+//
+// Example:
+//
+//	err := CompareJSON(`{"id": 1, "name": "x"}`, responseBody, []string{"updated_at"})
+//	if err != nil {
+//	    fmt.Println(err) // $.name: expected "x", got "y"
+//	}
+func CompareJSON(expected, actual interface{}, ignoreFields []string) error {
+	want, err := normalizeJSON(expected)
+	if err != nil {
+		return fmt.Errorf("invalid expected JSON: %v", err)
+	}
+	got, err := normalizeJSON(actual)
+	if err != nil {
+		return fmt.Errorf("invalid actual JSON: %v", err)
+	}
+
+	ignore := make(map[string]bool)
+	for _, field := range ignoreFields {
+		ignore[field] = true
+	}
+
+	if diff := jsonDiff("$", want, got, ignore); diff != "" {
+		return fmt.Errorf("%s", diff)
+	}
+	return nil
+}
+
+// normalizeJSON decodes JSON text, or round-trips decoded data so numbers and
+// nested values have the same types on both sides of a comparison
+func normalizeJSON(v interface{}) (interface{}, error) {
+	var data []byte
+	switch val := v.(type) {
+	case string:
+		data = []byte(val)
+	case []byte:
+		data = val
+	default:
+		encoded, err := json.Marshal(val)
+		if err != nil {
+			return nil, err
+		}
+		data = encoded
+	}
+
+	return decodeJSON(data)
+}
+
+// jsonDiff returns a description of the first difference between want and got,
+// or an empty string when they match
+func jsonDiff(path string, want, got interface{}, ignore map[string]bool) string {
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			return fmt.Sprintf("%s: expected object, got %s", path, jsonText(got))
+		}
+		for _, key := range sortedKeys(w) {
+			if ignore[key] {
+				continue
+			}
+			value, exists := g[key]
+			if !exists {
+				return fmt.Sprintf("%s.%s: missing", path, key)
+			}
+			if diff := jsonDiff(path+"."+key, w[key], value, ignore); diff != "" {
+				return diff
+			}
+		}
+		for _, key := range sortedKeys(g) {
+			if _, exists := w[key]; !exists && !ignore[key] {
+				return fmt.Sprintf("%s.%s: unexpected field with value %s", path, key, jsonText(g[key]))
+			}
+		}
+		return ""
+
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok {
+			return fmt.Sprintf("%s: expected array, got %s", path, jsonText(got))
+		}
+		if len(w) != len(g) {
+			return fmt.Sprintf("%s: expected %d items, got %d", path, len(w), len(g))
+		}
+		for i := range w {
+			if diff := jsonDiff(path+"["+strconv.Itoa(i)+"]", w[i], g[i], ignore); diff != "" {
+				return diff
+			}
+		}
+		return ""
+	}
+
+	if typeName(want) == "number" && typeName(got) == "number" {
+		// 1 and 1.0 are the same JSON number; big ids compare by their digits
+		if formatValue(want) != formatValue(got) {
+			return fmt.Sprintf("%s: expected %s, got %s", path, jsonText(want), jsonText(got))
+		}
+		return ""
+	}
+	if !reflect.DeepEqual(want, got) {
+		return fmt.Sprintf("%s: expected %s, got %s", path, jsonText(want), jsonText(got))
+	}
+	return ""
+}
+
+// sortedKeys returns the keys of an object in a stable order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// jsonText renders a decoded value for error messages
+func jsonText(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestHTTPDSLv3JSONAssertions tests structural JSON comparison assertions
+func TestHTTPDSLv3JSONAssertions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"x","id":1,"updated_at":"2024-01-01","user":{"role":"admin","tags":["a","b"]}}`))
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	if _, err := dsl.Parse(fmt.Sprintf(`GET "%s"`, server.URL)); err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		input     string
+		shouldErr string
+	}{
+		{
+			name:  "Whole body ignoring key order and fields",
+			input: `assert json equals {"id": 1, "user": {"tags": ["a", "b"], "role": "admin"}, "name": "x"} ignoring "updated_at"`,
+		},
+		{
+			name:      "Whole body with unexpected field",
+			input:     `assert json equals {"id": 1, "user": {"tags": ["a", "b"], "role": "admin"}, "name": "x"}`,
+			shouldErr: "$.updated_at: unexpected field",
+		},
+		{
+			name:  "Jsonpath subtree",
+			input: `assert jsonpath "$.user" equals json {"role": "admin", "tags": ["a", "b"]}`,
+		},
+		{
+			name:      "Jsonpath subtree mismatch",
+			input:     `assert jsonpath "$.user" equals json {"role": "guest", "tags": ["a", "b"]}`,
+			shouldErr: `$.role: expected "guest", got "admin"`,
+		},
+		{
+			name:  "Jsonpath ignoring nested field",
+			input: `expect jsonpath "$.user" equals json {"role": "admin"} ignoring "tags"`,
+		},
+		{
+			name:  "Jsonpath string leaf",
+			input: `assert jsonpath "$.name" equals json "x"`,
+		},
+		{
+			name:  "Jsonpath string leaf as JSON text",
+			input: `assert jsonpath "$.updated_at" equals json "\"2024-01-01\""`,
+		},
+		{
+			name:  "Jsonpath number leaf",
+			input: `assert jsonpath "$.id" equals json "1"`,
+		},
+		{
+			name:      "Jsonpath string leaf mismatch",
+			input:     `assert jsonpath "$.user.role" equals json "guest"`,
+			shouldErr: `$: expected "guest", got "admin"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := dsl.Parse(tt.input)
+			if tt.shouldErr == "" && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if tt.shouldErr != "" && (err == nil || !strings.Contains(err.Error(), tt.shouldErr)) {
+				t.Errorf("Expected error containing %q, got %v", tt.shouldErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// webLink is one link of a Link header (RFC 8288)
+type webLink struct {
+	target string            // URI reference between < and >
+	params map[string]string // Parameters by lower case name, unquoted
+}
+
+// hasRel reports whether the link has a relation type; rel may hold several
+// types separated by spaces
+func (l webLink) hasRel(rel string) bool {
+	for _, value := range strings.Fields(l.params["rel"]) {
+		if strings.EqualFold(value, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLinkHeader parses the values of Link headers. Commas and semicolons
+// inside <> and quoted parameter values do not split links.
+//
+//	Link: <https://api.example.com/items?page=2>; rel="next", <...?page=9>; rel="last"
+func parseLinkHeader(values []string) []webLink {
+	var links []webLink
+	for _, value := range values {
+		rest := value
+		for {
+			open := strings.Index(rest, "<")
+			if open < 0 {
+				break
+			}
+			closing := strings.Index(rest[open:], ">")
+			if closing < 0 {
+				break
+			}
+			link := webLink{target: strings.TrimSpace(rest[open+1 : open+closing]), params: map[string]string{}}
+			rest = rest[open+closing+1:]
+
+			// Parameters run up to the comma that starts the next link
+			end := len(rest)
+			inQuotes := false
+			for i := 0; i < len(rest); i++ {
+				if rest[i] == '"' {
+					inQuotes = !inQuotes
+				} else if rest[i] == ',' && !inQuotes {
+					end = i
+					break
+				}
+			}
+			for _, param := range splitLinkParams(rest[:end]) {
+				name, val, _ := strings.Cut(param, "=")
+				name = strings.ToLower(strings.TrimSpace(name))
+				if name == "" {
+					continue
+				}
+				val = strings.TrimSpace(val)
+				if len(val) >= 2 && val[0] == '"' && val[len(val)-1] == '"' {
+					val = val[1 : len(val)-1]
+				}
+				if _, seen := link.params[name]; !seen {
+					link.params[name] = val
+				}
+			}
+			links = append(links, link)
+			rest = rest[end:]
+		}
+	}
+	return links
+}
+
+// splitLinkParams splits the parameters of a link at semicolons outside
+// quoted values
+func splitLinkParams(s string) []string {
+	var params []string
+	inQuotes := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			inQuotes = !inQuotes
+		case s[i] == ';' && !inQuotes:
+			params = append(params, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(params, s[start:])
+}
+
+// linksByRel returns the targets of the links of a response with a relation
+// type, resolved against the URL of the request that got the response
+func linksByRel(resp *http.Response, rel string) []string {
+	var base *url.URL
+	if resp.Request != nil {
+		base = resp.Request.URL
+	}
+	var targets []string
+	for _, link := range parseLinkHeader(resp.Header.Values("Link")) {
+		if !link.hasRel(rel) {
+			continue
+		}
+		target := link.target
+		if ref, err := url.Parse(target); err == nil && base != nil {
+			target = base.ResolveReference(ref).String()
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
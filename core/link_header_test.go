@@ -0,0 +1,97 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestHTTPDSLv3LinkHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Header().Add("Link", `<https://api.example.com/repos?page=2>; rel="next", <https://api.example.com/repos?page=3>; rel="last"`)
+			w.Header().Add("Link", `</repos?page=2>; title="a; b, c"; rel="next alternate"`)
+			fmt.Fprint(w, `[]`)
+		case "1":
+			w.Header().Set("Link", `</repos?page=2>; rel="next"`)
+			fmt.Fprint(w, `[{"id": 1}, {"id": 2}]`)
+		case "2":
+			w.Header().Set("Link", `</repos?page=1>; rel="prev first"`)
+			fmt.Fprint(w, `[{"id": 3}]`)
+		case "5":
+			w.Header().Set("Link", `<https://api.example.com/repos?page=6>; rel="next", `+
+				`<https://api.example.com/repos?page=4>; rel="prev", `+
+				`<https://api.example.com/repos?page=9>; rel="last", `+
+				`<https://api.example.com/repos?page=1>; rel="first"`)
+			fmt.Fprint(w, `[]`)
+		}
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	_, err := dsl.ParseWithBlockSupport(`GET "$base/repos"
+extract link rel "next" as $next
+extract link rel "last" as $last
+extract link rel "alternate" as $alternate
+extract link rel "next" all as $nexts
+extract link rel "prev" as $prev`)
+	if err != nil {
+		t.Fatalf("extract link failed: %v", err)
+	}
+	expected := map[string]interface{}{
+		"next":      "https://api.example.com/repos?page=2",
+		"last":      "https://api.example.com/repos?page=3",
+		"alternate": server.URL + "/repos?page=2",
+		"prev":      "",
+	}
+	for name, want := range expected {
+		if got, _ := dsl.GetVariable(name); got != want {
+			t.Errorf("Expected $%s = %v, got %v", name, want, got)
+		}
+	}
+	if nexts, _ := dsl.GetVariable("nexts"); !reflect.DeepEqual(nexts, []interface{}{"https://api.example.com/repos?page=2", server.URL + "/repos?page=2"}) {
+		t.Errorf("Expected both next links, got %v", nexts)
+	}
+
+	// Every relation of a single header with several links
+	_, err = dsl.ParseWithBlockSupport(`GET "$base/repos?page=5"
+extract link rel "next" as $next
+extract link rel "prev" as $prev
+extract link rel "last" as $last
+extract link rel "first" as $first`)
+	if err != nil {
+		t.Fatalf("extract link failed: %v", err)
+	}
+	expected = map[string]interface{}{
+		"next":  "https://api.example.com/repos?page=6",
+		"prev":  "https://api.example.com/repos?page=4",
+		"last":  "https://api.example.com/repos?page=9",
+		"first": "https://api.example.com/repos?page=1",
+	}
+	for name, want := range expected {
+		if got, _ := dsl.GetVariable(name); got != want {
+			t.Errorf("Expected $%s = %v, got %v", name, want, got)
+		}
+	}
+
+	script := `paginate GET "$base/repos?page=1" next link do
+endpaginate`
+	if problems := dsl.Validate(script); len(problems) != 0 {
+		t.Fatalf("Unexpected problems: %v", problems)
+	}
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("paginate with next link failed: %v", err)
+	}
+	state, _ := dsl.GetVariable("paginate")
+	if page := state.(map[string]interface{})["page"]; page != 2 {
+		t.Errorf("Expected 2 pages, got %v", page)
+	}
+	if items := state.(map[string]interface{})["items"].([]interface{}); len(items) != 3 {
+		t.Errorf("Expected 3 items, got %v", items)
+	}
+}
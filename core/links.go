@@ -0,0 +1,75 @@
+package core
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// linkAttributes are the attributes that link to a page or an asset, by tag
+var linkAttributes = map[string]string{
+	"a":      "href",
+	"area":   "href",
+	"link":   "href",
+	"img":    "src",
+	"script": "src",
+	"iframe": "src",
+	"frame":  "src",
+	"embed":  "src",
+	"source": "src",
+	"track":  "src",
+	"video":  "src",
+	"audio":  "src",
+}
+
+// extractLinks returns the absolute URLs page links to with href and src,
+// resolved against base, once each and in document order. Fragments are
+// removed, and links that cannot be requested, like mailto:, javascript: or
+// data:, are left out.
+func extractLinks(page string, base *url.URL) []interface{} {
+	links := []interface{}{}
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		return links
+	}
+
+	seen := make(map[string]bool)
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if n.Data == "base" && hasHTMLAttr(n, "href") {
+				if resolved, err := base.Parse(strings.TrimSpace(htmlAttr(n, "href"))); err == nil {
+					base = resolved
+				}
+			}
+			if name, ok := linkAttributes[n.Data]; ok {
+				if target := resolveLink(base, htmlAttr(n, name)); target != "" && !seen[target] {
+					seen[target] = true
+					links = append(links, target)
+				}
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	return links
+}
+
+// resolveLink returns the absolute http or https URL of a link without its
+// fragment, or "" when it has none
+func resolveLink(base *url.URL, link string) string {
+	link = strings.TrimSpace(link)
+	if link == "" || strings.HasPrefix(link, "#") {
+		return ""
+	}
+	target, err := base.Parse(link)
+	if err != nil || target.Scheme != "http" && target.Scheme != "https" {
+		return ""
+	}
+	target.Fragment = ""
+	target.RawFragment = ""
+	return target.String()
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestHTTPDSLv3ExtractLinks(t *testing.T) {
+	var checked []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/docs/" {
+			checked = append(checked, r.URL.Path)
+			return
+		}
+		w.Write([]byte(`<html><head>
+<link rel="stylesheet" href="/style.css">
+<script src="app.js"></script>
+</head><body>
+<a href="guide#install">Guide</a>
+<a href="guide">Guide again</a>
+<a href="mailto:team@example.com">Mail</a>
+<a href="#top">Top</a>
+<img src="../logo.png">
+</body></html>`))
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+
+	_, err := dsl.ParseWithBlockSupport(`GET "$base/docs/"
+extract links as $links
+foreach $link in $links do
+    GET "$link"
+    assert status 200
+endloop`)
+	if err != nil {
+		t.Fatalf("crawl failed: %v", err)
+	}
+	links, _ := dsl.GetVariable("links")
+	expected := []interface{}{
+		server.URL + "/style.css",
+		server.URL + "/docs/app.js",
+		server.URL + "/docs/guide",
+		server.URL + "/logo.png",
+	}
+	if !reflect.DeepEqual(links, expected) {
+		t.Errorf("Expected links %v, got %v", expected, links)
+	}
+	if len(checked) != 4 {
+		t.Errorf("Expected every link to be requested, got %v", checked)
+	}
+}
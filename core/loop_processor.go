@@ -101,10 +101,37 @@ func (hd *HTTPDSLv3) ProcessLoopBody(body []string) (*LoopResult, error) {
 			continue
 		}
 
+		// for range, repeat ... until, switch and paginate blocks run as a whole
+		if forLoopHeader.MatchString(trimmed) || isUntilOpener(trimmed) || isSwitchOpener(trimmed) || isPaginateOpener(trimmed) {
+			opens, closes := isLoopOpener, isEndloop
+			switch {
+			case isUntilOpener(trimmed):
+				opens, closes = isUntilOpener, isUntilCloser
+			case isSwitchOpener(trimmed):
+				opens, closes = isSwitchOpener, isSwitchCloser
+			case isPaginateOpener(trimmed):
+				opens, closes = isPaginateOpener, isPaginateCloser
+			}
+			_, endIdx, ok := collectLoopLines(body, i, opens, closes)
+			if !ok {
+				return nil, fmt.Errorf("malformed loop block at line %d", i+1)
+			}
+
+			loopResult, err := hd.ParseWithBlockSupport(strings.Join(body[i:endIdx+1], "\n"))
+			if err != nil {
+				return nil, err
+			}
+			if loopResults, ok := loopResult.([]interface{}); ok {
+				result.Results = appendResults(result.Results, loopResults)
+			}
+			i = endIdx
+			continue
+		}
+
 		// Process regular line
 		lineResult, err := hd.ParseWithContext(trimmed)
 		if err != nil {
-			return nil, fmt.Errorf("error processing line %d: %v", i+1, err)
+			return nil, fmt.Errorf("error processing line %d: %w", i+1, err)
 		}
 		if lineResult != nil && lineResult != "" {
 			result.Results = append(result.Results, lineResult)
@@ -167,7 +194,7 @@ func (hd *HTTPDSLv3) ExtractLoopBlock(lines []string, startIdx int) ([]string, i
 		trimmed := strings.TrimSpace(line)
 
 		// Track nesting
-		if strings.HasSuffix(trimmed, " do") {
+		if isLoopOpener(trimmed) {
 			nestLevel++
 		} else if trimmed == "endloop" {
 			nestLevel--
@@ -274,12 +301,25 @@ func (hd *HTTPDSLv3) ProcessIfBlockWithControl(block []string) (*LoopResult, err
 	// Evaluate condition using the new evaluator that supports AND/OR
 	shouldExecute := hd.EvaluateCondition(conditionStr)
 
-	// Parse the if block to find then/else sections
+	// Parse the if block to find then/elseif/else sections
 	var thenBlock []string
 	var elseBlock []string
+	var elseIfs []elseIfBranch
 	inElse := false
 	nestLevel := 0
 
+	// add puts a line in the branch being collected
+	add := func(line string) {
+		switch {
+		case inElse:
+			elseBlock = append(elseBlock, line)
+		case len(elseIfs) > 0:
+			elseIfs[len(elseIfs)-1].body = append(elseIfs[len(elseIfs)-1].body, line)
+		default:
+			thenBlock = append(thenBlock, line)
+		}
+	}
+
 	for i := 1; i < len(block); i++ {
 		line := strings.TrimSpace(block[i])
 
@@ -287,48 +327,37 @@ func (hd *HTTPDSLv3) ProcessIfBlockWithControl(block []string) (*LoopResult, err
 		if strings.HasPrefix(line, "if ") && strings.HasSuffix(line, " then") {
 			nestLevel++
 			// Add line to appropriate block (include nested if/endif/else)
-			if inElse {
-				elseBlock = append(elseBlock, line)
-			} else {
-				thenBlock = append(thenBlock, line)
-			}
+			add(line)
 		} else if line == "endif" {
 			if nestLevel == 0 {
 				break // End of our if block
 			}
 			nestLevel--
 			// Add line to appropriate block (include nested if/endif/else)
-			if inElse {
-				elseBlock = append(elseBlock, line)
-			} else {
-				thenBlock = append(thenBlock, line)
-			}
+			add(line)
+		} else if condition, ok := elseIfCondition(line); ok && nestLevel == 0 && !inElse {
+			elseIfs = append(elseIfs, elseIfBranch{condition: condition})
 		} else if line == "else" && nestLevel == 0 {
 			inElse = true
 			continue
-		} else if line == "else" && nestLevel > 0 {
-			// This else belongs to a nested if
-			if inElse {
-				elseBlock = append(elseBlock, line)
-			} else {
-				thenBlock = append(thenBlock, line)
-			}
 		} else if line != "" && !strings.HasPrefix(line, "#") {
-			// Add regular lines to appropriate block
-			if inElse {
-				elseBlock = append(elseBlock, line)
-			} else {
-				thenBlock = append(thenBlock, line)
-			}
+			// Add regular lines to appropriate block, else and elseif lines
+			// of nested blocks included
+			add(line)
 		}
 	}
 
-	// Execute the appropriate block
-	var blockToExecute []string
-	if shouldExecute {
-		blockToExecute = thenBlock
-	} else {
+	// Execute the appropriate block; elseif conditions are only evaluated
+	// when no branch before them was taken
+	blockToExecute := thenBlock
+	if !shouldExecute {
 		blockToExecute = elseBlock
+		for _, branch := range elseIfs {
+			if hd.EvaluateCondition(branch.condition) {
+				blockToExecute = branch.body
+				break
+			}
+		}
 	}
 
 	// Process the block recursively to handle nested structures
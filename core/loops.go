@@ -0,0 +1,258 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// forLoopHeader matches the first line of a for block:
+// for $i in <from> to <to> [step <n>] do
+var forLoopHeader = regexp.MustCompile(`^for\s+\$(\w+)\s+in\s+(.+?)\s+to\s+(.+?)(?:\s+step\s+(.+?))?\s+do$`)
+
+// maxUntilIterations stops repeat ... until loops whose condition never holds,
+// like the limit on while loops
+const maxUntilIterations = 1000
+
+// isUntilOpener reports whether a line starts a repeat ... until block
+func isUntilOpener(line string) bool {
+	return line == "repeat"
+}
+
+// isUntilCloser reports whether a line closes a repeat ... until block
+func isUntilCloser(line string) bool {
+	return strings.HasPrefix(line, "until ")
+}
+
+// rangeBounds converts the bounds and step of a for loop to integers. Without
+// a step the range counts up by one, or down by one when from is above to.
+func rangeBounds(from, to, step interface{}) (int, int, int, error) {
+	bounds := make([]int, 3)
+	for i, value := range []interface{}{from, to, step} {
+		if i == 2 && value == nil {
+			break
+		}
+		num, err := numberArgument(value)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("for loop: %w", err)
+		}
+		if num != math.Trunc(num) {
+			return 0, 0, 0, fmt.Errorf("for loop: %v is not an integer", value)
+		}
+		bounds[i] = int(num)
+	}
+
+	if step == nil {
+		bounds[2] = 1
+		if bounds[0] > bounds[1] {
+			bounds[2] = -1
+		}
+	}
+	if bounds[2] == 0 {
+		return 0, 0, 0, fmt.Errorf("for loop: step cannot be 0")
+	}
+	return bounds[0], bounds[1], bounds[2], nil
+}
+
+// inRange reports whether value has not gone past the end of a range
+func inRange(value, end, step int) bool {
+	if step > 0 {
+		return value <= end
+	}
+	return value >= end
+}
+
+// collectLoopLines returns the lines of a block opened at index start, nested
+// blocks included, and the index of the line that closes it
+func collectLoopLines(lines []string, start int, opens, closes func(string) bool) ([]string, int, bool) {
+	depth := 1
+	for i := start + 1; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if opens(line) {
+			depth++
+		} else if closes(line) {
+			depth--
+			if depth == 0 {
+				return lines[start+1 : i], i, true
+			}
+		}
+	}
+	return nil, len(lines), false
+}
+
+// isLoopOpener reports whether a line starts a block closed by endloop
+func isLoopOpener(line string) bool {
+	return strings.HasSuffix(line, " do") && !isPaginateOpener(line)
+}
+
+// isEndloop reports whether a line closes a loop block
+func isEndloop(line string) bool {
+	return line == "endloop"
+}
+
+// runForBlock runs a multiline for block starting at index start and returns
+// its results and the index of its endloop
+func (hd *HTTPDSLv3) runForBlock(lines []string, start int) ([]interface{}, int, error) {
+	header := forLoopHeader.FindStringSubmatch(strings.TrimSpace(lines[start]))
+	if header == nil {
+		return nil, start, fmt.Errorf("invalid for syntax, expected: for $i in <from> to <to> [step <n>] do")
+	}
+	body, end, ok := collectLoopLines(lines, start, isLoopOpener, isEndloop)
+	if !ok {
+		return nil, end, fmt.Errorf("for block is never closed, missing endloop")
+	}
+
+	bounds := make([]interface{}, 3)
+	for i, text := range header[2:] {
+		if text == "" {
+			continue
+		}
+		// Integer literals may be negative, which NUMBER tokens cannot be
+		if n, err := strconv.Atoi(text); err == nil {
+			bounds[i] = n
+			continue
+		}
+		value, err := hd.evaluateRule("value", text)
+		if err != nil {
+			return nil, end, fmt.Errorf("for loop: %w", err)
+		}
+		bounds[i] = value
+	}
+	from, to, step, err := rangeBounds(bounds[0], bounds[1], bounds[2])
+	if err != nil {
+		return nil, end, err
+	}
+
+	var results []interface{}
+	iterations := 0
+	for value := from; inRange(value, to, step); value += step {
+		hd.SetVariable(header[1], value)
+		hd.SetVariable("_index", iterations)
+		hd.SetVariable("_iteration", iterations+1)
+		hd.emitIteration("for", iterations+1)
+
+		loopResult, err := hd.ProcessLoopBody(body)
+		if err != nil {
+			return results, end, fmt.Errorf("error in for iteration %d: %w", iterations+1, err)
+		}
+		results = appendResults(results, loopResult.Results)
+		iterations++
+
+		if loopResult.ShouldBreak {
+			break
+		}
+	}
+
+	return append(results, fmt.Sprintf("For loop executed %d times", iterations)), end, nil
+}
+
+// runUntilBlock runs a multiline repeat ... until block starting at index
+// start and returns its results and the index of its until line. The body
+// always runs once; the condition is checked after every iteration.
+func (hd *HTTPDSLv3) runUntilBlock(lines []string, start int) ([]interface{}, int, error) {
+	body, end, ok := collectLoopLines(lines, start, isUntilOpener, isUntilCloser)
+	if !ok {
+		return nil, end, fmt.Errorf("repeat block is never closed, missing until")
+	}
+
+	// Like while blocks, conditions outside the grammar fall back to the
+	// string evaluator
+	conditionStr := strings.TrimPrefix(strings.TrimSpace(lines[end]), "until ")
+	condition, parseErr := hd.parseRule("condition", conditionStr)
+
+	var results []interface{}
+	iterations := 0
+	for {
+		if iterations >= maxUntilIterations {
+			return results, end, fmt.Errorf("repeat until loop exceeded maximum iterations (%d)", maxUntilIterations)
+		}
+
+		hd.SetVariable("_index", iterations)
+		hd.SetVariable("_iteration", iterations+1)
+		hd.emitIteration("repeat", iterations+1)
+
+		loopResult, err := hd.ProcessLoopBody(body)
+		if err != nil {
+			return results, end, fmt.Errorf("error in repeat iteration %d: %w", iterations+1, err)
+		}
+		results = appendResults(results, loopResult.Results)
+		iterations++
+
+		if loopResult.ShouldBreak {
+			break
+		}
+
+		var done bool
+		if parseErr == nil {
+			if done, err = hd.evaluateCondition(condition); err != nil {
+				return results, end, fmt.Errorf("error in until condition: %w", err)
+			}
+		} else {
+			done = hd.EvaluateCondition(conditionStr)
+		}
+		if done {
+			break
+		}
+	}
+
+	return append(results, fmt.Sprintf("Repeat until executed %d times", iterations)), end, nil
+}
+
+// forRange runs the statements of a single-line for loop once for every value
+// of the range
+func (hd *HTTPDSLv3) forRange(variable string, fromArg, toArg, stepArg, statements interface{}) (interface{}, error) {
+	bounds := make([]interface{}, 3)
+	for i, arg := range []interface{}{fromArg, toArg, stepArg} {
+		if arg == nil {
+			continue
+		}
+		value, err := hd.evaluate(arg)
+		if err != nil {
+			return nil, err
+		}
+		bounds[i] = value
+	}
+	from, to, step, err := rangeBounds(bounds[0], bounds[1], bounds[2])
+	if err != nil {
+		return nil, err
+	}
+
+	iterations := 0
+	for value := from; inRange(value, to, step); value += step {
+		hd.SetVariable(strings.TrimPrefix(variable, "$"), value)
+		hd.SetVariable("_index", iterations)
+		hd.SetVariable("_iteration", iterations+1)
+		hd.emitIteration("for", iterations+1)
+
+		if _, err := hd.executeStatements(statements); err != nil {
+			return nil, err
+		}
+		iterations++
+		if hd.endIteration() {
+			break
+		}
+	}
+
+	return fmt.Sprintf("For loop executed %d times", iterations), nil
+}
+
+// evaluateRule parses input with a grammar rule and evaluates it
+func (hd *HTTPDSLv3) evaluateRule(rule, input string) (interface{}, error) {
+	tree, err := hd.parseRule(rule, input)
+	if err != nil {
+		return nil, err
+	}
+	return hd.evaluate(tree)
+}
+
+// appendResults adds the non-empty results of a loop iteration
+func appendResults(results, iteration []interface{}) []interface{} {
+	for _, res := range iteration {
+		if res != nil && res != "" {
+			results = append(results, res)
+		}
+	}
+	return results
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHTTPDSLv3ForAndRepeatUntil(t *testing.T) {
+	dsl := NewHTTPDSLv3()
+	script := `set $seen ""
+for $i in 1 to 10 step 3 do
+    set $seen "$seen $i"
+endloop
+set $polls 0
+repeat
+    set $polls $polls + 1
+until $polls >= 3
+set $down ""
+for $i in 3 to 1 do
+    for $j in 1 to 2 do
+        set $down "$down $i.$j"
+    endloop
+endloop
+set $total 0
+for $i in 1 to 4 do set $total $total + $i endloop
+set $once 0
+repeat set $once $once + 1 until $once > 0`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"seen":  " 1 4 7 10",
+		"polls": "3",
+		"down":  " 3.1 3.2 2.1 2.2 1.1 1.2",
+		"total": "10",
+		"once":  "1",
+	}
+	for name, want := range expected {
+		if value, _ := dsl.GetVariable(name); fmt.Sprint(value) != want {
+			t.Errorf("Expected $%s to be %q, got %q", name, want, fmt.Sprint(value))
+		}
+	}
+
+	if _, err := dsl.ParseWithBlockSupport("for $i in 1 to 5 step 0 do\n    print \"x\"\nendloop"); err == nil {
+		t.Error("Expected a zero step to fail")
+	}
+	if problems := dsl.Validate("repeat\n    print \"x\"\nendloop"); len(problems) != 2 {
+		t.Errorf("Expected a mismatched endloop and an unclosed repeat, got %v", problems)
+	}
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+)
+
+// kerberosLogins are Kerberos clients by keytab and principal, "" for the
+// credentials cache
+type kerberosLogins map[string]*client.Client
+
+// kerberosConfigPath returns the krb5.conf to use: $KRB5_CONFIG or the
+// system one
+func kerberosConfigPath() string {
+	if path := os.Getenv("KRB5_CONFIG"); path != "" {
+		return path
+	}
+	return "/etc/krb5.conf"
+}
+
+// kerberosCachePath returns the credentials cache kinit writes to:
+// $KRB5CCNAME or /tmp/krb5cc_<uid>. Only file caches can be read.
+func kerberosCachePath() (string, error) {
+	name := os.Getenv("KRB5CCNAME")
+	if name == "" {
+		return fmt.Sprintf("/tmp/krb5cc_%d", os.Getuid()), nil
+	}
+	if kind, path, ok := strings.Cut(name, ":"); ok && !strings.Contains(kind, "/") {
+		if strings.ToUpper(kind) != "FILE" {
+			return "", fmt.Errorf("credentials cache %s is not supported, only FILE caches can be read", name)
+		}
+		return path, nil
+	}
+	return name, nil
+}
+
+// kerberosClient returns the Kerberos client of a login: the tickets of the
+// ambient credentials cache when keytab is "", or a login of principal with
+// the keys of keytab. Clients are kept, so tickets are reused by later
+// requests.
+func (he *HTTPEngine) kerberosClient(keytabPath, principal string) (*client.Client, error) {
+	login := keytabPath + "\x00" + principal
+	he.mu.RLock()
+	cl := he.kerberos[login]
+	he.mu.RUnlock()
+	if cl != nil {
+		return cl, nil
+	}
+
+	krb5conf, err := config.Load(kerberosConfigPath())
+	if err != nil {
+		return nil, fmt.Errorf("loading %s: %w", kerberosConfigPath(), err)
+	}
+
+	if keytabPath == "" {
+		path, err := kerberosCachePath()
+		if err != nil {
+			return nil, err
+		}
+		cache, err := credentials.LoadCCache(path)
+		if err != nil {
+			return nil, fmt.Errorf("loading credentials cache %s (run kinit, or use a keytab): %w", path, err)
+		}
+		if cl, err = client.NewFromCCache(cache, krb5conf, client.DisablePAFXFAST(true)); err != nil {
+			return nil, fmt.Errorf("using credentials cache %s: %w", path, err)
+		}
+	} else {
+		kt, err := keytab.Load(keytabPath)
+		if err != nil {
+			return nil, fmt.Errorf("loading keytab %s: %w", keytabPath, err)
+		}
+		user, realm, ok := strings.Cut(principal, "@")
+		if !ok {
+			realm = krb5conf.LibDefaults.DefaultRealm
+		}
+		cl = client.NewWithKeytab(user, realm, kt, krb5conf, client.DisablePAFXFAST(true))
+		if err := cl.Login(); err != nil {
+			return nil, fmt.Errorf("logging in as %s with keytab %s: %w", principal, keytabPath, err)
+		}
+	}
+
+	he.mu.Lock()
+	if he.kerberos == nil {
+		he.kerberos = make(kerberosLogins)
+	}
+	he.kerberos[login] = cl
+	he.mu.Unlock()
+	return cl, nil
+}
+
+// setNegotiateHeader authenticates req with a SPNEGO token for the HTTP
+// service of its host, from the login of the auth negotiate option
+func (he *HTTPEngine) setNegotiateHeader(req *http.Request, auth map[string]string) error {
+	cl, err := he.kerberosClient(auth["keytab"], auth["principal"])
+	if err != nil {
+		return err
+	}
+	return spnego.SetSPNEGOHeader(cl, req, "HTTP/"+req.URL.Hostname())
+}
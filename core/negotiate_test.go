@@ -0,0 +1,46 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHTTPDSLv3AuthNegotiate(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	krb5conf := filepath.Join(dir, "krb5.conf")
+	os.WriteFile(krb5conf, []byte("[libdefaults]\n  default_realm = EXAMPLE.COM\n"), 0o600)
+	t.Setenv("KRB5_CONFIG", krb5conf)
+	t.Setenv("KRB5CCNAME", "FILE:"+filepath.Join(dir, "krb5cc_missing"))
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	dsl.SetVariable("keytab", filepath.Join(dir, "ci.keytab"))
+
+	_, err := dsl.ParseWithBlockSupport(`GET "$base/" auth negotiate`)
+	if err == nil || !strings.Contains(err.Error(), "run kinit") {
+		t.Errorf("Expected a missing credentials cache error, got %v", err)
+	}
+	_, err = dsl.ParseWithBlockSupport(`GET "$base/" auth negotiate keytab "$keytab" principal "svc-ci@EXAMPLE.COM"`)
+	if err == nil || !strings.Contains(err.Error(), "loading keytab") {
+		t.Errorf("Expected a missing keytab error, got %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("Expected no request without a ticket, got %d", requests)
+	}
+
+	t.Setenv("KRB5CCNAME", "KEYRING:persistent:1000")
+	_, err = dsl.ParseWithBlockSupport(`GET "$base/" auth negotiate`)
+	if err == nil || !strings.Contains(err.Error(), "only FILE caches") {
+		t.Errorf("Expected an unsupported cache error, got %v", err)
+	}
+}
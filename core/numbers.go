@@ -0,0 +1,196 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Numbers of scripts are ints when they are whole and float64 otherwise:
+// literals like 5, JSON numbers of responses and fixtures, and the results
+// of arithmetic on integers stay ints, so they expand as 5 and not 5.0 or
+// 1.2345678901e+10, compare exactly and are sent as integers in JSON. JSON
+// integers too large for an int are kept as json.Number, their text, so they
+// are sent on and compared digit for digit.
+
+// maxExactFloat is the largest whole float64 every smaller integer is exact
+// up to (2^53)
+const maxExactFloat = 1 << 53
+
+// parseNumber returns a number literal as an int when it is whole and fits,
+// as a json.Number when it is whole and does not, and as a float64 otherwise
+func parseNumber(text string) (interface{}, error) {
+	if n, err := strconv.Atoi(text); err == nil {
+		return n, nil
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return nil, err
+	}
+	if !strings.ContainsAny(text, ".eE") {
+		return json.Number(text), nil
+	}
+	return f, err
+}
+
+// toInteger returns v as an int when it is a whole number held exactly: an
+// int, a whole float64 up to 2^53 or a string of an integer
+func toInteger(v interface{}) (int, bool) {
+	switch val := v.(type) {
+	case int:
+		return val, true
+	case int64:
+		return int(val), true
+	case json.Number:
+		if n, err := strconv.Atoi(val.String()); err == nil {
+			return n, true
+		}
+	case float64:
+		if val == math.Trunc(val) && math.Abs(val) <= maxExactFloat {
+			return int(val), true
+		}
+	case string:
+		if n, err := strconv.Atoi(strings.TrimSpace(val)); err == nil {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// bigInteger returns v as a big.Int when it is a whole number held exactly,
+// of any size: the integers toInteger takes and json.Numbers beyond an int
+func bigInteger(v interface{}) (*big.Int, bool) {
+	var text string
+	switch val := v.(type) {
+	case json.Number:
+		text = val.String()
+	case string:
+		text = strings.TrimSpace(val)
+	default:
+		n, ok := toInteger(v)
+		if !ok {
+			return nil, false
+		}
+		return big.NewInt(int64(n)), true
+	}
+	return new(big.Int).SetString(text, 10)
+}
+
+// compareIntegers compares two whole numbers exactly, returning -1, 0 or +1,
+// and false when either is not an integer
+func compareIntegers(left, right interface{}) (int, bool) {
+	a, ok := bigInteger(left)
+	if !ok {
+		return 0, false
+	}
+	b, ok := bigInteger(right)
+	if !ok {
+		return 0, false
+	}
+	return a.Cmp(b), true
+}
+
+// arithmetic applies +, -, * or / to two values. Integers give an int unless
+// the result overflows or a division leaves a remainder; anything else is
+// computed with float64.
+func (hd *HTTPDSLv3) arithmetic(left interface{}, op string, right interface{}) (interface{}, error) {
+	a, aInt := toInteger(left)
+	b, bInt := toInteger(right)
+	if aInt && bInt {
+		switch op {
+		case "+":
+			if sum := a + b; (sum > a) == (b > 0) {
+				return sum, nil
+			}
+		case "-":
+			if diff := a - b; (diff < a) == (b > 0) {
+				return diff, nil
+			}
+		case "*":
+			if a == 0 || b == 0 {
+				return 0, nil
+			}
+			if product := a * b; product/b == a && !(a == -1 && b == math.MinInt) && !(b == -1 && a == math.MinInt) {
+				return product, nil
+			}
+		case "/":
+			if b == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			if a%b == 0 && !(a == math.MinInt && b == -1) {
+				return a / b, nil
+			}
+		}
+	}
+
+	x, y := hd.toNumber(left), hd.toNumber(right)
+	switch op {
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	case "/":
+		if y == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return x / y, nil
+	}
+	return nil, fmt.Errorf("unknown operator: %s", op)
+}
+
+// formatNumber writes a float64 without an exponent where JSON would, so
+// whole values read as integers
+func formatNumber(f float64) string {
+	if math.Abs(f) < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// decodeJSON decodes JSON keeping whole numbers as ints, or as json.Number
+// beyond the range of an int, so large ids are never rounded through float64
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("invalid character after top-level value")
+	}
+	return jsonNumbers(value), nil
+}
+
+// jsonNumbers replaces the json.Numbers of decoded data with ints or float64s,
+// keeping only integers an int cannot hold
+func jsonNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		text := v.String()
+		if n, err := strconv.Atoi(text); err == nil {
+			return n
+		}
+		if !strings.ContainsAny(text, ".eE") {
+			return v
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = jsonNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = jsonNumbers(item)
+		}
+	}
+	return value
+}
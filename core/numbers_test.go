@@ -0,0 +1,119 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestHTTPDSLv3IntegerNumbers tests that whole numbers stay ints through
+// arithmetic, extraction, expansion, comparisons and JSON bodies
+func TestHTTPDSLv3IntegerNumbers(t *testing.T) {
+	var paths, bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.Write([]byte(`{"id": 12345678901, "count": 3, "ratio": 0.5}`))
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+
+	script := `set $id 5
+set $next $id + 10
+set $half $id / 2
+set $whole 10 / 2
+GET "$base/items/$id/$next/$half"
+extract jsonpath "$.id" as $big
+extract jsonpath "$.ratio" as $ratio
+extract status as $status
+POST "$base/orders/$big" json {"id": $id, "big": $big}
+assert status 200
+assert jsonpath "$.id" equals 12345678901
+if $big == 12345678901 then set $exact "yes" else set $exact "no"
+if $status == 200 then set $ok "yes" else set $ok "no"
+set $sum $big + 1`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+
+	for name, expected := range map[string]interface{}{
+		"id":    5,
+		"next":  15,
+		"half":  2.5,
+		"whole": 5,
+		"big":   12345678901,
+		"ratio": 0.5,
+		"exact": "yes",
+		"ok":    "yes",
+		"sum":   12345678902,
+	} {
+		if value, _ := dsl.GetVariable(name); value != expected {
+			t.Errorf("Expected $%s to be %v (%T), got %v (%T)", name, expected, expected, value, value)
+		}
+	}
+
+	if len(paths) != 2 || paths[0] != "/items/5/15/2.5" || paths[1] != "/orders/12345678901" {
+		t.Errorf("Unexpected paths: %v", paths)
+	}
+	if len(bodies) != 2 || !strings.Contains(bodies[1], `"id": 5`) || !strings.Contains(bodies[1], `"big": 12345678901`) {
+		t.Errorf("Unexpected JSON body: %v", bodies)
+	}
+
+	if _, err := dsl.Parse(`set $broken $id / 0`); err == nil || !strings.Contains(err.Error(), "division by zero") {
+		t.Errorf("Expected a division by zero error, got %v", err)
+	}
+}
+
+// TestHTTPDSLv3SnowflakeIDs tests that JSON ids beyond 2^53 and beyond int64
+// keep every digit when extracted, sent on and compared
+func TestHTTPDSLv3SnowflakeIDs(t *testing.T) {
+	var paths, bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.Write([]byte(`{"id": 9007199254740993, "huge": 123456789012345678901234567890, "users": [{"id": 1790000000000000001}]}`))
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+
+	script := `GET "$base/start"
+extract jsonpath "$.id" as $id
+extract jsonpath "$.huge" as $huge
+extract jsonpath "$.users[0].id" as $user
+set $first jq ".users[].id | first"
+GET "$base/items/$id/$huge/$user"
+POST "$base/items" json {"id": $id, "huge": $huge, "user": $first}
+assert jsonpath "$.id" equals 9007199254740993
+assert jsonpath "$.huge" equals 123456789012345678901234567890
+assert json equals {"id": 9007199254740993, "huge": 123456789012345678901234567890, "users": [{"id": 1790000000000000001}]}
+if $id == 9007199254740993 then set $same "yes" else set $same "no"
+if $id == 9007199254740992 then set $rounded "yes" else set $rounded "no"
+if $huge == 123456789012345678901234567891 then set $neighbour "yes" else set $neighbour "no"`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+
+	if len(paths) != 3 || paths[1] != "/items/9007199254740993/123456789012345678901234567890/1790000000000000001" {
+		t.Errorf("Unexpected paths: %v", paths)
+	}
+	if len(bodies) != 3 || bodies[2] != `{"id": 9007199254740993, "huge": 123456789012345678901234567890, "user": 1790000000000000001}` {
+		t.Errorf("Unexpected JSON body: %v", bodies)
+	}
+	for name, expected := range map[string]string{"same": "yes", "rounded": "no", "neighbour": "no"} {
+		if value, _ := dsl.GetVariable(name); value != expected {
+			t.Errorf("Expected $%s to be %s, got %v", name, expected, value)
+		}
+	}
+
+	if _, err := dsl.Parse(`assert jsonpath "$.id" equals 9007199254740992`); err == nil {
+		t.Error("Expected a rounded id not to match")
+	}
+}
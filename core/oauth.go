@@ -0,0 +1,190 @@
+package core
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// azureADAuthority is the host of Azure AD token endpoints
+var azureADAuthority = "https://login.microsoftonline.com"
+
+// jwtBearerGrant is the grant type of service account assertions (RFC 7523)
+const jwtBearerGrant = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+
+// AzureADConfig returns the client credentials config of an Azure AD (Entra
+// ID) app registration. Scopes are usually one resource's .default scope,
+// like https://graph.microsoft.com/.default.
+func AzureADConfig(tenant, clientID, clientSecret string, scopes []string) *OAuth2Config {
+	return &OAuth2Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		TokenURL:     azureADAuthority + "/" + url.PathEscape(tenant) + "/oauth2/v2.0/token",
+		Scopes:       scopes,
+	}
+}
+
+// OAuth2ClientCredentials gets an access token with the client credentials
+// grant of the OAuth 2.0 config, for service to service calls without a
+// user, and sends it as a bearer token on later requests
+func (he *HTTPEngine) OAuth2ClientCredentials(ctx context.Context) error {
+	config := he.oauth2()
+	if config == nil {
+		return fmt.Errorf("OAuth2 not configured")
+	}
+
+	data := url.Values{}
+	data.Set("grant_type", "client_credentials")
+	data.Set("client_id", config.ClientID)
+	data.Set("client_secret", config.ClientSecret)
+	if len(config.Scopes) > 0 {
+		data.Set("scope", strings.Join(config.Scopes, " "))
+	}
+	return he.fetchOAuth2Token(ctx, config, data)
+}
+
+// serviceAccountKey is the part of a Google service account JSON key used
+// to sign token requests
+type serviceAccountKey struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// OAuth2ServiceAccount gets an access token for a Google service account
+// with the JWT bearer grant: a JWT signed with the private key of the JSON
+// key file is exchanged at the token_uri of the file. The token is sent as a
+// bearer token on later requests.
+func (he *HTTPEngine) OAuth2ServiceAccount(ctx context.Context, keyFile string, scopes []string) error {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return fmt.Errorf("reading service account key: %w", err)
+	}
+	var key serviceAccountKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return fmt.Errorf("invalid service account key %s: %w", keyFile, err)
+	}
+	if key.ClientEmail == "" || key.PrivateKey == "" || key.TokenURI == "" {
+		return fmt.Errorf("service account key %s needs client_email, private_key and token_uri", keyFile)
+	}
+	assertion, err := key.assertion(scopes, time.Now())
+	if err != nil {
+		return fmt.Errorf("signing service account assertion: %w", err)
+	}
+
+	config := &OAuth2Config{ClientID: key.ClientEmail, TokenURL: key.TokenURI, Scopes: scopes}
+	he.SetOAuth2Config(config)
+
+	form := url.Values{}
+	form.Set("grant_type", jwtBearerGrant)
+	form.Set("assertion", assertion)
+	return he.fetchOAuth2Token(ctx, config, form)
+}
+
+// assertion returns the RS256 JWT asking for scopes, valid for an hour
+func (k *serviceAccountKey) assertion(scopes []string, now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(k.PrivateKey))
+	if block == nil {
+		return "", fmt.Errorf("private_key is not PEM encoded")
+	}
+	var signer *rsa.PrivateKey
+	if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return "", fmt.Errorf("private_key is not an RSA key")
+		}
+		signer = rsaKey
+	} else if signer, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+		return "", fmt.Errorf("parsing private_key: %w", err)
+	}
+
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	if k.PrivateKeyID != "" {
+		header["kid"] = k.PrivateKeyID
+	}
+	claims := map[string]interface{}{
+		"iss":   k.ClientEmail,
+		"scope": strings.Join(scopes, " "),
+		"aud":   k.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+	encodedHeader, _ := json.Marshal(header)
+	encodedClaims, _ := json.Marshal(claims)
+	unsigned := base64.RawURLEncoding.EncodeToString(encodedHeader) + "." + base64.RawURLEncoding.EncodeToString(encodedClaims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, signer, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// fetchOAuth2Token posts a token request to the token URL of config, stores
+// the tokens and expiry it answers with in config, and sends the access
+// token as a bearer token on later requests. Errors of the authorization
+// server are returned with their description.
+func (he *HTTPEngine) fetchOAuth2Token(ctx context.Context, config *OAuth2Config, data url.Values) error {
+	he.mu.RLock()
+	client := he.client
+	he.mu.RUnlock()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", config.TokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading token response: %w", err)
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("token endpoint answered %d with invalid JSON: %w", resp.StatusCode, err)
+	}
+	if code, ok := result["error"].(string); ok || resp.StatusCode >= 400 {
+		if description, ok := result["error_description"].(string); ok {
+			code += ": " + description
+		}
+		return fmt.Errorf("token endpoint answered %d: %s", resp.StatusCode, code)
+	}
+
+	token, ok := result["access_token"].(string)
+	if !ok {
+		return fmt.Errorf("token response has no access_token")
+	}
+	config.AccessToken = token
+	he.SetBearerToken(token)
+
+	if refresh, ok := result["refresh_token"].(string); ok {
+		config.RefreshToken = refresh
+	}
+	if expiresIn, ok := result["expires_in"].(float64); ok {
+		config.Expiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+	he.LogInfo("Got an OAuth2 access token from %s", config.TokenURL)
+	return nil
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHTTPDSLv3OAuthServiceTokens(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch {
+		case r.URL.Path == "/api":
+			w.Write([]byte(r.Header.Get("Authorization")))
+		case r.PostForm.Get("grant_type") == "client_credentials":
+			if r.PostForm.Get("client_secret") != "s3cret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error": "invalid_client", "error_description": "bad secret"}`))
+				return
+			}
+			fmt.Fprintf(w, `{"access_token": "cc-%s-%s", "expires_in": 3600}`, strings.TrimPrefix(r.URL.Path, "/"), r.PostForm.Get("scope"))
+		case r.PostForm.Get("grant_type") == "urn:ietf:params:oauth:grant-type:jwt-bearer":
+			parts := strings.Split(r.PostForm.Get("assertion"), ".")
+			signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error": "invalid_grant"}`))
+				return
+			}
+			payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+			var claims map[string]interface{}
+			json.Unmarshal(payload, &claims)
+			if claims["aud"] != server.URL+"/google" {
+				t.Errorf("Expected the token URI as audience, got %v", claims["aud"])
+			}
+			fmt.Fprintf(w, `{"access_token": "sa-%s", "expires_in": 3600}`, claims["iss"])
+		}
+	}))
+	defer server.Close()
+
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	keyFile := filepath.Join(t.TempDir(), "key.json")
+	keyJSON, _ := json.Marshal(map[string]string{
+		"client_email": "robot@project.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    server.URL + "/google",
+	})
+	os.WriteFile(keyFile, keyJSON, 0o600)
+
+	defer func(authority string) { azureADAuthority = authority }(azureADAuthority)
+	azureADAuthority = server.URL
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	dsl.SetVariable("key", keyFile)
+
+	for script, expected := range map[string]string{
+		`auth oidc client_credentials "$base/token" client "app" secret "s3cret" scope "read write"`:            "Bearer cc-token-read write",
+		`auth azure tenant "contoso" client "app" secret "s3cret" scope "https://graph.microsoft.com/.default"`: "Bearer cc-contoso/oauth2/v2.0/token-https://graph.microsoft.com/.default",
+		`auth google service account "$key" scope "https://www.googleapis.com/auth/cloud-platform"`:             "Bearer sa-robot@project.iam.gserviceaccount.com",
+	} {
+		if _, err := dsl.ParseWithBlockSupport(script + `
+GET "$base/api"`); err != nil {
+			t.Fatalf("%s: %v", script, err)
+		}
+		if got := dsl.GetEngine().GetLastResponse(); got != expected {
+			t.Errorf("%s: expected %q, got %q", script, expected, got)
+		}
+	}
+
+	_, err = dsl.ParseWithBlockSupport(`auth oidc client_credentials "$base/token" client "app" secret "wrong"`)
+	if err == nil || !strings.Contains(err.Error(), "invalid_client: bad secret") {
+		t.Errorf("Expected the token endpoint error, got %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxPages stops paginate blocks that give no max, so an API that
+// always links to another page cannot loop forever
+const defaultMaxPages = 100
+
+// paginateSpec is the header of a paginate block
+type paginateSpec struct {
+	request  interface{} // Request of the first page, not evaluated
+	next     string      // jsonpath of the next page link, or of the cursor with param; empty for the Link header
+	param    string      // Query parameter that carries the cursor, empty for links
+	items    string      // jsonpath of the items of a page, empty when the page is an array
+	maxPages int
+}
+
+// isPaginateOpener reports whether a line starts a paginate block
+func isPaginateOpener(line string) bool {
+	return strings.HasPrefix(line, "paginate ") && strings.HasSuffix(line, " do")
+}
+
+// isPaginateCloser reports whether a line closes a paginate block
+func isPaginateCloser(line string) bool {
+	return line == "endpaginate"
+}
+
+// runPaginateBlock runs a paginate block starting at index start once for
+// every page and returns its results and the index of its endpaginate. The
+// items of the pages fetched so far are collected in $paginate.items, next
+// to $paginate.page and $paginate.next.
+func (hd *HTTPDSLv3) runPaginateBlock(lines []string, start int) ([]interface{}, int, error) {
+	body, end, ok := collectLoopLines(lines, start, isPaginateOpener, isPaginateCloser)
+	if !ok {
+		return nil, end, fmt.Errorf("paginate block is never closed, missing endpaginate")
+	}
+	header, err := hd.evaluateRule("paginate_header", strings.TrimSpace(lines[start]))
+	if err != nil {
+		return nil, end, fmt.Errorf("paginate: %w", err)
+	}
+	spec := header.(*paginateSpec)
+
+	// The first request is built once; every page sends it again with the
+	// URL of that page
+	first, err := hd.takeRequest(spec.request)
+	if err != nil {
+		return nil, end, fmt.Errorf("paginate: %w", err)
+	}
+
+	var results []interface{}
+	items := []interface{}{}
+	pageURL := first.url
+	pages := 0
+	for pageURL != "" && pages < spec.maxPages {
+		options := make(map[string]interface{}, len(first.options))
+		for key, value := range first.options {
+			options[key] = value
+		}
+		if _, err := hd.sendRequest(first.method, pageURL, options); err != nil {
+			return results, end, fmt.Errorf("paginate page %d: %w", pages+1, err)
+		}
+		pages++
+
+		// Read the page before the body sends requests of its own
+		items = append(items, hd.pageItems(spec.items)...)
+		next, err := hd.nextPageURL(spec, first.url, pageURL)
+		if err != nil {
+			return results, end, fmt.Errorf("paginate page %d: %w", pages, err)
+		}
+		hd.SetVariable("paginate", map[string]interface{}{
+			"page":  pages,
+			"items": items,
+			"next":  next,
+		})
+		hd.emitIteration("paginate", pages)
+
+		loopResult, err := hd.ProcessLoopBody(body)
+		if err != nil {
+			return results, end, fmt.Errorf("error in paginate page %d: %w", pages, err)
+		}
+		results = appendResults(results, loopResult.Results)
+		if loopResult.ShouldBreak || next == pageURL {
+			break
+		}
+		pageURL = next
+	}
+
+	return append(results, fmt.Sprintf("Paginate fetched %d page(s) with %d item(s)", pages, len(items))), end, nil
+}
+
+// pageItems returns the items of the last response: the elements of the
+// array at path, every match of a wildcard path, or the elements of a page
+// that is an array when there is no path
+func (hd *HTTPDSLv3) pageItems(path string) []interface{} {
+	if path == "" {
+		if page, err := decodeJSON([]byte(hd.engine.GetLastResponse())); err == nil {
+			if array, ok := page.([]interface{}); ok {
+				return array
+			}
+		}
+		return nil
+	}
+
+	matches := hd.engine.ExtractAll("jsonpath", path)
+	if len(matches) == 1 {
+		if array, ok := matches[0].([]interface{}); ok {
+			return array
+		}
+	}
+	return matches
+}
+
+// nextPageURL reads the next page from the last response, from its body or
+// its Link header. Links may be relative to the page; a cursor is sent in its
+// query parameter of the first URL. It returns an empty string after the last
+// page.
+func (hd *HTTPDSLv3) nextPageURL(spec *paginateSpec, firstURL, pageURL string) (string, error) {
+	if spec.next == "" {
+		// Link targets are already resolved against the page
+		next, _ := hd.engine.Extract("link", "next").(string)
+		return next, nil
+	}
+
+	matches := hd.engine.ExtractAll("jsonpath", spec.next)
+	if len(matches) == 0 || matches[0] == nil {
+		return "", nil
+	}
+	next := formatValue(matches[0])
+	if next == "" || next == "false" {
+		return "", nil
+	}
+
+	if spec.param != "" {
+		u, err := url.Parse(firstURL)
+		if err != nil {
+			return "", err
+		}
+		query := u.Query()
+		query.Set(spec.param, next)
+		u.RawQuery = query.Encode()
+		return u.String(), nil
+	}
+
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(next)
+	if err != nil {
+		return "", fmt.Errorf("invalid next page link %s: %w", next, err)
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// newPaginateSpec builds the header of a paginate block from its request,
+// next jsonpath and evaluated options
+func (hd *HTTPDSLv3) newPaginateSpec(request interface{}, next string, options []interface{}) (*paginateSpec, error) {
+	spec := &paginateSpec{
+		request:  request,
+		next:     next,
+		maxPages: defaultMaxPages,
+	}
+	for _, opt := range options {
+		option := opt.(map[string]interface{})
+		value := option["value"].(string)
+		switch option["type"] {
+		case "param":
+			spec.param = value
+		case "items":
+			spec.items = value
+		case "max":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid max pages %s", value)
+			}
+			spec.maxPages = n
+		}
+	}
+	return spec, nil
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPDSLv3Paginate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path + "?" + r.URL.RawQuery {
+		case "/items?":
+			fmt.Fprint(w, `{"data": [{"id": 1}, {"id": 2}], "next": "/items?page=2"}`)
+		case "/items?page=2":
+			fmt.Fprint(w, `{"data": [{"id": 3}], "next": null}`)
+		case "/cursor?limit=1":
+			fmt.Fprint(w, `{"data": ["a"], "cursor": "c2"}`)
+		case "/cursor?cursor=c2&limit=1":
+			fmt.Fprint(w, `{"data": ["b"], "cursor": "c3"}`)
+		default:
+			fmt.Fprint(w, `{"data": ["c"], "cursor": "c4"}`)
+		}
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	script := `set $seen 0
+paginate GET "$base/items" next jsonpath "$.next" items jsonpath "$.data" do
+    set $seen $seen + 1
+endpaginate`
+	if problems := dsl.Validate(script); len(problems) != 0 {
+		t.Fatalf("Unexpected problems: %v", problems)
+	}
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("paginate failed: %v", err)
+	}
+	state, _ := dsl.GetVariable("paginate")
+	items := state.(map[string]interface{})["items"].([]interface{})
+	if seen, _ := dsl.GetVariable("seen"); len(items) != 3 || fmt.Sprint(seen) != "2" {
+		t.Errorf("Expected 3 items from 2 pages, got %v after %v pages", items, seen)
+	}
+
+	script = `paginate GET "$base/cursor?limit=1" next jsonpath "$.cursor" param "cursor" items jsonpath "$.data" max 3 pages do
+endpaginate`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("paginate with a cursor failed: %v", err)
+	}
+	state, _ = dsl.GetVariable("paginate")
+	if got := fmt.Sprint(state.(map[string]interface{})["items"]); got != "[a b c]" {
+		t.Errorf("Expected items from 3 pages, got %s", got)
+	}
+
+	if problems := dsl.Validate("paginate GET \"$base/items\" next jsonpath \"$.next\" do\nendloop"); len(problems) != 2 {
+		t.Errorf("Expected a mismatched closer and an unclosed block, got %v", problems)
+	}
+}
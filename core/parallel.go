@@ -0,0 +1,255 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"net/http/cookiejar"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// parallelClause matches the parallel N at the end of a foreach list
+var parallelClause = regexp.MustCompile(`\s+parallel\s+(\S+)$`)
+
+// splitParallel removes a trailing parallel N from the list of a foreach
+// header and returns the list and N as written, "" when there is none
+func splitParallel(list string) (string, string) {
+	match := parallelClause.FindStringSubmatchIndex(list)
+	if match == nil {
+		return list, ""
+	}
+	return list[:match[0]], list[match[2]:match[3]]
+}
+
+// parseWorkers reads the worker count of parallel N; N may be a variable
+func (hd *HTTPDSLv3) parseWorkers(value string) (int, error) {
+	workers, err := strconv.Atoi(hd.expandVariables(value))
+	if err != nil || workers < 1 {
+		return 0, fmt.Errorf("foreach parallel expects a positive number of workers, got %s", value)
+	}
+	return workers, nil
+}
+
+// fork returns an engine for one iteration of a parallel foreach. It has the
+// settings of he and shares its transport, so connections are reused, but it
+// has its own cookie jar, starting with a copy of the cookies of he, and its
+// own history and last response. Sessions are copied, the cookie jars of
+// those not in use shared. Wire traces and log lines of forks are written
+// one at a time to the outputs of he.
+func (he *HTTPEngine) fork() *HTTPEngine {
+	cookies, _ := he.ExportCookies()
+
+	he.mu.RLock()
+	client := *he.client
+	child := &HTTPEngine{
+		client:             &client,
+		baseURL:            he.baseURL,
+		cookieRecorders:    make(map[*cookiejar.Jar]*cookieRecorder),
+		headers:            make(map[string]string, len(he.headers)),
+		debug:              he.debug,
+		logs:               make([]string, 0),
+		logLevel:           he.logLevel,
+		history:            make([]RequestHistory, 0),
+		maxHistory:         he.maxHistory,
+		retryPolicy:        he.retryPolicy,
+		proxy:              he.proxy,
+		tlsConfig:          he.tlsConfig,
+		requestHooks:       he.requestHooks,
+		responseHooks:      he.responseHooks,
+		rateLimit:          he.rateLimit,
+		metrics:            make(map[string]interface{}),
+		sessions:           make(map[string]*Session, len(he.sessions)),
+		currentSession:     he.currentSession,
+		oauth2Config:       he.oauth2Config,
+		resolves:           make(map[string]string, len(he.resolves)),
+		unixSocket:         he.unixSocket,
+		traceIDs:           he.traceIDs,
+		maxResponseSize:    he.maxResponseSize,
+		hostPolicy:         he.hostPolicy,
+		hostHeaders:        he.hostHeaders,
+		redirects:          he.redirects,
+		requestCompression: he.requestCompression,
+		acceptEncoding:     he.acceptEncoding,
+		chaos:              he.chaos,
+		downloadRate:       he.downloadRate,
+		uploadRate:         he.uploadRate,
+		wireTrace:          he.wireTrace,
+		wireOutput:         &lockedWriter{mu: &he.outputLock, w: he.wireWriter()},
+		logOutput:          &lockedWriter{mu: &he.outputLock, w: he.logWriter()},
+	}
+	for name, session := range he.sessions {
+		copied := *session
+		copied.Headers = maps.Clone(session.Headers)
+		copied.Variables = maps.Clone(session.Variables)
+		copied.History = append([]RequestHistory(nil), session.History...)
+		child.sessions[name] = &copied
+	}
+	child.kerberos = maps.Clone(he.kerberos)
+	for key, value := range he.headers {
+		child.headers[key] = value
+	}
+	for key, value := range he.resolves {
+		child.resolves[key] = value
+	}
+	if he.etags != nil {
+		child.etags = make(map[string]validators, len(he.etags))
+		for key, value := range he.etags {
+			child.etags[key] = value
+		}
+	}
+	if he.responses != nil {
+		child.responses = make(map[string]*cachedResponse, len(he.responses))
+		for key, value := range he.responses {
+			child.responses[key] = value
+		}
+	}
+	he.mu.RUnlock()
+
+	jar, _ := cookiejar.New(nil)
+	child.useJar(child.client, jar)
+	child.ImportCookies(cookies)
+	return child
+}
+
+// fork returns an interpreter for one worker of a parallel foreach, with the
+// custom commands and functions, hooks, request templates and snapshot
+// settings of hd
+func (hd *HTTPDSLv3) fork() *HTTPDSLv3 {
+	worker := NewHTTPDSLv3()
+	for _, extend := range hd.extensions {
+		extend(worker)
+	}
+	worker.ctx = hd.ctx
+	worker.beforeHooks = hd.beforeHooks
+	worker.afterHooks = hd.afterHooks
+	for name, request := range hd.templates {
+		worker.templates[name] = request
+	}
+	worker.snapshotDir = hd.snapshotDir
+	worker.updateSnapshots = hd.updateSnapshots
+	for name := range hd.defaultHeaders {
+		worker.defaultHeaders[name] = true
+	}
+	worker.recording = hd.recording
+	worker.stepLine = hd.stepLine
+	return worker
+}
+
+// lockedWriter serializes writes to w under mu, for a writer shared by
+// goroutines; every writer sharing mu waits for the others
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// iterationOutcome is what one iteration of a parallel foreach left behind
+type iterationOutcome struct {
+	ran    bool
+	result *LoopResult
+	steps  []StepResult
+	output []byte // What the iteration printed to the log output
+	err    error
+}
+
+// parallelForeach runs the iterations of foreach ... parallel N do on up to
+// workers goroutines. Every iteration starts from a copy of the variables and
+// gets an engine of its own, so iterations never see each other's variables,
+// cookies or last response, and changes are not kept after the loop. Outputs,
+// steps and the lines printed to the log output are gathered in item order,
+// whatever order the iterations finish in. After a failure or break no new iteration starts, and the error of the
+// first failed item is returned. It returns the outputs and the number of
+// iterations that ran.
+func (hd *HTTPDSLv3) parallelForeach(itemVar string, items []interface{}, body []string, workers int) ([]interface{}, int, error) {
+	if workers > len(items) {
+		workers = len(items)
+	}
+	variables := hd.GetVariables()
+	outcomes := make([]iterationOutcome, len(items))
+
+	// Seeds are drawn in item order, so with a fixed seed every iteration
+	// draws the same random values whichever worker runs it
+	seeds := make([]int64, len(items))
+	for idx := range seeds {
+		seeds[idx] = hd.random.Int63()
+	}
+
+	var next atomic.Int64
+	var stop atomic.Bool
+	var wg sync.WaitGroup
+	forks := make([]*HTTPDSLv3, workers)
+	for n := range forks {
+		worker := hd.fork()
+		forks[n] = worker
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				idx := int(next.Add(1)) - 1
+				if idx >= len(items) || stop.Load() || hd.ctx.Err() != nil {
+					return
+				}
+
+				var output bytes.Buffer
+				worker.engine = hd.engine.fork()
+				worker.engine.SetLogOutput(&lockedWriter{mu: &sync.Mutex{}, w: &output})
+				worker.SetRandomSeed(seeds[idx])
+				worker.varsLock.Lock()
+				worker.variables = make(map[string]interface{}, len(variables)+3)
+				for name, value := range variables {
+					worker.variables[name] = value
+				}
+				worker.variables[itemVar] = items[idx]
+				worker.variables["_index"] = idx
+				worker.variables["_iteration"] = idx + 1
+				worker.varsLock.Unlock()
+				worker.steps = nil
+
+				result, err := worker.ProcessLoopBody(body)
+				outcomes[idx] = iterationOutcome{ran: true, result: result, steps: worker.steps, output: output.Bytes(), err: err}
+				if err != nil || result != nil && result.ShouldBreak {
+					stop.Store(true)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for _, worker := range forks {
+		hd.usedRandom = hd.usedRandom || worker.usedRandom
+	}
+
+	var results []interface{}
+	iterations := 0
+	for idx, outcome := range outcomes {
+		if !outcome.ran {
+			continue
+		}
+		iterations++
+		hd.emitIteration("foreach", idx+1)
+		hd.engine.writeLog(outcome.output)
+		if hd.recording {
+			hd.steps = append(hd.steps, outcome.steps...)
+		}
+		if outcome.err != nil {
+			return results, iterations, fmt.Errorf("error in foreach iteration %d: %w", idx+1, outcome.err)
+		}
+		for _, res := range outcome.result.Results {
+			if res != nil && res != "" {
+				results = append(results, res)
+			}
+		}
+	}
+	if err := hd.ctx.Err(); err != nil {
+		return results, iterations, err
+	}
+	return results, iterations, nil
+}
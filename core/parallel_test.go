@@ -0,0 +1,173 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestHTTPDSLv3ParallelForeach(t *testing.T) {
+	var active, peak atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+			return
+		}
+		now := active.Add(1)
+		defer active.Add(-1)
+		for {
+			max := peak.Load()
+			if now <= max || peak.CompareAndSwap(max, now) {
+				break
+			}
+		}
+		time.Sleep(30 * time.Millisecond)
+		// Every item gets a cookie that other iterations must not send
+		http.SetCookie(w, &http.Cookie{Name: "item" + strings.TrimPrefix(r.URL.Path, "/items/"), Value: "1", Path: "/"})
+		w.Write([]byte(r.Header.Get("Cookie")))
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.RegisterFunction("double", func(n int) int { return n * 2 })
+	dsl.SetVariable("base", server.URL)
+	script := `GET "$base/login"
+set $ids "[1, 2, 3, 4, 5, 6, 7, 8]"
+set $workers 3
+foreach $id in $ids parallel $workers do
+    GET "$base/items/$id"
+    extract body as $cookies
+    set $n double($_iteration)
+    print "$id $n $cookies"
+endloop`
+	result, err := dsl.ParseWithBlockSupport(script)
+	if err != nil {
+		t.Fatalf("parallel foreach failed: %v", err)
+	}
+	results := result.([]interface{})
+	if p := peak.Load(); p < 2 || p > 3 {
+		t.Errorf("Expected between 2 and 3 concurrent requests, got %d", p)
+	}
+
+	var printed []string
+	for _, output := range results {
+		if line, ok := output.(string); ok && len(line) > 0 && line[0] >= '1' && line[0] <= '8' {
+			printed = append(printed, line)
+		}
+	}
+	var expected []string
+	for i := 1; i <= 8; i++ {
+		expected = append(expected, fmt.Sprintf("%d %d session=abc", i, i*2))
+	}
+	if !reflect.DeepEqual(printed, expected) {
+		t.Errorf("Unexpected iteration output:\n got %q\nwant %q", printed, expected)
+	}
+	if last := results[len(results)-1]; last != "Foreach executed for 8 items on 3 workers" {
+		t.Errorf("Unexpected summary %v", last)
+	}
+	if _, ok := dsl.GetVariable("cookies"); ok {
+		t.Error("Expected iteration variables to stay in the iterations")
+	}
+
+	script = `foreach $id in ["1", "2", "3"] parallel 2 do
+    GET "$base/items/$id"
+    assert status 404
+endloop`
+	if _, err := dsl.ParseWithBlockSupport(script); err == nil || !strings.Contains(err.Error(), "error in foreach iteration 1") {
+		t.Errorf("Expected the first iteration to fail, got %v", err)
+	}
+	if problems := dsl.Validate("foreach $id in $ids parallel 0 do\nendloop"); len(problems) != 1 {
+		t.Errorf("Expected parallel 0 to be rejected, got %v", problems)
+	}
+}
+
+func TestHTTPEngineForkCopiesFields(t *testing.T) {
+	// State of the last request or of the fork itself, which starts empty
+	fresh := map[string]bool{
+		"mu": true, "lastResponse": true, "lastResponseBody": true, "lastStatusCode": true,
+		"lastResponseTime": true, "lastTiming": true, "cookies": true, "cookieRecorders": true,
+		"logs": true, "history": true, "lastRequestTime": true, "metrics": true, "metricsLock": true,
+		"lastResponseSize": true, "lastRedirects": true, "lastConnection": true, "lastRawSize": true,
+		"stream": true, "lastCacheStatus": true, "outputLock": true,
+	}
+	// Copied in a way of their own, checked below
+	special := map[string]bool{"client": true, "wireOutput": true, "logOutput": true}
+
+	he := NewHTTPEngine()
+	parent := reflect.ValueOf(he).Elem()
+	for i := 0; i < parent.NumField(); i++ {
+		name := parent.Type().Field(i).Name
+		if !fresh[name] && !special[name] {
+			fillValue(settable(parent.Field(i)))
+		}
+	}
+	var wire, log bytes.Buffer
+	he.wireOutput, he.logOutput = &wire, &log
+
+	child := reflect.ValueOf(he.fork()).Elem()
+	for i := 0; i < parent.NumField(); i++ {
+		name := parent.Type().Field(i).Name
+		if fresh[name] || special[name] {
+			continue
+		}
+		want := settable(parent.Field(i)).Interface()
+		if got := settable(child.Field(i)).Interface(); !reflect.DeepEqual(got, want) {
+			t.Errorf("fork does not copy %s: got %v, want %v", name, got, want)
+		}
+	}
+
+	forked := child.Addr().Interface().(*HTTPEngine)
+	if forked.client == he.client || forked.client.Transport != he.client.Transport {
+		t.Error("Expected the fork to have its own client on the same transport")
+	}
+	fmt.Fprint(forked.wireOutput, "wire")
+	fmt.Fprint(forked.logOutput, "log")
+	if wire.String() != "wire" || log.String() != "log" {
+		t.Errorf("Expected the fork to write to the outputs of the engine, got %q and %q", wire.String(), log.String())
+	}
+}
+
+// fillValue sets v to a value other than its zero value, so a field fork
+// forgets to copy shows up as a difference
+func fillValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1)
+	case reflect.String:
+		v.SetString("x")
+	case reflect.Pointer:
+		v.Set(reflect.New(v.Type().Elem()))
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		key := reflect.New(v.Type().Key()).Elem()
+		value := reflect.New(v.Type().Elem()).Elem()
+		fillValue(key)
+		fillValue(value)
+		m.SetMapIndex(key, value)
+		v.Set(m)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			fillValue(settable(v.Field(i)))
+		}
+	}
+}
+
+// settable returns an unexported field as a value that can be set
+func settable(field reflect.Value) reflect.Value {
+	return reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem()
+}
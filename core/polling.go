@@ -0,0 +1,102 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Defaults of wait for and assert eventually when the script gives no
+// timeout or interval
+const (
+	defaultPollTimeout  = 30 * time.Second
+	defaultPollInterval = time.Second
+)
+
+// pollDuration converts the NUMBER and time unit arguments of a rule to a
+// duration
+func (hd *HTTPDSLv3) pollDuration(number, unit interface{}) (time.Duration, error) {
+	values := make([]string, 2)
+	for i, arg := range []interface{}{number, unit} {
+		value, err := hd.evaluate(arg)
+		if err != nil {
+			return 0, err
+		}
+		values[i] = formatValue(value)
+	}
+	amount, err := strconv.ParseFloat(values[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %s", values[0])
+	}
+	if values[1] == "s" {
+		amount *= 1000
+	}
+	return time.Duration(amount * float64(time.Millisecond)), nil
+}
+
+// waitForStatus sends a request again every interval until it answers with
+// the expected status. Connection errors count as not ready yet, so it can
+// wait for a service that is still starting.
+func (hd *HTTPDSLv3) waitForStatus(request interface{}, status int, timeout, interval time.Duration) (interface{}, error) {
+	deadline := time.Now().Add(timeout)
+	for attempt := 1; ; attempt++ {
+		var last string
+		result, err := hd.evaluate(request)
+		if err != nil {
+			if ctxErr := hd.ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			last = err.Error()
+		} else {
+			got := 0
+			if response, ok := result.(map[string]interface{}); ok {
+				got, _ = response["status"].(int)
+			}
+			if got == status {
+				return fmt.Sprintf("Ready after %d attempt(s): status %d", attempt, status), nil
+			}
+			last = fmt.Sprintf("status %d", got)
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			return nil, fmt.Errorf("not ready after %v and %d attempt(s), expected status %d, last got %s",
+				timeout, attempt, status, last)
+		}
+		if err := hd.engine.WaitContext(hd.ctx, int(interval/time.Millisecond)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// assertEventually checks an assertion every interval until it passes. The
+// request is sent before every check; without one the last request is sent
+// again, after a first check against the response already received.
+func (hd *HTTPDSLv3) assertEventually(request, assertion interface{}, timeout, interval time.Duration) (interface{}, error) {
+	deadline := time.Now().Add(timeout)
+	for attempt := 1; ; attempt++ {
+		var err error
+		switch {
+		case request != nil:
+			_, err = hd.evaluate(request)
+		case attempt > 1:
+			_, err = hd.replay(len(hd.engine.GetHistory()))
+		}
+
+		var result interface{}
+		if err == nil {
+			if result, err = hd.evaluate(assertion); err == nil {
+				return fmt.Sprintf("%v (after %d attempt(s))", result, attempt), nil
+			}
+		}
+		if ctxErr := hd.ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			return nil, fmt.Errorf("still failing after %v and %d attempt(s): %w", timeout, attempt, err)
+		}
+		if err := hd.engine.WaitContext(hd.ctx, int(interval/time.Millisecond)); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPDSLv3WaitFor(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	result, err := dsl.Parse(`wait for GET "$base/health" status 200 timeout 2 s interval 10 ms`)
+	if err != nil {
+		t.Fatalf("wait for failed: %v", err)
+	}
+	if calls != 3 || !strings.Contains(fmt.Sprint(result), "3 attempt") {
+		t.Errorf("Expected 3 attempts, got %d calls and %v", calls, result)
+	}
+
+	_, err = dsl.Parse(`wait for GET "$base/health" status 201 timeout 50 ms interval 10 ms`)
+	if err == nil || !strings.Contains(err.Error(), "last got status 200") {
+		t.Errorf("Expected wait for to time out, got %v", err)
+	}
+}
+
+func TestHTTPDSLv3AssertEventually(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		state := "PENDING"
+		if calls >= 3 {
+			state = "DONE"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"state": %q, "count": %d}`, state, calls)
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	script := `GET "$base/jobs/1"
+assert eventually jsonpath "$.state" equals "DONE" timeout 2 s interval 10 ms`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("assert eventually failed: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected the last request to be sent 3 times, got %d", calls)
+	}
+
+	result, err := dsl.Parse(`assert eventually GET "$base/jobs/1" jsonpath "$.count" equals 5 timeout 2 s interval 10 ms`)
+	if err != nil {
+		t.Fatalf("assert eventually with a request failed: %v", err)
+	}
+	if calls != 5 || !strings.Contains(fmt.Sprint(result), "2 attempt") {
+		t.Errorf("Expected 2 more attempts, got %d calls and %v", calls, result)
+	}
+
+	_, err = dsl.Parse(`assert eventually jsonpath "$.state" equals "FAILED" timeout 50 ms interval 10 ms`)
+	if err == nil || !strings.Contains(err.Error(), `expected FAILED`) {
+		t.Errorf("Expected assert eventually to time out, got %v", err)
+	}
+}
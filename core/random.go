@@ -0,0 +1,77 @@
+package core
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+)
+
+// randomAlphabet holds the characters of random_string
+const randomAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// SetRandomSeed seeds the random functions. Runs with the same seed draw the
+// same values, so a run that failed with random data can be replayed.
+func (hd *HTTPDSLv3) SetRandomSeed(seed int64) {
+	hd.randomSeed = seed
+	hd.random = rand.New(rand.NewSource(seed))
+}
+
+// RandomSeed returns the seed of the random functions, set with
+// SetRandomSeed or set random seed, or picked when the interpreter was created
+func (hd *HTTPDSLv3) RandomSeed() int64 {
+	return hd.randomSeed
+}
+
+// UsedRandom reports whether the script drew random values, which makes its
+// seed worth reporting when it fails
+func (hd *HTTPDSLv3) UsedRandom() bool {
+	return hd.usedRandom
+}
+
+// registerRandomFunctions adds the functions that draw from the seeded
+// source: random(min, max), random_string(n), random_email() and uuid()
+func (hd *HTTPDSLv3) registerRandomFunctions() {
+	hd.registerFunction("random", reflect.ValueOf(hd.randomInt))
+	hd.registerFunction("random_string", reflect.ValueOf(hd.randomString))
+	hd.registerFunction("random_email", reflect.ValueOf(hd.randomEmail))
+	hd.registerFunction("uuid", reflect.ValueOf(hd.randomUUID))
+}
+
+// randomInt returns an integer between min and max, both included
+func (hd *HTTPDSLv3) randomInt(min, max int) (int, error) {
+	if max < min {
+		return 0, fmt.Errorf("max %d is less than min %d", max, min)
+	}
+	hd.usedRandom = true
+	return min + hd.random.Intn(max-min+1), nil
+}
+
+// randomString returns length lowercase letters and digits
+func (hd *HTTPDSLv3) randomString(length int) (string, error) {
+	if length < 0 {
+		return "", fmt.Errorf("negative length %d", length)
+	}
+	hd.usedRandom = true
+	chars := make([]byte, length)
+	for i := range chars {
+		chars[i] = randomAlphabet[hd.random.Intn(len(randomAlphabet))]
+	}
+	return string(chars), nil
+}
+
+// randomEmail returns an address at example.com, which is reserved for
+// documentation and never delivers mail
+func (hd *HTTPDSLv3) randomEmail() string {
+	name, _ := hd.randomString(10)
+	return name + "@example.com"
+}
+
+// randomUUID returns a version 4 UUID drawn from the seeded source
+func (hd *HTTPDSLv3) randomUUID() string {
+	hd.usedRandom = true
+	var b [16]byte
+	hd.random.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
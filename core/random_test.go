@@ -0,0 +1,57 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTTPDSLv3RandomSeed(t *testing.T) {
+	script := `set random seed 42
+set $n random(1, 6)
+set $s random_string(12)
+set $e random_email()
+set $u uuid()
+set $ids "[1, 2, 3, 4]"
+foreach $id in $ids parallel 2 do
+    set $r random(1, 1000000)
+    print "$id: $r"
+endloop`
+	run := func() (map[string]interface{}, interface{}) {
+		dsl := NewHTTPDSLv3()
+		result, err := dsl.ParseWithBlockSupport(script)
+		if err != nil {
+			t.Fatalf("random script failed: %v", err)
+		}
+		if !dsl.UsedRandom() || dsl.RandomSeed() != 42 {
+			t.Errorf("Expected seed 42 to be used, got %d used %v", dsl.RandomSeed(), dsl.UsedRandom())
+		}
+		return dsl.GetVariables(), result
+	}
+
+	first, firstResult := run()
+	second, secondResult := run()
+	if !reflect.DeepEqual(first, second) || !reflect.DeepEqual(firstResult, secondResult) {
+		t.Errorf("Expected the same values with the same seed:\n%v\n%v", first, second)
+	}
+	if n := first["n"].(int); n < 1 || n > 6 {
+		t.Errorf("Expected random(1, 6) between 1 and 6, got %d", n)
+	}
+	if s := first["s"].(string); len(s) != 12 || strings.Trim(s, randomAlphabet) != "" {
+		t.Errorf("Unexpected random string %q", s)
+	}
+	if e := first["e"].(string); !strings.HasSuffix(e, "@example.com") {
+		t.Errorf("Unexpected random email %q", e)
+	}
+	if u := first["u"].(string); len(u) != 36 || u[14] != '4' {
+		t.Errorf("Unexpected uuid %q", u)
+	}
+
+	dsl := NewHTTPDSLv3()
+	if _, err := dsl.Parse(`set $n random(5, 1)`); err == nil {
+		t.Error("Expected an error for random(5, 1)")
+	}
+	if dsl.UsedRandom() {
+		t.Error("Expected no random values drawn")
+	}
+}
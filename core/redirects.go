@@ -0,0 +1,104 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RedirectHop is a redirect followed on the way to a response
+type RedirectHop struct {
+	Method   string // Method of the request that was redirected
+	URL      string // URL that answered with the redirect
+	Status   int    // Status of the redirect, like 301 or 302
+	Location string // URL the redirect led to
+}
+
+// redirectPolicy is how redirects are followed. The zero value follows them
+// quietly, like http.Client.
+type redirectPolicy struct {
+	stop          bool // Return redirects as the response instead of following them
+	log           bool // Print every redirect followed to the log output
+	denyCrossHost bool // Fail requests redirected to another host
+}
+
+// checkRedirect wraps a client's redirect check so redirects follow the
+// policy and those followed are appended to hops
+func (he *HTTPEngine) checkRedirect(policy redirectPolicy, hops *[]RedirectHop, next func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if policy.stop {
+			return http.ErrUseLastResponse
+		}
+		from := via[len(via)-1]
+		if policy.denyCrossHost && !strings.EqualFold(req.URL.Hostname(), via[0].URL.Hostname()) {
+			return &policyError{fmt.Sprintf("redirect from %s to %s denied: cross-host redirects are denied", from.URL, req.URL)}
+		}
+		if next != nil {
+			if err := next(req, via); err != nil {
+				return err
+			}
+		} else if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+
+		hop := RedirectHop{Method: from.Method, URL: from.URL.String(), Location: req.URL.String()}
+		if req.Response != nil {
+			hop.Status = req.Response.StatusCode
+		}
+		*hops = append(*hops, hop)
+		he.LogInfo("Redirect %d %s %s -> %s", hop.Status, hop.Method, hop.URL, hop.Location)
+		if policy.log {
+			he.printLine("  ↪ %d %s %s -> %s", hop.Status, hop.Method, hop.URL, hop.Location)
+		}
+		return nil
+	}
+}
+
+// SetFollowRedirects sets whether redirects are followed. When they are not,
+// the redirect itself is the response, so its status and Location header can
+// be asserted.
+func (he *HTTPEngine) SetFollowRedirects(follow bool) {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	he.redirects.stop = !follow
+}
+
+// SetRedirectLogging sets whether every redirect followed is printed to the
+// log output
+func (he *HTTPEngine) SetRedirectLogging(enabled bool) {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	he.redirects.log = enabled
+}
+
+// SetDenyCrossHostRedirects makes a request fail when it is redirected to a
+// host other than the one it was sent to, instead of silently following it
+// there with its headers
+func (he *HTTPEngine) SetDenyCrossHostRedirects(deny bool) {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	he.redirects.denyCrossHost = deny
+}
+
+// GetLastRedirects returns the redirects followed by the last request, in
+// order; none when it was answered directly
+func (he *HTTPEngine) GetLastRedirects() []RedirectHop {
+	he.mu.RLock()
+	defer he.mu.RUnlock()
+	return append([]RedirectHop(nil), he.lastRedirects...)
+}
+
+// redirectValues turns hops into the values of extract redirects
+func redirectValues(hops []RedirectHop) []interface{} {
+	values := make([]interface{}, len(hops))
+	for i, hop := range hops {
+		values[i] = map[string]interface{}{
+			"method":   hop.Method,
+			"url":      hop.URL,
+			"status":   hop.Status,
+			"location": hop.Location,
+		}
+	}
+	return values
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPDSLv3RedirectPolicy(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("other"))
+	}))
+	defer other.Close()
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/moved", http.StatusMovedPermanently)
+		case "/moved":
+			http.Redirect(w, r, "/new", http.StatusFound)
+		case "/away":
+			http.Redirect(w, r, otherURL+"/landing", http.StatusFound)
+		default:
+			w.Write([]byte("new"))
+		}
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	script := `GET "$base/old"
+assert status 200
+extract redirects count as $hops
+extract redirects as $redirects
+GET "$base/new"
+extract redirects count as $none
+on redirect stop
+GET "$base/old"
+assert status 301
+assert header "Location" matches "^/moved$"
+on redirect follow
+deny cross-host redirects
+GET "$base/old"
+assert status 200`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("redirect script failed: %v", err)
+	}
+	if hops, _ := dsl.GetVariable("hops"); hops != 2 {
+		t.Errorf("Expected 2 redirects, got %v", hops)
+	}
+	if none, _ := dsl.GetVariable("none"); none != 0 {
+		t.Errorf("Expected no redirects, got %v", none)
+	}
+	redirects, _ := dsl.GetVariable("redirects")
+	expected := []interface{}{
+		map[string]interface{}{"method": "GET", "url": server.URL + "/old", "status": 301, "location": server.URL + "/moved"},
+		map[string]interface{}{"method": "GET", "url": server.URL + "/moved", "status": 302, "location": server.URL + "/new"},
+	}
+	if !reflect.DeepEqual(redirects, expected) {
+		t.Errorf("Unexpected redirects:\n got %v\nwant %v", redirects, expected)
+	}
+	history := dsl.GetEngine().GetHistory()
+	if len(history[0].Redirects) != 2 || len(history[1].Redirects) != 0 {
+		t.Errorf("Expected the hops of each request in the history, got %d and %d",
+			len(history[0].Redirects), len(history[1].Redirects))
+	}
+
+	_, err := dsl.ParseWithBlockSupport(`GET "$base/away"`)
+	if err == nil || !strings.Contains(err.Error(), "cross-host redirects are denied") {
+		t.Errorf("Expected the cross-host redirect to be denied, got %v", err)
+	}
+	if _, err := dsl.ParseWithBlockSupport(`allow cross-host redirects
+GET "$base/away"
+assert response contains "other"`); err != nil {
+		t.Errorf("Expected the cross-host redirect to be followed, got %v", err)
+	}
+	if _, err := dsl.Parse(`on redirect wander`); err == nil || !strings.Contains(err.Error(), "expected on redirect follow, log or stop") {
+		t.Errorf("Expected an invalid mode error, got %v", err)
+	}
+}
+
+func TestHTTPDSLv3RedirectLogParallel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := strings.CutPrefix(r.URL.Path, "/hop/"); ok {
+			// Earlier items answer later, so they finish out of order
+			n, _ := strconv.Atoi(id)
+			time.Sleep(time.Duration(4-n) * 20 * time.Millisecond)
+			http.Redirect(w, r, "/done/"+id, http.StatusFound)
+			return
+		}
+		w.Write([]byte("done"))
+	}))
+	defer server.Close()
+
+	var output bytes.Buffer
+	dsl := NewHTTPDSLv3()
+	dsl.GetEngine().SetLogOutput(&output)
+	dsl.SetVariable("base", server.URL)
+	script := `on redirect log
+foreach $id in ["1", "2", "3"] parallel 3 do
+    GET "$base/hop/$id"
+    assert status 200
+endloop`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("parallel redirect script failed: %v", err)
+	}
+
+	var expected strings.Builder
+	for id := 1; id <= 3; id++ {
+		fmt.Fprintf(&expected, "  ↪ 302 GET %s/hop/%d -> %s/done/%d\n", server.URL, id, server.URL, id)
+	}
+	if output.String() != expected.String() {
+		t.Errorf("Expected the redirects in item order on the log output:\n got %q\nwant %q", output.String(), expected.String())
+	}
+}
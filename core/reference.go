@@ -0,0 +1,355 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CommandDoc documents a DSL keyword for editor tooling and reference output
+type CommandDoc struct {
+	Keyword  string    // Keyword as written in scripts
+	Summary  string    // One line description
+	Syntax   []string  // Accepted forms, rendered from the grammar rules
+	Rules    []RuleDoc // Forms of the <rules> the syntax refers to, like <option>
+	Examples []string  // Statements using the keyword
+}
+
+// RuleDoc documents a grammar rule that command syntax refers to
+type RuleDoc struct {
+	Name    string   // Rule as shown in the syntax, without the <>
+	Element string   // Rule the list is made of, for lists like option_list
+	Forms   []string // Accepted forms of the rule, or of its element
+}
+
+// commandSummaries holds the hand-written descriptions of the main keywords.
+// Syntax is never written here; it is always derived from the grammar.
+var commandSummaries = map[string]string{
+	"GET":           "Send an HTTP GET request",
+	"POST":          "Send an HTTP POST request",
+	"PUT":           "Send an HTTP PUT request",
+	"DELETE":        "Send an HTTP DELETE request",
+	"PATCH":         "Send an HTTP PATCH request",
+	"HEAD":          "Send an HTTP HEAD request",
+	"OPTIONS":       "Send an HTTP OPTIONS request",
+	"CONNECT":       "Send an HTTP CONNECT request",
+	"TRACE":         "Send an HTTP TRACE request; trace id auto adds request ids to later requests, trace on dumps requests and responses",
+	"METHOD":        "Send a request with a method that has no keyword, like PROPFIND or PURGE",
+	"graphql":       "POST a GraphQL query, inline or from a file, with its variables",
+	"submit":        "Fill a form of the last HTML response and send it, with its hidden fields",
+	"invoke":        "Send a request template declared with request \"<name>\" ... end, with variables overridden",
+	"form":          "Pick the form of submit form by #id, .class or name",
+	"query":         "Give the GraphQL query of a graphql request, or read it with query file",
+	"variables":     "Give the variables of a graphql request as JSON or an object variable",
+	"header":        "Add a request header (request option)",
+	"body":          "Set a raw request body, or take it from the last response (request option)",
+	"binary":        "Send a body file as raw bytes, without expanding variables",
+	"template":      "Render a body file with text/template and the script variables (request option)",
+	"tls":           "Bound the TLS handshake of a request with tls timeout or timeout tls",
+	"json":          "Set a JSON request body and Content-Type (request option)",
+	"auth":          "Authenticate the request with basic or bearer credentials, or get a service token for later requests",
+	"negotiate":     "Authenticate a request with Kerberos (SPNEGO) from the credentials cache or a keytab",
+	"keytab":        "Log in to Kerberos with the keys of a keytab file, for auth negotiate on CI",
+	"oidc":          "Get a token with auth oidc client_credentials from a token URL, client id and secret",
+	"azure":         "Get a token for an Azure AD app with auth azure tenant ... client ... secret ... scope",
+	"google":        "Get a token for a Google service account key file with auth google service account",
+	"scope":         "Give the scopes of a service token, separated by spaces",
+	"save":          "Stream the response body to a file instead of memory (request option)",
+	"retry":         "Send a request again after network errors and 429, 502, 503 or 504 (request option)",
+	"backoff":       "Set the first wait between retries; max backoff caps the doubling waits (request option)",
+	"jitter":        "Move every wait between retries at random by up to a percentage (request option)",
+	"throttle":      "Limit the download or upload bandwidth of a request, or of later requests",
+	"stream":        "Read a response as it arrives, for assert stream receives and extract stream lines (request option)",
+	"receives":      "Wait for text on a stream with assert stream receives ... within",
+	"within":        "Give how long assert stream receives waits",
+	"lines":         "Extract the lines a stream has received with extract stream lines",
+	"compress":      "Compress request bodies with gzip, deflate or br, for later requests or one request",
+	"accept":        "Set the Accept-Encoding header of later requests",
+	"raw":           "Extract the size of the last body as received, before decoding",
+	"csrf":          "Extract the CSRF token of the last response from a meta tag, hidden input, header or cookie",
+	"links":         "Extract the absolute URLs of every href and src of the last HTML response",
+	"cache":         "Answer GETs from fresh responses with cache on, assert cache hit or miss, or send ETag validators back with cache etags on",
+	"etags":         "Turn the ETag cache on or off, or forget its validators with clear etags",
+	"dns":           "Resolve a host into a variable: all addresses, or A, AAAA, CNAME or TXT records",
+	"lookup":        "Resolve a host with dns lookup",
+	"timeout":       "Set the request timeout, or separate connect, tls and read timeouts",
+	"set":           "Assign the result of an expression or the response of a request to a variable, or set max response size",
+	"unset":         "Remove a variable, or a field of an object variable",
+	"increment":     "Add 1, or the number after by, to a variable; $n += 2 does the same",
+	"decrement":     "Subtract 1, or the number after by, from a variable; $n -= 2 does the same",
+	"by":            "Give the amount of increment or decrement",
+	"var":           "Assign the result of an expression to a variable (alias of set)",
+	"print":         "Print a variable or an interpolated string",
+	"vars":          "Print every variable with its type, masking passwords, tokens and other secrets",
+	"length":        "Return the length of a string, array or object variable, or of a jsonpath of the last response",
+	"bytes":         "Return the size of a variable in UTF-8 bytes",
+	"split":         "Split a string variable into an array",
+	"jq":            "Query the last JSON response with a jq-like path, piped through functions like length or map",
+	"random":        "Return a random integer between two bounds, or seed the random functions with set random seed",
+	"seed":          "Seed the random functions with set random seed, to replay a run",
+	"random_string": "Return random lowercase letters and digits of a given length",
+	"random_email":  "Return a random address at example.com",
+	"uuid":          "Return a random version 4 UUID",
+	"extract":       "Extract data from the last response into a variable",
+	"load":          "Read a JSON or YAML fixture file into a variable",
+	"yaml":          "Read a fixture as YAML with load",
+	"link":          "Extract a link of the Link header by relation type, or follow rel next in paginate",
+	"rel":           "Name the relation type of the link to extract",
+	"if":            "Run statements only when a condition holds",
+	"elseif":        "Test another condition when the conditions before it do not hold",
+	"else":          "Statements to run when the if condition does not hold",
+	"endif":         "Close a multiline if block",
+	"repeat":        "Run a block a fixed number of times, or until a condition holds",
+	"until":         "Close a repeat block with the condition that ends it",
+	"for":           "Run a block once for every integer of a range, or send headers and auth only to one host",
+	"step":          "Set the increment of a for range",
+	"switch":        "Run the case matching a value",
+	"case":          "Start a branch of a switch for one or more values",
+	"endswitch":     "Close a switch block",
+	"while":         "Run a block while a condition holds",
+	"foreach":       "Run a block once for every item of an array or row of a CSV file, N at a time with parallel N",
+	"endloop":       "Close a loop block",
+	"break":         "Exit the innermost loop",
+	"continue":      "Skip to the next loop iteration",
+	"assert":        "Fail the script when the last response does not match",
+	"expect":        "Alias of assert",
+	"wait":          "Pause execution, or send a request until it answers with a status",
+	"interval":      "Set the time between attempts of wait for and assert eventually",
+	"eventually":    "Retry an assertion until it passes, sending the request again",
+	"benchmark":     "Send a request many times and report latency statistics",
+	"concurrency":   "Set how many requests benchmark sends at once",
+	"paginate":      "Run a block once for every page of a paginated API, following next links",
+	"endpaginate":   "Close a paginate block",
+	"snapshot":      "Compare the last response with a stored snapshot, saving it on the first run",
+	"diff":          "Compare two responses, variables or files and fail when they differ",
+	"file":          "Read a file: a diff operand, a graphql query or a request body",
+	"allow":         "Only let requests reach hosts matching the patterns",
+	"deny":          "Refuse requests to hosts matching the patterns, or to private networks",
+	"hosts":         "List host patterns of allow hosts and deny hosts",
+	"redirect":      "Follow redirects quietly, print each one or stop at them with on redirect follow, log or stop",
+	"redirects":     "Deny or allow cross-host redirects, or extract the redirects the last request followed",
+	"cross-host":    "Refuse redirects to another host with deny cross-host redirects",
+	"connection":    "Assert whether the last request reused a kept-alive connection or opened a new one",
+	"private":       "Refuse loopback, private and link-local addresses with deny private networks",
+	"proxy":         "Send requests through the HTTP_PROXY, HTTPS_PROXY and NO_PROXY proxies, or clear proxy",
+	"sleep":         "Pause execution (alias of wait)",
+	"log":           "Write a message to the engine log, or set the verbosity with log level",
+	"debug":         "Write a debug message to the engine log, or turn debug output on or off",
+	"chaos":         "Delay or fail a share of later requests to test resilience; clear chaos stops it",
+	"latency":       "Delay later requests with chaos latency, optionally only a share of them",
+	"probability":   "Give the share of requests, from 0 to 1, that a chaos fault hits",
+	"fail":          "Fail a share of later requests with a network error with chaos fail",
+	"clear":         "Clear engine state such as cookies, default headers, host headers, the host policy, the proxy, etags, chaos or throttle",
+	"reset":         "Reset the engine and all variables",
+	"base":          "Set the base URL used for relative requests",
+	"default":       "Set a variable only when it is undefined, a header sent with every later request, the value of a missing extraction, or the default branch of a switch",
+	"history":       "List or show the requests sent so far",
+	"show":          "Show a request of the history, or the last response with show response",
+	"pretty":        "Indent the JSON body printed by show response pretty",
+	"replay":        "Send a request from the history again",
+}
+
+// commandExamples holds statements showing the main keywords in use. Tests
+// check every one against the grammar, so they cannot go stale.
+var commandExamples = map[string][]string{
+	"GET": {
+		`GET "https://api.example.com/users"`,
+		`GET "$base_url/users" header "Accept" "application/json" timeout 5 s`,
+	},
+	"POST": {
+		`POST "$base_url/users" json {"name": "Ada"}`,
+		`POST "$base_url/login" header "Content-Type" "text/plain" body "hello"`,
+	},
+	"PUT":    {`PUT "$base_url/users/$id" json {"name": "Ada"}`},
+	"DELETE": {`DELETE "$base_url/users/$id" header "Authorization" "Bearer $token"`},
+	"set": {
+		`set $count 0`,
+		`set $total $price * $quantity`,
+		`set $label $status == 200 ? "ok" : "fail"`,
+		`set $user GET "$base_url/users/1"`,
+	},
+	"increment": {`increment $count`, `increment $total by $price`},
+	"decrement": {`decrement $retries`, `decrement $stock by 2`},
+	"default":   {`default $timeout 30`},
+	"unset":     {`unset $tmp`},
+	"print":     {`print "Created user $id"`},
+	"extract": {
+		`extract jsonpath "$.id" as $id`,
+		`extract header "X-Request-ID" as $request_id`,
+		`extract status as $status`,
+	},
+	"assert": {
+		`assert status 200`,
+		`assert jsonpath "$.name" equals "Ada"`,
+		`assert time less 500 ms`,
+		`assert response contains "ok"`,
+	},
+	"for": {
+		`for host "api.internal" header "X-Internal" "1"`,
+		`for host "auth.example.com" auth basic "$user" "$password"`,
+		`for host "*.partner.com" auth bearer "$token"`,
+	},
+	"redirect": {`on redirect log`, `on redirect stop`},
+	"redirects": {
+		`deny cross-host redirects`,
+		`extract redirects count as $hops`,
+		`extract redirects as $redirects`,
+	},
+	"if":      {`if $status == 200 then print "ok" else print "failed"`},
+	"while":   {`while $page < 5 do increment $page endloop`},
+	"repeat":  {`repeat 3 times do GET "$base_url/health" endloop`},
+	"foreach": {`foreach $user in $users do print "$user" endloop`},
+	"wait":    {`wait 500 ms`},
+	"jq":      {`set $names jq ".users[].name"`},
+	"length":  {`set $count length $items`, `set $count length jsonpath "$.items"`},
+	"invoke":  {`invoke "create user" with $name "Ada"`},
+	"load":    {`load json "fixtures/user.json" as $user`},
+	"vars":    {`vars`},
+	"show":    {`show response pretty`},
+}
+
+// DescribeCommand returns documentation for a keyword. The syntax forms are
+// rendered from the grammar so they always match what the parser accepts.
+//
+// Example:
+//
+//	if doc, ok := hd.DescribeCommand("extract"); ok {
+//	    fmt.Println(doc.Summary)
+//	    for _, form := range doc.Syntax {
+//	        fmt.Println("  " + form)
+//	    }
+//	}
+func (hd *HTTPDSLv3) DescribeCommand(keyword string) (*CommandDoc, bool) {
+	var tokenName string
+	keywords := make(map[string]bool)
+	for _, kw := range hd.Keywords() {
+		keywords[kw] = true
+		if tokenName == "" && strings.EqualFold(kw, keyword) {
+			tokenName = kw
+		}
+	}
+	if tokenName == "" {
+		return nil, false
+	}
+
+	gc := hd.newGrammarChecker()
+	forms := make(map[string][]string)
+
+	// Render every alternative that starts with the keyword. When the keyword
+	// is the only symbol of an alternative (like http_method -> GET), render the
+	// rules that start with that wrapper rule instead.
+	for rule, alternatives := range gc.rules {
+		for _, seq := range alternatives {
+			if len(seq) == 0 || seq[0] != tokenName {
+				continue
+			}
+			if len(seq) > 1 {
+				forms[gc.render(seq, keywords)] = seq
+				continue
+			}
+			for _, parentAlts := range gc.rules {
+				for _, parent := range parentAlts {
+					if len(parent) > 1 && parent[0] == rule {
+						expanded := append([]string{tokenName}, parent[1:]...)
+						forms[gc.render(expanded, keywords)] = expanded
+					}
+				}
+			}
+		}
+	}
+
+	summary, hasSummary := commandSummaries[tokenName]
+	examples := commandExamples[tokenName]
+	if len(forms) == 0 && !hasSummary && len(examples) == 0 {
+		return nil, false
+	}
+	if !hasSummary {
+		summary = fmt.Sprintf("Keyword %s", tokenName)
+	}
+
+	doc := &CommandDoc{Keyword: tokenName, Summary: summary, Examples: examples}
+	for form := range forms {
+		doc.Syntax = append(doc.Syntax, form)
+	}
+	sort.Strings(doc.Syntax)
+
+	// Document the rules the forms refer to, in the order they first appear
+	seen := make(map[string]bool)
+	for _, form := range doc.Syntax {
+		for _, symbol := range forms[form] {
+			if seen[symbol] || len(gc.rules[symbol]) == 0 {
+				continue
+			}
+			seen[symbol] = true
+			if rule, ok := gc.describeRule(symbol, keywords); ok {
+				doc.Rules = append(doc.Rules, rule)
+			}
+		}
+	}
+	return doc, true
+}
+
+// describeRule renders the forms of a rule, or of the element of a list rule
+// like option_list. Rules that only choose between other rules, like
+// statement, tell nothing of their own and are left out.
+func (gc *grammarChecker) describeRule(name string, keywords map[string]bool) (RuleDoc, bool) {
+	rule := RuleDoc{Name: name}
+	target := name
+	if element := gc.listElement(name); element != "" {
+		rule.Element, target = element, element
+	}
+
+	informative := false
+	forms := make(map[string]bool)
+	for _, seq := range gc.rules[target] {
+		if len(seq) != 1 || len(gc.rules[seq[0]]) == 0 {
+			informative = true
+		}
+		forms[gc.render(seq, keywords)] = true
+	}
+	if !informative {
+		return rule, false
+	}
+	for form := range forms {
+		rule.Forms = append(rule.Forms, form)
+	}
+	sort.Strings(rule.Forms)
+	return rule, true
+}
+
+// listElement returns X when a rule is a list of one or more X, written as
+// rule: X | rule X or as rule: X | X rule, and "" otherwise
+func (gc *grammarChecker) listElement(name string) string {
+	alternatives := gc.rules[name]
+	if len(alternatives) != 2 {
+		return ""
+	}
+	for i, single := range alternatives {
+		other := alternatives[1-i]
+		if len(single) != 1 || len(other) != 2 {
+			continue
+		}
+		element := single[0]
+		if other[0] == name && other[1] == element || other[0] == element && other[1] == name {
+			return element
+		}
+	}
+	return ""
+}
+
+// render formats a rule sequence for humans: keywords stay as written while
+// token classes and sub-rules are shown as <placeholders>.
+func (gc *grammarChecker) render(seq []string, keywords map[string]bool) string {
+	parts := make([]string, len(seq))
+	for i, symbol := range seq {
+		switch {
+		case keywords[symbol], strings.Trim(symbol, "()[],%?:") == "":
+			parts[i] = symbol
+		case len(gc.rules[symbol]) > 0:
+			parts[i] = "<" + symbol + ">"
+		default:
+			parts[i] = "<" + strings.ToLower(symbol) + ">"
+		}
+	}
+	return strings.Join(parts, " ")
+}
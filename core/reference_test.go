@@ -0,0 +1,47 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestHTTPDSLv3DescribeCommand tests the reference of a keyword: syntax and
+// rules from the grammar, and examples that must parse
+func TestHTTPDSLv3DescribeCommand(t *testing.T) {
+	dsl := NewHTTPDSLv3()
+
+	doc, ok := dsl.DescribeCommand("get")
+	if !ok {
+		t.Fatal("Expected GET to be documented")
+	}
+	if doc.Keyword != "GET" || !strings.Contains(strings.Join(doc.Syntax, "\n"), "GET <url_value> <option_list>") {
+		t.Errorf("Unexpected syntax: %+v", doc)
+	}
+	var options *RuleDoc
+	for i := range doc.Rules {
+		if doc.Rules[i].Name == "option_list" {
+			options = &doc.Rules[i]
+		}
+	}
+	if options == nil || options.Element != "option" || !strings.Contains(strings.Join(options.Forms, "\n"), "header <string> <string>") {
+		t.Errorf("Expected the options of a request, got %+v", doc.Rules)
+	}
+
+	if doc, _ := dsl.DescribeCommand("foreach"); len(doc.Rules) != 0 {
+		t.Errorf("Expected rules that only choose between others to be left out, got %+v", doc.Rules)
+	}
+	if _, ok := dsl.DescribeCommand("nonsense"); ok {
+		t.Error("Expected no reference for an unknown keyword")
+	}
+
+	for keyword, examples := range commandExamples {
+		if _, ok := dsl.DescribeCommand(keyword); !ok {
+			t.Errorf("Examples of %s, which is not a keyword", keyword)
+		}
+		for _, example := range examples {
+			if problems := dsl.Validate(example); len(problems) > 0 {
+				t.Errorf("Example of %s does not parse: %s: %v", keyword, example, problems)
+			}
+		}
+	}
+}
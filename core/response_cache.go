@@ -0,0 +1,266 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cache statuses of a GET sent while the response cache is on
+const (
+	cacheHit  = "hit"
+	cacheMiss = "miss"
+)
+
+// cachedResponse is a response kept by the client cache
+type cachedResponse struct {
+	status  string
+	code    int
+	proto   string
+	header  http.Header
+	body    []byte
+	rawSize int
+	stored  time.Time
+	age     time.Duration     // Age the response already had when stored
+	expires time.Time         // When it stops being fresh
+	vary    map[string]string // Request headers it varies on, with their values
+}
+
+// SetResponseCache turns the client cache on or off. While on, responses to
+// GET that Cache-Control max-age or Expires mark as fresh are kept per URL
+// and later GETs are answered from them without a request until they expire,
+// like a browser cache. no-store and no-cache responses and Vary: * are not
+// kept; a request with Cache-Control no-cache or max-age=0 goes to the
+// server. Turning it off forgets the kept responses.
+func (he *HTTPEngine) SetResponseCache(enabled bool) {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	he.lastCacheStatus = ""
+	if !enabled {
+		he.responses = nil
+		return
+	}
+	if he.responses == nil {
+		he.responses = make(map[string]*cachedResponse)
+	}
+}
+
+// ClearResponseCache forgets the kept responses but keeps the cache on
+func (he *HTTPEngine) ClearResponseCache() {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	if he.responses != nil {
+		he.responses = make(map[string]*cachedResponse)
+	}
+}
+
+// GetLastCacheStatus returns "hit" when the last GET was answered by the
+// response cache, "miss" when it went to the server, and "" when the cache
+// is off or the last request was not a GET
+func (he *HTTPEngine) GetLastCacheStatus() string {
+	he.mu.RLock()
+	defer he.mu.RUnlock()
+	return he.lastCacheStatus
+}
+
+// cachedFor returns the fresh response kept for a request, if any
+func (he *HTTPEngine) cachedFor(key string, req *http.Request) (*cachedResponse, bool) {
+	if req.Method != http.MethodGet || bypassesCache(req.Header) {
+		return nil, false
+	}
+	he.mu.RLock()
+	entry := he.responses[key]
+	he.mu.RUnlock()
+	if entry == nil || !time.Now().Before(entry.expires) {
+		return nil, false
+	}
+	for name, value := range entry.vary {
+		if req.Header.Get(name) != value {
+			return nil, false
+		}
+	}
+	return entry, true
+}
+
+// serveCached answers a request from the cache: the kept response becomes the
+// last response, with an Age header, and goes into the history
+func (he *HTTPEngine) serveCached(req *http.Request, entry *cachedResponse, reqBody string, dump io.Writer) map[string]interface{} {
+	header := entry.header.Clone()
+	age := entry.age + time.Since(entry.stored)
+	header.Set("Age", strconv.Itoa(int(age.Seconds())))
+	resp := &http.Response{
+		Status:     entry.status,
+		StatusCode: entry.code,
+		Proto:      entry.proto,
+		Header:     header,
+		Body:       http.NoBody,
+		Request:    req,
+	}
+	body := string(entry.body)
+
+	he.mu.Lock()
+	he.lastResponse = resp
+	he.lastResponseBody = body
+	he.lastResponseSize = len(entry.body)
+	he.lastRawSize = entry.rawSize
+	he.lastStatusCode = entry.code
+	he.lastResponseTime = 0
+	he.lastTiming = RequestTiming{}
+	he.lastCacheStatus = cacheHit
+	he.lastRedirects = nil
+	he.lastConnection = connectionUse{}
+	he.addToHistory(req, resp, reqBody, body, 0, RequestTiming{}, nil, false)
+	he.mu.Unlock()
+
+	if dump != nil {
+		fmt.Fprintf(dump, "* served from the response cache\n")
+		traceResponse(dump, resp, entry.body, false)
+	}
+	he.LogInfo("%s %s - Status: %d, served from cache, Size: %d bytes",
+		req.Method, req.URL, entry.code, len(entry.body))
+
+	return map[string]interface{}{
+		"method":   req.Method,
+		"url":      req.URL.String(),
+		"status":   entry.code,
+		"body":     body,
+		"headers":  header,
+		"time":     0.0,
+		"size":     len(entry.body),
+		"raw_size": entry.rawSize,
+		"timing":   RequestTiming{},
+		"cache":    cacheHit,
+	}
+}
+
+// storeResponse keeps a response to GET when it is fresh and records the
+// cache status of the request; other methods make the cache forget the URL,
+// since they may change it. complete is false when the body was not kept in
+// full. Callers hold he.mu.
+func (he *HTTPEngine) storeResponse(key string, req *http.Request, resp *http.Response, body []byte, rawSize int, complete bool) {
+	he.lastCacheStatus = ""
+	if he.responses == nil {
+		return
+	}
+	if req.Method != http.MethodGet {
+		if req.Method != http.MethodHead && req.Method != http.MethodOptions {
+			delete(he.responses, key)
+		}
+		return
+	}
+	he.lastCacheStatus = cacheMiss
+
+	now := time.Now()
+	lifetime, age, ok := freshness(resp, now)
+	if !ok || !complete || hasDirective(req.Header, "no-store") {
+		delete(he.responses, key)
+		return
+	}
+	vary := make(map[string]string)
+	for _, line := range resp.Header.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				vary[name] = req.Header.Get(name)
+			}
+		}
+	}
+	he.responses[key] = &cachedResponse{
+		status:  resp.Status,
+		code:    resp.StatusCode,
+		proto:   resp.Proto,
+		header:  resp.Header.Clone(),
+		body:    append([]byte(nil), body...),
+		rawSize: rawSize,
+		stored:  now,
+		age:     age,
+		expires: now.Add(lifetime - age),
+		vary:    vary,
+	}
+}
+
+// freshness returns how long a response stays fresh and the age it already
+// has, from Cache-Control max-age or else Expires and Date. Responses without
+// an explicit lifetime, with no-store, no-cache or Vary: *, partial ones and
+// those already stale are not cacheable.
+func freshness(resp *http.Response, now time.Time) (time.Duration, time.Duration, bool) {
+	switch {
+	case resp.StatusCode == http.StatusPartialContent, resp.StatusCode == http.StatusNotModified:
+		return 0, 0, false
+	case hasDirective(resp.Header, "no-store"), hasDirective(resp.Header, "no-cache"):
+		return 0, 0, false
+	case strings.TrimSpace(resp.Header.Get("Vary")) == "*":
+		return 0, 0, false
+	}
+
+	var age time.Duration
+	if seconds, err := strconv.Atoi(strings.TrimSpace(resp.Header.Get("Age"))); err == nil && seconds > 0 {
+		age = time.Duration(seconds) * time.Second
+	}
+
+	var lifetime time.Duration
+	if seconds, ok := directiveSeconds(resp.Header, "max-age"); ok {
+		lifetime = time.Duration(seconds) * time.Second
+	} else if expires := resp.Header.Get("Expires"); expires != "" {
+		at, err := http.ParseTime(expires)
+		if err != nil {
+			return 0, 0, false
+		}
+		date := now
+		if sent, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+			date = sent
+		}
+		lifetime = at.Sub(date)
+	} else {
+		return 0, 0, false
+	}
+	return lifetime, age, lifetime > age
+}
+
+// bypassesCache reports whether a request asks for a response from the
+// server, with Cache-Control no-cache, no-store or max-age=0, or Pragma
+func bypassesCache(header http.Header) bool {
+	if hasDirective(header, "no-cache") || hasDirective(header, "no-store") {
+		return true
+	}
+	if seconds, ok := directiveSeconds(header, "max-age"); ok && seconds == 0 {
+		return true
+	}
+	return strings.EqualFold(strings.TrimSpace(header.Get("Pragma")), "no-cache")
+}
+
+// cacheDirectives returns the Cache-Control directives of a header, by
+// lowercase name, with their values unquoted
+func cacheDirectives(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, line := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name != "" {
+				directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+			}
+		}
+	}
+	return directives
+}
+
+// hasDirective reports whether Cache-Control holds a directive
+func hasDirective(header http.Header, name string) bool {
+	_, ok := cacheDirectives(header)[name]
+	return ok
+}
+
+// directiveSeconds returns the seconds of a directive like max-age=60
+func directiveSeconds(header http.Header, name string) (int, bool) {
+	value, ok := cacheDirectives(header)[name]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return seconds, true
+}
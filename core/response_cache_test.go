@@ -0,0 +1,74 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHTTPDSLv3ResponseCache(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/nostore":
+			w.Header().Set("Cache-Control", "no-store, max-age=60")
+		case "/expired":
+			w.Header().Set("Expires", "Wed, 14 Oct 2020 10:00:00 GMT")
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+		}
+		fmt.Fprintf(w, `{"n": %d}`, hits.Load())
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	script := `cache on
+GET "$base/fresh"
+assert cache miss
+GET "$base/fresh"
+assert cache hit
+assert status 200
+assert header "Age" matches "^[0-9]+$"
+GET "$base/fresh" header "Cache-Control" "no-cache"
+assert cache miss
+GET "$base/nostore"
+GET "$base/nostore"
+assert cache miss
+GET "$base/expired"
+GET "$base/expired"
+assert cache miss
+GET "$base/vary" header "Accept-Language" "en"
+GET "$base/vary" header "Accept-Language" "en"
+assert cache hit
+GET "$base/vary" header "Accept-Language" "es"
+assert cache miss
+POST "$base/fresh"
+GET "$base/fresh"
+assert cache miss`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("response cache script failed: %v", err)
+	}
+	if got := hits.Load(); got != 10 {
+		t.Errorf("Expected 10 requests to reach the server, got %d", got)
+	}
+	if body := dsl.GetEngine().GetLastResponse(); body != `{"n": 10}` {
+		t.Errorf("Unexpected body after invalidation: %s", body)
+	}
+
+	if _, err := dsl.ParseWithBlockSupport(`cache off
+GET "$base/fresh"
+assert cache hit`); err == nil || !strings.Contains(err.Error(), "cache on") {
+		t.Errorf("Expected assert cache to fail with the cache off, got %v", err)
+	}
+	if _, err := dsl.Parse(`cache maybe`); err == nil || !strings.Contains(err.Error(), "expected cache on or cache off") {
+		t.Errorf("Expected an invalid setting error, got %v", err)
+	}
+}
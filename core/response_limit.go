@@ -0,0 +1,95 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// byteUnits are the units accepted by set max response size
+var byteUnits = map[string]int64{
+	"b":  1,
+	"kb": 1 << 10,
+	"mb": 1 << 20,
+	"gb": 1 << 30,
+}
+
+// truncationMarker ends a body cut at the max response size, so the cut is
+// visible in output and assertions instead of looking like a short response
+const truncationMarker = "\n[truncated: response exceeds the max response size of %d bytes]"
+
+// SetMaxResponseSize limits how many bytes of a response body are kept in
+// memory; the rest is discarded and the kept part ends with a truncation
+// marker. Zero or less removes the limit.
+func (he *HTTPEngine) SetMaxResponseSize(bytes int64) {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	if bytes < 0 {
+		bytes = 0
+	}
+	he.maxResponseSize = bytes
+}
+
+// MaxResponseSize returns the body size limit, zero when there is none
+func (he *HTTPEngine) MaxResponseSize() int64 {
+	he.mu.RLock()
+	defer he.mu.RUnlock()
+	return he.maxResponseSize
+}
+
+// parseByteSize reads the size of set max response size: a number with an
+// optional unit (b, kb, mb or gb, bytes by default), or off for no limit
+func parseByteSize(value string, unit []interface{}) (int64, error) {
+	if strings.EqualFold(value, "off") {
+		return 0, nil
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid response size %s, expected a number and b, kb, mb or gb, or off", value)
+	}
+	multiplier := int64(1)
+	if len(unit) > 0 {
+		name, _ := unit[0].(string)
+		var ok bool
+		if multiplier, ok = byteUnits[strings.ToLower(name)]; !ok {
+			return 0, fmt.Errorf("unknown size unit %s, expected b, kb, mb or gb", name)
+		}
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// readBody reads a response body up to limit bytes, or all of it when limit
+// is zero. It returns the bytes read and whether the body was longer.
+func readBody(body io.Reader, limit int64) ([]byte, bool, error) {
+	if limit <= 0 {
+		data, err := io.ReadAll(body)
+		return data, false, err
+	}
+	data, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(data)) > limit {
+		return data[:limit], true, nil
+	}
+	return data, false, nil
+}
+
+// saveBody streams a response body to a file without keeping it in memory
+// and returns the number of bytes written
+func saveBody(body io.Reader, name string) (int64, error) {
+	file, err := os.Create(name)
+	if err != nil {
+		return 0, err
+	}
+	written, err := io.Copy(file, body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return written, fmt.Errorf("saving response to %s: %w", name, err)
+	}
+	return written, nil
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHTTPDSLv3MaxResponseSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat("x", 3000))
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	saved := filepath.Join(t.TempDir(), "big.txt")
+	dsl.SetVariable("saved", saved)
+	script := `set max response size 1 kb
+GET "$base/big"
+extract size as $kept
+extract body as $body
+GET "$base/big" save to "$saved"
+extract size as $written
+set max response size off
+GET "$base/big"
+extract size as $full`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("max response size failed: %v", err)
+	}
+	body, _ := dsl.GetVariable("body")
+	if kept, _ := dsl.GetVariable("kept"); kept != 1024 || !strings.HasPrefix(body.(string), strings.Repeat("x", 1024)+"\n[truncated:") {
+		t.Errorf("Expected 1024 bytes and a truncation marker, got %v bytes: %.40q", kept, body)
+	}
+	if written, _ := dsl.GetVariable("written"); written != 3000 {
+		t.Errorf("Expected 3000 bytes saved, got %v", written)
+	}
+	if data, err := os.ReadFile(saved); err != nil || len(data) != 3000 {
+		t.Errorf("Expected the whole body in the file, got %d bytes (%v)", len(data), err)
+	}
+	if full, _ := dsl.GetVariable("full"); full != 3000 {
+		t.Errorf("Expected no limit after off, got %v bytes", full)
+	}
+
+	if _, err := dsl.Parse("set max response size 10 parsecs"); err == nil {
+		t.Error("Expected an unknown unit to fail")
+	}
+}
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// networkError is a request that was sent but got no response, like a
+// refused connection, a reset or a timeout
+type networkError struct {
+	err error
+}
+
+func (e *networkError) Error() string {
+	return e.err.Error()
+}
+
+func (e *networkError) Unwrap() error {
+	return e.err
+}
+
+// defaultRetryPolicy is the policy of the retry option when the engine has
+// none: 200ms doubling between attempts, on network errors and on the
+// statuses that usually mean a busy or restarting server
+var defaultRetryPolicy = RetryPolicy{
+	InitialBackoff: 200 * time.Millisecond,
+	Multiplier:     2,
+	RetryOn:        []int{429, 502, 503, 504},
+}
+
+// RequestWithRetryContext performs a request, sending it again while the
+// retry policy allows. The policy is the engine's, set with SetRetryPolicy,
+// changed for this request by the retry, backoff, max_backoff (milliseconds)
+// and jitter (a fraction) options; without either the request is sent once.
+// When the engine's policy runs out of retries the request fails with "max
+// retries exceeded". With the retry option the last attempt is returned as
+// usual instead, even with a status the policy retries, so assertions can
+// report it.
+func (he *HTTPEngine) RequestWithRetryContext(ctx context.Context, method, urlStr string, options map[string]interface{}) (interface{}, error) {
+	he.mu.RLock()
+	policy := retryPolicyFor(he.retryPolicy, options)
+	he.mu.RUnlock()
+
+	if policy == nil {
+		return he.RequestContext(ctx, method, urlStr, options)
+	}
+	_, keepLast := options["retry"].(int)
+
+	for attempt := 0; ; attempt++ {
+		result, err := he.RequestContext(ctx, method, urlStr, options)
+		reason := policy.retryReason(result, err)
+		if reason != "" && attempt >= policy.MaxRetries && !keepLast && ctx.Err() == nil {
+			if err != nil {
+				return nil, fmt.Errorf("max retries exceeded after %d retries: %w", attempt, err)
+			}
+			return nil, fmt.Errorf("max retries exceeded after %d retries: %s", attempt, reason)
+		}
+		if reason == "" || attempt >= policy.MaxRetries || ctx.Err() != nil {
+			if err != nil && attempt > 0 {
+				err = fmt.Errorf("%w (after %d retries)", err, attempt)
+			}
+			return result, err
+		}
+
+		wait := policy.backoff(attempt)
+		he.LogInfo("Retry attempt %d/%d after %v: %s", attempt+1, policy.MaxRetries, wait, reason)
+		if err := he.WaitContext(ctx, int(wait.Milliseconds())); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryPolicyFor returns the policy of a request: base changed by the retry
+// options, or nil when the request is sent once. Base is never modified.
+func retryPolicyFor(base *RetryPolicy, options map[string]interface{}) *RetryPolicy {
+	retries, hasRetry := options["retry"].(int)
+	if base == nil && !hasRetry {
+		return nil
+	}
+
+	policy := defaultRetryPolicy
+	if base != nil {
+		policy = *base
+	}
+	if hasRetry {
+		policy.MaxRetries = retries
+	}
+	if backoff, ok := options["backoff"].(int); ok {
+		policy.InitialBackoff = time.Duration(backoff) * time.Millisecond
+	}
+	if maxBackoff, ok := options["max_backoff"].(int); ok {
+		policy.MaxBackoff = time.Duration(maxBackoff) * time.Millisecond
+	}
+	if jitter, ok := options["jitter"].(float64); ok {
+		policy.Jitter = jitter
+	}
+	return &policy
+}
+
+// retryReason says why the outcome of an attempt should be retried, or
+// returns "" when it should not
+func (p *RetryPolicy) retryReason(result interface{}, err error) string {
+	if err != nil {
+		var failed *networkError
+		if !p.SkipNetworkErrors && errors.As(err, &failed) {
+			return err.Error()
+		}
+		return ""
+	}
+	response, _ := result.(map[string]interface{})
+	status, _ := response["status"].(int)
+	for _, retryStatus := range p.RetryOn {
+		if status == retryStatus {
+			return fmt.Sprintf("status %d", status)
+		}
+	}
+	return ""
+}
+
+// backoff returns the wait after the attempt numbered from 0: the initial
+// backoff times the multiplier for every earlier retry, up to the max
+// backoff, then moved at random by up to the jitter fraction
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	wait := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt))
+	if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+		wait = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		wait *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(wait)
+}
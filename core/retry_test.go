@@ -0,0 +1,121 @@
+package core
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPDSLv3RetryBackoff(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	start := time.Now()
+	script := `GET "$base/flaky" retry 3 times backoff 20 ms max backoff 30 ms jitter 10%
+assert status 200`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("retry script failed: %v", err)
+	}
+	if n := attempts.Load(); n != 3 {
+		t.Errorf("Expected 3 attempts, got %d", n)
+	}
+	// Waits of 20ms and 30ms (capped), each within 10%
+	if elapsed := time.Since(start); elapsed < 45*time.Millisecond {
+		t.Errorf("Expected the backoff to be waited, took %v", elapsed)
+	}
+
+	// Out of retries, the last response is kept for the assertions
+	attempts.Store(-10)
+	if _, err := dsl.ParseWithBlockSupport(`GET "$base/flaky" retry 1 times backoff 1 ms
+assert status 503`); err != nil {
+		t.Errorf("Expected the last 503 to be kept: %v", err)
+	}
+
+	// Network errors are retried; the listener is closed so connections fail
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := listener.Addr().String()
+	listener.Close()
+	_, err := dsl.Parse(`GET "http://` + addr + `/" retry 2 times backoff 1 ms`)
+	if err == nil || !strings.Contains(err.Error(), "after 2 retries") {
+		t.Errorf("Expected the refused connection to be retried twice, got %v", err)
+	}
+
+	if _, err := dsl.Parse(`GET "$base/flaky" retry 1 times jitter 150%`); err == nil {
+		t.Error("Expected jitter over 100% to be rejected")
+	}
+
+	policy := &RetryPolicy{InitialBackoff: 100 * time.Millisecond, Multiplier: 2, MaxBackoff: time.Second, Jitter: 0.2}
+	for attempt, want := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		if wait := policy.backoff(attempt); wait < want*8/10 || wait > want*12/10 {
+			t.Errorf("Backoff %d: expected %v ± 20%%, got %v", attempt, want, wait)
+		}
+	}
+}
+
+func TestHTTPEngineRetryPolicy(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		switch r.URL.Path {
+		case "/busy":
+			// Not kept alive, as the transport itself resends a request
+			// dropped on a reused connection
+			w.Header().Set("Connection", "close")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/drop":
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer server.Close()
+
+	he := NewHTTPEngine()
+	he.SetRetryPolicy(&RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond, Multiplier: 1, RetryOn: []int{503}})
+
+	// Out of retries, the engine's policy fails instead of returning the response
+	result, err := he.RequestWithRetry("GET", server.URL+"/busy", nil)
+	if err == nil || !strings.Contains(err.Error(), "max retries exceeded") || result != nil {
+		t.Errorf("Expected max retries exceeded, got %v, %v", result, err)
+	}
+	if n := attempts.Swap(0); n != 3 {
+		t.Errorf("Expected 3 attempts on 503, got %d", n)
+	}
+
+	// Network errors are retried unless the policy skips them
+	_, err = he.RequestWithRetry("GET", server.URL+"/drop", nil)
+	var failed *networkError
+	if err == nil || !strings.Contains(err.Error(), "max retries exceeded") || !errors.As(err, &failed) {
+		t.Errorf("Expected max retries exceeded on a network error, got %v", err)
+	}
+	if n := attempts.Swap(0); n != 3 {
+		t.Errorf("Expected 3 attempts on a dropped connection, got %d", n)
+	}
+	he.SetRetryPolicy(&RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond, SkipNetworkErrors: true})
+	if _, err := he.RequestWithRetry("GET", server.URL+"/drop", nil); err == nil || strings.Contains(err.Error(), "max retries exceeded") {
+		t.Errorf("Expected the network error without retries, got %v", err)
+	}
+	if n := attempts.Swap(0); n != 1 {
+		t.Errorf("Expected 1 attempt with network errors skipped, got %d", n)
+	}
+
+	result, err = he.RequestWithRetry("GET", server.URL+"/ok", nil)
+	if response, _ := result.(map[string]interface{}); err != nil || response["status"] != 200 {
+		t.Errorf("Expected the response, got %v, %v", result, err)
+	}
+}
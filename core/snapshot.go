@@ -0,0 +1,109 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultSnapshotDir is where snapshot keeps its files unless SetSnapshotDir
+// chooses another directory
+const defaultSnapshotDir = "__snapshots__"
+
+// SetSnapshotDir sets the directory of the files written and compared by the
+// snapshot command. Relative paths are relative to the working directory.
+func (hd *HTTPDSLv3) SetSnapshotDir(dir string) {
+	hd.snapshotDir = dir
+}
+
+// SetUpdateSnapshots makes the snapshot command overwrite stored snapshots
+// with the current response instead of comparing them
+func (hd *HTTPDSLv3) SetUpdateSnapshots(update bool) {
+	hd.updateSnapshots = update
+}
+
+// snapshotPath returns the file that stores the snapshot with a name
+func (hd *HTTPDSLv3) snapshotPath(name string) (string, error) {
+	if name == "" || strings.Contains(name, "..") {
+		return "", fmt.Errorf("invalid snapshot name %q", name)
+	}
+	dir := hd.snapshotDir
+	if dir == "" {
+		dir = defaultSnapshotDir
+	}
+	return filepath.Join(dir, filepath.FromSlash(name)+".snap"), nil
+}
+
+// normalizeSnapshot renders a response body as stored in a snapshot. JSON is
+// indented with its keys sorted, so formatting and key order never show up as
+// differences; other bodies are kept as they are.
+func normalizeSnapshot(body string) (string, bool) {
+	data, err := decodeJSON([]byte(body))
+	if err != nil {
+		return body, false
+	}
+	indented, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return body, false
+	}
+	return string(indented), true
+}
+
+// snapshot compares the last response with the snapshot stored under a name,
+// ignoring the listed JSON fields. The first run, or any run with updates on,
+// stores the response instead.
+func (hd *HTTPDSLv3) snapshot(name string, ignoreFields []string) (interface{}, error) {
+	path, err := hd.snapshotPath(name)
+	if err != nil {
+		return nil, err
+	}
+	body := hd.engine.GetLastResponse()
+	normalized, isJSON := normalizeSnapshot(body)
+
+	stored, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) || hd.updateSnapshots {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, fmt.Errorf("snapshot %s: %w", name, err)
+		}
+		if err := os.WriteFile(path, []byte(normalized+"\n"), 0o644); err != nil {
+			return nil, fmt.Errorf("snapshot %s: %w", name, err)
+		}
+		return fmt.Sprintf("✓ Snapshot %s saved to %s", name, path), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", name, err)
+	}
+
+	expected := strings.TrimSuffix(string(stored), "\n")
+	if isJSON {
+		if err := CompareJSON(expected, body, ignoreFields); err != nil {
+			return nil, fmt.Errorf("assertion failed: response does not match snapshot %s: %v", name, err)
+		}
+	} else if expected != normalized {
+		return nil, fmt.Errorf("assertion failed: response does not match snapshot %s: %s", name, firstLineDiff(expected, normalized))
+	}
+	return fmt.Sprintf("✓ Response matches snapshot %s", name), nil
+}
+
+// firstLineDiff describes the first line where two texts differ
+func firstLineDiff(expected, actual string) string {
+	want := strings.Split(expected, "\n")
+	got := strings.Split(actual, "\n")
+	for i := 0; i < len(want) || i < len(got); i++ {
+		var w, g string
+		if i < len(want) {
+			w = want[i]
+		}
+		if i < len(got) {
+			g = got[i]
+		}
+		if i >= len(want) || i >= len(got) || w != g {
+			return fmt.Sprintf("line %d: expected %q, got %q", i+1, w, g)
+		}
+	}
+	return "texts differ"
+}
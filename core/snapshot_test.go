@@ -0,0 +1,55 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHTTPDSLv3Snapshot(t *testing.T) {
+	body := `{"users": [{"id": 1, "name": "Ada"}], "generated_at": "10:00"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	dsl := NewHTTPDSLv3()
+	dsl.SetSnapshotDir(dir)
+	dsl.SetVariable("base", server.URL)
+	script := `GET "$base/users"
+snapshot "users_list" ignoring "generated_at"`
+
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("First snapshot run failed: %v", err)
+	}
+	stored, err := os.ReadFile(filepath.Join(dir, "users_list.snap"))
+	if err != nil || !strings.Contains(string(stored), "\n  \"users\": [") {
+		t.Fatalf("Expected an indented snapshot file, got %q (%v)", stored, err)
+	}
+
+	// Key order and ignored fields do not count as differences
+	body = `{"generated_at": "11:00", "users": [{"name": "Ada", "id": 1}]}`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Errorf("Expected the response to match the snapshot: %v", err)
+	}
+
+	body = `{"users": [{"id": 1, "name": "Grace"}], "generated_at": "12:00"}`
+	_, err = dsl.ParseWithBlockSupport(script)
+	if err == nil || !strings.Contains(err.Error(), `$.users[0].name: expected "Ada", got "Grace"`) {
+		t.Errorf("Expected a snapshot mismatch, got %v", err)
+	}
+
+	dsl.SetUpdateSnapshots(true)
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("Updating the snapshot failed: %v", err)
+	}
+	if stored, _ := os.ReadFile(filepath.Join(dir, "users_list.snap")); !strings.Contains(string(stored), "Grace") {
+		t.Errorf("Expected the snapshot to be updated, got %q", stored)
+	}
+}
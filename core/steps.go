@@ -0,0 +1,151 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// StepResult is the outcome of one statement executed by RunScript. Statements
+// inside blocks, loops and hooks are steps too; block markers are not.
+type StepResult struct {
+	Kind     string                 // request, assertion, variable, print, conditional, loop, control, command, utility or statement (unparsed)
+	Line     int                    // 1-based line of the top-level statement or block that ran the step
+	Source   string                 // Statement text, header continuations included
+	Request  *StepRequest           // Request sent by the step, nil when it sent none
+	Response map[string]interface{} // Response data (status, body, headers, time, size, timing), nil without a request
+	Passed   bool                   // False when the statement failed, like an assertion that did not hold
+	Output   interface{}            // Value the statement returned
+	Err      error                  // Why the step failed, nil when it passed
+	Duration time.Duration          // Time spent running the statement
+}
+
+// StepRequest summarizes the request sent by a step
+type StepRequest struct {
+	Method string
+	URL    string
+}
+
+// RunScript runs a script like ParseWithBlockSupport and returns one
+// StepResult per statement executed, in the order the statements started.
+// On failure the last step holds the error that stopped the script.
+//
+// Example:
+//
+//	steps, err := hd.RunScript(script)
+//	for _, step := range steps {
+//	    if step.Kind == "assertion" && !step.Passed {
+//	        fmt.Printf("line %d: %v\n", step.Line, step.Err)
+//	    }
+//	}
+func (hd *HTTPDSLv3) RunScript(script string) ([]StepResult, error) {
+	hd.steps, hd.recording = nil, true
+	defer func() { hd.steps, hd.recording = nil, false }()
+
+	_, err := hd.ParseWithBlockSupport(script)
+	return hd.steps, err
+}
+
+// RunScriptContext is like RunScript but bound to ctx, as in ParseContext
+func (hd *HTTPDSLv3) RunScriptContext(ctx context.Context, script string) ([]StepResult, error) {
+	var steps []StepResult
+	_, err := hd.withContext(ctx, func() (interface{}, error) {
+		var err error
+		steps, err = hd.RunScript(script)
+		return nil, err
+	})
+	return steps, err
+}
+
+// startStep reports the start of a statement to listeners and, while a
+// script is being recorded, adds its step. It returns the step index or -1.
+func (hd *HTTPDSLv3) startStep(source, kind string) int {
+	hd.emit(Event{Type: EventStatementStart, Source: source, Kind: kind})
+	if !hd.recording {
+		return -1
+	}
+	hd.steps = append(hd.steps, StepResult{
+		Kind:   kind,
+		Line:   hd.stepLine,
+		Source: source,
+	})
+	return len(hd.steps) - 1
+}
+
+// finishStep reports the outcome of a statement to listeners and stores it in
+// the step started at index
+func (hd *HTTPDSLv3) finishStep(index int, source, kind string, output interface{}, err error, duration time.Duration) {
+	if kind == "assertion" {
+		if err != nil {
+			hd.emit(Event{Type: EventAssertionFailed, Source: source, Kind: kind, Err: err})
+		} else {
+			hd.emit(Event{Type: EventAssertionPassed, Source: source, Kind: kind})
+		}
+	}
+	hd.emit(Event{Type: EventStatementEnd, Source: source, Kind: kind, Output: output, Err: err, Duration: duration})
+
+	if index < 0 || index >= len(hd.steps) {
+		return
+	}
+	step := &hd.steps[index]
+	step.Output = output
+	step.Err = err
+	step.Passed = err == nil
+	step.Duration = duration
+
+	if response, ok := output.(map[string]interface{}); ok {
+		if method, ok := response["method"].(string); ok {
+			url, _ := response["url"].(string)
+			step.Request = &StepRequest{Method: method, URL: url}
+			step.Response = response
+		}
+	}
+}
+
+// stepKind classifies a parsed statement by the action at its root. Lines
+// holding several statements are classified by the first one.
+func stepKind(tree interface{}) string {
+	node, ok := tree.(*astNode)
+	for ok && len(node.args) == 1 {
+		switch node.action {
+		case "executeProgram":
+			statements, _ := node.args[0].([]interface{})
+			if len(statements) == 0 {
+				return "statement"
+			}
+			node, ok = statements[0].(*astNode)
+		case "executeSingleStatement", "passthrough":
+			node, ok = node.args[0].(*astNode)
+		default:
+			return actionKind(node.action)
+		}
+	}
+	if !ok {
+		return "statement"
+	}
+	return actionKind(node.action)
+}
+
+// actionKind maps the action of a statement to its step kind
+func actionKind(action string) string {
+
+	switch {
+	case action == "httpSimple", action == "httpWithOptions", action == "replayCmd", action == "graphqlRequest", action == "submitForm", action == "invokeTemplate", action == "setResponse":
+		return "request"
+	case action == "doAssertion", action == "assertEventually", action == "snapshotCmd", action == "diffCmd":
+		return "assertion"
+	case action == "setVariable", action == "setConditional", action == "defaultVariable", action == "unsetVariable", action == "updateVariable", action == "loadVariable", action == "dnsLookupVariable", strings.HasPrefix(action, "extract"):
+		return "variable"
+	case strings.HasPrefix(action, "print"):
+		return "print"
+	case strings.HasPrefix(action, "if"):
+		return "conditional"
+	case strings.HasSuffix(action, "Loop"):
+		return "loop"
+	case action == "breakCmd", action == "continueCmd":
+		return "control"
+	case strings.HasPrefix(action, "command:"):
+		return "command"
+	}
+	return "utility"
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestHTTPDSLv3RunScriptSteps(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": 7}`))
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	script := `GET "$base/users"
+extract jsonpath "$.id" as $id
+
+repeat 2 times do
+    print "item $_iteration"
+endloop
+assert status 201`
+
+	steps, err := dsl.RunScript(script)
+	if err == nil {
+		t.Fatal("Expected the failing assertion to stop the script")
+	}
+
+	var kinds []string
+	for _, step := range steps {
+		kinds = append(kinds, step.Kind)
+	}
+	expected := []string{"request", "variable", "print", "print", "assertion"}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Fatalf("Expected steps %v, got %v", expected, kinds)
+	}
+
+	request := steps[0]
+	if request.Request == nil || request.Request.Method != "GET" || request.Request.URL != server.URL+"/users" {
+		t.Errorf("Unexpected request summary: %+v", request.Request)
+	}
+	if request.Response["status"] != 200 || !request.Passed || request.Line != 1 || request.Duration <= 0 {
+		t.Errorf("Unexpected request step: %+v", request)
+	}
+	if steps[3].Output != "item 2" || steps[3].Line != 4 {
+		t.Errorf("Expected the second loop iteration on line 4, got %+v", steps[3])
+	}
+	if last := steps[4]; last.Passed || last.Err == nil || last.Line != 7 {
+		t.Errorf("Expected a failed assertion on line 7, got %+v", last)
+	}
+}
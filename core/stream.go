@@ -0,0 +1,198 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxStreamBuffer is how much of a stream is kept when no max response size
+// is set; older data is dropped
+const maxStreamBuffer = 1 << 20
+
+// errNoStream is returned by the stream assertions before any stream request
+var errNoStream = errors.New("no stream request has been sent; add the stream option to a request")
+
+// responseStream is the body of a request sent with the stream option, read
+// in the background as it arrives so a script can assert on it while the
+// server keeps sending
+type responseStream struct {
+	mu       sync.Mutex
+	changed  chan struct{} // Closed and replaced whenever data arrives or the stream ends
+	data     []byte        // Received data, the latest limit bytes
+	dropped  bool          // Whether older data was dropped
+	limit    int
+	received int64 // Bytes received in total, after decoding
+	done     bool
+	err      error
+	cancel   func()
+}
+
+// newResponseStream returns a stream keeping at most limit bytes; cancel is
+// called when the stream ends or is closed
+func newResponseStream(limit int64, cancel func()) *responseStream {
+	if limit <= 0 {
+		limit = maxStreamBuffer
+	}
+	return &responseStream{changed: make(chan struct{}), limit: int(limit), cancel: cancel}
+}
+
+// read copies body into the stream until it ends. Decoding starts here, as
+// it waits for the first bytes of compressed bodies.
+func (s *responseStream) read(body io.ReadCloser, raw io.Reader, encoding string) {
+	defer s.cancel()
+	defer body.Close()
+
+	decoded, err := decodeBody(raw, encoding)
+	if err != nil {
+		s.finish(fmt.Errorf("decoding %s body: %w", encoding, err))
+		return
+	}
+	buffer := make([]byte, 4096)
+	for {
+		n, err := decoded.Read(buffer)
+		if n > 0 {
+			s.append(buffer[:n])
+		}
+		if err == io.EOF {
+			s.finish(nil)
+			return
+		}
+		if err != nil {
+			s.finish(err)
+			return
+		}
+	}
+}
+
+// append adds received data, dropping the oldest past the limit
+func (s *responseStream) append(chunk []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data = append(s.data, chunk...)
+	s.received += int64(len(chunk))
+	if extra := len(s.data) - s.limit; extra > 0 {
+		s.data = append(s.data[:0], s.data[extra:]...)
+		s.dropped = true
+	}
+	s.notify()
+}
+
+// finish marks the stream as ended, with the error that ended it if any
+func (s *responseStream) finish(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return
+	}
+	s.done = true
+	s.err = err
+	s.notify()
+}
+
+// notify wakes up the waiters; the lock must be held
+func (s *responseStream) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// waitFor waits until the stream has received text, for at most timeout
+func (s *responseStream) waitFor(ctx context.Context, text string, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		s.mu.Lock()
+		found := strings.Contains(string(s.data), text)
+		done, err, received, changed := s.done, s.err, s.received, s.changed
+		s.mu.Unlock()
+
+		switch {
+		case found:
+			return nil
+		case done && err != nil && !errors.Is(err, context.Canceled):
+			return fmt.Errorf("stream failed before receiving %q (%d bytes received): %w", text, received, err)
+		case done:
+			return fmt.Errorf("stream ended without receiving %q (%d bytes received)", text, received)
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return fmt.Errorf("stream did not receive %q within %v (%d bytes received)", text, timeout, received)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// lines returns the complete lines received so far, without line endings. A
+// last line without a newline counts once the stream has ended, and a first
+// line cut by dropped data is left out.
+func (s *responseStream) lines() []interface{} {
+	s.mu.Lock()
+	data := string(s.data)
+	done, dropped := s.done, s.dropped
+	s.mu.Unlock()
+
+	lines := []interface{}{}
+	parts := strings.Split(data, "\n")
+	if !done || parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	if dropped && len(parts) > 0 {
+		parts = parts[1:]
+	}
+	for _, line := range parts {
+		lines = append(lines, strings.TrimSuffix(line, "\r"))
+	}
+	return lines
+}
+
+// close stops reading the stream
+func (s *responseStream) close() {
+	s.cancel()
+}
+
+// setStream makes stream the current one, closing the one it replaces
+func (he *HTTPEngine) setStream(stream *responseStream) {
+	he.mu.Lock()
+	previous := he.stream
+	he.stream = stream
+	he.mu.Unlock()
+	if previous != nil {
+		previous.close()
+	}
+}
+
+// CloseStream stops reading the response of the last stream request
+func (he *HTTPEngine) CloseStream() {
+	he.setStream(nil)
+}
+
+// AwaitStream waits until the response of the last stream request has
+// received text, for at most timeout. Data that arrived before the call
+// counts too.
+func (he *HTTPEngine) AwaitStream(ctx context.Context, text string, timeout time.Duration) error {
+	he.mu.RLock()
+	stream := he.stream
+	he.mu.RUnlock()
+	if stream == nil {
+		return errNoStream
+	}
+	return stream.waitFor(ctx, text, timeout)
+}
+
+// StreamLines returns the lines received so far by the last stream request
+func (he *HTTPEngine) StreamLines() ([]interface{}, error) {
+	he.mu.RLock()
+	stream := he.stream
+	he.mu.RUnlock()
+	if stream == nil {
+		return nil, errNoStream
+	}
+	return stream.lines(), nil
+}
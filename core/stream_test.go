@@ -0,0 +1,62 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPDSLv3Streaming(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		w.Write([]byte("event: hello\n"))
+		flusher.Flush()
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		w.Write([]byte("event: chunk\npartial"))
+		flusher.Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+
+	// The request returns before the stream ends
+	start := time.Now()
+	if _, err := dsl.ParseWithBlockSupport(`GET "$base/stream" stream
+assert status 200
+assert stream receives "chunk" within 2 s
+extract stream lines as $lines`); err != nil {
+		t.Fatalf("stream assertions failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the stream to be checked as it arrives, took %v", elapsed)
+	}
+	lines, _ := dsl.GetVariable("lines")
+	if !reflect.DeepEqual(lines, []interface{}{"event: hello", "event: chunk"}) {
+		t.Errorf("Expected the complete lines, got %v", lines)
+	}
+
+	_, err := dsl.ParseWithBlockSupport(`assert stream receives "goodbye" within 100 ms`)
+	if err == nil || !strings.Contains(err.Error(), "did not receive") {
+		t.Errorf("Expected a stream timeout, got %v", err)
+	}
+
+	dsl.GetEngine().Reset()
+	_, err = dsl.ParseWithBlockSupport(`extract stream lines as $lines`)
+	if err == nil || !strings.Contains(err.Error(), "no stream request") {
+		t.Errorf("Expected a missing stream error, got %v", err)
+	}
+}
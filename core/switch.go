@@ -0,0 +1,142 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// switchClause is one case of a switch block, or its default
+type switchClause struct {
+	values []string // Case values as written, nil for default
+	body   []string
+}
+
+// isSwitchOpener reports whether a line starts a switch block
+func isSwitchOpener(line string) bool {
+	return strings.HasPrefix(line, "switch ")
+}
+
+// isSwitchCloser reports whether a line closes a switch block
+func isSwitchCloser(line string) bool {
+	return line == "endswitch"
+}
+
+// isCaseLine reports whether a line starts a case of a switch block
+func isCaseLine(line string) bool {
+	return strings.HasPrefix(line, "case ")
+}
+
+// isSwitchClause reports whether a line starts a case or the default of a
+// switch block
+func isSwitchClause(line string) bool {
+	return isCaseLine(line) || line == "default"
+}
+
+// splitCaseValues splits the values of a case line: case 401, 403
+func splitCaseValues(line string) []string {
+	var values []string
+	for _, value := range strings.Split(strings.TrimPrefix(line, "case "), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// runSwitchBlock runs the first case of a switch block starting at index start
+// whose value equals the switch value, or its default when none does. It
+// returns the results and the index of the endswitch line.
+func (hd *HTTPDSLv3) runSwitchBlock(lines []string, start int) ([]interface{}, int, error) {
+	body, end, ok := collectLoopLines(lines, start, isSwitchOpener, isSwitchCloser)
+	if !ok {
+		return nil, end, fmt.Errorf("switch block is never closed, missing endswitch")
+	}
+
+	// Split the body into clauses; nested switch blocks stay in their clause
+	var clauses []switchClause
+	defaultIndex := -1
+	depth := 0
+	for _, raw := range body {
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		switch {
+		case depth == 0 && isCaseLine(line):
+			clauses = append(clauses, switchClause{values: splitCaseValues(line)})
+			continue
+		case depth == 0 && line == "default":
+			if defaultIndex >= 0 {
+				return nil, end, fmt.Errorf("switch has more than one default")
+			}
+			defaultIndex = len(clauses)
+			clauses = append(clauses, switchClause{})
+			continue
+		case isSwitchOpener(line):
+			depth++
+		case isSwitchCloser(line):
+			depth--
+		}
+
+		if len(clauses) == 0 {
+			return nil, end, fmt.Errorf("statement before the first case of switch: %s", line)
+		}
+		clauses[len(clauses)-1].body = append(clauses[len(clauses)-1].body, raw)
+	}
+
+	subject, err := hd.evaluateRule("expression", strings.TrimPrefix(strings.TrimSpace(lines[start]), "switch "))
+	if err != nil {
+		return nil, end, fmt.Errorf("switch value: %w", err)
+	}
+
+	var chosen *switchClause
+	for i := range clauses {
+		for _, text := range clauses[i].values {
+			value, err := hd.caseValue(text)
+			if err != nil {
+				return nil, end, fmt.Errorf("case %s: %w", text, err)
+			}
+			if caseMatches(subject, value) {
+				chosen = &clauses[i]
+				break
+			}
+		}
+		if chosen != nil {
+			break
+		}
+	}
+	if chosen == nil && defaultIndex >= 0 {
+		chosen = &clauses[defaultIndex]
+	}
+	if chosen == nil || len(chosen.body) == 0 {
+		return nil, end, nil
+	}
+
+	result, err := hd.ParseWithBlockSupport(strings.Join(chosen.body, "\n"))
+	results, _ := result.([]interface{})
+	return results, end, err
+}
+
+// caseValue evaluates the value of a case; integer literals may be negative
+func (hd *HTTPDSLv3) caseValue(text string) (interface{}, error) {
+	if n, err := strconv.Atoi(text); err == nil {
+		return n, nil
+	}
+	return hd.evaluateRule("value", text)
+}
+
+// caseMatches compares a switch value with a case value, as numbers when
+// both are numeric so that a status of 200 matches case "200"
+func caseMatches(subject, value interface{}) bool {
+	if cmp, ok := compareIntegers(subject, value); ok {
+		return cmp == 0
+	}
+	if a, err := numberArgument(subject); err == nil {
+		if b, err := numberArgument(value); err == nil {
+			return a == b
+		}
+	}
+	return formatValue(subject) == formatValue(value)
+}
@@ -0,0 +1,38 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestHTTPDSLv3Switch(t *testing.T) {
+	dsl := NewHTTPDSLv3()
+	script := `set $out ""
+foreach $code in $codes do
+    switch $code
+    case 200
+        set $out "$out ok"
+    case 401, "404"
+        set $out "$out missing"
+    default
+        set $out "$out other"
+    endswitch
+endloop
+switch "none"
+case "some"
+    set $out "$out some"
+endswitch`
+	dsl.SetVariable("codes", []interface{}{200, 404, 500, "401"})
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+	if out, _ := dsl.GetVariable("out"); out != " ok missing other missing" {
+		t.Errorf("Unexpected switch results: %q", out)
+	}
+
+	if _, err := dsl.ParseWithBlockSupport("switch 1\n    print \"x\"\nendswitch"); err == nil {
+		t.Error("Expected a statement before the first case to fail")
+	}
+	if problems := dsl.Validate("case 1\nswitch $x\ncase 1"); len(problems) != 2 {
+		t.Errorf("Expected a stray case and an unclosed switch, got %v", problems)
+	}
+}
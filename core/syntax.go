@@ -0,0 +1,610 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/arturoeanton/go-dsl/pkg/dslbuilder"
+)
+
+// SyntaxError describes a problem found while checking a script without running it
+type SyntaxError struct {
+	Line    int    // 1-based line number in the script
+	Column  int    // 1-based column number
+	Message string // Human readable description
+}
+
+// Error implements the error interface
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// SyntaxNode is one node of the parse tree built by ParseTree. Rule nodes
+// carry the grammar rule, the alternative that matched, and its action name;
+// leaves carry a single token.
+type SyntaxNode struct {
+	Rule     string        // Grammar rule name, empty for token leaves
+	Action   string        // Action registered for the matched alternative
+	Token    string        // Token type of a leaf
+	Value    string        // Matched text of a leaf
+	Children []*SyntaxNode // Matched symbols, in order
+}
+
+// grammarAlternative is one sequence of a rule together with its action name
+type grammarAlternative struct {
+	sequence []string
+	action   string
+}
+
+// grammarMatch is a memoized rule match
+type grammarMatch struct {
+	end  int
+	node *SyntaxNode
+}
+
+// grammarChecker recognizes token sequences against the DSL grammar without
+// running any action. It mirrors the ordered-choice and seed-growing behavior
+// of the dslbuilder improved parser so results match what Parse would accept.
+type grammarChecker struct {
+	rules    map[string][][]string
+	actions  map[string][]string
+	tokens   []dslbuilder.TokenMatch
+	isToken  map[string]bool
+	memo     map[string]map[int]*grammarMatch
+	growing  map[string]bool
+	furthest int
+	expected map[string]bool
+}
+
+// newGrammarChecker builds a checker from the grammar registered in the DSL
+func (hd *HTTPDSLv3) newGrammarChecker() *grammarChecker {
+	info := hd.dsl.Debug()
+	gc := &grammarChecker{
+		rules:   make(map[string][][]string),
+		actions: make(map[string][]string),
+		isToken: make(map[string]bool),
+	}
+
+	if tokens, ok := info["tokens"].(map[string]string); ok {
+		for name := range tokens {
+			gc.isToken[name] = true
+		}
+	}
+
+	if rules, ok := info["rules"].(map[string]interface{}); ok {
+		for name, alts := range rules {
+			alternatives, _ := alts.([]map[string]interface{})
+			for _, alt := range alternatives {
+				if seq, ok := alt["sequence"].([]string); ok {
+					action, _ := alt["action"].(string)
+					gc.rules[name] = append(gc.rules[name], seq)
+					gc.actions[name] = append(gc.actions[name], action)
+				}
+			}
+		}
+	}
+
+	return gc
+}
+
+// check reports whether the tokens form exactly one instance of rule
+func (gc *grammarChecker) check(rule string, tokens []dslbuilder.TokenMatch) (*SyntaxNode, bool) {
+	gc.tokens = tokens
+	gc.memo = make(map[string]map[int]*grammarMatch)
+	gc.growing = make(map[string]bool)
+	gc.furthest = 0
+	gc.expected = make(map[string]bool)
+
+	node, end, ok := gc.matchRule(rule, 0)
+	if ok && end < len(tokens) {
+		// The rule matched a prefix; the leftover token is what broke the statement
+		gc.expect("end of statement", end)
+	}
+	return node, ok && end == len(tokens)
+}
+
+// expect records a symbol that would have allowed matching to continue at pos
+func (gc *grammarChecker) expect(symbol string, pos int) {
+	if pos > gc.furthest {
+		gc.furthest = pos
+		gc.expected = make(map[string]bool)
+	}
+	if pos == gc.furthest {
+		gc.expected[symbol] = true
+	}
+}
+
+// matchRule matches rule at pos and returns the node and the position after the match
+func (gc *grammarChecker) matchRule(rule string, pos int) (*SyntaxNode, int, bool) {
+	if ruleMemo, ok := gc.memo[rule]; ok {
+		if m, ok := ruleMemo[pos]; ok {
+			return m.node, m.end, m.end >= 0
+		}
+	} else {
+		gc.memo[rule] = make(map[int]*grammarMatch)
+	}
+
+	var node *SyntaxNode
+	end, ok := -1, false
+	if gc.isLeftRecursive(rule) {
+		node, end, ok = gc.matchLeftRecursive(rule, pos)
+	} else {
+		for i, seq := range gc.rules[rule] {
+			if children, e, matched := gc.matchSequence(seq, pos, 0); matched {
+				node = &SyntaxNode{Rule: rule, Action: gc.actions[rule][i], Children: children}
+				end, ok = e, true
+				break
+			}
+		}
+	}
+
+	if !ok {
+		node, end = nil, -1
+	}
+	gc.memo[rule][pos] = &grammarMatch{end: end, node: node}
+	return node, end, ok
+}
+
+// matchSequence matches the symbols of seq starting at index from
+func (gc *grammarChecker) matchSequence(seq []string, pos, from int) ([]*SyntaxNode, int, bool) {
+	var children []*SyntaxNode
+	for _, symbol := range seq[from:] {
+		if gc.isToken[symbol] {
+			if pos >= len(gc.tokens) || gc.tokens[pos].TokenType != symbol {
+				gc.expect(symbol, pos)
+				return nil, pos, false
+			}
+			children = append(children, &SyntaxNode{Token: symbol, Value: gc.tokens[pos].Value})
+			pos++
+			continue
+		}
+		node, end, ok := gc.matchRule(symbol, pos)
+		if !ok {
+			return nil, pos, false
+		}
+		children = append(children, node)
+		pos = end
+	}
+	if pos > gc.furthest {
+		gc.furthest = pos
+		gc.expected = make(map[string]bool)
+	}
+	return children, pos, true
+}
+
+// isLeftRecursive checks whether any alternative of rule starts with rule itself
+func (gc *grammarChecker) isLeftRecursive(rule string) bool {
+	for _, seq := range gc.rules[rule] {
+		if len(seq) > 0 && seq[0] == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLeftRecursive grows a seed match the same way the improved parser does
+func (gc *grammarChecker) matchLeftRecursive(rule string, pos int) (*SyntaxNode, int, bool) {
+	key := fmt.Sprintf("%s_%d", rule, pos)
+	if gc.growing[key] {
+		return nil, -1, false
+	}
+	gc.growing[key] = true
+	defer delete(gc.growing, key)
+
+	var seed *SyntaxNode
+	seedEnd := -1
+	for i, seq := range gc.rules[rule] {
+		if len(seq) > 0 && seq[0] == rule {
+			continue
+		}
+		if children, end, ok := gc.matchSequence(seq, pos, 0); ok {
+			seed = &SyntaxNode{Rule: rule, Action: gc.actions[rule][i], Children: children}
+			seedEnd = end
+			break
+		}
+	}
+	if seedEnd < 0 {
+		return nil, -1, false
+	}
+
+	for {
+		best, bestEnd := seed, seedEnd
+		gc.memo[rule][pos] = &grammarMatch{end: seedEnd, node: seed}
+		for i, seq := range gc.rules[rule] {
+			if len(seq) == 0 || seq[0] != rule {
+				continue
+			}
+			if children, end, ok := gc.matchSequence(seq, seedEnd, 1); ok && end > bestEnd {
+				best = &SyntaxNode{
+					Rule:     rule,
+					Action:   gc.actions[rule][i],
+					Children: append([]*SyntaxNode{seed}, children...),
+				}
+				bestEnd = end
+			}
+		}
+		if bestEnd == seedEnd {
+			return seed, seedEnd, true
+		}
+		seed, seedEnd = best, bestEnd
+	}
+}
+
+// Tokenize splits a single statement into DSL tokens without parsing it.
+// Token positions are relative to the statement with surrounding whitespace removed.
+// An end-of-line comment is not tokenized.
+func (hd *HTTPDSLv3) Tokenize(input string) ([]dslbuilder.TokenMatch, error) {
+	return hd.dsl.DebugTokens(stripComment(input))
+}
+
+// Functions returns the names of the functions expressions can call, those
+// built in and those added with RegisterFunction, sorted alphabetically
+func (hd *HTTPDSLv3) Functions() []string {
+	rules, _ := hd.dsl.Debug()["rules"].(map[string]interface{})
+	alternatives, _ := rules["function_call"].([]map[string]interface{})
+	seen := make(map[string]bool)
+	var functions []string
+	for _, alt := range alternatives {
+		if seq, ok := alt["sequence"].([]string); ok && len(seq) > 0 && !seen[seq[0]] {
+			seen[seq[0]] = true
+			functions = append(functions, seq[0])
+		}
+	}
+	sort.Strings(functions)
+	return functions
+}
+
+// Keywords returns every keyword recognized by the grammar, sorted alphabetically
+func (hd *HTTPDSLv3) Keywords() []string {
+	info := hd.dsl.Debug()
+	tokens, _ := info["tokens"].(map[string]string)
+
+	var keywords []string
+	for _, pattern := range tokens {
+		if strings.HasPrefix(pattern, `(?i)\b`) && strings.HasSuffix(pattern, `\b`) {
+			keyword := strings.TrimSuffix(strings.TrimPrefix(pattern, `(?i)\b`), `\b`)
+			keywords = append(keywords, strings.ReplaceAll(keyword, `\`, ""))
+		}
+	}
+	sort.Strings(keywords)
+	return keywords
+}
+
+// CheckStatement verifies that input is a single valid statement of the given
+// grammar rule without executing it. Columns in the returned error are relative
+// to input.
+func (hd *HTTPDSLv3) CheckStatement(rule, input string) *SyntaxError {
+	indent := len(input) - len(strings.TrimLeft(input, " \t"))
+
+	tokens, err := hd.Tokenize(input)
+	if err != nil {
+		column := 1
+		if parseErr, ok := err.(*dslbuilder.ParseError); ok {
+			column = parseErr.Position + 1
+			return &SyntaxError{Line: 1, Column: indent + column, Message: parseErr.Message}
+		}
+		return &SyntaxError{Line: 1, Column: indent + column, Message: err.Error()}
+	}
+	if len(tokens) == 0 {
+		return &SyntaxError{Line: 1, Column: indent + 1, Message: "empty statement"}
+	}
+
+	gc := hd.newGrammarChecker()
+	if _, ok := gc.check(rule, tokens); ok {
+		return nil
+	}
+	return gc.syntaxError(input, indent)
+}
+
+// syntaxError describes where the last check stopped matching
+func (gc *grammarChecker) syntaxError(input string, indent int) *SyntaxError {
+	tokens := gc.tokens
+
+	if gc.furthest >= len(tokens) {
+		trimmed := strings.TrimSpace(input)
+		return &SyntaxError{
+			Line:    1,
+			Column:  indent + len(trimmed) + 1,
+			Message: "unexpected end of input",
+		}
+	}
+
+	token := tokens[gc.furthest]
+	return &SyntaxError{
+		Line:    1,
+		Column:  indent + token.Start + 1,
+		Message: fmt.Sprintf("unexpected token: %s", token.Value),
+	}
+}
+
+// StatementLines returns the 1-based lines of the top-level statements of a
+// script, in order: the steps of a run, with every block one step at the line
+// that opens it. The line of a step event is one of them.
+func (hd *HTTPDSLv3) StatementLines(script string) []int {
+	var lines []int
+	depth := 0
+	for _, unit := range splitScript(script) {
+		switch unit.kind {
+		case "statement":
+			if depth == 0 {
+				lines = append(lines, unit.line+1)
+			}
+		case "if", "repeat", "while", "foreach", "for", "hook", "repeat-until", "switch", "paginate":
+			if depth == 0 {
+				lines = append(lines, unit.line+1)
+			}
+			depth++
+		case "endif", "endloop", "end", "until", "endswitch", "endpaginate":
+			if depth > 0 {
+				depth--
+			}
+		}
+	}
+	return lines
+}
+
+// scriptUnit is one statement or block marker of a script, split the same way
+// ParseWithBlockSupport walks lines
+type scriptUnit struct {
+	line    int    // 0-based index of the first line
+	offset  int    // column of the first non-blank character
+	kind    string // statement, if, elseif, else, endif, repeat, while, foreach, for, endloop, hook, end, repeat-until, until, switch, case, default, endswitch, paginate or endpaginate
+	source  string // statement text, header continuations included
+	rule    string // grammar rule checked for the unit, empty if none
+	text    string // part of source checked against rule
+	column  int    // offset of text within source
+	problem string // structural problem found while splitting
+}
+
+// splitScript splits a script into statements and block markers. Header and
+// continued lines are joined with the statement above them like the block
+// handler does.
+func splitScript(script string) []scriptUnit {
+	// Multiline strings are checked as part of the line that opens them; an
+	// unclosed one ends the script
+	lines, heredocErr := joinHeredocs(strings.Split(script, "\n"))
+	if heredocErr != nil {
+		lines = strings.Split(script, "\n")[:heredocErr.Line]
+	}
+	for i := range lines {
+		lines[i] = stripComment(lines[i])
+	}
+	lines = joinContinuations(lines)
+	var units []scriptUnit
+
+	for i := 0; i < len(lines); i++ {
+		raw := lines[i]
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		unit := scriptUnit{line: i, offset: strings.Index(raw, line), kind: "statement", source: line}
+
+		switch {
+		case isHTTPMethod(line):
+			unit.rule, unit.text = "statement", unit.source
+
+		case strings.HasPrefix(line, "if ") && strings.HasSuffix(line, " then"):
+			unit.kind = "if"
+			unit.rule = "condition"
+			unit.text = strings.TrimSuffix(strings.TrimPrefix(line, "if "), " then")
+			unit.column = len("if ")
+
+		case isElseIf(line):
+			unit.kind = "elseif"
+			unit.rule = "condition"
+			unit.text, _ = elseIfCondition(line)
+			unit.column = len(line) - len(" then") - len(unit.text)
+
+		case line == "else", line == "endif", line == "endloop", line == "end":
+			unit.kind = line
+
+		case isEndBlockOpener(line), isTemplateOpener(line):
+			unit.kind = "hook"
+
+		case requestTemplateLine.MatchString(line):
+			if _, request, inline, _ := parseTemplateLine(line); inline {
+				unit.rule, unit.text = "http_request", request
+				unit.column = strings.Index(line, request)
+			} else {
+				unit.problem = `invalid request syntax, expected: request "<name>" followed by a request and end`
+			}
+
+		case isUntilOpener(line):
+			unit.kind = "repeat-until"
+
+		case isSwitchOpener(line):
+			unit.kind = "switch"
+			unit.rule = "expression"
+			unit.text = strings.TrimPrefix(line, "switch ")
+			unit.column = len("switch ")
+
+		case isCaseLine(line):
+			unit.kind = "case"
+			if len(splitCaseValues(line)) == 0 {
+				unit.problem = "invalid case syntax, expected: case <value>[, <value>...]"
+			}
+
+		case line == "default", isSwitchCloser(line):
+			unit.kind = line
+
+		case isPaginateOpener(line):
+			unit.kind = "paginate"
+			unit.rule, unit.text = "paginate_header", line
+
+		case isPaginateCloser(line):
+			unit.kind = line
+
+		case isUntilCloser(line):
+			unit.kind = "until"
+			unit.rule = "condition"
+			unit.text = strings.TrimPrefix(line, "until ")
+			unit.column = len("until ")
+
+		case strings.HasPrefix(line, "for ") && strings.HasSuffix(line, " do"):
+			unit.kind = "for"
+			if !forLoopHeader.MatchString(line) {
+				unit.problem = "invalid for syntax, expected: for $i in <from> to <to> [step <n>] do"
+			}
+
+		case strings.HasPrefix(line, "repeat ") && strings.HasSuffix(line, " do"):
+			unit.kind = "repeat"
+			parts := strings.Fields(line)
+			if len(parts) != 4 || parts[2] != "times" {
+				unit.problem = "invalid repeat syntax, expected: repeat <count> times do"
+			} else {
+				unit.rule, unit.text, unit.column = "value", parts[1], len("repeat ")
+			}
+
+		case strings.HasPrefix(line, "while ") && strings.HasSuffix(line, " do"):
+			unit.kind = "while"
+			unit.rule = "condition"
+			unit.text = strings.TrimSuffix(strings.TrimPrefix(line, "while "), " do")
+			unit.column = len("while ")
+
+		case strings.HasPrefix(line, "foreach ") && strings.HasSuffix(line, " do"):
+			unit.kind = "foreach"
+			parts := strings.SplitN(strings.TrimSuffix(strings.TrimPrefix(line, "foreach "), " do"), " in ", 2)
+			if len(parts) != 2 || !strings.HasPrefix(strings.TrimSpace(parts[0]), "$") {
+				unit.problem = "invalid foreach syntax, expected: foreach $item in <list> do"
+			} else {
+				list, workers := splitParallel(strings.TrimSpace(parts[1]))
+				if n, err := strconv.Atoi(workers); workers != "" && !strings.HasPrefix(workers, "$") && (err != nil || n < 1) {
+					unit.problem = "foreach parallel expects a positive number of workers, got " + workers
+				} else if file, ok := strings.CutPrefix(list, "csv "); ok {
+					file = strings.TrimSpace(file)
+					unit.rule, unit.text, unit.column = "value", file, strings.LastIndex(line, file)
+				} else if !(strings.HasPrefix(list, "[") && strings.HasSuffix(list, "]")) {
+					unit.rule, unit.text, unit.column = "value", list, strings.Index(line, list)
+				}
+			}
+
+		default:
+			unit.rule, unit.text = "statement", line
+		}
+
+		units = append(units, unit)
+	}
+
+	if heredocErr != nil {
+		raw := strings.Split(script, "\n")[heredocErr.Line]
+		units = append(units, scriptUnit{
+			line:    heredocErr.Line,
+			offset:  len(raw) - len(strings.TrimLeft(raw, " \t")),
+			kind:    "statement",
+			problem: heredocErr.Error(),
+		})
+	}
+
+	return units
+}
+
+// Validate checks a whole script for syntax errors without executing any statement.
+// It follows the same line and block structure as ParseWithBlockSupport, so
+// multiline if/else/endif blocks, loops, hook blocks, and header and continued
+// lines are understood.
+//
+// Example:
+//
+//	for _, problem := range hd.Validate(script) {
+//	    fmt.Printf("%d:%d %s\n", problem.Line, problem.Column, problem.Message)
+//	}
+func (hd *HTTPDSLv3) Validate(script string) []SyntaxError {
+	var problems []SyntaxError
+	var stack []scriptUnit
+
+	mismatch := func(unit scriptUnit, message string) {
+		problems = append(problems, SyntaxError{Line: unit.line + 1, Column: unit.offset + 1, Message: message})
+	}
+
+	for _, unit := range splitScript(script) {
+		if unit.problem != "" {
+			mismatch(unit, unit.problem)
+		} else if unit.rule != "" {
+			if err := hd.CheckStatement(unit.rule, unit.text); err != nil {
+				problems = append(problems, SyntaxError{
+					Line:    unit.line + 1,
+					Column:  err.Column + unit.offset + unit.column,
+					Message: err.Message,
+				})
+			}
+		}
+
+		switch unit.kind {
+		case "if", "repeat", "while", "foreach", "for", "hook", "repeat-until", "switch", "paginate":
+			stack = append(stack, unit)
+		case "case", "default":
+			if len(stack) == 0 || stack[len(stack)-1].kind != "switch" {
+				mismatch(unit, unit.kind+" without matching switch")
+			}
+		case "endswitch":
+			if len(stack) == 0 || stack[len(stack)-1].kind != "switch" {
+				mismatch(unit, "endswitch without matching switch")
+			} else {
+				stack = stack[:len(stack)-1]
+			}
+		case "else", "elseif":
+			if len(stack) == 0 || stack[len(stack)-1].kind != "if" {
+				mismatch(unit, unit.kind+" without matching if")
+			}
+		case "endif":
+			if len(stack) == 0 || stack[len(stack)-1].kind != "if" {
+				mismatch(unit, "endif without matching if")
+			} else {
+				stack = stack[:len(stack)-1]
+			}
+		case "endpaginate":
+			if len(stack) == 0 || stack[len(stack)-1].kind != "paginate" {
+				mismatch(unit, "endpaginate without matching paginate")
+			} else {
+				stack = stack[:len(stack)-1]
+			}
+		case "endloop":
+			if len(stack) == 0 || stack[len(stack)-1].kind == "if" || stack[len(stack)-1].kind == "hook" ||
+				stack[len(stack)-1].kind == "repeat-until" || stack[len(stack)-1].kind == "switch" ||
+				stack[len(stack)-1].kind == "paginate" {
+				mismatch(unit, "endloop without matching loop")
+			} else {
+				stack = stack[:len(stack)-1]
+			}
+		case "until":
+			if len(stack) == 0 || stack[len(stack)-1].kind != "repeat-until" {
+				mismatch(unit, "until without matching repeat")
+			} else {
+				stack = stack[:len(stack)-1]
+			}
+		case "end":
+			if len(stack) == 0 || stack[len(stack)-1].kind != "hook" {
+				mismatch(unit, "end without matching before request, after response, default headers or request")
+			} else {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	for _, block := range stack {
+		closing := "endloop"
+		switch block.kind {
+		case "if":
+			closing = "endif"
+		case "hook":
+			closing = "end"
+		case "repeat-until":
+			closing = "until"
+		case "switch":
+			closing = "endswitch"
+		case "paginate":
+			closing = "endpaginate"
+		}
+		problems = append(problems, SyntaxError{
+			Line:    block.line + 1,
+			Column:  1,
+			Message: fmt.Sprintf("block is never closed, missing %s", closing),
+		})
+	}
+
+	return problems
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTTPDSLv3StatementLines(t *testing.T) {
+	dsl := NewHTTPDSLv3()
+
+	script := `# Counts
+set $count 0
+set $body """
+{"a": 1}
+"""
+if $count > 0 then
+    print "positive"
+else
+    print "not positive"
+endif
+repeat 2 times do
+    set $count $count + 1
+endloop
+set $total $count \
+    + 1
+
+switch $count
+case 2
+    print "two"
+default
+    print "other"
+endswitch`
+
+	expected := []int{2, 3, 6, 11, 14, 17}
+	if lines := dsl.StatementLines(script); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected statement lines %v, got %v", expected, lines)
+	}
+
+	// Every step of a run reports one of those lines
+	reported := map[int]bool{}
+	dsl.OnEvent(func(e Event) {
+		if e.Type == EventStatementStart {
+			reported[e.Line] = true
+		}
+	})
+	if _, err := dsl.RunScript(script); err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+	for line := range reported {
+		found := false
+		for _, l := range expected {
+			found = found || l == line
+		}
+		if !found {
+			t.Errorf("A statement reported line %d, which is not a step", line)
+		}
+	}
+}
+
+// TestHTTPDSLv3Validate tests syntax checking without execution
+func TestHTTPDSLv3Validate(t *testing.T) {
+	dsl := NewHTTPDSLv3()
+
+	tests := []struct {
+		name         string
+		script       string
+		expectedLine int
+		expectedMsg  string
+	}{
+		{
+			name: "Valid script",
+			script: `set $count 0
+GET "http://localhost:1/users"
+    header "Accept" "application/json"
+if $count > 1 and $count < 10 then
+    print "in range"
+else
+    set $count $count + 1
+endif
+while $count < 3 do
+    set $count $count + 1
+endloop`,
+		},
+		{
+			name:         "Unknown option",
+			script:       `GET "http://localhost:1" heder "X" "y"`,
+			expectedLine: 1,
+			expectedMsg:  "unexpected token: heder",
+		},
+		{
+			name: "Unclosed block",
+			script: `set $a 1
+if $a > 0 then
+    print "positive"`,
+			expectedLine: 2,
+			expectedMsg:  "missing endif",
+		},
+		{
+			name:         "Stray endloop",
+			script:       "endloop",
+			expectedLine: 1,
+			expectedMsg:  "endloop without matching loop",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := dsl.Validate(tt.script)
+			if tt.expectedMsg == "" {
+				if len(problems) > 0 {
+					t.Errorf("Expected no problems, got %v", problems)
+				}
+				return
+			}
+			if len(problems) == 0 {
+				t.Fatalf("Expected a problem containing '%s', got none", tt.expectedMsg)
+			}
+			if problems[0].Line != tt.expectedLine || !strings.Contains(problems[0].Message, tt.expectedMsg) {
+				t.Errorf("Got %v, expected line %d with '%s'", problems[0], tt.expectedLine, tt.expectedMsg)
+			}
+		})
+	}
+
+	// Validation must never touch the network or variables
+	if _, ok := dsl.GetVariable("count"); ok {
+		t.Errorf("Validate should not execute statements")
+	}
+}
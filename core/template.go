@@ -0,0 +1,39 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs are the functions available to body templates besides the
+// text/template builtins
+var templateFuncs = template.FuncMap{
+	// json writes a value as a JSON value: {{json .user}}
+	"json": jsonLiteral,
+}
+
+// renderTemplate renders a body template file with the current variables as
+// its data, so {{.name}} and {{range .items}} see what $name and $items hold.
+// Referring to a variable that is not set is an error. Relative paths are
+// relative to the working directory.
+func (hd *HTTPDSLv3) renderTemplate(name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := template.New(filepath.Base(name)).
+		Option("missingkey=error").
+		Funcs(templateFuncs).
+		Parse(string(data))
+	if err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, hd.GetVariables()); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
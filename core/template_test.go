@@ -0,0 +1,61 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHTTPDSLv3BodyTemplate(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		received = append(received, r.Header.Get("Content-Type")+" "+string(data))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"order.json.tmpl": `{"customer": "{{.customer}}", "address": {{json .address}}, "items": [` +
+			`{{range $i, $sku := .items}}{{if $i}}, {{end}}{"sku": "{{$sku}}"}{{end}}], "note": "$note"}`,
+		"mail.txt.tmpl": "Hi {{.customer}}, ${note}",
+		"missing.tmpl":  "{{.nobody}}",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	dsl.SetVariable("dir", dir)
+	dsl.SetVariable("customer", "Ann")
+	dsl.SetVariable("address", map[string]interface{}{"city": "Lima"})
+	dsl.SetVariable("items", []interface{}{"a1", "b2"})
+	dsl.SetVariable("note", "ring twice")
+	script := `POST "$base/orders" json template "$dir/order.json.tmpl"
+POST "$base/mail" body template "$dir/mail.txt.tmpl"`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("body template failed: %v", err)
+	}
+	expected := []string{
+		`application/json {"customer": "Ann", "address": {"city":"Lima"}, "items": [{"sku": "a1"}, {"sku": "b2"}], "note": "ring twice"}`,
+		" Hi Ann, ring twice",
+	}
+	if len(received) != len(expected) {
+		t.Fatalf("Expected %d requests, got %q", len(expected), received)
+	}
+	for i, want := range expected {
+		if received[i] != want {
+			t.Errorf("Request %d: expected %q, got %q", i+1, want, received[i])
+		}
+	}
+
+	if _, err := dsl.Parse(`POST "$base/mail" body template "$dir/missing.tmpl"`); err == nil {
+		t.Error("Expected a template using an unset variable to fail")
+	}
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// requestTemplateLine matches the first line of a request template: its name,
+// then the request and end on the same line, or nothing when the request
+// follows on the lines up to end
+var requestTemplateLine = regexp.MustCompile(`^request\s+"([^"]+)"\s*(.*)$`)
+
+// templateOverride is a variable set for one invoke of a template
+type templateOverride struct {
+	name  string
+	value interface{}
+}
+
+// parseTemplateLine returns the name of the template a line defines, and its
+// request when the line holds all of it: request "name" GET "..." end
+func parseTemplateLine(line string) (name, request string, inline, ok bool) {
+	match := requestTemplateLine.FindStringSubmatch(line)
+	if match == nil {
+		return "", "", false, false
+	}
+	rest := match[2]
+	if rest == "" {
+		return match[1], "", false, true
+	}
+	if request, found := strings.CutSuffix(rest, " end"); found {
+		return match[1], strings.TrimSpace(request), true, true
+	}
+	return "", "", false, false
+}
+
+// isTemplateOpener reports whether a line starts a request template whose
+// request is on the lines up to end
+func isTemplateOpener(line string) bool {
+	_, _, inline, ok := parseTemplateLine(line)
+	return ok && !inline
+}
+
+// defineTemplate parses the request of a template and keeps it under name,
+// replacing an earlier template of that name. Variables in it are expanded
+// when it is invoked.
+func (hd *HTTPDSLv3) defineTemplate(name, body string) error {
+	var request string
+	for _, line := range strings.Split(body, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if request != "" {
+			return fmt.Errorf("request %q must hold a single request", name)
+		}
+		request = line
+	}
+	if request == "" {
+		return fmt.Errorf("request %q has no request", name)
+	}
+	tree, err := hd.parseRule("http_request", request)
+	if err != nil {
+		return fmt.Errorf("request %q: %w", name, err)
+	}
+	hd.templates[name] = tree
+	return nil
+}
+
+// invokeTemplate sends the request of a template. The overrides are set as
+// variables while it is built and restored afterwards.
+func (hd *HTTPDSLv3) invokeTemplate(name string, overrides []templateOverride) (interface{}, error) {
+	tree, ok := hd.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("request %q is not defined", name)
+	}
+
+	for _, override := range overrides {
+		previous, had := hd.GetVariable(override.name)
+		defer func(name string) {
+			if had {
+				hd.SetVariable(name, previous)
+			} else {
+				hd.DeleteVariable(name)
+			}
+		}(override.name)
+		hd.SetVariable(override.name, override.value)
+	}
+	return hd.evaluate(tree)
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTTPDSLv3RequestTemplates(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r.Method+" "+r.URL.Path+" "+r.Header.Get("X-Suite")+" "+string(body))
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+
+	script := `set $name "Ada"
+set $role "admin"
+request "create_user"
+    POST "$base/users" json {"name": "$name", "role": "$role"}
+    header "X-Suite" "smoke"
+end
+request "ping" GET "$base/ping" end
+
+invoke "create_user"
+invoke "create_user" with $name "Bob"
+set $created invoke "create_user" with $name "Eve" $role "viewer"
+invoke "ping"`
+	if problems := dsl.Validate(script); len(problems) > 0 {
+		t.Fatalf("Expected valid syntax, got %v", problems)
+	}
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+
+	expected := []string{
+		`POST /users smoke {"name": "Ada", "role": "admin"}`,
+		`POST /users smoke {"name": "Bob", "role": "admin"}`,
+		`POST /users smoke {"name": "Eve", "role": "viewer"}`,
+		`GET /ping  `,
+	}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("Expected requests %q, got %q", expected, received)
+	}
+	if name, _ := dsl.GetVariable("name"); name != "Ada" {
+		t.Errorf("Expected the overrides to be restored, got $name %v", name)
+	}
+	created, _ := dsl.GetVariable("created")
+	if status := created.(map[string]interface{})["status"]; status != 201 {
+		t.Errorf("Expected the response of an invoke to be assignable, got %v", status)
+	}
+
+	if _, err := dsl.ParseWithBlockSupport(`invoke "missing"`); err == nil || !strings.Contains(err.Error(), `request "missing" is not defined`) {
+		t.Errorf("Expected an undefined template error, got %v", err)
+	}
+	if _, err := dsl.ParseWithBlockSupport("request \"broken\"\n    GET\nend"); err == nil {
+		t.Error("Expected a template with an invalid request to fail")
+	}
+	if problems := dsl.Validate("request \"open\"\n    GET \"/x\""); len(problems) == 0 {
+		t.Error("Expected a template without end to be reported")
+	}
+}
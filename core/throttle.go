@@ -0,0 +1,133 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bandwidthUnits are the bits per second of the units of throttle
+var bandwidthUnits = map[string]int64{
+	"bps":  1,
+	"kbps": 1000,
+	"mbps": 1000 * 1000,
+	"gbps": 1000 * 1000 * 1000,
+}
+
+// parseBandwidth converts a rate like 256 kbps to bytes per second
+func parseBandwidth(value, unit string) (int64, error) {
+	bits, ok := bandwidthUnits[strings.ToLower(unit)]
+	if !ok {
+		return 0, fmt.Errorf("unknown bandwidth unit %s, expected bps, kbps, mbps or gbps", unit)
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number <= 0 {
+		return 0, fmt.Errorf("invalid bandwidth %s %s", value, unit)
+	}
+	rate := int64(number * float64(bits) / 8)
+	if rate < 1 {
+		rate = 1
+	}
+	return rate, nil
+}
+
+// throttleArgs reads throttle download|upload <number> <unit>
+func throttleArgs(args []interface{}) (string, int64, error) {
+	direction := strings.ToLower(args[1].(string))
+	if direction != "download" && direction != "upload" {
+		return "", 0, fmt.Errorf("throttle expects download or upload, got %s", args[1])
+	}
+	rate, err := parseBandwidth(args[2].(string), args[3].(string))
+	return direction, rate, err
+}
+
+// SetDownloadRate limits how fast later responses are read, in bytes per
+// second, to test timeouts and streaming on slow networks. 0 removes the limit.
+func (he *HTTPEngine) SetDownloadRate(bytesPerSecond int64) {
+	he.mu.Lock()
+	he.downloadRate = bytesPerSecond
+	he.mu.Unlock()
+}
+
+// SetUploadRate limits how fast the bodies of later requests are sent, in
+// bytes per second. 0 removes the limit.
+func (he *HTTPEngine) SetUploadRate(bytesPerSecond int64) {
+	he.mu.Lock()
+	he.uploadRate = bytesPerSecond
+	he.mu.Unlock()
+}
+
+// errThrottleTimeout is returned when a throttled body would pass the
+// request timeout; http.Client only notices it on reads that block
+var errThrottleTimeout = errors.New("request timeout exceeded while transferring the throttled body")
+
+// throttledReader reads at most rate bytes per second from r
+type throttledReader struct {
+	ctx      context.Context
+	r        io.Reader
+	rate     int64     // Bytes per second
+	deadline time.Time // End of the request timeout, zero for none
+	start    time.Time // Time of the first read
+	n        int64     // Bytes read so far
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if t.start.IsZero() {
+		t.start = time.Now()
+	}
+	// Small reads keep the flow steady: about 20 a second
+	if chunk := max(t.rate/20, 1); int64(len(p)) > chunk {
+		p = p[:chunk]
+	}
+	n, err := t.r.Read(p)
+	t.n += int64(n)
+
+	due := time.Duration(float64(t.n) / float64(t.rate) * float64(time.Second))
+	wait := due - time.Since(t.start)
+	expired := !t.deadline.IsZero() && time.Now().Add(wait).After(t.deadline)
+	if expired {
+		wait = time.Until(t.deadline)
+	}
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-t.ctx.Done():
+			return n, t.ctx.Err()
+		}
+	}
+	if expired {
+		return n, errThrottleTimeout
+	}
+	return n, err
+}
+
+// throttledBody is a request body sent through a throttledReader
+type throttledBody struct {
+	io.Reader
+	io.Closer
+}
+
+// throttleRequestBody limits how fast the body of req is sent. The length
+// is kept, and bodies sent again after a redirect are limited too.
+func throttleRequestBody(ctx context.Context, req *http.Request, rate int64, deadline time.Time) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+	req.Body = throttledBody{&throttledReader{ctx: ctx, r: req.Body, rate: rate, deadline: deadline}, req.Body}
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return throttledBody{&throttledReader{ctx: ctx, r: body, rate: rate, deadline: deadline}, body}, nil
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPDSLv3Throttle(t *testing.T) {
+	var uploaded atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		data, _ := io.ReadAll(r.Body)
+		uploaded.Store(int64(time.Since(start)))
+		if r.ContentLength != int64(len(data)) {
+			t.Errorf("Expected Content-Length %d, got %d", len(data), r.ContentLength)
+		}
+		w.Write([]byte(strings.Repeat("x", 800)))
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	dsl.SetVariable("payload", strings.Repeat("y", 1000))
+
+	// 800 bytes at 16 kbps (2000 bytes/s) take 0.4 s
+	start := time.Now()
+	if _, err := dsl.ParseWithBlockSupport(`GET "$base/" throttle download 16 kbps
+assert status 200
+extract size as $size`); err != nil {
+		t.Fatalf("throttled download failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 350*time.Millisecond {
+		t.Errorf("Expected the download to take 0.4 s, took %v", elapsed)
+	}
+	if size, _ := dsl.GetVariable("size"); size != 800 {
+		t.Errorf("Expected the whole body, got %v bytes", size)
+	}
+
+	// 1000 bytes at 40 kbps (5000 bytes/s) take 0.2 s to arrive
+	if _, err := dsl.ParseWithBlockSupport(`throttle upload 40 kbps
+POST "$base/" body "$payload"
+clear throttle`); err != nil {
+		t.Fatalf("throttled upload failed: %v", err)
+	}
+	if took := time.Duration(uploaded.Load()); took < 150*time.Millisecond {
+		t.Errorf("Expected the upload to take 0.2 s, took %v", took)
+	}
+
+	// A slow network makes the request timeout fire
+	_, err := dsl.ParseWithBlockSupport(`throttle download 8 kbps
+GET "$base/" timeout 100 ms`)
+	if err == nil {
+		t.Error("Expected the throttled download to time out")
+	}
+
+	if _, err := dsl.Parse("throttle download 10 kbs"); err == nil || !strings.Contains(err.Error(), "unknown bandwidth unit") {
+		t.Errorf("Expected an unknown unit error, got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// Headers set on every request while trace IDs are on
+const (
+	requestIDHeader   = "X-Request-ID"
+	traceparentHeader = "Traceparent"
+)
+
+// SetTraceIDs makes every request carry a generated X-Request-ID header and a
+// W3C traceparent header with the same trace id, so a request can be found in
+// backend logs. Headers the request sets itself are kept.
+func (he *HTTPEngine) SetTraceIDs(enabled bool) {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	he.traceIDs = enabled
+}
+
+// TraceIDs reports whether requests get generated trace headers
+func (he *HTTPEngine) TraceIDs() bool {
+	he.mu.RLock()
+	defer he.mu.RUnlock()
+	return he.traceIDs
+}
+
+// newTraceContext returns a random trace id and the traceparent value of a
+// new span in that trace
+func newTraceContext() (string, string) {
+	var ids [24]byte
+	rand.Read(ids[:])
+	traceID := hex.EncodeToString(ids[:16])
+	return traceID, "00-" + traceID + "-" + hex.EncodeToString(ids[16:]) + "-01"
+}
+
+// withRequestID adds the id of the last request to the error of a failed
+// statement while trace IDs are on
+func (hd *HTTPDSLv3) withRequestID(err error) error {
+	if err == nil || !hd.engine.TraceIDs() {
+		return err
+	}
+	history := hd.engine.GetHistory()
+	if len(history) == 0 || history[len(history)-1].RequestID == "" {
+		return err
+	}
+	return fmt.Errorf("%w (request id %s)", err, history[len(history)-1].RequestID)
+}
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPDSLv3TraceIDs(t *testing.T) {
+	var ids, parents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get("X-Request-ID"))
+		parents = append(parents, r.Header.Get("Traceparent"))
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("base", server.URL)
+	script := `trace id auto
+GET "$base/a"
+GET "$base/b" header "X-Request-ID" "mine"
+assert status 201`
+	_, err := dsl.ParseWithBlockSupport(script)
+	if err == nil || !strings.Contains(err.Error(), "(request id mine)") {
+		t.Errorf("Expected the failed assertion to show the request id, got %v", err)
+	}
+	if len(ids) != 2 || len(ids[0]) != 32 || ids[1] != "mine" {
+		t.Fatalf("Unexpected request ids: %v", ids)
+	}
+	if !strings.HasPrefix(parents[0], "00-"+ids[0]+"-") || parents[1] == "" {
+		t.Errorf("Unexpected traceparent headers: %v", parents)
+	}
+	history := dsl.GetEngine().GetHistory()
+	if history[0].RequestID != ids[0] {
+		t.Errorf("Expected the history to keep request id %s, got %q", ids[0], history[0].RequestID)
+	}
+
+	if _, err := dsl.ParseWithBlockSupport(`trace id off
+GET "$base/c"`); err != nil {
+		t.Fatal(err)
+	}
+	if ids[2] != "" {
+		t.Errorf("Expected no request id after trace id off, got %q", ids[2])
+	}
+}
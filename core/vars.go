@@ -0,0 +1,94 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// secretName matches the names of variables and fields whose values vars
+// masks
+var secretName = regexp.MustCompile(`(?i)password|passwd|secret|token|api_?key|authorization|credential|private_?key|cookie`)
+
+// secretMask replaces the values of secrets
+const secretMask = "****"
+
+// VariableDump is the result of the vars statement: every variable by name,
+// with the values of secrets like $password or $api_token masked. It prints
+// one variable per line with its type.
+type VariableDump map[string]interface{}
+
+// String lists the variables by name as $name (type) = value
+func (d VariableDump) String() string {
+	names := make([]string, 0, len(d))
+	for name := range d {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Variables (%d):", len(d))
+	for _, name := range names {
+		value := d[name]
+		text := formatValue(value)
+		if str, ok := value.(string); ok && str != secretMask {
+			text = strconv.Quote(str)
+		}
+		fmt.Fprintf(&b, "\n  $%s (%s) = %s", name, typeName(value), text)
+	}
+	return b.String()
+}
+
+// DumpVariables returns a copy of the variables with secrets masked, by name
+// or by the name of a field of an object, for debugging output
+func (hd *HTTPDSLv3) DumpVariables() VariableDump {
+	dump := make(VariableDump)
+	for name, value := range hd.GetVariables() {
+		dump[name] = maskSecrets(name, value)
+	}
+	return dump
+}
+
+// maskSecrets returns value with the values of secret names masked
+func maskSecrets(name string, value interface{}) interface{} {
+	if value != nil && secretName.MatchString(name) {
+		return secretMask
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		masked := make(map[string]interface{}, len(v))
+		for field, fieldValue := range v {
+			masked[field] = maskSecrets(field, fieldValue)
+		}
+		return masked
+	case []interface{}:
+		masked := make([]interface{}, len(v))
+		for i, item := range v {
+			masked[i] = maskSecrets("", item)
+		}
+		return masked
+	}
+	return value
+}
+
+// typeName names the type of a variable value as scripts see it
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case int, int64, float64, json.Number:
+		return "number"
+	case []interface{}, []string:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
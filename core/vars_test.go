@@ -0,0 +1,55 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTTPDSLv3Vars(t *testing.T) {
+	dsl := NewHTTPDSLv3()
+	dsl.SetVariable("login", map[string]interface{}{"user": "ada", "password": "hunter2"})
+	dsl.SetVariable("ok", true)
+	dsl.SetVariable("items", []interface{}{1, 2})
+
+	script := `set $base "http://localhost"
+set $count 3
+set $api_token "abc123"`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+
+	result, err := dsl.Parse("vars")
+	if err != nil {
+		t.Fatalf("vars failed: %v", err)
+	}
+	dump, ok := result.(VariableDump)
+	if !ok {
+		t.Fatalf("Expected a VariableDump, got %T", result)
+	}
+	if dump["api_token"] != secretMask {
+		t.Errorf("Expected $api_token to be masked, got %v", dump["api_token"])
+	}
+	login := dump["login"].(map[string]interface{})
+	if login["password"] != secretMask || login["user"] != "ada" {
+		t.Errorf("Expected only the password of $login to be masked, got %v", login)
+	}
+	if token, _ := dsl.GetVariable("api_token"); token != "abc123" {
+		t.Errorf("Expected vars not to change $api_token, got %v", token)
+	}
+
+	output := dump.String()
+	for _, line := range []string{
+		`$api_token (string) = ****`,
+		`$base (string) = "http://localhost"`,
+		`$count (number) = 3`,
+		`$ok (bool) = true`,
+		`$items (array) = [1,2]`,
+	} {
+		if !strings.Contains(output, line) {
+			t.Errorf("Expected %q in output:\n%s", line, output)
+		}
+	}
+	if strings.Contains(output, "abc123") || strings.Contains(output, "hunter2") {
+		t.Errorf("Expected secrets to be masked in output:\n%s", output)
+	}
+}
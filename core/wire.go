@@ -0,0 +1,94 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// SetWireTrace turns dumps of every request and response on or off: request
+// and status lines, headers and bodies, prefixed with > and < like curl -v
+func (he *HTTPEngine) SetWireTrace(enabled bool) {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	he.wireTrace = enabled
+}
+
+// SetWireTraceOutput sets where wire traces are written, nil for stderr
+func (he *HTTPEngine) SetWireTraceOutput(w io.Writer) {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	he.wireOutput = w
+}
+
+// wireWriter returns where wire traces go; he.mu must be held
+func (he *HTTPEngine) wireWriter() io.Writer {
+	if he.wireOutput != nil {
+		return he.wireOutput
+	}
+	return os.Stderr
+}
+
+// traceRequest dumps req as it is about to be sent
+func traceRequest(w io.Writer, req *http.Request) {
+	header := req.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	header.Set("Host", host)
+	if req.ContentLength > 0 && header.Get("Content-Length") == "" {
+		header.Set("Content-Length", fmt.Sprint(req.ContentLength))
+	}
+	start := fmt.Sprintf("%s %s %s", req.Method, req.URL.RequestURI(), req.Proto)
+	writeWire(w, ">", start, header, peekRequestBody(req), "")
+}
+
+// traceResponse dumps resp with its decoded body. Bodies of streamed or
+// saved responses are not in memory and are left out.
+func traceResponse(w io.Writer, resp *http.Response, body []byte, elided bool) {
+	note := ""
+	if elided {
+		note = "[body streamed or saved to a file]"
+	}
+	writeWire(w, "<", resp.Proto+" "+resp.Status, resp.Header, body, note)
+}
+
+// writeWire writes one message with every line prefixed, in one write so
+// messages of concurrent requests do not interleave
+func writeWire(w io.Writer, prefix, start string, header http.Header, body []byte, note string) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s\n", prefix, start)
+
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range header[name] {
+			fmt.Fprintf(&b, "%s %s: %s\n", prefix, name, value)
+		}
+	}
+	fmt.Fprintf(&b, "%s\n", prefix)
+
+	switch {
+	case note != "":
+		fmt.Fprintf(&b, "%s %s\n", prefix, note)
+	case len(body) == 0:
+	case !utf8.Valid(body):
+		fmt.Fprintf(&b, "%s [%d bytes of binary data]\n", prefix, len(body))
+	default:
+		for _, line := range strings.Split(strings.TrimSuffix(string(body), "\n"), "\n") {
+			fmt.Fprintf(&b, "%s %s\n", prefix, strings.TrimSuffix(line, "\r"))
+		}
+	}
+	io.WriteString(w, b.String())
+}
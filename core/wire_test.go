@@ -0,0 +1,54 @@
+package core
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPDSLv3WireTrace(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": 7}`))
+	}))
+	defer server.Close()
+
+	dsl := NewHTTPDSLv3()
+	var dump bytes.Buffer
+	dsl.GetEngine().SetWireTraceOutput(&dump)
+	dsl.SetVariable("base", server.URL)
+
+	script := `GET "$base/before"
+trace on
+POST "$base/users?page=1" header "X-Test" "yes" json {"name": "ada"}
+trace off
+GET "$base/after"`
+	if _, err := dsl.ParseWithBlockSupport(script); err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+
+	output := dump.String()
+	for _, line := range []string{
+		"> POST /users?page=1 HTTP/1.1\n",
+		"> Host: " + strings.TrimPrefix(server.URL, "http://") + "\n",
+		"> X-Test: yes\n",
+		"> {\"name\": \"ada\"}\n",
+		"< HTTP/1.1 201 Created\n",
+		"< Content-Type: application/json\n",
+		"<\n< {\"id\": 7}\n",
+	} {
+		if !strings.Contains(output, line) {
+			t.Errorf("Expected %q in trace:\n%s", line, output)
+		}
+	}
+	if strings.Contains(output, "/before") || strings.Contains(output, "/after") {
+		t.Errorf("Expected only the section between trace on and off, got:\n%s", output)
+	}
+
+	if _, err := dsl.Parse("trace loudly"); err == nil {
+		t.Error("Expected trace loudly to fail")
+	}
+}
@@ -3,8 +3,20 @@ module httpdsl
 go 1.24.5
 
 require (
+	github.com/andybalholm/brotli v1.2.5
 	github.com/arturoeanton/go-dsl v0.0.0-20250813042047-7b74eba1f446
+	github.com/jcmturner/gokrb5/v8 v8.4.4
 	golang.org/x/net v0.43.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require gopkg.in/yaml.v3 v3.0.1 // indirect
+require (
+	github.com/hashicorp/go-uuid v1.0.3 // indirect
+	github.com/jcmturner/aescts/v2 v2.0.0 // indirect
+	github.com/jcmturner/dnsutils/v2 v2.0.0 // indirect
+	github.com/jcmturner/gofork v1.7.6 // indirect
+	github.com/jcmturner/goidentity/v6 v6.0.1 // indirect
+	github.com/jcmturner/rpc/v2 v2.0.3 // indirect
+	golang.org/x/crypto v0.41.0 // indirect
+	golang.org/x/text v0.28.0 // indirect
+)